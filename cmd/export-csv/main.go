@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/sebastianmontero/dgraph-go-client/dgraph"
+	"github.com/sebastianmontero/hypha-document-cache-go/doccache"
+	"github.com/sebastianmontero/slog-go/slog"
+)
+
+var log *slog.Log
+
+func main() {
+	log = slog.New(&slog.Config{Pretty: true, Level: zerolog.InfoLevel}, "export-csv")
+	docType := flag.String("type", "", "Type of the documents to export, e.g. payout")
+	edges := flag.String("edges", "", "Comma separated edges whose target hashes are added as columns, e.g. assignee,period")
+	out := flag.String("out", "", "File the CSV is written to, stdout if not set")
+	flag.Parse()
+
+	if *docType == "" {
+		fmt.Println("Specify the document type with -type")
+		flag.Usage()
+		os.Exit(1)
+	}
+	edgeNames := make([]string, 0)
+	if *edges != "" {
+		edgeNames = strings.Split(*edges, ",")
+	}
+
+	dgraphEndpoint := fmt.Sprintf("%v:%v", os.Getenv("DGRAPH_ALPHA_HOST"), os.Getenv("DGRAPH_ALPHA_EXTERNAL_PORT"))
+	dg, err := dgraph.New(dgraphEndpoint)
+	if err != nil {
+		log.Panic(err, "Error creating dgraph client")
+	}
+	defer dg.Close()
+	cache, err := doccache.New(dg, nil)
+	if err != nil {
+		log.Panic(err, "Error creating doccache client")
+	}
+
+	var output io.Writer = os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			log.Panicf(err, "Unable to create csv file: %v", *out)
+		}
+		defer file.Close()
+		output = file
+	}
+	writer := bufio.NewWriter(output)
+	rows, err := cache.ExportCSV(writer, *docType, edgeNames)
+	if err != nil {
+		log.Panic(err, "Failed to export csv")
+	}
+	err = writer.Flush()
+	if err != nil {
+		log.Panic(err, "Failed to write csv")
+	}
+	log.Infof("Exported %v documents of type: %v", rows, *docType)
+}
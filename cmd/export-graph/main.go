@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/sebastianmontero/dgraph-go-client/dgraph"
+	"github.com/sebastianmontero/hypha-document-cache-go/doccache"
+	"github.com/sebastianmontero/slog-go/slog"
+)
+
+var log *slog.Log
+
+func main() {
+	log = slog.New(&slog.Config{Pretty: true, Level: zerolog.InfoLevel}, "export-graph")
+	root := flag.String("root", "", "Hash of the document the subgraph is built around")
+	depth := flag.Int("depth", doccache.DefaultSubgraphDepth, fmt.Sprintf("Number of edges traversed from the root, at most %v", doccache.MaxSubgraphDepth))
+	edges := flag.String("edges", "", "Comma separated edges to traverse, all edges if not set, e.g. assignee,period")
+	undirected := flag.Bool("undirected", false, "Also traverse reverse indexed edges from their target to their source")
+	format := flag.String("format", string(doccache.GraphDOT), "Output format: dot or graphml")
+	out := flag.String("out", "", "File the graph is written to, stdout if not set")
+	flag.Parse()
+
+	if *root == "" {
+		fmt.Println("Specify the root document hash with -root")
+		flag.Usage()
+		os.Exit(1)
+	}
+	graphFormat, err := doccache.ParseGraphFormat(*format)
+	if err != nil {
+		log.Panic(err, "Invalid format")
+	}
+	edgeNames := make([]string, 0)
+	if *edges != "" {
+		edgeNames = strings.Split(*edges, ",")
+	}
+
+	dgraphEndpoint := fmt.Sprintf("%v:%v", os.Getenv("DGRAPH_ALPHA_HOST"), os.Getenv("DGRAPH_ALPHA_EXTERNAL_PORT"))
+	dg, err := dgraph.New(dgraphEndpoint)
+	if err != nil {
+		log.Panic(err, "Error creating dgraph client")
+	}
+	defer dg.Close()
+	cache, err := doccache.New(dg, nil)
+	if err != nil {
+		log.Panic(err, "Error creating doccache client")
+	}
+
+	subgraph, err := cache.Subgraph(&doccache.SubgraphQuery{
+		Root:       *root,
+		Depth:      *depth,
+		Edges:      edgeNames,
+		Undirected: *undirected,
+	})
+	if err != nil {
+		log.Panic(err, "Failed to build subgraph")
+	}
+	if subgraph.Truncated {
+		log.Warnf("Subgraph truncated at %v documents, reduce the depth or filter the edges", doccache.MaxSubgraphNodes)
+	}
+
+	var output io.Writer = os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			log.Panicf(err, "Unable to create graph file: %v", *out)
+		}
+		defer file.Close()
+		output = file
+	}
+	writer := bufio.NewWriter(output)
+	err = doccache.WriteGraph(writer, subgraph, graphFormat)
+	if err != nil {
+		log.Panic(err, "Failed to export graph")
+	}
+	err = writer.Flush()
+	if err != nil {
+		log.Panic(err, "Failed to write graph")
+	}
+	log.Infof("Exported %v documents and %v edges around: %v", len(subgraph.Nodes), len(subgraph.Edges), subgraph.Root)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog"
+	"github.com/sebastianmontero/dgraph-go-client/dgraph"
+	"github.com/sebastianmontero/hypha-document-cache-go/doccache"
+	"github.com/sebastianmontero/slog-go/slog"
+)
+
+var log *slog.Log
+
+func main() {
+	log = slog.New(&slog.Config{Pretty: true, Level: zerolog.InfoLevel}, "integrity")
+	fixAll := flag.Bool("fix-all", false, "Fix every class of problem found")
+	fixOrphans := flag.Bool("fix-orphans", false, "Delete content groups, contents and certificates without a parent document")
+	fixEdges := flag.Bool("fix-edges", false, "Delete edges pointing to nodes that are not documents")
+	fixDuplicates := flag.Bool("fix-duplicates", false, "Merge documents sharing a hash into the oldest one")
+	fixCursors := flag.Bool("fix-cursors", false, "Delete cursor nodes beyond the one in use")
+	fixChecksums := flag.Bool("fix-checksums", false, "Link checksum contents to their target documents")
+	flag.Parse()
+
+	dgraphEndpoint := fmt.Sprintf("%v:%v", os.Getenv("DGRAPH_ALPHA_HOST"), os.Getenv("DGRAPH_ALPHA_EXTERNAL_PORT"))
+	dg, err := dgraph.New(dgraphEndpoint)
+	if err != nil {
+		log.Panic(err, "Error creating dgraph client")
+	}
+	defer dg.Close()
+	cache, err := doccache.New(dg, nil)
+	if err != nil {
+		log.Panic(err, "Error creating doccache client")
+	}
+
+	report, err := cache.CheckIntegrity()
+	if err != nil {
+		log.Panic(err, "Failed to check integrity")
+	}
+	fmt.Print(report)
+	if report.IsClean() {
+		log.Info("No problems found")
+		return
+	}
+
+	config := &doccache.RepairConfig{
+		Orphans:    *fixOrphans,
+		Edges:      *fixEdges,
+		Duplicates: *fixDuplicates,
+		Cursors:    *fixCursors,
+		Checksums:  *fixChecksums,
+	}
+	if *fixAll {
+		config = doccache.RepairAll()
+	}
+	err = cache.Repair(report, config)
+	if err != nil {
+		log.Panic(err, "Failed to repair")
+	}
+}
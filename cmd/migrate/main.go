@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog"
+	"github.com/sebastianmontero/dgraph-go-client/dgraph"
+	"github.com/sebastianmontero/hypha-document-cache-go/doccache"
+	"github.com/sebastianmontero/slog-go/slog"
+)
+
+var log *slog.Log
+
+func main() {
+	log = slog.New(&slog.Config{Pretty: true, Level: zerolog.InfoLevel}, "migrate")
+	dryRun := flag.Bool("dry-run", false, "Only list the pending migrations")
+	flag.Parse()
+
+	dgraphEndpoint := fmt.Sprintf("%v:%v", os.Getenv("DGRAPH_ALPHA_HOST"), os.Getenv("DGRAPH_ALPHA_EXTERNAL_PORT"))
+	dg, err := dgraph.New(dgraphEndpoint)
+	if err != nil {
+		log.Panic(err, "Error creating dgraph client")
+	}
+	defer dg.Close()
+
+	version, pending, err := doccache.PendingMigrations(dg)
+	if err != nil {
+		log.Panic(err, "Failed to get pending migrations")
+	}
+	fmt.Printf("Current schema version: %v, latest: %v\n", version, doccache.LatestSchemaVersion())
+	for _, migration := range pending {
+		fmt.Printf("\t%v: %v\n", migration.Version, migration.Description)
+	}
+	if *dryRun || len(pending) == 0 {
+		return
+	}
+	_, err = doccache.New(dg, nil)
+	if err != nil {
+		log.Panic(err, "Failed to apply migrations")
+	}
+	log.Infof("Applied %v migrations", len(pending))
+}
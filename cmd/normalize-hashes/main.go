@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog"
+	"github.com/sebastianmontero/dgraph-go-client/dgraph"
+	"github.com/sebastianmontero/hypha-document-cache-go/doccache"
+	"github.com/sebastianmontero/slog-go/slog"
+)
+
+var log *slog.Log
+
+func main() {
+	log = slog.New(&slog.Config{Pretty: true, Level: zerolog.InfoLevel}, "normalize-hashes")
+	dryRun := flag.Bool("dry-run", false, "Only count the hashes that are not in canonical form, without applying pending migrations")
+	flag.Parse()
+
+	dgraphEndpoint := fmt.Sprintf("%v:%v", os.Getenv("DGRAPH_ALPHA_HOST"), os.Getenv("DGRAPH_ALPHA_EXTERNAL_PORT"))
+	dg, err := dgraph.New(dgraphEndpoint)
+	if err != nil {
+		log.Panic(err, "Error creating dgraph client")
+	}
+	defer dg.Close()
+
+	var result *doccache.HashNormalization
+	if *dryRun {
+		result, err = doccache.CountUnnormalizedHashes(dg, nil)
+	} else {
+		var cache *doccache.Doccache
+		cache, err = doccache.New(dg, nil)
+		if err != nil {
+			log.Panic(err, "Error creating doccache client")
+		}
+		result, err = cache.NormalizeStoredHashes(false)
+	}
+	if err != nil {
+		log.Panic(err, "Failed to normalize hashes")
+	}
+	log.Infof("Hashes not in canonical form, documents: %v, checksum contents: %v, merged documents: %v, dry run: %v", result.Documents, result.Contents, result.Merged, *dryRun)
+}
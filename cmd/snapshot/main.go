@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/rs/zerolog"
+	"github.com/sebastianmontero/dgraph-go-client/dgraph"
+	"github.com/sebastianmontero/hypha-document-cache-go/doccache"
+	"github.com/sebastianmontero/slog-go/slog"
+)
+
+var log *slog.Log
+
+//config uses the schema settings of the streamer, so that an imported cache is built as a streamed one would be
+func config() *doccache.Config {
+	var err error
+	kindTypes := false
+	if os.Getenv("DOCUMENT_KIND_TYPES") != "" {
+		kindTypes, err = strconv.ParseBool(os.Getenv("DOCUMENT_KIND_TYPES"))
+		if err != nil {
+			log.Panicf(err, "Unable to parse document kind types: %v", os.Getenv("DOCUMENT_KIND_TYPES"))
+		}
+	}
+	edgeOptions := doccache.DefaultEdgeOptions()
+	if os.Getenv("EDGE_REVERSE") != "" {
+		edgeOptions.Reverse, err = strconv.ParseBool(os.Getenv("EDGE_REVERSE"))
+		if err != nil {
+			log.Panicf(err, "Unable to parse edge reverse: %v", os.Getenv("EDGE_REVERSE"))
+		}
+	}
+	if os.Getenv("EDGE_COUNT") != "" {
+		edgeOptions.Count, err = strconv.ParseBool(os.Getenv("EDGE_COUNT"))
+		if err != nil {
+			log.Panicf(err, "Unable to parse edge count: %v", os.Getenv("EDGE_COUNT"))
+		}
+	}
+	contentIndexes, err := doccache.ParseContentIndexes(os.Getenv("CONTENT_INDEXES"))
+	if err != nil {
+		log.Panicf(err, "Unable to parse content indexes: %v", os.Getenv("CONTENT_INDEXES"))
+	}
+	return &doccache.Config{KindTypes: kindTypes, EdgeOptions: edgeOptions, ContentIndexes: contentIndexes}
+}
+
+func main() {
+	log = slog.New(&slog.Config{Pretty: true, Level: zerolog.InfoLevel}, "snapshot")
+	exportFile := flag.String("export", "", "Write a snapshot of the cache to this file")
+	format := flag.String("format", string(doccache.SnapshotJSON), "Format of the exported snapshot, json or rdf")
+	importFile := flag.String("import", "", "Load the snapshot in this file into an empty cache, streaming resumes at its cursor")
+	flag.Parse()
+
+	if (*exportFile == "") == (*importFile == "") {
+		fmt.Println("Specify either -export or -import")
+		flag.Usage()
+		os.Exit(1)
+	}
+	snapshotFormat, err := doccache.ParseSnapshotFormat(*format)
+	if err != nil {
+		log.Panic(err, "Invalid format")
+	}
+
+	dgraphEndpoint := fmt.Sprintf("%v:%v", os.Getenv("DGRAPH_ALPHA_HOST"), os.Getenv("DGRAPH_ALPHA_EXTERNAL_PORT"))
+	dg, err := dgraph.New(dgraphEndpoint)
+	if err != nil {
+		log.Panic(err, "Error creating dgraph client")
+	}
+	defer dg.Close()
+	cache, err := doccache.NewWithConfig(dg, config(), nil)
+	if err != nil {
+		log.Panic(err, "Error creating doccache client")
+	}
+
+	var stats *doccache.SnapshotStats
+	if *exportFile != "" {
+		file, err := os.Create(*exportFile)
+		if err != nil {
+			log.Panicf(err, "Unable to create snapshot file: %v", *exportFile)
+		}
+		writer := bufio.NewWriter(file)
+		stats, err = cache.ExportSnapshot(writer, snapshotFormat)
+		if err != nil {
+			log.Panic(err, "Failed to export snapshot")
+		}
+		err = writer.Flush()
+		if err == nil {
+			err = file.Close()
+		}
+		if err != nil {
+			log.Panicf(err, "Unable to write snapshot file: %v", *exportFile)
+		}
+	} else {
+		file, err := os.Open(*importFile)
+		if err != nil {
+			log.Panicf(err, "Unable to open snapshot file: %v", *importFile)
+		}
+		defer file.Close()
+		stats, err = cache.ImportSnapshot(file)
+		if err != nil {
+			log.Panic(err, "Failed to import snapshot")
+		}
+	}
+	fmt.Printf("Format: %v, cursor: %v, documents: %v, edges: %v, skipped edges: %v\n", stats.Format, stats.Cursor, stats.Documents, stats.Edges, stats.SkippedEdges)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/sebastianmontero/dgraph-go-client/dgraph"
+	"github.com/sebastianmontero/hypha-document-cache-go/doccache"
+	"github.com/sebastianmontero/slog-go/slog"
+)
+
+var log *slog.Log
+
+func parseList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+//readSecret reads the webhook secret from the first line of stdin if secretStdin is set, otherwise from the WEBHOOK_SECRET env var,
+//so that it does not show up in the process list or the shell history
+func readSecret(secretStdin bool) (string, error) {
+	if !secretStdin {
+		return os.Getenv("WEBHOOK_SECRET"), nil
+	}
+	secret, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && secret == "" {
+		return "", fmt.Errorf("failed to read secret from stdin, error: %v", err)
+	}
+	return strings.TrimRight(secret, "\r\n"), nil
+}
+
+func main() {
+	log = slog.New(&slog.Config{Pretty: true, Level: zerolog.InfoLevel}, "webhooks")
+	add := flag.String("add", "", "Register or update the webhook with this name")
+	url := flag.String("url", "", "Endpoint the events are posted to, required by -add")
+	secretStdin := flag.Bool("secret-stdin", false, "Read the secret used to sign the deliveries of the webhook from stdin instead of the WEBHOOK_SECRET env var")
+	types := flag.String("types", "", "Comma separated event types to deliver, e.g. document_created,edge_created")
+	docTypes := flag.String("doc-types", "", "Comma separated document types to deliver, e.g. payout")
+	creators := flag.String("creators", "", "Comma separated document creators to deliver")
+	hashes := flag.String("hashes", "", "Comma separated document hashes to deliver")
+	edges := flag.String("edges", "", "Comma separated edge names to deliver, e.g. vote")
+	remove := flag.String("remove", "", "Remove the webhook with this name and its deliveries")
+	retry := flag.String("retry", "", "Queue the failed deliveries of the webhook with this name again")
+	flag.Parse()
+
+	dgraphEndpoint := fmt.Sprintf("%v:%v", os.Getenv("DGRAPH_ALPHA_HOST"), os.Getenv("DGRAPH_ALPHA_EXTERNAL_PORT"))
+	dg, err := dgraph.New(dgraphEndpoint)
+	if err != nil {
+		log.Panic(err, "Error creating dgraph client")
+	}
+	defer dg.Close()
+	cache, err := doccache.New(dg, nil)
+	if err != nil {
+		log.Panic(err, "Error creating doccache client")
+	}
+
+	switch {
+	case *add != "":
+		filter := &doccache.EventFilter{
+			DocTypes: parseList(*docTypes),
+			Creators: parseList(*creators),
+			Hashes:   parseList(*hashes),
+			Edges:    parseList(*edges),
+		}
+		for _, eventType := range parseList(*types) {
+			filter.Types = append(filter.Types, doccache.EventType(eventType))
+		}
+		secret, err := readSecret(*secretStdin)
+		if err != nil {
+			log.Panic(err, "Failed to read webhook secret")
+		}
+		webhook, err := cache.SaveWebhook(*add, *url, secret, filter)
+		if err != nil {
+			log.Panicf(err, "Failed to save webhook: %v", *add)
+		}
+		log.Infof("Saved webhook: %v", webhook)
+	case *remove != "":
+		err = cache.RemoveWebhook(*remove)
+		if err != nil {
+			log.Panicf(err, "Failed to remove webhook: %v", *remove)
+		}
+		log.Infof("Removed webhook: %v", *remove)
+	case *retry != "":
+		queued, err := cache.RetryFailedDeliveries(*retry)
+		if err != nil {
+			log.Panicf(err, "Failed to retry deliveries of webhook: %v", *retry)
+		}
+		log.Infof("Queued %v failed deliveries of webhook: %v", queued, *retry)
+	}
+
+	statuses, err := cache.GetWebhookStatuses()
+	if err != nil {
+		log.Panic(err, "Failed to get webhooks")
+	}
+	for _, status := range statuses {
+		fmt.Printf("%v: %v filter: %v pending: %v delivered: %v failed: %v\n", status.Name, status.URL, status.Filter, status.Pending, status.Delivered, status.Failed)
+	}
+}
@@ -0,0 +1,167 @@
+package doccache
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//CSVValueSeparator separates the values of a cell with several values, e.g. a label repeated in
+//a content group or an edge with several targets
+const CSVValueSeparator = "|"
+
+//csvFixedColumns columns that precede the content columns
+var csvFixedColumns = []string{"hash", "creator", "created_date"}
+
+//CSVEdgeColumn returns the column that holds the target hashes of the edge
+func CSVEdgeColumn(edgeName string) string {
+	return "edge." + edgeName
+}
+
+//CSVContentColumn returns the column of a content, content groups without label are named by their sequence
+func CSVContentColumn(contentGroup *ContentGroup, label string) string {
+	groupLabel := contentGroup.Label()
+	if groupLabel == "" {
+		groupLabel = fmt.Sprintf("content_group_%v", contentGroup.ContentGroupSequence)
+	}
+	return groupLabel + "." + label
+}
+
+//csvTable collects the rows before writing them, as the content columns are only known once all documents are read
+type csvTable struct {
+	edgeNames      []string
+	contentColumns map[string]bool
+	rows           []map[string]string
+}
+
+func newCSVTable(edgeNames []string) *csvTable {
+	return &csvTable{
+		edgeNames:      edgeNames,
+		contentColumns: make(map[string]bool),
+		rows:           make([]map[string]string, 0),
+	}
+}
+
+func (m *csvTable) add(doc *Document) {
+	row := map[string]string{
+		"hash":    doc.Hash,
+		"creator": doc.Creator,
+	}
+	if doc.CreatedDate != nil {
+		row["created_date"] = doc.CreatedDate.UTC().Format(chainTimeLayout)
+	}
+	for _, contentGroup := range doc.ContentGroups {
+		for _, content := range contentGroup.Contents {
+			if content.Label == "content_group_label" {
+				continue
+			}
+			column := CSVContentColumn(contentGroup, content.Label)
+			m.contentColumns[column] = true
+			if value, ok := row[column]; ok {
+				row[column] = value + CSVValueSeparator + content.Value
+			} else {
+				row[column] = content.Value
+			}
+		}
+	}
+	for _, edgeName := range m.edgeNames {
+		targets := make([]string, 0, len(doc.Edges[edgeName]))
+		for _, target := range doc.Edges[edgeName] {
+			targets = append(targets, target.Hash)
+		}
+		sort.Strings(targets)
+		row[CSVEdgeColumn(edgeName)] = strings.Join(targets, CSVValueSeparator)
+	}
+	m.rows = append(m.rows, row)
+}
+
+//columns returns the fixed columns, followed by the content columns sorted by name and the edge columns
+func (m *csvTable) columns() []string {
+	contentColumns := make([]string, 0, len(m.contentColumns))
+	for column := range m.contentColumns {
+		contentColumns = append(contentColumns, column)
+	}
+	sort.Strings(contentColumns)
+	columns := append([]string{}, csvFixedColumns...)
+	columns = append(columns, contentColumns...)
+	for _, edgeName := range m.edgeNames {
+		columns = append(columns, CSVEdgeColumn(edgeName))
+	}
+	return columns
+}
+
+//csvCell prefixes the values a spreadsheet would evaluate as a formula with a quote, so that chain data can not inject formulas,
+//signed numbers and assets such as -5 or -1.00 USD are kept as they are
+func csvCell(value string) string {
+	if value != "" && strings.ContainsAny(value[:1], "=+-@\t\r") && !isCSVNumber(value) {
+		return "'" + value
+	}
+	return value
+}
+
+//isCSVNumber indicates if the value is a number optionally followed by an asset symbol, e.g. -1.00 USD
+func isCSVNumber(value string) bool {
+	parts := strings.SplitN(value, " ", 2)
+	if _, err := strconv.ParseFloat(parts[0], 64); err != nil {
+		return false
+	}
+	return len(parts) == 1 || isAssetSymbol(parts[1])
+}
+
+//isAssetSymbol indicates if the value is a valid asset symbol, up to 7 upper case letters
+func isAssetSymbol(value string) bool {
+	if len(value) == 0 || len(value) > 7 {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		if value[i] < 'A' || value[i] > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *csvTable) write(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	columns := m.columns()
+	record := make([]string, len(columns))
+	for i, column := range columns {
+		record[i] = csvCell(column)
+	}
+	err := writer.Write(record)
+	if err != nil {
+		return err
+	}
+	for _, row := range m.rows {
+		for i, column := range columns {
+			record[i] = csvCell(row[column])
+		}
+		err = writer.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+//ExportCSV writes a row per document of the type, with a column per content named <content_group_label>.<label>
+//and a column per edge with the hashes of its targets, returns the number of rows written
+func (m *Doccache) ExportCSV(w io.Writer, docType string, edgeNames []string) (int, error) {
+	table := newCSVTable(edgeNames)
+	err := m.ScanDocumentsOfType(docType, edgeNames, func(doc *Document) error {
+		table.add(doc)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	err = table.write(w)
+	if err != nil {
+		return 0, err
+	}
+	return len(table.rows), nil
+}
@@ -0,0 +1,129 @@
+package doccache
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCSVTable(t *testing.T) {
+	table := newCSVTable([]string{"assignee"})
+	doc := NewDocument(&ChainDocument{
+		Hash:        "aa01",
+		CreatedDate: "2021-08-01T10:00:00.000",
+		Creator:     "dao.hypha",
+		ContentGroups: [][]*ChainContent{
+			{
+				{Label: "content_group_label", Value: []interface{}{"string", "details"}},
+				{Label: "amount", Value: []interface{}{"asset", "10.00 HUSD"}},
+				{Label: "member", Value: []interface{}{"name", "alice"}},
+				{Label: "member", Value: []interface{}{"name", "bob"}},
+			},
+			{
+				{Label: "note", Value: []interface{}{"string", "first, \"quoted\""}},
+			},
+		},
+	})
+	doc.Edges = map[string][]*Document{"assignee": {{Hash: "bb02"}, {Hash: "bb01"}}}
+	table.add(doc)
+	table.add(NewDocument(&ChainDocument{
+		Hash:        "aa02",
+		CreatedDate: "2021-08-02T10:00:00.000",
+		Creator:     "dao.hypha",
+		ContentGroups: [][]*ChainContent{
+			{
+				{Label: "content_group_label", Value: []interface{}{"string", "details"}},
+				{Label: "title", Value: []interface{}{"string", "=HYPERLINK(\"http://evil\")"}},
+			},
+		},
+	}))
+	var buffer bytes.Buffer
+	err := table.write(&buffer)
+	if err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	records, err := csv.NewReader(strings.NewReader(buffer.String())).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read csv: %v", err)
+	}
+	expected := [][]string{
+		{"hash", "creator", "created_date", "content_group_2.note", "details.amount", "details.member", "details.title", "edge.assignee"},
+		{"aa01", "dao.hypha", "2021-08-01T10:00:00.000", "first, \"quoted\"", "10.00 HUSD", "alice|bob", "", "bb01|bb02"},
+		{"aa02", "dao.hypha", "2021-08-02T10:00:00.000", "", "", "", "'=HYPERLINK(\"http://evil\")", ""},
+	}
+	if !reflect.DeepEqual(expected, records) {
+		t.Fatalf("Expected: %v, found: %v", expected, records)
+	}
+}
+
+func TestCSVCell(t *testing.T) {
+	tests := map[string]string{
+		"":                     "",
+		"alice":                "alice",
+		"10.00 HUSD":           "10.00 HUSD",
+		"=1+1":                 "'=1+1",
+		"+1":                   "+1",
+		"-1":                   "-1",
+		"-5":                   "-5",
+		"-1.00 USD":            "-1.00 USD",
+		"+2.5 HVOICE":          "+2.5 HVOICE",
+		"-1+2":                 "'-1+2",
+		"-1 =cmd":              "'-1 =cmd",
+		"-1.00 usd":            "'-1.00 usd",
+		"-1.00 USD+cmd":        "'-1.00 USD+cmd",
+		"-SUM(A1:A2)":          "'-SUM(A1:A2)",
+		"+HYPERLINK(\"http\")": "'+HYPERLINK(\"http\")",
+		"@SUM(A1:A2)":          "'@SUM(A1:A2)",
+		"\tcmd":                "'\tcmd",
+		"\rcmd":                "'\rcmd",
+	}
+	for value, expected := range tests {
+		if cell := csvCell(value); cell != expected {
+			t.Fatalf("Expected cell of: %q to be: %q, found: %q", value, expected, cell)
+		}
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	hashes := []string{
+		"e1f0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c40",
+		"e2f0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c41",
+	}
+	for i, docType := range []string{"csvpayout", "csvmember"} {
+		err := doccache.StoreDocument(&ChainDocument{
+			Hash:        hashes[i],
+			CreatedDate: "2021-08-01T10:00:00.000",
+			Creator:     "csvexporter",
+			ContentGroups: [][]*ChainContent{
+				{
+					{Label: "content_group_label", Value: []interface{}{"string", "system"}},
+					{Label: "type", Value: []interface{}{"name", docType}},
+				},
+			},
+		}, "csv0")
+		if err != nil {
+			t.Fatalf("StoreDocument failed: %v", err)
+		}
+	}
+	err := doccache.MutateEdge(&ChainEdge{Name: "csvrecipient", From: hashes[0], To: hashes[1]}, false, "csv1")
+	if err != nil {
+		t.Fatalf("MutateEdge failed: %v", err)
+	}
+	var buffer bytes.Buffer
+	rows, err := doccache.ExportCSV(&buffer, "csvpayout", []string{"csvrecipient"})
+	if err != nil {
+		t.Fatalf("ExportCSV failed: %v", err)
+	}
+	expected := "hash,creator,created_date,system.type,edge.csvrecipient\n" +
+		hashes[0] + ",csvexporter,2021-08-01T10:00:00.000,csvpayout," + hashes[1] + "\n"
+	if rows != 1 || buffer.String() != expected {
+		t.Fatalf("Expected 1 row: %v, found: %v rows: %v", expected, rows, buffer.String())
+	}
+
+	_, err = doccache.ExportCSV(&buffer, "csvpayout", []string{"missing"})
+	if _, ok := err.(*InvalidRequestError); !ok {
+		t.Fatalf("Expected InvalidRequestError for missing edge, found: %v", err)
+	}
+}
@@ -2,68 +2,16 @@ package doccache
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/dgraph-io/dgo/v2/protos/api"
 	"github.com/sebastianmontero/dgraph-go-client/dgraph"
 	"github.com/sebastianmontero/slog-go/slog"
 )
 
-const schema = `
-      type Document {
-          hash
-          created_date
-          creator
-          content_groups
-          certificates
-      }
-      
-      type ContentGroup {
-        content_group_sequence
-        contents
-      }
-      
-      type Content {
-        label
-        value
-        type
-        content_sequence
-        document
-      }
-      
-      type Certificate {
-        certifier
-        notes
-        certification_date
-        certification_sequence
-      }
-
-			type Cursor {
-				cursor
-			}
-      
-      hash: string @index(exact) .
-      created_date: datetime .
-      creator: string @index(term) .
-      content_groups: [uid] .
-      certificates: [uid] .
-      
-      content_group_sequence: int .
-      contents: [uid] .
-      
-      label: string @index(term) .
-      value: string @index(term) .
-      type: string @index(term) .
-      content_sequence: int .
-      document: [uid] .
-      
-      certifier: string @index(term) .
-      notes: string .
-      certification_date: datetime .
-      certification_sequence: int .
-
-			cursor: string @index(term) .
-    `
 const contentGroupsRequest = `
       content_groups (orderasc:content_group_sequence){
 				content_group_sequence
@@ -73,6 +21,12 @@ const contentGroupsRequest = `
           label
           value
 					type
+					int_value
+					time_value
+					asset_amount
+					asset_symbol
+					asset_precision
+					name_value
 					dgraph.type
           document{
             expand(_all_)
@@ -93,28 +47,83 @@ var log *slog.Log
 
 //RequestConfig enables query configuration
 type RequestConfig struct {
-	ContentGroups bool
-	Certificates  bool
-	Edges         []string
+	ContentGroups bool     `json:"content_groups,omitempty"`
+	Certificates  bool     `json:"certificates,omitempty"`
+	Edges         []string `json:"edges,omitempty"`
+	//InboundEdges edges to traverse from the documents that point to the requested one, requires reverse indexing
+	InboundEdges []string `json:"inbound_edges,omitempty"`
+	//Traversals edges to traverse with their own configuration, enables multi-hop traversal
+	Traversals []*EdgeRequest `json:"traversals,omitempty"`
+}
+
+func (m *RequestConfig) String() string {
+	return fmt.Sprintf("RequestConfig{ContentGroups: %v, Certificates: %v, Edges: %v, InboundEdges: %v, Traversals: %v}", m.ContentGroups, m.Certificates, m.Edges, m.InboundEdges, m.Traversals)
+}
+
+//Config enables doccache configuration
+type Config struct {
+	//KindTypes assigns a Dgraph type per document kind, e.g. Role, based on the system type field
+	KindTypes bool
+	//EdgeOptions schema options for edge predicates, if not set DefaultEdgeOptions is used for new edges
+	//and the registered edges keep their stored options
+	EdgeOptions *EdgeOptions
+	//EdgeOverrides schema options for specific edges by chain name
+	EdgeOverrides map[string]*EdgeOptions
+	//GraphQLAdminURL Dgraph alpha http url, e.g. http://alpha:8080, if set the GraphQL schema
+	//is generated and uploaded to Dgraph as edges are added
+	GraphQLAdminURL string
+	//ContentIndexes indexes of the label and value content predicates, if not set the current indexes are kept
+	ContentIndexes map[string][]ContentIndex
+	//EventBufferSize number of recent events kept to resume subscriptions, DefaultEventBufferSize is used if not set
+	EventBufferSize int
+	//EventSinks receive the changes before they are committed
+	EventSinks []EventSink
 }
 
 //Doccache Service class to store and retrieve docs
 type Doccache struct {
 	dgraph           *dgraph.Dgraph
+	config           *Config
 	documentFieldMap map[string]*dgraph.SchemaField
+	edges            map[string]*EdgeDefinition
+	edgesLock        sync.RWMutex
+	graphQLSchema    string
+	graphQLLock      sync.Mutex
+	kindTypes        map[string]bool
+	contentIndexes   map[string][]ContentIndex
+	webhooks         []*Webhook
+	webhooksLoaded   time.Time
+	webhooksLock     sync.Mutex
+	trxID            string
 	Cursor           *Cursor
+	//Events publishes the changes committed by StoreDocument, DeleteDocument and MutateEdge
+	Events *EventBroker
 }
 
-//New creates a new doccache
+//New creates a new doccache with the default configuration
 func New(dg *dgraph.Dgraph, logConfig *slog.Config) (*Doccache, error) {
+	return NewWithConfig(dg, &Config{}, logConfig)
+}
+
+//NewWithConfig creates a new doccache
+func NewWithConfig(dg *dgraph.Dgraph, config *Config, logConfig *slog.Config) (*Doccache, error) {
 	log = slog.New(logConfig, "doccache")
 
+	contentIndexes, err := normalizeContentIndexes(config.ContentIndexes)
+	if err != nil {
+		return nil, err
+	}
 	m := &Doccache{
 		dgraph:           dg,
+		config:           config,
 		documentFieldMap: make(map[string]*dgraph.SchemaField),
+		edges:            make(map[string]*EdgeDefinition),
+		kindTypes:        make(map[string]bool),
+		contentIndexes:   contentIndexes,
+		Events:           NewEventBroker(config.EventBufferSize),
 	}
 
-	err := m.PrepareSchema()
+	err = m.PrepareSchema()
 	if err != nil {
 		return nil, err
 	}
@@ -135,17 +144,39 @@ func (m *Doccache) SchemaExists() (bool, error) {
 	return len(missing) == 0, nil
 }
 
-//PrepareSchema prepares the base schema
+//PrepareSchema applies the pending schema migrations
 func (m *Doccache) PrepareSchema() error {
-	exists, err := m.SchemaExists()
+	err := m.loadDocumentFieldMap()
 	if err != nil {
 		return err
 	}
-	if !exists {
-		err = m.dgraph.UpdateSchema(schema)
-		if err != nil {
-			return err
-		}
+	err = m.migrate()
+	if err != nil {
+		return err
+	}
+	err = m.loadEdges()
+	if err != nil {
+		return err
+	}
+	err = m.syncEdgeOptions()
+	if err != nil {
+		return err
+	}
+	err = m.syncContentIndexes()
+	if err != nil {
+		return err
+	}
+	return m.SyncGraphQLSchema()
+}
+
+func (m *Doccache) loadDocumentFieldMap() error {
+	missing, err := m.dgraph.MissingTypes([]string{"Document"})
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		m.documentFieldMap = make(map[string]*dgraph.SchemaField)
+		return nil
 	}
 	m.documentFieldMap, err = m.dgraph.GetTypeFieldMap("Document")
 	return err
@@ -158,6 +189,8 @@ func (m *Doccache) getCursor() (*Cursor, error) {
 			cursors(func: type(Cursor)){
 				uid
 				cursor
+				block_num
+				history_start
 				dgraph.type
 			}
 		}
@@ -196,17 +229,26 @@ func (m *Doccache) cursorMutation(cursor *Cursor) (*api.Mutation, error) {
 	return m.dgraph.JSONMutation(cursor, false)
 }
 
+//StoredCursor finds the last cursor persisted with a mutation
+func (m *Doccache) StoredCursor() (*Cursor, error) {
+	return m.getCursor()
+}
+
 //GetByHash Finds document by hash
 func (m *Doccache) GetByHash(hash string, rc *RequestConfig) (*Document, error) {
+	request, err := configureRequest(rc)
+	if err != nil {
+		return nil, err
+	}
 	query := fmt.Sprintf(`
 		query docs($hash: string){
 			docs(func: eq(hash, $hash))
 				%v
 		}
-	`, configureRequest(rc))
+	`, request)
 
 	docs := &Docs{}
-	err := m.dgraph.Query(query, map[string]string{"$hash": hash}, docs)
+	err = m.dgraph.Query(query, map[string]string{"$hash": NormalizeHash(hash)}, docs)
 	if err != nil {
 		return nil, err
 	}
@@ -218,33 +260,71 @@ func (m *Doccache) GetByHash(hash string, rc *RequestConfig) (*Document, error)
 
 //GetByHashAsMap Finds document by hash returns a map
 func (m *Doccache) GetByHashAsMap(hash string, rc *RequestConfig) (map[string]interface{}, error) {
+	request, err := configureRequest(rc)
+	if err != nil {
+		return nil, err
+	}
 	query := fmt.Sprintf(`
 		query docs($hash: string){
 			docs(func: eq(hash, $hash))
 				%v
 		}
-	`, configureRequest(rc))
+	`, request)
 
 	documents := make(map[string]interface{})
-	err := m.dgraph.Query(query, map[string]string{"$hash": hash}, &documents)
+	err = m.dgraph.Query(query, map[string]string{"$hash": NormalizeHash(hash)}, &documents)
 	if err != nil {
 		return nil, err
 	}
 	if docsi, ok := documents["docs"]; ok {
 		docs := docsi.([]interface{})
 		if len(docs) > 0 {
-			return docs[0].(map[string]interface{}), nil
+			doc := docs[0].(map[string]interface{})
+			decodeDocumentMap(doc)
+			return doc, nil
 		}
 		return nil, nil
 	}
 	return nil, nil
 }
 
-//GetHashUIDMap finds docs by hashes and returns a map hash->uid
+//GetByHashes finds documents by hashes, documents that are not found are omitted
+func (m *Doccache) GetByHashes(hashes []string, rc *RequestConfig) ([]*Document, error) {
+	if len(hashes) == 0 {
+		return make([]*Document, 0), nil
+	}
+	request, err := configureRequest(rc)
+	if err != nil {
+		return nil, err
+	}
+	quoted := make([]string, 0, len(hashes))
+	for _, hash := range NormalizeHashes(hashes) {
+		quoted = append(quoted, strconv.Quote(hash))
+	}
+	query := fmt.Sprintf(`
+		{
+			docs(func: eq(hash, [%v]))
+				%v
+		}
+	`, strings.Join(quoted, ","), request)
+
+	docs := &Docs{}
+	err = m.dgraph.Query(query, nil, docs)
+	if err != nil {
+		return nil, err
+	}
+	return docs.Docs, nil
+}
+
+//GetHashUIDMap finds docs by hashes and returns a map hash->uid, keyed by the hashes as requested
 func (m *Doccache) GetHashUIDMap(hashes []string) (map[string]string, error) {
 	if len(hashes) == 0 {
 		return make(map[string]string), nil
 	}
+	quoted := make([]string, 0, len(hashes))
+	for _, hash := range NormalizeHashes(hashes) {
+		quoted = append(quoted, strconv.Quote(hash))
+	}
 	query := fmt.Sprintf(`
 		{
 			docs(func: eq(hash, [%v])){
@@ -252,17 +332,22 @@ func (m *Doccache) GetHashUIDMap(hashes []string) (map[string]string, error) {
 				hash
 			}
 		}
-	`, strings.Join(hashes, ","))
+	`, strings.Join(quoted, ","))
 
 	docs := &Docs{}
 	err := m.dgraph.Query(query, nil, docs)
 	if err != nil {
 		return nil, err
 	}
-	var hashUIDMap = make(map[string]string, len(hashes))
-
+	var canonicalUIDMap = make(map[string]string, len(docs.Docs))
 	for _, doc := range docs.Docs {
-		hashUIDMap[doc.Hash] = doc.UID
+		canonicalUIDMap[doc.Hash] = doc.UID
+	}
+	var hashUIDMap = make(map[string]string, len(hashes))
+	for _, hash := range hashes {
+		if uid, ok := canonicalUIDMap[NormalizeHash(hash)]; ok {
+			hashUIDMap[hash] = uid
+		}
 	}
 	return hashUIDMap, nil
 }
@@ -279,14 +364,46 @@ func (m *Doccache) GetUID(hash string) (string, error) {
 	return "", nil
 }
 
-func (m *Doccache) mutate(mutation *api.Mutation, cursor string) error {
+//mutate applies the mutation together with the cursor update, the version changes and the webhook deliveries of the event,
+//the event is written to the sinks before and published after the mutation is committed
+func (m *Doccache) mutate(mutation *api.Mutation, cursor string, event *Event, versions ...*api.Mutation) error {
+	if event != nil {
+		event.ID = eventID(m.Cursor.BlockNum, m.trxID, event)
+		for _, sink := range m.config.EventSinks {
+			err := sink.Write(event)
+			if err != nil {
+				return &EventSinkError{Sink: sink.Name(), Err: err}
+			}
+		}
+	}
 	m.Cursor.Cursor = cursor
 	cursorMutation, err := m.cursorMutation(m.Cursor)
 	if err != nil {
 		return err
 	}
-	_, err = m.dgraph.Mutate(mutation, cursorMutation)
-	return err
+	mutations := []*api.Mutation{mutation, cursorMutation}
+	for _, version := range versions {
+		if version != nil {
+			mutations = append(mutations, version)
+		}
+	}
+	if event != nil {
+		deliveriesMutation, err := m.deliveriesMutation(event)
+		if err != nil {
+			return err
+		}
+		if deliveriesMutation != nil {
+			mutations = append(mutations, deliveriesMutation)
+		}
+	}
+	_, err = m.dgraph.Mutate(mutations...)
+	if err != nil {
+		return err
+	}
+	if event != nil {
+		m.Events.Publish(event)
+	}
+	return nil
 }
 
 func (m *Doccache) UpdateCursor(cursor string) error {
@@ -299,48 +416,83 @@ func (m *Doccache) UpdateCursor(cursor string) error {
 	return err
 }
 
+//QuarantineRow stores a row that failed validation so that it can be inspected later
+func (m *Doccache) QuarantineRow(row *InvalidRow, cursor string) error {
+	row.RowCursor = cursor
+	row.DType = []string{"InvalidRow"}
+	log.Warnf("Quarantining row of table: %v, errors: %v", row.TableName, row.ValidationErrors)
+	mutation, err := m.dgraph.JSONMutation(row, false)
+	if err != nil {
+		return err
+	}
+	return m.mutate(mutation, cursor, nil)
+}
+
 //StoreDocument Creates a new document or updates its certificates
 func (m *Doccache) StoreDocument(chainDoc *ChainDocument, cursor string) error {
+	chainDoc.Normalize()
 	doc, err := m.GetByHash(chainDoc.Hash, &RequestConfig{Certificates: true})
 	if err != nil {
 		return err
 	}
+	eventType := EventDocumentUpdated
+	changed := true
 	if doc == nil {
 		log.Infof("Creating document: %v", chainDoc.Hash)
 		doc, err = m.transformNew(chainDoc)
 		if err != nil {
 			return err
 		}
+		eventType = EventDocumentCreated
 	} else {
 		log.Infof("Updating certificates for document: <%v>%v", doc.UID, doc.Hash)
+		certificates := len(doc.Certificates)
 		doc.UpdateCertificates(chainDoc.Certificates)
+		changed = len(doc.Certificates) != certificates
 	}
 
 	mutation, err := m.dgraph.JSONMutation(doc, false)
 	if err != nil {
 		return err
 	}
-	return m.mutate(mutation, cursor)
+	var versionMutation *api.Mutation
+	if changed {
+		versionMutation, err = m.documentVersionMutation(chainDoc.Hash, chainDoc, doc.DocType)
+		if err != nil {
+			return err
+		}
+	}
+	var event *Event
+	if changed {
+		event = newDocumentEvent(eventType, doc, cursor)
+	}
+	return m.mutate(mutation, cursor, event, versionMutation)
 }
 
 //DeleteDocument Deletes a document
 func (m *Doccache) DeleteDocument(chainDoc *ChainDocument, cursor string) error {
-	uid, err := m.GetUID(chainDoc.Hash)
+	chainDoc.Normalize()
+	doc, err := m.GetByHash(chainDoc.Hash, &RequestConfig{Certificates: true})
 	if err != nil {
 		return err
 	}
-	if uid != "" {
-		log.Infof("Deleting Node: <%v>%v", uid, chainDoc.Hash)
-		mutation := m.dgraph.DeleteNodeMutation(uid)
-		return m.mutate(mutation, cursor)
+	if doc != nil {
+		log.Infof("Deleting Node: <%v>%v", doc.UID, chainDoc.Hash)
+		mutation := m.dgraph.DeleteNodeMutation(doc.UID)
+		versionMutation, err := m.documentVersionMutation(chainDoc.Hash, nil, "")
+		if err != nil {
+			return err
+		}
+		return m.mutate(mutation, cursor, newDocumentEvent(EventDocumentDeleted, doc, cursor), versionMutation)
 	}
 	log.Infof("Document: %v not found, couldn't delete", chainDoc.Hash)
-	return nil
+	return m.UpdateCursor(cursor)
 }
 
 //MutateEdge Creates/Deletes an edge
 func (m *Doccache) MutateEdge(chainEdge *ChainEdge, deleteOp bool, cursor string) error {
-	err := m.updateDocumentTypeSchema(chainEdge.Name)
+	chainEdge.Normalize()
+	edge, err := m.registerEdge(chainEdge.Name)
 	if err != nil {
 		return err
 	}
@@ -348,44 +500,77 @@ func (m *Doccache) MutateEdge(chainEdge *ChainEdge, deleteOp bool, cursor string
 	if err != nil {
 		return err
 	}
+	//no event has been written yet, so the edge can be skipped when one of its nodes does not exist
 	fromUID, ok := hashUIDMap[chainEdge.From]
 	if !ok {
-		return fmt.Errorf("From node of the relationship: [Edge: %v, From: %v, To: %v] does not exist, Delete Op: %v", chainEdge.Name, chainEdge.From, chainEdge.To, deleteOp)
+		return &DocumentNotFoundError{Hash: chainEdge.From}
 	}
 	toUID, ok := hashUIDMap[chainEdge.To]
 	if !ok {
-		return fmt.Errorf("To node of the relationship: [Edge: %v, From: %v, To: %v] does not exist, Delete Op: %v", chainEdge.Name, chainEdge.From, chainEdge.To, deleteOp)
+		return &DocumentNotFoundError{Hash: chainEdge.To}
 	}
 	log.Infof("Mutating [Edge: %v, From: <%v>%v, To: <%v>%v] Delete Op: %v", chainEdge.Name, fromUID, chainEdge.From, toUID, chainEdge.To, deleteOp)
-	mutation := m.dgraph.EdgeMutation(fromUID, toUID, chainEdge.Name, deleteOp)
-	return m.mutate(mutation, cursor)
-
+	mutation := m.dgraph.EdgeMutation(fromUID, toUID, edge.Predicate, deleteOp)
+	versionMutation, err := m.edgeVersionMutation(chainEdge, deleteOp)
+	if err != nil {
+		return err
+	}
+	return m.mutate(mutation, cursor, newEdgeEvent(chainEdge, deleteOp, cursor), versionMutation)
 }
 
-func (m *Doccache) updateDocumentTypeSchema(newField string) error {
-	if _, ok := m.documentFieldMap[newField]; !ok {
-		fields := ""
-		for key := range m.documentFieldMap {
-			fields += "\n" + key
-		}
-		err := m.dgraph.UpdateSchema(fmt.Sprintf(
-			`
-				%v: [uid] .
-				type Document{
-					%v
-					%v
-				}
-		 `, newField, fields, newField))
-		if err != nil {
-			return err
+//addDocumentFields adds the predicates to the schema and the new fields to the Document type
+func (m *Doccache) addDocumentFields(predicates string, newFields ...string) error {
+	fields := make([]string, 0, len(m.documentFieldMap)+len(newFields))
+	for key := range m.documentFieldMap {
+		fields = append(fields, key)
+	}
+	for _, newField := range newFields {
+		if _, ok := m.documentFieldMap[newField]; !ok {
+			fields = append(fields, newField)
 		}
+	}
+	err := m.updateDocumentType(predicates, fields)
+	if err != nil {
+		return err
+	}
+	for _, newField := range newFields {
 		m.documentFieldMap[newField] = &dgraph.SchemaField{Name: newField}
 	}
 	return nil
 }
 
+//removeDocumentField removes the field from the Document type, the predicate is kept
+func (m *Doccache) removeDocumentField(field string) error {
+	fields := make([]string, 0, len(m.documentFieldMap))
+	for key := range m.documentFieldMap {
+		if key != field {
+			fields = append(fields, key)
+		}
+	}
+	err := m.updateDocumentType("", fields)
+	if err != nil {
+		return err
+	}
+	delete(m.documentFieldMap, field)
+	return nil
+}
+
+func (m *Doccache) updateDocumentType(predicates string, fields []string) error {
+	return m.dgraph.UpdateSchema(fmt.Sprintf(
+		`
+			%v
+			type Document{
+				%v
+			}
+	 `, predicates, strings.Join(fields, "\n")))
+}
+
 func (m *Doccache) transformNew(chainDoc *ChainDocument) (*Document, error) {
 	doc := NewDocument(chainDoc)
+	err := m.assignKindType(doc)
+	if err != nil {
+		return nil, err
+	}
 	checksumContents := doc.GetChecksumContents()
 	hashes := make([]string, 0, len(checksumContents))
 	for _, checksumContent := range checksumContents {
@@ -411,7 +596,23 @@ func (m *Doccache) transformNew(chainDoc *ChainDocument) (*Document, error) {
 	return doc, nil
 }
 
-func configureRequest(rc *RequestConfig) string {
+func configureRequest(rc *RequestConfig) (string, error) {
+	if rc == nil {
+		rc = &RequestConfig{}
+	}
+	fields, err := requestFields(rc, MaxTraversalDepth)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`
+		{
+			%v
+		}
+	`, fields), nil
+}
+
+//requestFields returns the document predicates and the requested edge blocks, levels is the number of edge levels left
+func requestFields(rc *RequestConfig, levels int) (string, error) {
 	contentGroups, certificates := "", ""
 	if rc.ContentGroups {
 		contentGroups = contentGroupsRequest
@@ -424,24 +625,62 @@ func configureRequest(rc *RequestConfig) string {
 		hash
 		creator
 		created_date
+		doc_type
+		node_label
+		ballot_id
 		dgraph.type
 		%v
 		%v
 	`, contentGroups, certificates)
 
 	edgeRequest := ""
+	if levels < 1 && (len(rc.Edges) > 0 || len(rc.InboundEdges) > 0 || len(rc.Traversals) > 0) {
+		return "", invalidRequest("request exceeds the maximum traversal depth: %v", MaxTraversalDepth)
+	}
+	aliases := make(map[string]bool)
+	addAlias := func(alias string) error {
+		if aliases[alias] {
+			return invalidRequest("edge: %v requested more than once at the same level", alias)
+		}
+		aliases[alias] = true
+		return nil
+	}
 
 	for _, edge := range rc.Edges {
+		err := addAlias(edge)
+		if err != nil {
+			return "", err
+		}
 		edgeRequest += fmt.Sprintf(`
-			%v {
+			%v: <%v> {
 				%v
 			}
-		`, edge, predicates)
+		`, queryAlias(edge), EdgePredicate(edge), predicates)
 	}
-	return fmt.Sprintf(`
-		{
-			%v,
-			%v
+	for _, edge := range rc.InboundEdges {
+		err := addAlias(InboundEdgeAlias(edge))
+		if err != nil {
+			return "", err
 		}
-	`, predicates, edgeRequest)
+		edgeRequest += fmt.Sprintf(`
+			%v: <~%v> {
+				%v
+			}
+		`, queryAlias(InboundEdgeAlias(edge)), EdgePredicate(edge), predicates)
+	}
+	for _, traversal := range rc.Traversals {
+		err := addAlias(traversal.EdgeAlias())
+		if err != nil {
+			return "", err
+		}
+		block, err := traversalRequest(traversal, levels)
+		if err != nil {
+			return "", err
+		}
+		edgeRequest += block
+	}
+	return fmt.Sprintf(`
+		%v
+		%v
+	`, predicates, edgeRequest), nil
 }
@@ -2,8 +2,10 @@ package doccache
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/sebastianmontero/dgraph-go-client/dgraph"
@@ -502,3 +504,251 @@ func TestChainEdgeUnmarshall(t *testing.T) {
 		t.Fatalf("Unmarshalling failed: %v", err)
 	}
 }
+
+func TestTypedContent(t *testing.T) {
+	hash := "c3e0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c03"
+	chainDoc := &ChainDocument{
+		Hash:        hash,
+		CreatedDate: "2021-01-15T19:17:44",
+		Creator:     "mem2.hypha",
+		ContentGroups: [][]*ChainContent{
+			{
+				{
+					Label: "annual_usd_salary",
+					Value: []interface{}{"asset", "190000.00 USD"},
+				},
+				{
+					Label: "fulltime_capacity_x100",
+					Value: []interface{}{"int64", float64(100)},
+				},
+				{
+					Label: "updated_date",
+					Value: []interface{}{"time_point", "2021-01-11T21:52:32"},
+				},
+				{
+					Label: "type",
+					Value: []interface{}{"name", "role"},
+				},
+			},
+		},
+	}
+	err := doccache.StoreDocument(chainDoc, "typed1")
+	if err != nil {
+		t.Fatalf("StoreDocument failed: %v", err)
+	}
+	doc, err := doccache.GetByHash(hash, &RequestConfig{ContentGroups: true})
+	if err != nil {
+		t.Fatalf("GetByHash failed: %v", err)
+	}
+	contents := doc.ContentGroups[0].Contents
+	asset := contents[0]
+	if asset.AssetAmount == nil || *asset.AssetAmount != 190000 || asset.AssetSymbol != "USD" || asset.AssetPrecision == nil || *asset.AssetPrecision != 2 {
+		t.Fatalf("Asset typed value not stored correctly, found: %v", asset)
+	}
+	capacity := contents[1]
+	if capacity.IntValue == nil || *capacity.IntValue != 100 {
+		t.Fatalf("Int typed value not stored correctly, found: %v", capacity)
+	}
+	updatedDate := contents[2]
+	if updatedDate.TimeValue == nil || *updatedDate.TimeValue != *ToTime("2021-01-11T21:52:32") {
+		t.Fatalf("Time typed value not stored correctly, found: %v", updatedDate)
+	}
+	docType := contents[3]
+	if docType.NameValue != "role" {
+		t.Fatalf("Name typed value not stored correctly, found: %v", docType)
+	}
+
+	query := `
+		{
+			contents(func: ge(asset_amount, 100000.0), orderdesc: asset_amount) @filter(eq(asset_symbol, "USD") AND eq(label, "annual_usd_salary")){
+				uid
+				asset_amount
+			}
+		}
+	`
+	result := &struct {
+		Contents []*Content `json:"contents,omitempty"`
+	}{}
+	err = dg.Query(query, nil, result)
+	if err != nil {
+		t.Fatalf("Range query failed: %v", err)
+	}
+	if len(result.Contents) != 1 || result.Contents[0].UID != asset.UID {
+		t.Fatalf("Expected range query to find content: %v, found: %v", asset.UID, result.Contents)
+	}
+
+	t.Log("Backfilling typed values")
+	legacyHash := "c4e0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c04"
+	legacy := NewDocument(&ChainDocument{
+		Hash:        legacyHash,
+		CreatedDate: "2021-01-15T19:17:44",
+		Creator:     "mem2.hypha",
+		ContentGroups: [][]*ChainContent{
+			{
+				{
+					Label: "legacy_salary",
+					Value: []interface{}{"asset", "-5.00 USD"},
+				},
+				{
+					Label: "legacy_capacity",
+					Value: []interface{}{"int64", "50"},
+				},
+			},
+		},
+	})
+	for _, content := range legacy.ContentGroups[0].Contents {
+		content.IntValue, content.AssetAmount, content.AssetSymbol, content.AssetPrecision = nil, nil, "", nil
+	}
+	_, err = dg.MutateJSON(legacy, false)
+	if err != nil {
+		t.Fatalf("Failed to create legacy document: %v", err)
+	}
+	_, err = doccache.BackfillTypedValues()
+	if err != nil {
+		t.Fatalf("BackfillTypedValues failed: %v", err)
+	}
+	doc, err = doccache.GetByHash(legacyHash, &RequestConfig{ContentGroups: true})
+	if err != nil {
+		t.Fatalf("GetByHash failed: %v", err)
+	}
+	contents = doc.ContentGroups[0].Contents
+	if contents[0].AssetAmount == nil || *contents[0].AssetAmount != -5 || contents[0].AssetSymbol != "USD" || contents[0].ContentSequence != 1 {
+		t.Fatalf("Expected asset typed value to be backfilled, found: %v", contents[0])
+	}
+	if contents[1].IntValue == nil || *contents[1].IntValue != 50 || contents[1].ContentSequence != 2 {
+		t.Fatalf("Expected int typed value to be backfilled, found: %v", contents[1])
+	}
+}
+
+func TestParseAsset(t *testing.T) {
+	asset, err := ParseAsset("1000.00000 SEEDS")
+	if err != nil {
+		t.Fatalf("ParseAsset failed: %v", err)
+	}
+	if asset.Amount != 1000 || asset.Symbol != "SEEDS" || asset.Precision != 5 {
+		t.Fatalf("Asset not parsed correctly, found: %v", asset)
+	}
+	_, err = ParseAsset("1000.00")
+	if err == nil {
+		t.Fatal("Expected ParseAsset to fail for asset without symbol")
+	}
+}
+
+func TestToInt64(t *testing.T) {
+	for _, value := range []interface{}{int64(42), 42, float64(42), json.Number("42"), "42"} {
+		converted, err := ToInt64(value)
+		if err != nil || converted != 42 {
+			t.Fatalf("Expected 42 for: %v of type: %T, found: %v, error: %v", value, value, converted, err)
+		}
+	}
+	converted, err := ToInt64(float64(-(1 << 53)))
+	if err != nil || converted != -(1<<53) {
+		t.Fatalf("Expected -2^53, found: %v, error: %v", converted, err)
+	}
+	for _, value := range []interface{}{1.5, float64(1<<53 + 2), -float64(1<<53 + 2), 1e19, "1.5", true} {
+		_, err = ToInt64(value)
+		if err == nil {
+			t.Fatalf("Expected ToInt64 to fail for: %v of type: %T", value, value)
+		}
+	}
+}
+
+func TestHashNormalization(t *testing.T) {
+	hash := "d5e0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c05"
+	childHash := "e6e0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c06"
+	err := doccache.StoreDocument(&ChainDocument{
+		Hash:        strings.ToUpper(hash),
+		CreatedDate: "2021-01-15T19:17:44",
+		Creator:     "dao.hypha",
+	}, "normalize1")
+	if err != nil {
+		t.Fatalf("StoreDocument failed: %v", err)
+	}
+	err = doccache.StoreDocument(&ChainDocument{
+		Hash:        childHash,
+		CreatedDate: "2021-01-15T19:17:44",
+		Creator:     "dao.hypha",
+		ContentGroups: [][]*ChainContent{
+			{
+				{
+					Label: "parent",
+					Value: []interface{}{"checksum256", strings.ToUpper(hash)},
+				},
+			},
+		},
+	}, "normalize2")
+	if err != nil {
+		t.Fatalf("StoreDocument failed: %v", err)
+	}
+	doc, err := doccache.GetByHash(strings.ToUpper(childHash), &RequestConfig{ContentGroups: true})
+	if err != nil {
+		t.Fatalf("GetByHash failed: %v", err)
+	}
+	if doc == nil || doc.Hash != childHash {
+		t.Fatalf("Expected to find document with canonical hash: %v, found: %v", childHash, doc)
+	}
+	checksum := doc.ContentGroups[0].Contents[0]
+	if checksum.Value != hash || len(checksum.Document) != 1 || checksum.Document[0].Hash != hash {
+		t.Fatalf("Expected checksum content to be canonical and linked to: %v, found: %v", hash, checksum)
+	}
+	hashUIDMap, err := doccache.GetHashUIDMap([]string{strings.ToUpper(hash)})
+	if err != nil {
+		t.Fatalf("GetHashUIDMap failed: %v", err)
+	}
+	if _, ok := hashUIDMap[strings.ToUpper(hash)]; !ok {
+		t.Fatalf("Expected hash uid map to be keyed by requested hash, found: %v", hashUIDMap)
+	}
+	found, err := doccache.GetByHashes([]string{strings.ToUpper(hash), childHash, "aaaa"}, &RequestConfig{})
+	if err != nil {
+		t.Fatalf("GetByHashes failed: %v", err)
+	}
+	if len(found) != 2 {
+		t.Fatalf("Expected to find 2 documents by hashes, found: %v", found)
+	}
+
+	t.Log("Normalizing stored hashes")
+	legacyHash := "f7e0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c07"
+	_, err = dg.MutateJSON(&Document{Hash: strings.ToUpper(legacyHash), DType: []string{"Document"}}, false)
+	if err != nil {
+		t.Fatalf("Failed to create legacy document: %v", err)
+	}
+	hashUIDMap, err = doccache.GetHashUIDMap([]string{hash})
+	if err != nil {
+		t.Fatalf("GetHashUIDMap failed: %v", err)
+	}
+	_, err = dg.MutateJSON(&Document{Hash: strings.ToUpper(hash), DType: []string{"Document"}}, false)
+	if err != nil {
+		t.Fatalf("Failed to create legacy duplicate document: %v", err)
+	}
+	result, err := CountUnnormalizedHashes(dg, nil)
+	if err != nil {
+		t.Fatalf("CountUnnormalizedHashes failed: %v", err)
+	}
+	if result.Documents != 2 || result.Merged != 1 {
+		t.Fatalf("Expected 2 documents to normalize and 1 to merge, found: %v", result)
+	}
+	result, err = doccache.NormalizeStoredHashes(false)
+	if err != nil {
+		t.Fatalf("NormalizeStoredHashes failed: %v", err)
+	}
+	if result.Documents != 2 || result.Merged != 1 {
+		t.Fatalf("Expected 2 documents to be normalized and 1 merged, found: %v", result)
+	}
+	for _, canonical := range []string{legacyHash, hash} {
+		docs := &Docs{}
+		err = dg.Query(fmt.Sprintf(`{docs(func: eq(hash, "%v")){uid hash}}`, canonical), nil, docs)
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if len(docs.Docs) != 1 {
+			t.Fatalf("Expected a single document stored with canonical hash: %v, found: %v", canonical, docs.Docs)
+		}
+	}
+	merged, err := doccache.GetHashUIDMap([]string{hash})
+	if err != nil {
+		t.Fatalf("GetHashUIDMap failed: %v", err)
+	}
+	if merged[hash] != hashUIDMap[hash] {
+		t.Fatalf("Expected the original document: %v to be kept, found: %v", hashUIDMap[hash], merged[hash])
+	}
+}
@@ -0,0 +1,348 @@
+package doccache
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dgraph-io/dgo/v2/protos/api"
+)
+
+//edgeNamespace prefix of the predicates that store chain edges, keeps edges from clobbering core predicates
+const edgeNamespace = "edge."
+
+//inboundEdgePrefix prefix of the alias under which inbound edges are requested
+const inboundEdgePrefix = "inbound."
+
+const moveEdgesPageSize = 500
+
+const edgeDefinitionSchema = `
+      type EdgeDefinition {
+        edge_name
+        edge_predicate
+      }
+
+      edge_name: string @index(exact) .
+      edge_predicate: string @index(exact) .
+    `
+
+const edgeOptionsSchema = `
+      type EdgeDefinition {
+        edge_name
+        edge_predicate
+        edge_reverse
+        edge_count
+      }
+
+      edge_reverse: bool .
+      edge_count: bool .
+    `
+
+//EdgeOptions schema options of an edge predicate
+type EdgeOptions struct {
+	//Reverse enables traversing the edge from its target to its source
+	Reverse bool
+	//Count enables counting the edges of a document
+	Count bool
+}
+
+//DefaultEdgeOptions options used for edges that are not configured, reverse indexing is enabled
+func DefaultEdgeOptions() *EdgeOptions {
+	return &EdgeOptions{
+		Reverse: true,
+	}
+}
+
+func (m *EdgeOptions) String() string {
+	return fmt.Sprintf("EdgeOptions{Reverse: %v, Count: %v}", m.Reverse, m.Count)
+}
+
+//edgeSchema returns the schema of the edge predicate with the specified options
+func edgeSchema(predicate string, options *EdgeOptions) string {
+	directives := ""
+	if options.Reverse {
+		directives += " @reverse"
+	}
+	if options.Count {
+		directives += " @count"
+	}
+	return fmt.Sprintf("<%v>: [uid]%v .", predicate, directives)
+}
+
+//EdgePredicate returns the predicate that stores the chain edge
+func EdgePredicate(edgeName string) string {
+	return edgeNamespace + edgeName
+}
+
+//IsEdgePredicate indicates if the predicate stores a chain edge
+func IsEdgePredicate(predicate string) bool {
+	return strings.HasPrefix(predicate, edgeNamespace)
+}
+
+//EdgeNames returns the chain names of the registered edges
+func (m *Doccache) EdgeNames() []string {
+	m.edgesLock.RLock()
+	defer m.edgesLock.RUnlock()
+	names := make([]string, 0, len(m.edges))
+	for name := range m.edges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//InboundEdgeAlias returns the key under which the inbound edges are returned when requested as a map
+func InboundEdgeAlias(edgeName string) string {
+	return inboundEdgePrefix + edgeName
+}
+
+//queryAlias encodes the key under which edge documents are returned as a valid DQL alias, letters and digits
+//are kept, any other character and a leading digit are escaped as _ followed by their hex code
+func queryAlias(key string) string {
+	var alias strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9') {
+			alias.WriteByte(c)
+		} else {
+			fmt.Fprintf(&alias, "_%02x", c)
+		}
+	}
+	return alias.String()
+}
+
+//decodeQueryAlias returns the key encoded by queryAlias
+func decodeQueryAlias(alias string) string {
+	if !strings.Contains(alias, "_") {
+		return alias
+	}
+	var key strings.Builder
+	for i := 0; i < len(alias); i++ {
+		if alias[i] == '_' && i+2 < len(alias) {
+			if c, err := strconv.ParseUint(alias[i+1:i+3], 16, 8); err == nil {
+				key.WriteByte(byte(c))
+				i += 2
+				continue
+			}
+		}
+		key.WriteByte(alias[i])
+	}
+	return key.String()
+}
+
+//decodeDocumentMap replaces the query aliases of the edges of the document map and its neighbours by their keys
+func decodeDocumentMap(doc map[string]interface{}) {
+	renames := make(map[string]string)
+	for alias, value := range doc {
+		if documentJSONFields[alias] {
+			continue
+		}
+		if neighbours, ok := value.([]interface{}); ok {
+			for _, neighbour := range neighbours {
+				if neighbourMap, ok := neighbour.(map[string]interface{}); ok {
+					decodeDocumentMap(neighbourMap)
+				}
+			}
+		}
+		if key := decodeQueryAlias(alias); key != alias {
+			renames[alias] = key
+		}
+	}
+	for alias, key := range renames {
+		doc[key] = doc[alias]
+		delete(doc, alias)
+	}
+}
+
+//GetEdgeDefinition returns the definition of the chain edge, nil if the edge has not been seen
+func (m *Doccache) GetEdgeDefinition(edgeName string) *EdgeDefinition {
+	m.edgesLock.RLock()
+	defer m.edgesLock.RUnlock()
+	return m.edges[edgeName]
+}
+
+//edgeOptions returns the configured options for the edge, the default ones if not configured
+func (m *Doccache) edgeOptions(edgeName string) *EdgeOptions {
+	if options := m.configuredEdgeOptions(edgeName); options != nil {
+		return options
+	}
+	return DefaultEdgeOptions()
+}
+
+//configuredEdgeOptions returns the options explicitly configured for the edge, nil if none are
+func (m *Doccache) configuredEdgeOptions(edgeName string) *EdgeOptions {
+	if options, ok := m.config.EdgeOverrides[edgeName]; ok {
+		return options
+	}
+	return m.config.EdgeOptions
+}
+
+func (m *Doccache) loadEdges() error {
+	query := `
+		{
+			edges(func: type(EdgeDefinition)){
+				uid
+				edge_name
+				edge_predicate
+				edge_reverse
+				edge_count
+				dgraph.type
+			}
+		}
+	`
+	edges := &EdgeDefinitions{}
+	err := m.dgraph.Query(query, nil, edges)
+	if err != nil {
+		return err
+	}
+	m.edgesLock.Lock()
+	defer m.edgesLock.Unlock()
+	for _, edge := range edges.Edges {
+		m.edges[edge.Name] = edge
+	}
+	return nil
+}
+
+//registerEdge adds the edge predicate to the schema and stores its definition if it has not been seen
+func (m *Doccache) registerEdge(edgeName string) (*EdgeDefinition, error) {
+	if edge := m.GetEdgeDefinition(edgeName); edge != nil {
+		return edge, nil
+	}
+	options := m.edgeOptions(edgeName)
+	edge := &EdgeDefinition{
+		Name:      edgeName,
+		Predicate: EdgePredicate(edgeName),
+		Reverse:   options.Reverse,
+		Count:     options.Count,
+		DType:     []string{"EdgeDefinition"},
+	}
+	log.Infof("Registering edge: %v, predicate: %v, options: %v", edge.Name, edge.Predicate, options)
+	err := m.addDocumentFields(edgeSchema(edge.Predicate, options), edge.Predicate)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := m.dgraph.MutateJSON(edge, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, uid := range resp.GetUids() {
+		edge.UID = uid
+	}
+	m.edgesLock.Lock()
+	m.edges[edgeName] = edge
+	m.edgesLock.Unlock()
+	err = m.SyncGraphQLSchema()
+	if err != nil {
+		log.Errorf(err, "Failed to sync GraphQL schema after registering edge: %v, will retry when the next edge is registered", edgeName)
+	}
+	return edge, nil
+}
+
+//syncEdgeOptions updates the schema of the registered edges whose options differ from the explicitly
+//configured ones, the stored edge definition is kept for edges without configured options, e.g. when
+//the doccache is created by a tool with the default configuration
+func (m *Doccache) syncEdgeOptions() error {
+	return m.applyEdgeOptions(m.configuredEdgeOptions)
+}
+
+//applyEdgeOptions updates the schema of the registered edges whose options differ from the ones returned by optionsFn,
+//edges for which it returns nil are not updated
+func (m *Doccache) applyEdgeOptions(optionsFn func(edgeName string) *EdgeOptions) error {
+	for _, edgeName := range m.EdgeNames() {
+		edge := m.GetEdgeDefinition(edgeName)
+		options := optionsFn(edgeName)
+		if options == nil || (edge.Reverse == options.Reverse && edge.Count == options.Count) {
+			continue
+		}
+		log.Infof("Updating options of edge: %v, predicate: %v, from: %v to: %v", edge.Name, edge.Predicate, edge.Options(), options)
+		err := m.dgraph.UpdateSchema(edgeSchema(edge.Predicate, options))
+		if err != nil {
+			return err
+		}
+		edge.Reverse = options.Reverse
+		edge.Count = options.Count
+		_, err = m.dgraph.MutateJSON(edge, false)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//namespaceLegacyEdges moves edges stored directly under their chain name to their namespaced predicate
+func (m *Doccache) namespaceLegacyEdges() error {
+	for _, edgeName := range m.legacyEdgePredicates() {
+		edge, err := m.registerEdge(edgeName)
+		if err != nil {
+			return err
+		}
+		moved, err := m.moveEdges(edgeName, edge.Predicate)
+		if err != nil {
+			return err
+		}
+		log.Infof("Moved %v edges from: %v to: %v", moved, edgeName, edge.Predicate)
+		err = m.removeDocumentField(edgeName)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//legacyEdgePredicates document fields that hold edges stored before edge predicates were namespaced
+func (m *Doccache) legacyEdgePredicates() []string {
+	legacy := make([]string, 0)
+	for field := range m.documentFieldMap {
+		if !coreDocumentFields[field] && !IsEdgePredicate(field) {
+			legacy = append(legacy, field)
+		}
+	}
+	sort.Strings(legacy)
+	return legacy
+}
+
+func (m *Doccache) moveEdges(fromPredicate, toPredicate string) (int, error) {
+	moved := 0
+	for {
+		query := fmt.Sprintf(`
+			{
+				docs(func: type(Document), first: %v) @filter(has(<%v>)){
+					uid
+					targets: <%v> {
+						uid
+					}
+				}
+			}
+		`, moveEdgesPageSize, fromPredicate, fromPredicate)
+		result := &struct {
+			Docs []*struct {
+				UID     string     `json:"uid,omitempty"`
+				Targets []*uidNode `json:"targets,omitempty"`
+			} `json:"docs,omitempty"`
+		}{}
+		err := m.dgraph.Query(query, nil, result)
+		if err != nil {
+			return 0, err
+		}
+		if len(result.Docs) == 0 {
+			return moved, nil
+		}
+		var set, del strings.Builder
+		for _, doc := range result.Docs {
+			fmt.Fprintf(&del, "<%v> <%v> * .\n", doc.UID, fromPredicate)
+			for _, target := range doc.Targets {
+				fmt.Fprintf(&set, "<%v> <%v> <%v> .\n", doc.UID, toPredicate, target.UID)
+				moved++
+			}
+		}
+		mutations := []*api.Mutation{m.dgraph.DeleteNQuadsMutation(del.String())}
+		if set.Len() > 0 {
+			mutations = append(mutations, m.dgraph.NQuadsMutation(set.String(), false))
+		}
+		_, err = m.dgraph.Mutate(mutations...)
+		if err != nil {
+			return 0, err
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package doccache
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestEdgeSchema(t *testing.T) {
+	tests := []struct {
+		options  *EdgeOptions
+		expected string
+	}{
+		{&EdgeOptions{}, "<edge.ownedby>: [uid] ."},
+		{DefaultEdgeOptions(), "<edge.ownedby>: [uid] @reverse ."},
+		{&EdgeOptions{Reverse: true, Count: true}, "<edge.ownedby>: [uid] @reverse @count ."},
+	}
+	for _, test := range tests {
+		actual := edgeSchema(EdgePredicate("ownedby"), test.options)
+		if actual != test.expected {
+			t.Fatalf("Expected schema for options: %v to be: %v, found: %v", test.options, test.expected, actual)
+		}
+	}
+}
+
+func TestDocumentEdgesDecoding(t *testing.T) {
+	data := `{
+		"uid": "0x1",
+		"hash": "a1",
+		"dgraph.type": ["Document"],
+		"ownedby": [{"uid": "0x2", "hash": "b2"}],
+		"inbound_2emember": [{"uid": "0x3", "hash": "c3"}, {"uid": "0x4", "hash": "d4"}],
+		"owned_2dby_5fdao": [{"uid": "0x5", "hash": "e5"}],
+		"count_2eowned_2dby_5fdao": 1
+	}`
+	doc := &Document{}
+	err := json.Unmarshal([]byte(data), doc)
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if doc.UID != "0x1" || doc.Hash != "a1" {
+		t.Fatalf("Expected document fields to be decoded, found: %v", doc)
+	}
+	if len(doc.Edges["ownedby"]) != 1 || doc.Edges["ownedby"][0].Hash != "b2" {
+		t.Fatalf("Expected outgoing edge ownedby to b2, found: %v", doc.Edges)
+	}
+	if len(doc.InboundEdges["member"]) != 2 || doc.InboundEdges["member"][1].Hash != "d4" {
+		t.Fatalf("Expected 2 inbound member edges, found: %v", doc.InboundEdges)
+	}
+	if len(doc.Edges["owned-by_dao"]) != 1 || doc.EdgeCounts["owned-by_dao"] != 1 {
+		t.Fatalf("Expected escaped alias to be decoded to owned-by_dao, found: %v, counts: %v", doc.Edges, doc.EdgeCounts)
+	}
+}
+
+func TestQueryAlias(t *testing.T) {
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{"member", "member"},
+		{"inbound.member", "inbound_2emember"},
+		{"count.owned-by", "count_2eowned_2dby"},
+		{"snake_case", "snake_5fcase"},
+		{"1up", "_31up"},
+		{"member1", "member1"},
+	}
+	for _, test := range tests {
+		alias := queryAlias(test.key)
+		if alias != test.expected {
+			t.Fatalf("Expected alias for: %v to be: %v, found: %v", test.key, test.expected, alias)
+		}
+		if decoded := decodeQueryAlias(alias); decoded != test.key {
+			t.Fatalf("Expected alias: %v to decode to: %v, found: %v", alias, test.key, decoded)
+		}
+	}
+	doc := map[string]interface{}{
+		"hash":         "a1",
+		"created_date": "2021-01-15T19:17:44Z",
+		"inbound_2eowned_2dby": []interface{}{
+			map[string]interface{}{"hash": "b2", "member_5fof": []interface{}{}},
+		},
+	}
+	decodeDocumentMap(doc)
+	neighbours, ok := doc["inbound.owned-by"].([]interface{})
+	if !ok || doc["created_date"] == nil || len(doc) != 3 {
+		t.Fatalf("Expected inbound.owned-by key in the decoded map, found: %v", doc)
+	}
+	if _, ok := neighbours[0].(map[string]interface{})["member_of"]; !ok {
+		t.Fatalf("Expected neighbour aliases to be decoded, found: %v", neighbours[0])
+	}
+}
+
+func TestInboundEdges(t *testing.T) {
+	ownerHash := "e5c0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c05"
+	ownedHashes := []string{
+		"f6c0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c06",
+		"a7c0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c07",
+	}
+	for i, hash := range append([]string{ownerHash}, ownedHashes...) {
+		err := doccache.StoreDocument(&ChainDocument{
+			Hash:        hash,
+			CreatedDate: "2021-01-15T19:17:44",
+			Creator:     "dao.hypha",
+			ContentGroups: [][]*ChainContent{
+				{
+					{
+						Label: "inbound",
+						Value: []interface{}{"int64", int64(i)},
+					},
+				},
+			},
+		}, fmt.Sprintf("inbound%v", i))
+		if err != nil {
+			t.Fatalf("StoreDocument failed: %v", err)
+		}
+	}
+	for _, hash := range ownedHashes {
+		err := doccache.MutateEdge(&ChainEdge{Name: "ownedby", From: hash, To: ownerHash}, false, "inbound3")
+		if err != nil {
+			t.Fatalf("MutateEdge failed: %v", err)
+		}
+	}
+	edge := doccache.GetEdgeDefinition("ownedby")
+	if edge == nil || !edge.Reverse || edge.Count {
+		t.Fatalf("Expected edge ownedby to be registered with default options, found: %v", edge)
+	}
+
+	doc, err := doccache.GetByHash(ownerHash, &RequestConfig{InboundEdges: []string{"ownedby"}})
+	if err != nil {
+		t.Fatalf("GetByHash failed: %v", err)
+	}
+	inbound := doc.InboundEdges["ownedby"]
+	if len(inbound) != 2 {
+		t.Fatalf("Expected 2 documents owned by: %v, found: %v", ownerHash, inbound)
+	}
+	for _, neighbour := range inbound {
+		if neighbour.Hash != ownedHashes[0] && neighbour.Hash != ownedHashes[1] {
+			t.Fatalf("Unexpected inbound neighbour: %v", neighbour)
+		}
+	}
+	doc, err = doccache.GetByHash(ownedHashes[0], &RequestConfig{Edges: []string{"ownedby"}})
+	if err != nil {
+		t.Fatalf("GetByHash failed: %v", err)
+	}
+	if len(doc.Edges["ownedby"]) != 1 || doc.Edges["ownedby"][0].Hash != ownerHash {
+		t.Fatalf("Expected outgoing edge ownedby to: %v, found: %v", ownerHash, doc.Edges)
+	}
+
+	t.Log("Changing edge options updates the schema")
+	cache, err := NewWithConfig(dg, &Config{EdgeOverrides: map[string]*EdgeOptions{"ownedby": {Reverse: true, Count: true}}}, nil)
+	if err != nil {
+		t.Fatalf("Failed creating docCache: %v", err)
+	}
+	edge = cache.GetEdgeDefinition("ownedby")
+	if !edge.Count {
+		t.Fatalf("Expected edge ownedby to have count enabled, found: %v", edge)
+	}
+	result := &struct {
+		Docs []*struct {
+			Count int `json:"count"`
+		} `json:"docs"`
+	}{}
+	err = dg.Query(fmt.Sprintf(`{docs(func: eq(hash, "%v")) @filter(ge(count(<~%v>), 2)){count: count(<~%v>)}}`, ownerHash, edge.Predicate, edge.Predicate), nil, result)
+	if err != nil {
+		t.Fatalf("Count query failed: %v", err)
+	}
+	if len(result.Docs) != 1 || result.Docs[0].Count != 2 {
+		t.Fatalf("Expected owner to have 2 inbound edges, found: %v", result.Docs)
+	}
+	cache, err = New(dg, nil)
+	if err != nil {
+		t.Fatalf("Failed creating docCache: %v", err)
+	}
+	if !cache.GetEdgeDefinition("ownedby").Count {
+		t.Fatalf("Expected edge ownedby to keep its stored options when edge options are not configured")
+	}
+	cache, err = NewWithConfig(dg, &Config{EdgeOptions: DefaultEdgeOptions()}, nil)
+	if err != nil {
+		t.Fatalf("Failed creating docCache: %v", err)
+	}
+	if cache.GetEdgeDefinition("ownedby").Count {
+		t.Fatalf("Expected edge ownedby count to be disabled when the default options are configured")
+	}
+}
@@ -0,0 +1,294 @@
+package doccache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//DefaultEventBufferSize number of recent events kept to resume subscriptions when not configured
+const DefaultEventBufferSize = 10000
+
+//EventType kind of change
+type EventType string
+
+//Event types
+const (
+	EventDocumentCreated EventType = "document_created"
+	//EventDocumentUpdated certificates were added to an existing document
+	EventDocumentUpdated EventType = "document_updated"
+	EventDocumentDeleted EventType = "document_deleted"
+	EventEdgeCreated     EventType = "edge_created"
+	EventEdgeDeleted     EventType = "edge_deleted"
+)
+
+//EventDocument document the event refers to
+type EventDocument struct {
+	Hash         string     `json:"hash"`
+	Creator      string     `json:"creator,omitempty"`
+	CreatedDate  *time.Time `json:"created_date,omitempty"`
+	DocType      string     `json:"doc_type,omitempty"`
+	Certificates int        `json:"certificates"`
+}
+
+//EventEdge edge the event refers to
+type EventEdge struct {
+	Name string `json:"name"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+//Event change committed to the cache, ID is derived from the block and the change so that it is the same
+//when a delta is replayed, Cursor is the cursor to resume a subscription after this event and is assigned
+//when published, ChainCursor is the cursor of the chain delta that caused the change
+type Event struct {
+	ID          string         `json:"id,omitempty"`
+	Cursor      string         `json:"cursor,omitempty"`
+	Type        EventType      `json:"type"`
+	ChainCursor string         `json:"chain_cursor"`
+	Time        time.Time      `json:"time"`
+	Document    *EventDocument `json:"document,omitempty"`
+	Edge        *EventEdge     `json:"edge,omitempty"`
+}
+
+func (m *Event) String() string {
+	return fmt.Sprintf("Event{ID: %v, Cursor: %v, Type: %v, ChainCursor: %v, Time: %v, Document: %v, Edge: %v}", m.ID, m.Cursor, m.Type, m.ChainCursor, m.Time, m.Document, m.Edge)
+}
+
+//IsDocumentEvent indicates if the event refers to a document
+func (m *Event) IsDocumentEvent() bool {
+	return m.Document != nil
+}
+
+//EventFilter selects events, empty fields match all events. DocTypes and Creators only apply to
+//document events and Edges only to edge events, Hashes match the document or any end of the edge
+type EventFilter struct {
+	Types    []EventType `json:"types,omitempty"`
+	DocTypes []string    `json:"doc_types,omitempty"`
+	Creators []string    `json:"creators,omitempty"`
+	Hashes   []string    `json:"hashes,omitempty"`
+	Edges    []string    `json:"edges,omitempty"`
+}
+
+func (m *EventFilter) String() string {
+	return fmt.Sprintf("EventFilter{Types: %v, DocTypes: %v, Creators: %v, Hashes: %v, Edges: %v}", m.Types, m.DocTypes, m.Creators, m.Hashes, m.Edges)
+}
+
+//Matches indicates if the event passes the filter
+func (m *EventFilter) Matches(event *Event) bool {
+	if m == nil {
+		return true
+	}
+	if len(m.Types) > 0 {
+		found := false
+		for _, eventType := range m.Types {
+			found = found || eventType == event.Type
+		}
+		if !found {
+			return false
+		}
+	}
+	if event.IsDocumentEvent() {
+		return containsOrEmpty(m.DocTypes, event.Document.DocType) &&
+			containsOrEmpty(m.Creators, event.Document.Creator) &&
+			containsOrEmpty(m.Hashes, event.Document.Hash)
+	}
+	return containsOrEmpty(m.Edges, event.Edge.Name) &&
+		(containsOrEmpty(m.Hashes, event.Edge.From) || containsOrEmpty(m.Hashes, event.Edge.To))
+}
+
+func containsOrEmpty(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+//EventSink receives every change before it is committed, if Write fails the change and the cursor
+//are not committed so the delta is replayed when streaming restarts, which gives sinks at-least-once
+//delivery, duplicates can be detected by the event id
+type EventSink interface {
+	Name() string
+	//Write must not return until the event is stored or delivered
+	Write(event *Event) error
+	Close() error
+}
+
+//EventSinkError indicates that a sink failed to write an event and the change was not committed
+type EventSinkError struct {
+	Sink string
+	Err  error
+}
+
+func (m *EventSinkError) Error() string {
+	return fmt.Sprintf("failed writing event to sink: %v, error: %v", m.Sink, m.Err)
+}
+
+//EventCursorExpiredError indicates that the events after the cursor are no longer buffered,
+//the subscriber has to reload the state and subscribe without a cursor
+type EventCursorExpiredError struct {
+	Cursor string
+}
+
+func (m *EventCursorExpiredError) Error() string {
+	return fmt.Sprintf("events after cursor: %v are no longer available", m.Cursor)
+}
+
+//EventBroker keeps the most recent events in memory and notifies the subscriptions,
+//event cursors are only valid for the lifetime of the broker
+type EventBroker struct {
+	epoch  string
+	size   int
+	events []*Event
+	first  uint64
+	next   uint64
+	notify chan struct{}
+	lock   sync.Mutex
+}
+
+//NewEventBroker creates a broker that buffers up to size events
+func NewEventBroker(size int) *EventBroker {
+	if size <= 0 {
+		size = DefaultEventBufferSize
+	}
+	return &EventBroker{
+		epoch:  strconv.FormatInt(time.Now().UnixNano(), 36),
+		size:   size,
+		events: make([]*Event, 0),
+		notify: make(chan struct{}),
+	}
+}
+
+//Publish assigns the event cursor, buffers the event and wakes up the subscriptions
+func (m *EventBroker) Publish(event *Event) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	event.Cursor = fmt.Sprintf("%v.%v", m.epoch, m.next)
+	m.next++
+	m.events = append(m.events, event)
+	if len(m.events) > 2*m.size {
+		dropped := len(m.events) - m.size
+		m.events = append(make([]*Event, 0, m.size), m.events[dropped:]...)
+		m.first += uint64(dropped)
+	}
+	close(m.notify)
+	m.notify = make(chan struct{})
+}
+
+//Subscribe creates a subscription to the events that match the filter, if after is set
+//the buffered events after that event cursor are delivered first
+func (m *EventBroker) Subscribe(filter *EventFilter, after string) (*EventSubscription, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	subscription := &EventSubscription{
+		broker: m,
+		filter: filter,
+		next:   m.next,
+	}
+	if after == "" {
+		return subscription, nil
+	}
+	epochSeq := strings.SplitN(after, ".", 2)
+	if len(epochSeq) != 2 {
+		return nil, invalidRequest("invalid event cursor: %v", after)
+	}
+	seq, err := strconv.ParseUint(epochSeq[1], 10, 64)
+	if err != nil {
+		return nil, invalidRequest("invalid event cursor: %v", after)
+	}
+	if epochSeq[0] != m.epoch || seq+1 < m.first || seq >= m.next {
+		return nil, &EventCursorExpiredError{Cursor: after}
+	}
+	subscription.next = seq + 1
+	return subscription, nil
+}
+
+//EventSubscription reads the events of a broker in order
+type EventSubscription struct {
+	broker *EventBroker
+	filter *EventFilter
+	next   uint64
+}
+
+//Next blocks until the next event that matches the filter is published or the context is done,
+//returns an EventCursorExpiredError if the subscription fell behind the buffer
+func (m *EventSubscription) Next(ctx context.Context) (*Event, error) {
+	for {
+		m.broker.lock.Lock()
+		if m.next < m.broker.first {
+			m.broker.lock.Unlock()
+			return nil, &EventCursorExpiredError{Cursor: fmt.Sprintf("%v.%v", m.broker.epoch, m.next-1)}
+		}
+		if m.next < m.broker.next {
+			event := m.broker.events[m.next-m.broker.first]
+			m.next++
+			m.broker.lock.Unlock()
+			if m.filter.Matches(event) {
+				return event, nil
+			}
+			continue
+		}
+		notify := m.broker.notify
+		m.broker.lock.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-notify:
+		}
+	}
+}
+
+//eventID identifies the change by the block, the transaction and what changed, so that the event
+//written again when a delta is replayed has the same id
+func eventID(blockNum uint64, trxID string, event *Event) string {
+	var action string
+	if event.IsDocumentEvent() {
+		action = fmt.Sprintf("%v:%v:%v:%v", trxID, event.Type, event.Document.Hash, event.Document.Certificates)
+	} else {
+		action = fmt.Sprintf("%v:%v:%v:%v:%v", trxID, event.Type, event.Edge.Name, event.Edge.From, event.Edge.To)
+	}
+	sum := sha256.Sum256([]byte(action))
+	return fmt.Sprintf("%v-%v", blockNum, hex.EncodeToString(sum[:8]))
+}
+
+func newDocumentEvent(eventType EventType, doc *Document, cursor string) *Event {
+	return &Event{
+		Type:        eventType,
+		ChainCursor: cursor,
+		Time:        time.Now().UTC(),
+		Document: &EventDocument{
+			Hash:         doc.Hash,
+			Creator:      doc.Creator,
+			CreatedDate:  doc.CreatedDate,
+			DocType:      doc.DocType,
+			Certificates: len(doc.Certificates),
+		},
+	}
+}
+
+func newEdgeEvent(chainEdge *ChainEdge, deleteOp bool, cursor string) *Event {
+	eventType := EventEdgeCreated
+	if deleteOp {
+		eventType = EventEdgeDeleted
+	}
+	return &Event{
+		Type:        eventType,
+		ChainCursor: cursor,
+		Time:        time.Now().UTC(),
+		Edge: &EventEdge{
+			Name: chainEdge.Name,
+			From: chainEdge.From,
+			To:   chainEdge.To,
+		},
+	}
+}
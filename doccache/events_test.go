@@ -0,0 +1,249 @@
+package doccache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func nextEvent(t *testing.T, subscription *EventSubscription) *Event {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	event, err := subscription.Next(ctx)
+	if err != nil {
+		t.Fatalf("Next event failed: %v", err)
+	}
+	return event
+}
+
+func TestEventBroker(t *testing.T) {
+	broker := NewEventBroker(2)
+	all, err := broker.Subscribe(nil, "")
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	proposals, err := broker.Subscribe(&EventFilter{DocTypes: []string{"proposal"}, Edges: []string{"vote"}}, "")
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	events := []*Event{
+		{Type: EventDocumentCreated, Document: &EventDocument{Hash: "a", DocType: "member"}},
+		{Type: EventDocumentCreated, Document: &EventDocument{Hash: "b", DocType: "proposal"}},
+		{Type: EventEdgeCreated, Edge: &EventEdge{Name: "member", From: "a", To: "b"}},
+		{Type: EventEdgeCreated, Edge: &EventEdge{Name: "vote", From: "a", To: "b"}},
+	}
+	go func() {
+		for _, event := range events {
+			broker.Publish(event)
+		}
+	}()
+	for i, event := range events {
+		if received := nextEvent(t, all); received != event {
+			t.Fatalf("Expected event: %v, found: %v", i, received)
+		}
+	}
+	if event := nextEvent(t, proposals); event != events[1] {
+		t.Fatalf("Expected proposal event, found: %v", event)
+	}
+	if event := nextEvent(t, proposals); event != events[3] {
+		t.Fatalf("Expected vote event, found: %v", event)
+	}
+
+	resumed, err := broker.Subscribe(nil, events[2].Cursor)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if event := nextEvent(t, resumed); event != events[3] {
+		t.Fatalf("Expected to resume with last event, found: %v", event)
+	}
+	for i := 0; i < 4; i++ {
+		broker.Publish(&Event{Type: EventEdgeDeleted, Edge: &EventEdge{Name: "vote"}})
+	}
+	_, err = broker.Subscribe(nil, events[0].Cursor)
+	if _, ok := err.(*EventCursorExpiredError); !ok {
+		t.Fatalf("Expected cursor expired error, found: %v", err)
+	}
+	_, err = resumed.Next(context.Background())
+	if _, ok := err.(*EventCursorExpiredError); !ok {
+		t.Fatalf("Expected lagging subscription to expire, found: %v", err)
+	}
+	for _, after := range []string{"invalid", "epoch.1"} {
+		_, err = broker.Subscribe(nil, after)
+		if err == nil {
+			t.Fatalf("Expected error for cursor: %v", after)
+		}
+	}
+}
+
+func TestEventFilter(t *testing.T) {
+	document := &Event{Type: EventDocumentUpdated, Document: &EventDocument{Hash: "a", DocType: "payout", Creator: "alice"}}
+	edge := &Event{Type: EventEdgeDeleted, Edge: &EventEdge{Name: "vote", From: "a", To: "b"}}
+	tests := []struct {
+		filter   *EventFilter
+		document bool
+		edge     bool
+	}{
+		{&EventFilter{}, true, true},
+		{&EventFilter{Types: []EventType{EventEdgeDeleted}}, false, true},
+		{&EventFilter{DocTypes: []string{"payout"}}, true, true},
+		{&EventFilter{Creators: []string{"bob"}}, false, true},
+		{&EventFilter{Edges: []string{"member"}}, true, false},
+		{&EventFilter{Hashes: []string{"b"}}, false, true},
+	}
+	for _, test := range tests {
+		if test.filter.Matches(document) != test.document || test.filter.Matches(edge) != test.edge {
+			t.Fatalf("Expected filter: %v to match document: %v, edge: %v", test.filter, test.document, test.edge)
+		}
+	}
+}
+
+func TestMutationEvents(t *testing.T) {
+	hashes := []string{
+		"c1f0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c40",
+		"c2f0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c41",
+	}
+	subscription, err := doccache.Events.Subscribe(&EventFilter{Hashes: hashes}, "")
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	chainDocs := make([]*ChainDocument, 0, len(hashes))
+	for i, hash := range hashes {
+		chainDoc := &ChainDocument{
+			Hash:        hash,
+			CreatedDate: fmt.Sprintf("2021-06-1%vT10:00:00", i),
+			Creator:     "eventer",
+			ContentGroups: [][]*ChainContent{
+				{
+					{
+						Label: "content_group_label",
+						Value: []interface{}{"string", "system"},
+					},
+					{
+						Label: "type",
+						Value: []interface{}{"name", "payout"},
+					},
+				},
+			},
+		}
+		err = doccache.StoreDocument(chainDoc, fmt.Sprintf("events%v", i))
+		if err != nil {
+			t.Fatalf("StoreDocument failed: %v", err)
+		}
+		chainDocs = append(chainDocs, chainDoc)
+	}
+	err = doccache.MutateEdge(&ChainEdge{Name: "eventedge", From: hashes[0], To: hashes[1]}, false, "events2")
+	if err != nil {
+		t.Fatalf("MutateEdge failed: %v", err)
+	}
+	err = doccache.DeleteDocument(chainDocs[1], "events3")
+	if err != nil {
+		t.Fatalf("DeleteDocument failed: %v", err)
+	}
+	err = doccache.DeleteDocument(chainDocs[1], "deleted")
+	if err != nil {
+		t.Fatalf("DeleteDocument failed: %v", err)
+	}
+	stored, err := doccache.StoredCursor()
+	if err != nil {
+		t.Fatalf("StoredCursor failed: %v", err)
+	}
+	if stored.Cursor != "deleted" {
+		t.Fatalf("Expected cursor to be committed when the deleted document does not exist, found: %v", stored)
+	}
+	err = doccache.StoreDocument(chainDocs[0], "unchanged")
+	if err != nil {
+		t.Fatalf("StoreDocument failed: %v", err)
+	}
+	chainDocs[0].Certificates = []*ChainCertificate{{Certifier: "eventer", Notes: "approved", CertificationDate: "2021-06-11T10:00:00"}}
+	err = doccache.StoreDocument(chainDocs[0], "events4")
+	if err != nil {
+		t.Fatalf("StoreDocument failed: %v", err)
+	}
+
+	expected := []EventType{EventDocumentCreated, EventDocumentCreated, EventEdgeCreated, EventDocumentDeleted, EventDocumentUpdated}
+	for i, eventType := range expected {
+		event := nextEvent(t, subscription)
+		if event.Type != eventType || event.ChainCursor != fmt.Sprintf("events%v", i) {
+			t.Fatalf("Expected event: %v with cursor: events%v, found: %v", eventType, i, event)
+		}
+		if event.IsDocumentEvent() && (event.Document.DocType != "payout" || event.Document.Creator != "eventer") {
+			t.Fatalf("Expected payout document event, found: %v", event.Document)
+		}
+	}
+}
+
+type testSink struct {
+	fail      bool
+	events    []*Event
+	attempted []string
+}
+
+func (m *testSink) Name() string {
+	return "test"
+}
+
+func (m *testSink) Write(event *Event) error {
+	m.attempted = append(m.attempted, event.ID)
+	if m.fail {
+		return fmt.Errorf("sink unavailable")
+	}
+	m.events = append(m.events, event)
+	return nil
+}
+
+func (m *testSink) Close() error {
+	return nil
+}
+
+func TestEventSinks(t *testing.T) {
+	sink := &testSink{fail: true}
+	cache, err := NewWithConfig(dg, &Config{EventSinks: []EventSink{sink}}, nil)
+	if err != nil {
+		t.Fatalf("NewWithConfig failed: %v", err)
+	}
+	chainDoc := &ChainDocument{
+		Hash:        "c3f0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c42",
+		CreatedDate: "2021-06-20T10:00:00",
+		Creator:     "sinker",
+		ContentGroups: [][]*ChainContent{
+			{
+				{
+					Label: "content_group_label",
+					Value: []interface{}{"string", "system"},
+				},
+			},
+		},
+	}
+	err = cache.StoreDocument(chainDoc, "sinks0")
+	if _, ok := err.(*EventSinkError); !ok {
+		t.Fatalf("Expected EventSinkError, found: %v", err)
+	}
+	doc, err := cache.GetByHash(chainDoc.Hash, nil)
+	if err != nil {
+		t.Fatalf("GetByHash failed: %v", err)
+	}
+	if doc != nil {
+		t.Fatalf("Expected document not to be committed when the sink fails, found: %v", doc)
+	}
+
+	sink.fail = false
+	err = cache.StoreDocument(chainDoc, "sinks1")
+	if err != nil {
+		t.Fatalf("StoreDocument failed: %v", err)
+	}
+	if len(sink.events) != 1 || sink.events[0].Type != EventDocumentCreated || sink.events[0].ChainCursor != "sinks1" {
+		t.Fatalf("Expected document created event with cursor sinks1, found: %v", sink.events)
+	}
+	if sink.events[0].ID == "" || sink.attempted[0] != sink.events[0].ID {
+		t.Fatalf("Expected replayed event to keep its id, found: %v", sink.attempted)
+	}
+	if replayed := eventID(cache.Cursor.BlockNum+1, "", sink.events[0]); replayed == sink.events[0].ID {
+		t.Fatalf("Expected events of different blocks to have different ids, found: %v", replayed)
+	}
+	doc, err = cache.GetByHash(chainDoc.Hash, nil)
+	if err != nil || doc == nil {
+		t.Fatalf("Expected document to be committed, found: %v, error: %v", doc, err)
+	}
+}
@@ -0,0 +1,651 @@
+package doccache
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+//ContentIndex tokenizer of a content predicate index
+type ContentIndex string
+
+//Content indexes
+const (
+	IndexTerm     ContentIndex = "term"
+	IndexFulltext ContentIndex = "fulltext"
+	IndexTrigram  ContentIndex = "trigram"
+	IndexExact    ContentIndex = "exact"
+)
+
+//graphQLSearchTokens GraphQL search arguments that create each of the content indexes
+var graphQLSearchTokens = map[ContentIndex]string{
+	IndexTerm:     "term",
+	IndexFulltext: "fulltext",
+	IndexTrigram:  "regexp",
+	IndexExact:    "exact",
+}
+
+//contentIndexPredicates content predicates whose indexes can be configured
+var contentIndexPredicates = []string{"label", "value"}
+
+//DefaultContentIndexes indexes of the content predicates when not configured
+func DefaultContentIndexes() map[string][]ContentIndex {
+	return map[string][]ContentIndex{
+		"label": {IndexTerm},
+		"value": {IndexTerm},
+	}
+}
+
+//ParseContentIndexes parses the content index policies, e.g. value=fulltext,trigram;label=exact,
+//the term index is always kept as the search content filters depend on it
+func ParseContentIndexes(policies string) (map[string][]ContentIndex, error) {
+	contentIndexes := DefaultContentIndexes()
+	for _, policy := range strings.Split(policies, ";") {
+		if strings.TrimSpace(policy) == "" {
+			continue
+		}
+		predicateIndexes := strings.SplitN(policy, "=", 2)
+		if len(predicateIndexes) != 2 {
+			return nil, fmt.Errorf("invalid content index policy: %v, expected predicate=index[,index]", policy)
+		}
+		indexes := make([]ContentIndex, 0)
+		for _, index := range strings.Split(predicateIndexes[1], ",") {
+			indexes = append(indexes, ContentIndex(strings.TrimSpace(index)))
+		}
+		contentIndexes[strings.TrimSpace(predicateIndexes[0])] = indexes
+	}
+	return normalizeContentIndexes(contentIndexes)
+}
+
+//normalizeContentIndexes validates the content indexes, adds the term index and sorts them
+func normalizeContentIndexes(contentIndexes map[string][]ContentIndex) (map[string][]ContentIndex, error) {
+	normalized := DefaultContentIndexes()
+	for predicate, indexes := range contentIndexes {
+		if _, ok := normalized[predicate]; !ok {
+			return nil, fmt.Errorf("indexes of predicate: %v can not be configured, configurable predicates: %v", predicate, contentIndexPredicates)
+		}
+		set := map[ContentIndex]bool{IndexTerm: true}
+		for _, index := range indexes {
+			if _, ok := graphQLSearchTokens[index]; !ok {
+				return nil, fmt.Errorf("invalid index: %v for predicate: %v", index, predicate)
+			}
+			set[index] = true
+		}
+		normalized[predicate] = make([]ContentIndex, 0, len(set))
+		for index := range set {
+			normalized[predicate] = append(normalized[predicate], index)
+		}
+		sort.Slice(normalized[predicate], func(i, j int) bool { return normalized[predicate][i] < normalized[predicate][j] })
+	}
+	return normalized, nil
+}
+
+func hasContentIndex(indexes []ContentIndex, index ContentIndex) bool {
+	for _, i := range indexes {
+		if i == index {
+			return true
+		}
+	}
+	return false
+}
+
+//contentIndexSchema returns the schema of the content predicate with the indexes
+func contentIndexSchema(predicate string, indexes []ContentIndex) string {
+	tokenizers := make([]string, 0, len(indexes))
+	for _, index := range indexes {
+		tokenizers = append(tokenizers, string(index))
+	}
+	return fmt.Sprintf("%v: string @index(%v) .", predicate, strings.Join(tokenizers, ", "))
+}
+
+//syncContentIndexes updates the content predicate indexes that do not match the explicitly configured ones,
+//if they are not configured the current indexes are kept and used as the available ones
+func (m *Doccache) syncContentIndexes() error {
+	schema := &struct {
+		Predicates []struct {
+			Predicate string   `json:"predicate"`
+			Tokenizer []string `json:"tokenizer"`
+		} `json:"schema"`
+	}{}
+	err := m.dgraph.Query(fmt.Sprintf(`schema(pred: [%v]) { tokenizer }`, strings.Join(contentIndexPredicates, ", ")), nil, schema)
+	if err != nil {
+		return err
+	}
+	current := make(map[string][]ContentIndex)
+	for _, predicate := range schema.Predicates {
+		for _, tokenizer := range predicate.Tokenizer {
+			current[predicate.Predicate] = append(current[predicate.Predicate], ContentIndex(tokenizer))
+		}
+	}
+	if m.config.ContentIndexes == nil {
+		for _, predicate := range contentIndexPredicates {
+			indexes := make([]ContentIndex, 0, len(current[predicate]))
+			for _, index := range current[predicate] {
+				if _, ok := graphQLSearchTokens[index]; ok {
+					indexes = append(indexes, index)
+				}
+			}
+			sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+			m.contentIndexes[predicate] = indexes
+		}
+		return nil
+	}
+	for _, predicate := range contentIndexPredicates {
+		indexes := m.contentIndexes[predicate]
+		if sameContentIndexes(current[predicate], indexes) {
+			continue
+		}
+		log.Infof("Updating indexes of predicate: %v, from: %v to: %v", predicate, current[predicate], indexes)
+		err = m.dgraph.UpdateSchema(contentIndexSchema(predicate, indexes))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//regexpLiteral escapes the pattern to be enclosed in slashes as a DQL regular expression, escape sequences
+//are kept and slashes, quotes and line breaks are escaped so that the pattern can not end the expression
+func regexpLiteral(pattern string) string {
+	var literal strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '\\':
+			if i+1 == len(pattern) {
+				literal.WriteString(`\\`)
+				continue
+			}
+			i++
+			literal.WriteByte(c)
+			c = pattern[i]
+			switch c {
+			case '\n':
+				literal.WriteByte('n')
+			case '\r':
+				literal.WriteByte('r')
+			default:
+				literal.WriteByte(c)
+			}
+		case '/', '"':
+			literal.WriteByte('\\')
+			literal.WriteByte(c)
+		case '\n':
+			literal.WriteString(`\n`)
+		case '\r':
+			literal.WriteString(`\r`)
+		default:
+			literal.WriteByte(c)
+		}
+	}
+	return literal.String()
+}
+
+func sameContentIndexes(a, b []ContentIndex) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, index := range a {
+		if !hasContentIndex(b, index) {
+			return false
+		}
+	}
+	return true
+}
+
+//ContentMatchMode how the content search text is matched against the content predicate
+type ContentMatchMode string
+
+//Content match modes, and the index each one requires
+const (
+	//MatchAllOfText all the words of the text, stemmed and without stop words, requires fulltext
+	MatchAllOfText ContentMatchMode = "alloftext"
+	//MatchAnyOfText any of the words of the text, stemmed and without stop words, requires fulltext
+	MatchAnyOfText ContentMatchMode = "anyoftext"
+	//MatchPhrase the text as a phrase, case insensitive, requires fulltext
+	MatchPhrase ContentMatchMode = "phrase"
+	//MatchTerms all the terms of the text, requires term
+	MatchTerms ContentMatchMode = "terms"
+	//MatchExact the whole value, requires exact
+	MatchExact ContentMatchMode = "exact"
+	//MatchRegexp the text as a regular expression, requires trigram
+	MatchRegexp ContentMatchMode = "regexp"
+	//MatchFuzzy values within the levenshtein distance of the text, requires trigram
+	MatchFuzzy ContentMatchMode = "fuzzy"
+)
+
+//contentMatchIndexes index required by each match mode
+var contentMatchIndexes = map[ContentMatchMode]ContentIndex{
+	MatchAllOfText: IndexFulltext,
+	MatchAnyOfText: IndexFulltext,
+	MatchPhrase:    IndexFulltext,
+	MatchTerms:     IndexTerm,
+	MatchExact:     IndexExact,
+	MatchRegexp:    IndexTrigram,
+	MatchFuzzy:     IndexTrigram,
+}
+
+//DefaultFuzzyDistance levenshtein distance of the fuzzy match when not specified
+const DefaultFuzzyDistance = 2
+
+//MaxFuzzyDistance maximum levenshtein distance of the fuzzy match
+const MaxFuzzyDistance = 10
+
+//Default highlight markers
+const (
+	DefaultHighlightPre  = "<em>"
+	DefaultHighlightPost = "</em>"
+)
+
+//ContentSearchQuery finds the documents with contents that match the text
+type ContentSearchQuery struct {
+	Text string
+	//Match defaults to MatchAllOfText
+	Match ContentMatchMode
+	//Predicate content predicate to match, label or value, defaults to value
+	Predicate string
+	//Labels only match contents with these labels
+	Labels []string
+	//CaseInsensitive applies to the regexp match
+	CaseInsensitive bool
+	//Distance levenshtein distance of the fuzzy match, defaults to DefaultFuzzyDistance
+	Distance int
+	//HighlightPre and HighlightPost wrap the matches in the highlighted value, default to <em> and </em>
+	HighlightPre  string
+	HighlightPost string
+	First         int
+	//After cursor returned by the previous page
+	After string
+	//RequestConfig fields to return for each document
+	RequestConfig *RequestConfig
+}
+
+func (m *ContentSearchQuery) String() string {
+	return fmt.Sprintf("ContentSearchQuery{Text: %v, Match: %v, Predicate: %v, Labels: %v, CaseInsensitive: %v, Distance: %v, First: %v, After: %v}", m.Text, m.Match, m.Predicate, m.Labels, m.CaseInsensitive, m.Distance, m.First, m.After)
+}
+
+//ContentMatch content that matched the text, Highlighted is the matched predicate value
+//with the matches wrapped in the highlight markers
+type ContentMatch struct {
+	ContentGroupSequence int    `json:"content_group_sequence"`
+	ContentSequence      int    `json:"content_sequence"`
+	Label                string `json:"label"`
+	Value                string `json:"value"`
+	Highlighted          string `json:"highlighted"`
+}
+
+func (m *ContentMatch) String() string {
+	return fmt.Sprintf("ContentMatch{ContentGroupSequence: %v, ContentSequence: %v, Label: %v, Value: %v, Highlighted: %v}", m.ContentGroupSequence, m.ContentSequence, m.Label, m.Value, m.Highlighted)
+}
+
+//ContentSearchHit document with the contents that matched
+type ContentSearchHit struct {
+	Document *Document       `json:"document"`
+	Matches  []*ContentMatch `json:"matches"`
+}
+
+//ContentSearchResult page of hits ordered by document hash, Cursor is empty if there are no more pages
+type ContentSearchResult struct {
+	Hits   []*ContentSearchHit `json:"hits"`
+	Cursor string              `json:"cursor,omitempty"`
+}
+
+//contentMatchesKey alias under which the matched contents of a document are requested
+const contentMatchesKey = "content_matches"
+
+//SearchContents finds the documents with contents that match the text, phrase matches are refined
+//after the fulltext match so a page can have less hits than requested
+func (m *Doccache) SearchContents(q *ContentSearchQuery) (*ContentSearchResult, error) {
+	if strings.TrimSpace(q.Text) == "" {
+		return nil, invalidSearch("search text is required")
+	}
+	match := q.Match
+	if match == "" {
+		match = MatchAllOfText
+	}
+	requiredIndex, ok := contentMatchIndexes[match]
+	if !ok {
+		return nil, invalidSearch("invalid match mode: %v", match)
+	}
+	predicate := q.Predicate
+	if predicate == "" {
+		predicate = "value"
+	}
+	indexes, ok := m.contentIndexes[predicate]
+	if !ok {
+		return nil, invalidSearch("invalid content predicate: %v, searchable predicates: %v", predicate, contentIndexPredicates)
+	}
+	if !hasContentIndex(indexes, requiredIndex) {
+		return nil, invalidSearch("match mode: %v requires the %v index on predicate: %v", match, requiredIndex, predicate)
+	}
+	first := q.First
+	if first <= 0 {
+		first = DefaultSearchPageSize
+	}
+	if first > MaxSearchPageSize {
+		return nil, invalidSearch("page size: %v exceeds the maximum: %v", first, MaxSearchPageSize)
+	}
+	distance := q.Distance
+	if distance == 0 {
+		distance = DefaultFuzzyDistance
+	}
+	if distance < 0 || distance > MaxFuzzyDistance {
+		return nil, invalidSearch("fuzzy distance: %v must be between 1 and %v", distance, MaxFuzzyDistance)
+	}
+	var re *regexp.Regexp
+	if match == MatchRegexp {
+		pattern := q.Text
+		if q.CaseInsensitive {
+			pattern = "(?i)" + pattern
+		}
+		var err error
+		re, err = regexp.Compile(pattern)
+		if err != nil {
+			return nil, invalidSearch("invalid regular expression: %v", err)
+		}
+	}
+	rc := q.RequestConfig
+	if rc == nil {
+		rc = &RequestConfig{}
+	}
+	fields, err := requestFields(rc, MaxTraversalDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := map[string]string{"$text": q.Text}
+	declarations := []string{"$text: string"}
+	var function string
+	switch match {
+	case MatchAllOfText, MatchPhrase:
+		function = fmt.Sprintf("alloftext(%v, $text)", predicate)
+	case MatchAnyOfText:
+		function = fmt.Sprintf("anyoftext(%v, $text)", predicate)
+	case MatchTerms:
+		function = fmt.Sprintf("allofterms(%v, $text)", predicate)
+	case MatchExact:
+		function = fmt.Sprintf("eq(%v, $text)", predicate)
+	case MatchRegexp:
+		flags := ""
+		if q.CaseInsensitive {
+			flags = "i"
+		}
+		function = fmt.Sprintf("regexp(%v, /%v/%v)", predicate, regexpLiteral(q.Text), flags)
+	case MatchFuzzy:
+		function = fmt.Sprintf("match(%v, $text, %v)", predicate, distance)
+	}
+	labelFilters := make([]string, 0, len(q.Labels))
+	for i, label := range q.Labels {
+		name := fmt.Sprintf("$label%v", i)
+		vars[name] = label
+		declarations = append(declarations, name+": string")
+		labelFilters = append(labelFilters, fmt.Sprintf("eq(label, %v)", name))
+	}
+	labelFilter := ""
+	if len(labelFilters) > 0 {
+		labelFilter = fmt.Sprintf("@filter(%v)", strings.Join(labelFilters, " OR "))
+	}
+	afterFilter := ""
+	if q.After != "" {
+		cursor, err := decodeSearchCursor(q.After)
+		if err != nil {
+			return nil, err
+		}
+		vars["$after"] = cursor.Hash
+		declarations = append(declarations, "$after: string")
+		afterFilter = "@filter(gt(hash, $after))"
+	}
+	query := fmt.Sprintf(`
+		query search(%v){
+			matched as var(func: %v) %v {
+				~contents {
+					matchedDocs as ~content_groups
+				}
+			}
+			docs(func: uid(matchedDocs), first: %v, orderasc: hash) %v {
+				%v
+				%v: content_groups (orderasc: content_group_sequence) {
+					content_group_sequence
+					contents (orderasc: content_sequence) @filter(uid(matched)) {
+						content_sequence
+						label
+						value
+					}
+				}
+			}
+		}
+	`, strings.Join(declarations, ", "), function, labelFilter, first+1, afterFilter, fields, contentMatchesKey)
+	log.Debugf("Content search query: %v, vars: %v", query, vars)
+
+	response := &struct {
+		Docs []json.RawMessage `json:"docs"`
+	}{}
+	err = m.dgraph.Query(query, vars, response)
+	if err != nil {
+		return nil, err
+	}
+	result := &ContentSearchResult{
+		Hits: make([]*ContentSearchHit, 0, len(response.Docs)),
+	}
+	raws := response.Docs
+	if len(raws) > first {
+		raws = raws[:first]
+	}
+	highlighter := &highlighter{
+		match:    match,
+		text:     q.Text,
+		re:       re,
+		distance: distance,
+		pre:      q.HighlightPre,
+		post:     q.HighlightPost,
+	}
+	if highlighter.pre == "" && highlighter.post == "" {
+		highlighter.pre, highlighter.post = DefaultHighlightPre, DefaultHighlightPost
+	}
+	for _, raw := range raws {
+		hit, err := decodeContentSearchHit(raw, predicate, highlighter)
+		if err != nil {
+			return nil, err
+		}
+		if len(hit.Matches) > 0 {
+			result.Hits = append(result.Hits, hit)
+		}
+		if len(response.Docs) > first {
+			result.Cursor = encodeSearchCursor("", hit.Document.Hash)
+		}
+	}
+	return result, nil
+}
+
+func decodeContentSearchHit(raw json.RawMessage, predicate string, highlighter *highlighter) (*ContentSearchHit, error) {
+	doc := &Document{}
+	err := json.Unmarshal(raw, doc)
+	if err != nil {
+		return nil, err
+	}
+	matches := &struct {
+		ContentGroups []*ContentGroup `json:"content_matches"`
+	}{}
+	err = json.Unmarshal(raw, matches)
+	if err != nil {
+		return nil, err
+	}
+	hit := &ContentSearchHit{
+		Document: doc,
+		Matches:  make([]*ContentMatch, 0),
+	}
+	for _, contentGroup := range matches.ContentGroups {
+		for _, content := range contentGroup.Contents {
+			text := content.Value
+			if predicate == "label" {
+				text = content.Label
+			}
+			highlighted, ok := highlighter.highlight(text)
+			if !ok {
+				continue
+			}
+			hit.Matches = append(hit.Matches, &ContentMatch{
+				ContentGroupSequence: contentGroup.ContentGroupSequence,
+				ContentSequence:      content.ContentSequence,
+				Label:                content.Label,
+				Value:                content.Value,
+				Highlighted:          highlighted,
+			})
+		}
+	}
+	return hit, nil
+}
+
+//highlighter finds the parts of the matched values that correspond to the search text, the fulltext
+//stemming is approximated by matching words that share a prefix of at least 4 letters with a search word
+type highlighter struct {
+	match    ContentMatchMode
+	text     string
+	re       *regexp.Regexp
+	distance int
+	pre      string
+	post     string
+}
+
+//highlight returns the value with the matches wrapped in the markers, false if the value does not match,
+//which can only happen for phrase matches as the rest of the modes are matched by Dgraph
+func (m *highlighter) highlight(value string) (string, bool) {
+	var spans [][]int
+	switch m.match {
+	case MatchRegexp:
+		spans = m.re.FindAllStringIndex(value, -1)
+	case MatchPhrase:
+		spans = phraseSpans(value, m.text)
+		if len(spans) == 0 {
+			return value, false
+		}
+	case MatchExact:
+		spans = [][]int{{0, len(value)}}
+	case MatchFuzzy:
+		spans = wordSpans(value, m.text, func(word, searchWord string) bool {
+			return levenshtein(word, searchWord) <= m.distance
+		})
+		if len(spans) == 0 {
+			spans = [][]int{{0, len(value)}}
+		}
+	default:
+		spans = wordSpans(value, m.text, func(word, searchWord string) bool {
+			if word == searchWord {
+				return true
+			}
+			prefix := commonPrefixLength(word, searchWord)
+			return prefix >= 4 && (prefix == len(word) || prefix == len(searchWord) || prefix >= len(searchWord)-2)
+		})
+	}
+	var b strings.Builder
+	last := 0
+	for _, span := range spans {
+		if span[0] < last || span[0] == span[1] {
+			continue
+		}
+		b.WriteString(value[last:span[0]])
+		b.WriteString(m.pre)
+		b.WriteString(value[span[0]:span[1]])
+		b.WriteString(m.post)
+		last = span[1]
+	}
+	b.WriteString(value[last:])
+	return b.String(), true
+}
+
+//phraseSpans finds the case insensitive occurrences of the phrase
+func phraseSpans(value, phrase string) [][]int {
+	lower, lowerPhrase := strings.ToLower(value), strings.ToLower(strings.TrimSpace(phrase))
+	if len(lower) != len(value) || lowerPhrase == "" {
+		lower, lowerPhrase = value, strings.TrimSpace(phrase)
+	}
+	spans := make([][]int, 0)
+	for start := 0; start < len(lower); {
+		i := strings.Index(lower[start:], lowerPhrase)
+		if i < 0 {
+			break
+		}
+		spans = append(spans, []int{start + i, start + i + len(lowerPhrase)})
+		start += i + len(lowerPhrase)
+	}
+	return spans
+}
+
+//wordSpans finds the words of the value that match any of the words of the text
+func wordSpans(value, text string, matches func(word, searchWord string) bool) [][]int {
+	searchWords := make([]string, 0)
+	for _, span := range words(text) {
+		searchWords = append(searchWords, strings.ToLower(text[span[0]:span[1]]))
+	}
+	spans := make([][]int, 0)
+	for _, span := range words(value) {
+		word := strings.ToLower(value[span[0]:span[1]])
+		for _, searchWord := range searchWords {
+			if matches(word, searchWord) {
+				spans = append(spans, span)
+				break
+			}
+		}
+	}
+	return spans
+}
+
+//words returns the byte spans of the sequences of letters and digits
+func words(text string) [][]int {
+	spans := make([][]int, 0)
+	start := -1
+	for i, r := range text {
+		isWordRune := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWordRune && start < 0 {
+			start = i
+		}
+		if !isWordRune && start >= 0 {
+			spans = append(spans, []int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, []int{start, len(text)})
+	}
+	return spans
+}
+
+func commonPrefixLength(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	i := 0
+	for i < len(ra) && i < len(rb) && ra[i] == rb[i] {
+		i++
+	}
+	return i
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, minInt(current[j-1]+1, previous[j-1]+cost))
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
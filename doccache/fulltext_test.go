@@ -0,0 +1,196 @@
+package doccache
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+)
+
+func TestParseContentIndexes(t *testing.T) {
+	contentIndexes, err := ParseContentIndexes("value=fulltext, trigram;label=exact")
+	if err != nil {
+		t.Fatalf("ParseContentIndexes failed: %v", err)
+	}
+	if fmt.Sprint(contentIndexes["value"]) != "[fulltext term trigram]" || fmt.Sprint(contentIndexes["label"]) != "[exact term]" {
+		t.Fatalf("Expected term to be added to the configured indexes, found: %v", contentIndexes)
+	}
+	contentIndexes, err = ParseContentIndexes("")
+	if err != nil || fmt.Sprint(contentIndexes) != fmt.Sprint(DefaultContentIndexes()) {
+		t.Fatalf("Expected default content indexes, found: %v, error: %v", contentIndexes, err)
+	}
+	for _, policies := range []string{"value", "value=hash", "type=fulltext"} {
+		_, err = ParseContentIndexes(policies)
+		if err == nil {
+			t.Fatalf("Expected error for content index policies: %v", policies)
+		}
+	}
+	if schema := contentIndexSchema("value", []ContentIndex{IndexFulltext, IndexTerm}); schema != "value: string @index(fulltext, term) ." {
+		t.Fatalf("Unexpected content index schema: %v", schema)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		highlighter *highlighter
+		value       string
+		expected    string
+		matches     bool
+	}{
+		{&highlighter{match: MatchAllOfText, text: "running proposals"}, "The DAO is Running a proposal", "The DAO is <em>Running</em> a <em>proposal</em>", true},
+		{&highlighter{match: MatchPhrase, text: "treasury report"}, "Monthly Treasury Report and treasury reports", "Monthly <em>Treasury Report</em> and <em>treasury report</em>s", true},
+		{&highlighter{match: MatchPhrase, text: "treasury report"}, "report of the treasury", "report of the treasury", false},
+		{&highlighter{match: MatchRegexp, re: regexp.MustCompile("(?i)ha?ypha")}, "Hypha and hyphae", "<em>Hypha</em> and <em>hypha</em>e", true},
+		{&highlighter{match: MatchFuzzy, text: "recieve", distance: 2}, "receive the payout", "<em>receive</em> the payout", true},
+		{&highlighter{match: MatchExact, text: "member"}, "member", "<em>member</em>", true},
+	}
+	for _, test := range tests {
+		test.highlighter.pre, test.highlighter.post = DefaultHighlightPre, DefaultHighlightPost
+		highlighted, matches := test.highlighter.highlight(test.value)
+		if highlighted != test.expected || matches != test.matches {
+			t.Fatalf("Expected: %v, matches: %v for: %v, found: %v, matches: %v", test.expected, test.matches, test.value, highlighted, matches)
+		}
+	}
+	if distance := levenshtein("kitten", "sitting"); distance != 3 {
+		t.Fatalf("Expected levenshtein distance of 3, found: %v", distance)
+	}
+}
+
+func TestSearchContents(t *testing.T) {
+	cache, err := NewWithConfig(dg, &Config{
+		ContentIndexes: map[string][]ContentIndex{
+			"value": {IndexFulltext, IndexTrigram, IndexExact},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewWithConfig failed: %v", err)
+	}
+	hashes := []string{
+		"b1f0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c30",
+		"b2f0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c31",
+		"b3f0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c32",
+	}
+	descriptions := []string{
+		"The quarterly treasury report covers the **liquidity** of the DAO",
+		"Members receive voice tokens after the treasury reports are approved",
+		"Unrelated proposal about onboarding",
+	}
+	for i, hash := range hashes {
+		err = cache.StoreDocument(&ChainDocument{
+			Hash:        hash,
+			CreatedDate: fmt.Sprintf("2021-05-1%vT10:00:00", i),
+			Creator:     "fulltext",
+			ContentGroups: [][]*ChainContent{
+				{
+					{
+						Label: "content_group_label",
+						Value: []interface{}{"string", "details"},
+					},
+					{
+						Label: "description",
+						Value: []interface{}{"string", descriptions[i]},
+					},
+				},
+			},
+		}, fmt.Sprintf("fulltext%v", i))
+		if err != nil {
+			t.Fatalf("StoreDocument failed: %v", err)
+		}
+	}
+
+	result, err := cache.SearchContents(&ContentSearchQuery{Text: "treasury reports", Labels: []string{"description"}, First: 1})
+	if err != nil {
+		t.Fatalf("SearchContents failed: %v", err)
+	}
+	if len(result.Hits) != 1 || result.Hits[0].Document.Hash != hashes[0] || result.Cursor == "" {
+		t.Fatalf("Expected first page with document: %v, found: %v", hashes[0], result)
+	}
+	if match := result.Hits[0].Matches[0]; match.Label != "description" || match.Highlighted != "The quarterly <em>treasury</em> <em>report</em> covers the **liquidity** of the DAO" {
+		t.Fatalf("Unexpected highlighted match: %v", match)
+	}
+	result, err = cache.SearchContents(&ContentSearchQuery{Text: "treasury reports", Labels: []string{"description"}, First: 1, After: result.Cursor})
+	if err != nil {
+		t.Fatalf("SearchContents failed: %v", err)
+	}
+	if len(result.Hits) != 1 || result.Hits[0].Document.Hash != hashes[1] || result.Cursor != "" {
+		t.Fatalf("Expected last page with document: %v, found: %v", hashes[1], result)
+	}
+
+	result, err = cache.SearchContents(&ContentSearchQuery{Text: "treasury reports are", Match: MatchPhrase, HighlightPre: "[", HighlightPost: "]"})
+	if err != nil {
+		t.Fatalf("SearchContents failed: %v", err)
+	}
+	if len(result.Hits) != 1 || result.Hits[0].Matches[0].Highlighted != "Members receive voice tokens after the [treasury reports are] approved" {
+		t.Fatalf("Expected phrase match in document: %v, found: %v", hashes[1], result)
+	}
+	result, err = cache.SearchContents(&ContentSearchQuery{Text: `\*\*liquid[a-z]+\*\*`, Match: MatchRegexp, RequestConfig: &RequestConfig{ContentGroups: true}})
+	if err != nil {
+		t.Fatalf("SearchContents failed: %v", err)
+	}
+	if len(result.Hits) != 1 || result.Hits[0].Document.Hash != hashes[0] || len(result.Hits[0].Document.ContentGroups) != 1 {
+		t.Fatalf("Expected regexp match in document: %v with content groups, found: %v", hashes[0], result)
+	}
+	result, err = cache.SearchContents(&ContentSearchQuery{Text: "Unrelated proposal about onbaording", Match: MatchFuzzy})
+	if err != nil {
+		t.Fatalf("SearchContents failed: %v", err)
+	}
+	if len(result.Hits) != 1 || result.Hits[0].Document.Hash != hashes[2] {
+		t.Fatalf("Expected fuzzy match in document: %v, found: %v", hashes[2], result)
+	}
+
+	invalid := []*ContentSearchQuery{
+		{Text: ""},
+		{Text: "treasury", Match: "soundex"},
+		{Text: "treasury", Predicate: "type"},
+		{Text: "treasury", Predicate: "label"},
+		{Text: "(", Match: MatchRegexp},
+		{Text: "treasury", Match: MatchFuzzy, Distance: MaxFuzzyDistance + 1},
+	}
+	for _, q := range invalid {
+		_, err = cache.SearchContents(q)
+		if _, ok := err.(*InvalidSearchError); !ok {
+			t.Fatalf("Expected invalid search error for: %v, found: %v", q, err)
+		}
+	}
+
+	t.Log("Content indexes are kept when not configured")
+	cache, err = New(dg, nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	result, err = cache.SearchContents(&ContentSearchQuery{Text: "treasury reports", Match: MatchAnyOfText, Labels: []string{"description"}})
+	if err != nil {
+		t.Fatalf("Expected the fulltext index to be kept, found: %v", err)
+	}
+	if len(result.Hits) != 2 {
+		t.Fatalf("Expected 2 documents matching any of the text, found: %v", result)
+	}
+}
+
+func TestRegexpLiteral(t *testing.T) {
+	tests := map[string]string{
+		`liquid[a-z]+`: `liquid[a-z]+`,
+		`a/b`:          `a\/b`,
+		`a\/b`:         `a\/b`,
+		`\\/) } x(func: has(webhook_secret)) { webhook_secret } y(func: regexp(value, /`: `\\\/) } x(func: has(webhook_secret)) { webhook_secret } y(func: regexp(value, \/`,
+		`say "hi"`:    `say \"hi\"`,
+		"line\nbreak": `line\nbreak`,
+		`trailing\`:   `trailing\\`,
+	}
+	for pattern, expected := range tests {
+		literal := regexpLiteral(pattern)
+		if literal != expected {
+			t.Fatalf("Expected literal for: %v to be: %v, found: %v", pattern, expected, literal)
+		}
+		unescaped := 0
+		for i := 0; i < len(literal); i++ {
+			if literal[i] == '\\' {
+				i++
+			} else if literal[i] == '/' || literal[i] == '"' {
+				unescaped++
+			}
+		}
+		if unescaped > 0 {
+			t.Fatalf("Expected no unescaped slashes or quotes in literal: %v", literal)
+		}
+	}
+}
@@ -0,0 +1,419 @@
+package doccache
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+//DefaultSubgraphDepth number of edges from the root that are traversed when the depth is not specified
+const DefaultSubgraphDepth = 2
+
+//MaxSubgraphDepth maximum number of edges from the root that can be traversed
+const MaxSubgraphDepth = 6
+
+//MaxSubgraphNodes maximum number of documents in a subgraph, the traversal stops when it is reached
+const MaxSubgraphNodes = 2000
+
+//GraphFormat format a subgraph can be written in
+type GraphFormat string
+
+const (
+	//GraphDOT Graphviz DOT
+	GraphDOT GraphFormat = "dot"
+	//GraphML GraphML XML
+	GraphML GraphFormat = "graphml"
+)
+
+//ContentType returns the media type of the format
+func (m GraphFormat) ContentType() string {
+	if m == GraphML {
+		return "application/graphml+xml"
+	}
+	return "text/vnd.graphviz"
+}
+
+//ParseGraphFormat validates the graph format, DOT is used if empty
+func ParseGraphFormat(format string) (GraphFormat, error) {
+	switch GraphFormat(format) {
+	case "":
+		return GraphDOT, nil
+	case GraphDOT, GraphML:
+		return GraphFormat(format), nil
+	}
+	return "", invalidRequest("invalid graph format: %v, expected %v or %v", format, GraphDOT, GraphML)
+}
+
+//SubgraphQuery selects the documents around a root document
+type SubgraphQuery struct {
+	Root string
+	//Depth number of edges from the root that are traversed, defaults to DefaultSubgraphDepth
+	Depth int
+	//Edges names of the edges that are traversed, all edges if empty
+	Edges []string
+	//Undirected also traverses the reverse indexed edges from their target to their source
+	Undirected bool
+}
+
+func (m *SubgraphQuery) String() string {
+	return fmt.Sprintf("SubgraphQuery{Root: %v, Depth: %v, Edges: %v, Undirected: %v}", m.Root, m.Depth, m.Edges, m.Undirected)
+}
+
+//SubgraphNode document in a subgraph, Depth is the number of edges between it and the root
+type SubgraphNode struct {
+	Hash      string `json:"hash"`
+	NodeLabel string `json:"node_label,omitempty"`
+	DocType   string `json:"doc_type,omitempty"`
+	Depth     int    `json:"depth"`
+}
+
+//DisplayLabel returns the node label, or the document type and the start of the hash if the document has no label
+func (m *SubgraphNode) DisplayLabel() string {
+	if m.NodeLabel != "" {
+		return m.NodeLabel
+	}
+	hash := m.Hash
+	if len(hash) > 8 {
+		hash = hash[:8]
+	}
+	return strings.TrimSpace(m.DocType + " " + hash)
+}
+
+func (m *SubgraphNode) String() string {
+	return fmt.Sprintf("SubgraphNode{Hash: %v, NodeLabel: %v, DocType: %v, Depth: %v}", m.Hash, m.NodeLabel, m.DocType, m.Depth)
+}
+
+//SubgraphEdge chain edge between two documents of a subgraph
+type SubgraphEdge struct {
+	Name string `json:"name"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+func (m *SubgraphEdge) String() string {
+	return fmt.Sprintf("SubgraphEdge{Name: %v, From: %v, To: %v}", m.Name, m.From, m.To)
+}
+
+//Subgraph documents within the depth of the root and the edges traversed to reach them,
+//Truncated indicates that MaxSubgraphNodes was reached
+type Subgraph struct {
+	Root      string          `json:"root"`
+	Nodes     []*SubgraphNode `json:"nodes"`
+	Edges     []*SubgraphEdge `json:"edges"`
+	Truncated bool            `json:"truncated"`
+}
+
+func (m *Subgraph) String() string {
+	return fmt.Sprintf("Subgraph{Root: %v, Nodes: %v, Edges: %v, Truncated: %v}", m.Root, m.Nodes, m.Edges, m.Truncated)
+}
+
+//subgraphDoc document fields requested for the nodes of a subgraph
+type subgraphDoc struct {
+	UID       string `json:"uid"`
+	Hash      string `json:"hash"`
+	NodeLabel string `json:"node_label"`
+	DocType   string `json:"doc_type"`
+}
+
+//subgraphTraversal edge traversed from the frontier, requested under its alias
+type subgraphTraversal struct {
+	alias     string
+	edgeName  string
+	predicate string
+	inbound   bool
+}
+
+//Subgraph traverses the edges breadth first from the root up to the depth, the edges between documents
+//at the maximum depth are not included
+func (m *Doccache) Subgraph(q *SubgraphQuery) (*Subgraph, error) {
+	depth := q.Depth
+	if depth == 0 {
+		depth = DefaultSubgraphDepth
+	}
+	if depth < 0 || depth > MaxSubgraphDepth {
+		return nil, invalidRequest("subgraph depth: %v must be between 1 and %v", depth, MaxSubgraphDepth)
+	}
+	edgeNames := q.Edges
+	if len(edgeNames) == 0 {
+		edgeNames = m.EdgeNames()
+	}
+	traversals := make([]*subgraphTraversal, 0, len(edgeNames)*2)
+	for i, edgeName := range edgeNames {
+		edge := m.GetEdgeDefinition(edgeName)
+		if edge == nil {
+			return nil, invalidRequest("unknown edge: %v", edgeName)
+		}
+		traversals = append(traversals, &subgraphTraversal{alias: fmt.Sprintf("out%v", i), edgeName: edgeName, predicate: edge.Predicate})
+		if q.Undirected && edge.Reverse {
+			traversals = append(traversals, &subgraphTraversal{alias: fmt.Sprintf("in%v", i), edgeName: edgeName, predicate: "~" + edge.Predicate, inbound: true})
+		}
+	}
+	if !IsValidHash(q.Root) {
+		return nil, invalidRequest("invalid root hash: %v, expected 64 hex characters", q.Root)
+	}
+	root := NormalizeHash(q.Root)
+	hashUIDMap, err := m.GetHashUIDMap([]string{root})
+	if err != nil {
+		return nil, err
+	}
+	rootUID, ok := hashUIDMap[root]
+	if !ok {
+		return nil, &DocumentNotFoundError{Hash: root}
+	}
+
+	subgraph := &Subgraph{
+		Root:  root,
+		Nodes: make([]*SubgraphNode, 0),
+		Edges: make([]*SubgraphEdge, 0),
+	}
+	nodes := make(map[string]*SubgraphNode)
+	edges := make(map[string]bool)
+	frontier := []string{rootUID}
+	//the root is at depth 0, it is added when its fields are loaded with the first level
+	for level := 0; level < depth && len(frontier) > 0; level++ {
+		docs, err := m.subgraphLevel(frontier, traversals)
+		if err != nil {
+			return nil, err
+		}
+		next := make([]string, 0)
+		addNode := func(doc *subgraphDoc, nodeDepth int) bool {
+			if _, ok := nodes[doc.UID]; ok {
+				return true
+			}
+			if len(nodes) >= MaxSubgraphNodes {
+				subgraph.Truncated = true
+				return false
+			}
+			node := &SubgraphNode{Hash: doc.Hash, NodeLabel: doc.NodeLabel, DocType: doc.DocType, Depth: nodeDepth}
+			nodes[doc.UID] = node
+			subgraph.Nodes = append(subgraph.Nodes, node)
+			next = append(next, doc.UID)
+			return true
+		}
+		for _, doc := range docs {
+			if level == 0 {
+				nodes[doc.UID] = &SubgraphNode{Hash: doc.Hash, NodeLabel: doc.NodeLabel, DocType: doc.DocType}
+				subgraph.Nodes = append(subgraph.Nodes, nodes[doc.UID])
+			}
+			for _, traversal := range traversals {
+				for _, neighbour := range doc.neighbours[traversal.alias] {
+					if !addNode(neighbour, level+1) {
+						continue
+					}
+					edge := &SubgraphEdge{Name: traversal.edgeName, From: doc.Hash, To: neighbour.Hash}
+					if traversal.inbound {
+						edge.From, edge.To = neighbour.Hash, doc.Hash
+					}
+					key := edge.Name + " " + edge.From + " " + edge.To
+					if !edges[key] {
+						edges[key] = true
+						subgraph.Edges = append(subgraph.Edges, edge)
+					}
+				}
+			}
+		}
+		frontier = next
+	}
+	return subgraph, nil
+}
+
+//subgraphLevelDoc document of the frontier with its neighbours by traversal alias
+type subgraphLevelDoc struct {
+	*subgraphDoc
+	neighbours map[string][]*subgraphDoc
+}
+
+//subgraphLevel loads the documents of the frontier and their neighbours
+func (m *Doccache) subgraphLevel(uids []string, traversals []*subgraphTraversal) ([]*subgraphLevelDoc, error) {
+	fields := `
+		uid
+		hash
+		node_label
+		doc_type
+	`
+	edgeRequest := ""
+	for _, traversal := range traversals {
+		edgeRequest += fmt.Sprintf(`
+			%v: <%v> @filter(type(Document)) {
+				%v
+			}
+		`, traversal.alias, traversal.predicate, fields)
+	}
+	query := fmt.Sprintf(`
+		{
+			docs(func: uid(%v)) @filter(type(Document)) {
+				%v
+				%v
+			}
+		}
+	`, strings.Join(uids, ","), fields, edgeRequest)
+	response := &struct {
+		Docs []map[string]json.RawMessage `json:"docs"`
+	}{}
+	err := m.dgraph.Query(query, nil, response)
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]*subgraphLevelDoc, 0, len(response.Docs))
+	for _, raw := range response.Docs {
+		doc := &subgraphLevelDoc{
+			subgraphDoc: &subgraphDoc{},
+			neighbours:  make(map[string][]*subgraphDoc),
+		}
+		for key, value := range raw {
+			var err error
+			switch key {
+			case "uid":
+				err = json.Unmarshal(value, &doc.UID)
+			case "hash":
+				err = json.Unmarshal(value, &doc.Hash)
+			case "node_label":
+				err = json.Unmarshal(value, &doc.NodeLabel)
+			case "doc_type":
+				err = json.Unmarshal(value, &doc.DocType)
+			default:
+				var neighbours []*subgraphDoc
+				err = json.Unmarshal(value, &neighbours)
+				doc.neighbours[key] = neighbours
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode subgraph field: %v, error: %v", key, err)
+			}
+		}
+		docs = append(docs, doc)
+	}
+	//uid order keeps the output stable, the response order of uid() is not guaranteed
+	sort.Slice(docs, func(i, j int) bool {
+		return uidLess(docs[i].UID, docs[j].UID)
+	})
+	return docs, nil
+}
+
+//WriteGraph writes the subgraph in the format
+func WriteGraph(w io.Writer, subgraph *Subgraph, format GraphFormat) error {
+	switch format {
+	case GraphDOT:
+		return WriteDOT(w, subgraph)
+	case GraphML:
+		return WriteGraphML(w, subgraph)
+	}
+	return fmt.Errorf("invalid graph format: %v", format)
+}
+
+//WriteDOT writes the subgraph as a Graphviz digraph, nodes are identified by hash and labelled with their
+//display label, edges are labelled with the chain edge name, the root is drawn bold
+func WriteDOT(w io.Writer, subgraph *Subgraph) error {
+	var dot strings.Builder
+	dot.WriteString("digraph doccache {\n")
+	dot.WriteString("\tnode [shape=box];\n")
+	for _, node := range subgraph.Nodes {
+		attributes := fmt.Sprintf("label=%v, tooltip=%v", dotQuote(node.DisplayLabel()), dotQuote(node.DocType+" "+node.Hash))
+		if node.Hash == subgraph.Root {
+			attributes += ", style=bold"
+		}
+		fmt.Fprintf(&dot, "\t%v [%v];\n", dotQuote(node.Hash), attributes)
+	}
+	for _, edge := range subgraph.Edges {
+		fmt.Fprintf(&dot, "\t%v -> %v [label=%v];\n", dotQuote(edge.From), dotQuote(edge.To), dotQuote(edge.Name))
+	}
+	dot.WriteString("}\n")
+	_, err := io.WriteString(w, dot.String())
+	return err
+}
+
+func dotQuote(value string) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	value = strings.Replace(value, `"`, `\"`, -1)
+	value = strings.Replace(value, "\n", `\n`, -1)
+	return `"` + value + `"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLNode struct {
+	ID   string         `xml:"id,attr"`
+	Data []*graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string         `xml:"source,attr"`
+	Target string         `xml:"target,attr"`
+	Data   []*graphMLData `xml:"data"`
+}
+
+type graphMLGraph struct {
+	ID          string         `xml:"id,attr"`
+	EdgeDefault string         `xml:"edgedefault,attr"`
+	Nodes       []*graphMLNode `xml:"node"`
+	Edges       []*graphMLEdge `xml:"edge"`
+}
+
+type graphMLDocument struct {
+	XMLName xml.Name      `xml:"graphml"`
+	XMLNS   string        `xml:"xmlns,attr"`
+	Keys    []*graphMLKey `xml:"key"`
+	Graph   *graphMLGraph `xml:"graph"`
+}
+
+//WriteGraphML writes the subgraph as a directed GraphML graph, nodes are identified by hash and have
+//label, doc_type and depth data, edges have label data with the chain edge name
+func WriteGraphML(w io.Writer, subgraph *Subgraph) error {
+	graph := &graphMLGraph{
+		ID:          "doccache",
+		EdgeDefault: "directed",
+		Nodes:       make([]*graphMLNode, 0, len(subgraph.Nodes)),
+		Edges:       make([]*graphMLEdge, 0, len(subgraph.Edges)),
+	}
+	for _, node := range subgraph.Nodes {
+		graph.Nodes = append(graph.Nodes, &graphMLNode{
+			ID: node.Hash,
+			Data: []*graphMLData{
+				{Key: "label", Value: node.DisplayLabel()},
+				{Key: "doc_type", Value: node.DocType},
+				{Key: "depth", Value: fmt.Sprintf("%v", node.Depth)},
+			},
+		})
+	}
+	for _, edge := range subgraph.Edges {
+		graph.Edges = append(graph.Edges, &graphMLEdge{
+			Source: edge.From,
+			Target: edge.To,
+			Data:   []*graphMLData{{Key: "edge_label", Value: edge.Name}},
+		})
+	}
+	document := &graphMLDocument{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []*graphMLKey{
+			{ID: "label", For: "node", AttrName: "label", AttrType: "string"},
+			{ID: "doc_type", For: "node", AttrName: "doc_type", AttrType: "string"},
+			{ID: "depth", For: "node", AttrName: "depth", AttrType: "int"},
+			{ID: "edge_label", For: "edge", AttrName: "label", AttrType: "string"},
+		},
+		Graph: graph,
+	}
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	err = encoder.Encode(document)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
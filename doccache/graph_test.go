@@ -0,0 +1,159 @@
+package doccache
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func testSubgraph() *Subgraph {
+	return &Subgraph{
+		Root: "aa01",
+		Nodes: []*SubgraphNode{
+			{Hash: "aa01", NodeLabel: `DAO "hypha"`, DocType: "dho"},
+			{Hash: "bb0102030405", DocType: "member", Depth: 1},
+		},
+		Edges: []*SubgraphEdge{
+			{Name: "member", From: "aa01", To: "bb0102030405"},
+		},
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	var buffer bytes.Buffer
+	err := WriteGraph(&buffer, testSubgraph(), GraphDOT)
+	if err != nil {
+		t.Fatalf("WriteGraph failed: %v", err)
+	}
+	expected := "digraph doccache {\n" +
+		"\tnode [shape=box];\n" +
+		"\t\"aa01\" [label=\"DAO \\\"hypha\\\"\", tooltip=\"dho aa01\", style=bold];\n" +
+		"\t\"bb0102030405\" [label=\"member bb010203\", tooltip=\"member bb0102030405\"];\n" +
+		"\t\"aa01\" -> \"bb0102030405\" [label=\"member\"];\n" +
+		"}\n"
+	if buffer.String() != expected {
+		t.Fatalf("Expected: %v, found: %v", expected, buffer.String())
+	}
+}
+
+func TestWriteGraphML(t *testing.T) {
+	var buffer bytes.Buffer
+	err := WriteGraph(&buffer, testSubgraph(), GraphML)
+	if err != nil {
+		t.Fatalf("WriteGraph failed: %v", err)
+	}
+	if !strings.HasPrefix(buffer.String(), xml.Header) {
+		t.Fatalf("Expected xml header, found: %v", buffer.String())
+	}
+	document := &graphMLDocument{}
+	err = xml.Unmarshal(buffer.Bytes(), document)
+	if err != nil {
+		t.Fatalf("Failed to parse graphml: %v", err)
+	}
+	graph := document.Graph
+	if len(document.Keys) != 4 || graph.EdgeDefault != "directed" || len(graph.Nodes) != 2 || len(graph.Edges) != 1 {
+		t.Fatalf("Expected directed graph with 2 nodes and 1 edge, found: %v", buffer.String())
+	}
+	if graph.Nodes[0].ID != "aa01" || graph.Nodes[0].Data[0].Value != `DAO "hypha"` || graph.Nodes[1].Data[0].Value != "member bb010203" {
+		t.Fatalf("Expected labelled nodes, found: %v", buffer.String())
+	}
+	edge := graph.Edges[0]
+	if edge.Source != "aa01" || edge.Target != "bb0102030405" || edge.Data[0].Key != "edge_label" || edge.Data[0].Value != "member" {
+		t.Fatalf("Expected labelled edge, found: %v", buffer.String())
+	}
+}
+
+func TestParseGraphFormat(t *testing.T) {
+	for format, expected := range map[string]GraphFormat{"": GraphDOT, "dot": GraphDOT, "graphml": GraphML} {
+		parsed, err := ParseGraphFormat(format)
+		if err != nil || parsed != expected {
+			t.Fatalf("Expected: %v for: %v, found: %v, error: %v", expected, format, parsed, err)
+		}
+	}
+	_, err := ParseGraphFormat("png")
+	if _, ok := err.(*InvalidRequestError); !ok {
+		t.Fatalf("Expected invalid request error, found: %v", err)
+	}
+}
+
+func TestSubgraph(t *testing.T) {
+	hashes := []string{
+		"c1f0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c50",
+		"c2f0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c51",
+		"c3f0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c52",
+		"c4f0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c53",
+	}
+	for i, hash := range hashes {
+		err := doccache.StoreDocument(&ChainDocument{
+			Hash:        hash,
+			CreatedDate: "2021-06-01T10:00:00.000",
+			Creator:     "grapher",
+			ContentGroups: [][]*ChainContent{
+				{
+					{Label: "content_group_label", Value: []interface{}{"string", "system"}},
+					{Label: "type", Value: []interface{}{"name", "graphdoc"}},
+					{Label: "node_label", Value: []interface{}{"string", fmt.Sprintf("node %v", i)}},
+				},
+			},
+		}, fmt.Sprintf("graph%v", i))
+		if err != nil {
+			t.Fatalf("StoreDocument failed: %v", err)
+		}
+	}
+	edges := []*ChainEdge{
+		{Name: "gowns", From: hashes[0], To: hashes[1]},
+		{Name: "gmember", From: hashes[1], To: hashes[2]},
+		{Name: "gowns", From: hashes[3], To: hashes[0]},
+	}
+	for i, edge := range edges {
+		err := doccache.MutateEdge(edge, false, fmt.Sprintf("graphedge%v", i))
+		if err != nil {
+			t.Fatalf("MutateEdge failed: %v", err)
+		}
+	}
+
+	subgraph, err := doccache.Subgraph(&SubgraphQuery{Root: hashes[0], Edges: []string{"gowns", "gmember"}})
+	if err != nil {
+		t.Fatalf("Subgraph failed: %v", err)
+	}
+	if len(subgraph.Nodes) != 3 || len(subgraph.Edges) != 2 || subgraph.Truncated {
+		t.Fatalf("Expected 3 nodes and 2 edges, found: %v", subgraph)
+	}
+	if subgraph.Nodes[0].Hash != hashes[0] || subgraph.Nodes[0].NodeLabel != "node 0" || subgraph.Nodes[0].DocType != "graphdoc" || subgraph.Nodes[2].Depth != 2 {
+		t.Fatalf("Expected root first and nodes with labels and depth, found: %v", subgraph.Nodes)
+	}
+	if subgraph.Edges[1].Name != "gmember" || subgraph.Edges[1].From != hashes[1] || subgraph.Edges[1].To != hashes[2] {
+		t.Fatalf("Expected gmember edge, found: %v", subgraph.Edges)
+	}
+
+	subgraph, err = doccache.Subgraph(&SubgraphQuery{Root: hashes[0], Depth: 1, Edges: []string{"gowns"}, Undirected: true})
+	if err != nil {
+		t.Fatalf("Subgraph failed: %v", err)
+	}
+	if len(subgraph.Nodes) != 3 || len(subgraph.Edges) != 2 {
+		t.Fatalf("Expected 3 nodes and 2 edges, found: %v", subgraph)
+	}
+	for _, edge := range subgraph.Edges {
+		if edge.To == hashes[0] && edge.From != hashes[3] {
+			t.Fatalf("Expected inbound edge to keep its direction, found: %v", edge)
+		}
+	}
+
+	_, err = doccache.Subgraph(&SubgraphQuery{Root: strings.Repeat("a", 64)})
+	if _, ok := err.(*DocumentNotFoundError); !ok {
+		t.Fatalf("Expected document not found error, found: %v", err)
+	}
+	for _, q := range []*SubgraphQuery{
+		{Root: "aaaa"},
+		{Root: `a"]) OR has(hash`},
+		{Root: hashes[0], Edges: []string{"unknown"}},
+		{Root: hashes[0], Depth: MaxSubgraphDepth + 1},
+	} {
+		_, err = doccache.Subgraph(q)
+		if _, ok := err.(*InvalidRequestError); !ok {
+			t.Fatalf("Expected invalid request error for: %v, found: %v", q, err)
+		}
+	}
+}
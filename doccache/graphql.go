@@ -0,0 +1,286 @@
+package doccache
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sebastianmontero/dgraph-go-client/dgraph"
+)
+
+var invalidGraphQLChars = regexp.MustCompile(`[^_0-9A-Za-z]`)
+
+//documentGraphQLFields fields of the GraphQL Document type that edge fields can not use
+var documentGraphQLFields = map[string]bool{
+	"id":            true,
+	"uid":           true,
+	"hash":          true,
+	"creator":       true,
+	"createdDate":   true,
+	"docType":       true,
+	"nodeLabel":     true,
+	"ballotId":      true,
+	"types":         true,
+	"contentGroups": true,
+	"certificates":  true,
+	"inbound":       true,
+}
+
+//graphQLBaseSchema GraphQL types that map to the document predicates, the search directives
+//mirror the predicate indexes as Dgraph updates the predicates to match the GraphQL schema
+const graphQLBaseSchema = `type Document @dgraph(type: "Document") {
+  id: ID!
+  hash: String! @search(by: [exact]) @dgraph(pred: "hash")
+  creator: String @search(by: [term]) @dgraph(pred: "creator")
+  createdDate: DateTime @search(by: [hour]) @dgraph(pred: "created_date")
+  docType: String @search(by: [exact]) @dgraph(pred: "doc_type")
+  nodeLabel: String @search(by: [term]) @dgraph(pred: "node_label")
+  ballotId: String @search(by: [exact]) @dgraph(pred: "ballot_id")
+  contentGroups: [ContentGroup] @dgraph(pred: "content_groups")
+  certificates: [Certificate] @dgraph(pred: "certificates")
+%[1]v}
+
+type ContentGroup @dgraph(type: "ContentGroup") {
+  id: ID!
+  contentGroupSequence: Int @dgraph(pred: "content_group_sequence")
+  contents: [Content] @dgraph(pred: "contents")
+  document: [Document] @dgraph(pred: "~content_groups")
+}
+
+type Content @dgraph(type: "Content") {
+  id: ID!
+  label: String @search(by: [%[2]v]) @dgraph(pred: "label")
+  value: String @search(by: [%[3]v]) @dgraph(pred: "value")
+  type: String @search(by: [term]) @dgraph(pred: "type")
+  intValue: Int64 @search(by: [int64]) @dgraph(pred: "int_value")
+  timeValue: DateTime @search(by: [hour]) @dgraph(pred: "time_value")
+  assetAmount: Float @search(by: [float]) @dgraph(pred: "asset_amount")
+  assetSymbol: String @search(by: [exact]) @dgraph(pred: "asset_symbol")
+  assetPrecision: Int @dgraph(pred: "asset_precision")
+  nameValue: String @search(by: [exact]) @dgraph(pred: "name_value")
+  contentSequence: Int @dgraph(pred: "content_sequence")
+  document: [Document] @dgraph(pred: "document")
+  contentGroup: [ContentGroup] @dgraph(pred: "~contents")
+}
+
+type Certificate @dgraph(type: "Certificate") {
+  id: ID!
+  certifier: String @search(by: [term]) @dgraph(pred: "certifier")
+  notes: String @dgraph(pred: "notes")
+  certificationDate: DateTime @dgraph(pred: "certification_date")
+  certificationSequence: Int @dgraph(pred: "certification_sequence")
+}
+`
+
+//EdgeFieldName returns the name of the GraphQL Document field that resolves the edge
+func EdgeFieldName(edgeName string) string {
+	name := invalidGraphQLChars.ReplaceAllString(edgeName, "_")
+	if documentGraphQLFields[name] || (name[0] >= '0' && name[0] <= '9') || strings.HasPrefix(name, "__") {
+		name = "edge_" + name
+	}
+	return name
+}
+
+//InboundEdgeFieldName returns the name of the GraphQL Document field that resolves the inbound edge
+func InboundEdgeFieldName(edgeName string) string {
+	return "inbound_" + invalidGraphQLChars.ReplaceAllString(edgeName, "_")
+}
+
+//GraphQLSchema generates the Dgraph GraphQL schema for the document types and the registered edges,
+//reverse indexed edges get an inbound field
+func (m *Doccache) GraphQLSchema() string {
+	var edgeFields strings.Builder
+	used := make(map[string]string)
+	addField := func(edgeName, fieldName, predicate string) {
+		if other, ok := used[fieldName]; ok {
+			log.Warnf("Edge: %v not added to GraphQL schema, field: %v already used by edge: %v", edgeName, fieldName, other)
+			return
+		}
+		used[fieldName] = edgeName
+		fmt.Fprintf(&edgeFields, "  %v: [Document] @dgraph(pred: %q)\n", fieldName, predicate)
+	}
+	for _, edgeName := range m.EdgeNames() {
+		edge := m.GetEdgeDefinition(edgeName)
+		addField(edgeName, EdgeFieldName(edgeName), edge.Predicate)
+		if edge.Reverse {
+			addField(edgeName, InboundEdgeFieldName(edgeName), "~"+edge.Predicate)
+		}
+	}
+	return fmt.Sprintf(graphQLBaseSchema, edgeFields.String(), m.graphQLContentSearch("label"), m.graphQLContentSearch("value"))
+}
+
+//graphQLContentSearch returns the search arguments that create the configured indexes of the content predicate
+func (m *Doccache) graphQLContentSearch(predicate string) string {
+	indexes, ok := m.contentIndexes[predicate]
+	if !ok {
+		indexes = DefaultContentIndexes()[predicate]
+	}
+	search := make([]string, 0, len(indexes))
+	for _, index := range indexes {
+		search = append(search, graphQLSearchTokens[index])
+	}
+	return strings.Join(search, ", ")
+}
+
+//SyncGraphQLSchema uploads the GraphQL schema to Dgraph if it changed since the last upload,
+//does nothing if the admin url is not configured
+func (m *Doccache) SyncGraphQLSchema() error {
+	if m.config.GraphQLAdminURL == "" {
+		return nil
+	}
+	schema := m.GraphQLSchema()
+	m.graphQLLock.Lock()
+	defer m.graphQLLock.Unlock()
+	if schema == m.graphQLSchema {
+		return nil
+	}
+	dqlSchema, err := m.getDQLSchema()
+	if err != nil {
+		return err
+	}
+	log.Infof("Uploading GraphQL schema to: %v", m.config.GraphQLAdminURL)
+	err = uploadGraphQLSchema(m.config.GraphQLAdminURL, schema)
+	if err != nil {
+		return err
+	}
+	m.graphQLSchema = schema
+	return m.restoreDQLSchema(dqlSchema)
+}
+
+//predicateSchema DQL schema of a predicate as returned by a schema query
+type predicateSchema struct {
+	Predicate string   `json:"predicate"`
+	Type      string   `json:"type"`
+	Index     bool     `json:"index,omitempty"`
+	Tokenizer []string `json:"tokenizer,omitempty"`
+	Reverse   bool     `json:"reverse,omitempty"`
+	Count     bool     `json:"count,omitempty"`
+	List      bool     `json:"list,omitempty"`
+	Upsert    bool     `json:"upsert,omitempty"`
+	Lang      bool     `json:"lang,omitempty"`
+}
+
+//dql returns the schema definition of the predicate
+func (m *predicateSchema) dql() string {
+	predicateType := m.Type
+	if m.List {
+		predicateType = "[" + predicateType + "]"
+	}
+	directives := ""
+	if m.Index {
+		tokenizers := append([]string{}, m.Tokenizer...)
+		sort.Strings(tokenizers)
+		directives += fmt.Sprintf(" @index(%v)", strings.Join(tokenizers, ", "))
+	}
+	if m.Reverse {
+		directives += " @reverse"
+	}
+	if m.Count {
+		directives += " @count"
+	}
+	if m.Upsert {
+		directives += " @upsert"
+	}
+	if m.Lang {
+		directives += " @lang"
+	}
+	return fmt.Sprintf("<%v>: %v%v .", m.Predicate, predicateType, directives)
+}
+
+//dqlSchema predicates and types of the DQL schema
+type dqlSchema struct {
+	Predicates []*predicateSchema   `json:"schema"`
+	Types      []*dgraph.SchemaType `json:"types"`
+}
+
+//typeDQL returns the schema definition of the type
+func typeDQL(schemaType *dgraph.SchemaType) string {
+	fields := make([]string, 0, len(schemaType.Fields))
+	for _, field := range schemaType.Fields {
+		fields = append(fields, field.Name)
+	}
+	sort.Strings(fields)
+	return fmt.Sprintf("type %v {\n%v\n}", schemaType.Name, strings.Join(fields, "\n"))
+}
+
+func (m *Doccache) getDQLSchema() (*dqlSchema, error) {
+	schema := &dqlSchema{}
+	err := m.dgraph.Query("schema {}", nil, schema)
+	if err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+//restoreDQLSchema reapplies the definitions of the predicates and types that changed since the schema was read,
+//uploading a GraphQL schema rewrites the predicates it maps and the directives the GraphQL schema can not
+//express, e.g. @count, and indexes added on the DQL side would otherwise be lost
+func (m *Doccache) restoreDQLSchema(before *dqlSchema) error {
+	after, err := m.getDQLSchema()
+	if err != nil {
+		return err
+	}
+	current := make(map[string]string, len(after.Predicates)+len(after.Types))
+	for _, predicate := range after.Predicates {
+		current[predicate.Predicate] = predicate.dql()
+	}
+	for _, schemaType := range after.Types {
+		current["type "+schemaType.Name] = typeDQL(schemaType)
+	}
+	changed := make([]string, 0)
+	definitions := make([]string, 0)
+	restore := func(key, definition string) {
+		if strings.HasPrefix(strings.TrimPrefix(key, "type "), "dgraph.") || current[key] == definition {
+			return
+		}
+		changed = append(changed, key)
+		definitions = append(definitions, definition)
+	}
+	for _, predicate := range before.Predicates {
+		restore(predicate.Predicate, predicate.dql())
+	}
+	for _, schemaType := range before.Types {
+		restore("type "+schemaType.Name, typeDQL(schemaType))
+	}
+	if len(definitions) == 0 {
+		return nil
+	}
+	log.Infof("Restoring DQL schema of: %v, changed by the GraphQL schema upload", changed)
+	return m.dgraph.UpdateSchema(strings.Join(definitions, "\n"))
+}
+
+func uploadGraphQLSchema(adminURL, schema string) error {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(strings.TrimSuffix(adminURL, "/")+"/admin/schema", "application/graphql", bytes.NewBufferString(schema))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	result := &struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}{}
+	err = json.Unmarshal(body, result)
+	if resp.StatusCode != http.StatusOK || err != nil {
+		return fmt.Errorf("failed uploading GraphQL schema, status: %v, response: %v", resp.StatusCode, string(body))
+	}
+	if len(result.Errors) > 0 {
+		messages := make([]string, 0, len(result.Errors))
+		for _, e := range result.Errors {
+			messages = append(messages, e.Message)
+		}
+		return fmt.Errorf("failed uploading GraphQL schema: %v", strings.Join(messages, ", "))
+	}
+	return nil
+}
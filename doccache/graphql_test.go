@@ -0,0 +1,146 @@
+package doccache
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEdgeFieldName(t *testing.T) {
+	tests := map[string]string{
+		"member":   "member",
+		"owned.by": "owned_by",
+		"pay-out":  "pay_out",
+		"hash":     "edge_hash",
+		"1st":      "edge_1st",
+	}
+	for edgeName, expected := range tests {
+		actual := EdgeFieldName(edgeName)
+		if actual != expected {
+			t.Fatalf("Expected field name for edge: %v to be: %v, found: %v", edgeName, expected, actual)
+		}
+	}
+	if InboundEdgeFieldName("owned.by") != "inbound_owned_by" {
+		t.Fatalf("Expected inbound field name: inbound_owned_by, found: %v", InboundEdgeFieldName("owned.by"))
+	}
+}
+
+func TestPredicateSchemaDQL(t *testing.T) {
+	tests := []struct {
+		predicate *predicateSchema
+		expected  string
+	}{
+		{&predicateSchema{Predicate: "hash", Type: "string", Index: true, Tokenizer: []string{"exact"}, Upsert: true}, "<hash>: string @index(exact) @upsert ."},
+		{&predicateSchema{Predicate: "value", Type: "string", Index: true, Tokenizer: []string{"trigram", "fulltext", "term"}}, "<value>: string @index(fulltext, term, trigram) ."},
+		{&predicateSchema{Predicate: "edge.member", Type: "uid", List: true, Reverse: true, Count: true}, "<edge.member>: [uid] @reverse @count ."},
+		{&predicateSchema{Predicate: "notes", Type: "string", Lang: true}, "<notes>: string @lang ."},
+	}
+	for _, test := range tests {
+		if actual := test.predicate.dql(); actual != test.expected {
+			t.Fatalf("Expected DQL: %v, found: %v", test.expected, actual)
+		}
+	}
+}
+
+func TestSyncGraphQLSchema(t *testing.T) {
+	uploads := make([]string, 0)
+	admin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/admin/schema" || r.Method != http.MethodPost {
+			t.Errorf("Unexpected request: %v %v", r.Method, r.URL.Path)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		uploads = append(uploads, string(body))
+		if strings.Contains(string(body), "rejected") {
+			w.Write([]byte(`{"errors":[{"message":"invalid schema"}]}`))
+			return
+		}
+		//Dgraph rewrites the predicates and types mapped by the GraphQL schema
+		err := dg.UpdateSchema(`
+			gqlsync_label: string @index(term) .
+			type GQLSync {
+				gqlsync_label
+			}
+		`)
+		if err != nil {
+			t.Errorf("Failed to rewrite schema: %v", err)
+		}
+		w.Write([]byte(`{"data":{"code":"Success","message":"Done"}}`))
+	}))
+	defer admin.Close()
+
+	err := dg.UpdateSchema(`
+		gqlsync_label: string @index(trigram, term) @count .
+		gqlsync_sequence: int .
+		type GQLSync {
+			gqlsync_label
+			gqlsync_sequence
+		}
+	`)
+	if err != nil {
+		t.Fatalf("Failed to set up schema: %v", err)
+	}
+	cache := &Doccache{
+		dgraph: dg,
+		config: &Config{GraphQLAdminURL: admin.URL},
+		edges: map[string]*EdgeDefinition{
+			"member":  {Name: "member", Predicate: EdgePredicate("member"), Reverse: true},
+			"owns":    {Name: "owns", Predicate: EdgePredicate("owns")},
+			"ownedby": {Name: "ownedby", Predicate: EdgePredicate("ownedby"), Reverse: true},
+		},
+	}
+	schema := cache.GraphQLSchema()
+	for _, expected := range []string{
+		`  member: [Document] @dgraph(pred: "edge.member")`,
+		`  inbound_member: [Document] @dgraph(pred: "~edge.member")`,
+		`  owns: [Document] @dgraph(pred: "edge.owns")`,
+		`  ownedby: [Document] @dgraph(pred: "edge.ownedby")`,
+	} {
+		if !strings.Contains(schema, expected+"\n") {
+			t.Fatalf("Expected schema to contain: %v, found: %v", expected, schema)
+		}
+	}
+	if strings.Contains(schema, "inbound_owns") {
+		t.Fatalf("Expected no inbound field for edge without reverse indexing, found: %v", schema)
+	}
+
+	err = cache.SyncGraphQLSchema()
+	if err != nil {
+		t.Fatalf("SyncGraphQLSchema failed: %v", err)
+	}
+	dqlSchema, err := cache.getDQLSchema()
+	if err != nil {
+		t.Fatalf("getDQLSchema failed: %v", err)
+	}
+	restored := 0
+	for _, predicate := range dqlSchema.Predicates {
+		if predicate.Predicate == "gqlsync_label" && predicate.dql() == "<gqlsync_label>: string @index(term, trigram) @count ." {
+			restored++
+		}
+	}
+	for _, schemaType := range dqlSchema.Types {
+		if schemaType.Name == "GQLSync" && typeDQL(schemaType) == "type GQLSync {\ngqlsync_label\ngqlsync_sequence\n}" {
+			restored++
+		}
+	}
+	if restored != 2 {
+		t.Fatalf("Expected the DQL schema to be restored after the upload, found: %v", dqlSchema)
+	}
+	err = cache.SyncGraphQLSchema()
+	if err != nil {
+		t.Fatalf("SyncGraphQLSchema failed: %v", err)
+	}
+	if len(uploads) != 1 || uploads[0] != schema {
+		t.Fatalf("Expected schema to be uploaded once, found: %v uploads", len(uploads))
+	}
+
+	cache.edges["rejected"] = &EdgeDefinition{Name: "rejected", Predicate: EdgePredicate("rejected")}
+	err = cache.SyncGraphQLSchema()
+	if err == nil || !strings.Contains(err.Error(), "invalid schema") {
+		t.Fatalf("Expected upload error, found: %v", err)
+	}
+	if len(uploads) != 2 || cache.graphQLSchema != schema {
+		t.Fatalf("Expected failed upload to be retried on next sync, uploads: %v", len(uploads))
+	}
+}
@@ -0,0 +1,201 @@
+package doccache
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sebastianmontero/dgraph-go-client/dgraph"
+	"github.com/sebastianmontero/slog-go/slog"
+)
+
+const normalizePageSize = 1000
+
+//NormalizeHash returns the canonical form of a hash, lower case hex without surrounding spaces
+func NormalizeHash(hash string) string {
+	return strings.ToLower(strings.TrimSpace(hash))
+}
+
+//NormalizeHashes returns the canonical form of the hashes
+func NormalizeHashes(hashes []string) []string {
+	normalized := make([]string, 0, len(hashes))
+	for _, hash := range hashes {
+		normalized = append(normalized, NormalizeHash(hash))
+	}
+	return normalized
+}
+
+//IsNormalizedHash indicates if the hash is already in canonical form
+func IsNormalizedHash(hash string) bool {
+	return hash == NormalizeHash(hash)
+}
+
+//IsValidHash indicates if the hash is a sha256 checksum, 64 hex characters, once normalized
+func IsValidHash(hash string) bool {
+	hash = NormalizeHash(hash)
+	if len(hash) != 64 {
+		return false
+	}
+	for i := 0; i < len(hash); i++ {
+		if (hash[i] < '0' || hash[i] > '9') && (hash[i] < 'a' || hash[i] > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+//HashNormalization summary of the hash migration
+type HashNormalization struct {
+	Documents int
+	Contents  int
+	//Merged documents that had the same hash as another document once normalized
+	Merged int
+}
+
+func (m *HashNormalization) String() string {
+	return fmt.Sprintf("HashNormalization{Documents: %v, Contents: %v, Merged: %v}", m.Documents, m.Contents, m.Merged)
+}
+
+//CountUnnormalizedHashes counts the hashes NormalizeStoredHashes would rewrite and the documents it would merge,
+//without applying the pending migrations, one of which normalizes the hashes
+func CountUnnormalizedHashes(dg *dgraph.Dgraph, logConfig *slog.Config) (*HashNormalization, error) {
+	log = slog.New(logConfig, "doccache")
+	m := &Doccache{dgraph: dg}
+	return m.NormalizeStoredHashes(true)
+}
+
+//NormalizeStoredHashes rewrites document hashes and checksum content values that are not in canonical form,
+//documents that end up with the same hash are merged into the oldest one, if dryRun is true it only counts them
+func (m *Doccache) NormalizeStoredHashes(dryRun bool) (*HashNormalization, error) {
+	result := &HashNormalization{}
+	documents, err := m.normalizePredicate("Document", "", "hash", dryRun)
+	if err != nil {
+		return nil, err
+	}
+	contents, err := m.normalizePredicate("Content", `@filter(eq(type, "checksum256"))`, "value", dryRun)
+	if err != nil {
+		return nil, err
+	}
+	for _, uids := range documents {
+		result.Documents += len(uids)
+	}
+	for _, uids := range contents {
+		result.Contents += len(uids)
+	}
+	duplicates, err := m.normalizedDuplicates(documents, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	for _, uids := range duplicates {
+		result.Merged += len(uids) - 1
+	}
+	if !dryRun && len(duplicates) > 0 {
+		err = m.repairDuplicates(&IntegrityReport{DuplicateDocuments: duplicates})
+		if err != nil {
+			return nil, fmt.Errorf("failed merging documents with the same normalized hash: %v", err)
+		}
+	}
+	return result, nil
+}
+
+//normalizedDuplicates finds the documents that have the same hash once normalized, normalized maps the normalized hashes
+//to the uids of the documents that were or would be normalized, the uids of each hash are ordered oldest first
+func (m *Doccache) normalizedDuplicates(normalized map[string][]string, dryRun bool) (map[string][]string, error) {
+	hashes := make([]string, 0, len(normalized))
+	for hash := range normalized {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	duplicates := make(map[string][]string)
+	for start := 0; start < len(hashes); start += normalizePageSize {
+		end := start + normalizePageSize
+		if end > len(hashes) {
+			end = len(hashes)
+		}
+		quoted := make([]string, 0, end-start)
+		for _, hash := range hashes[start:end] {
+			quoted = append(quoted, strconv.Quote(hash))
+		}
+		query := fmt.Sprintf(`
+			{
+				docs(func: eq(hash, [%v])){
+					uid
+					hash
+				}
+			}
+		`, strings.Join(quoted, ","))
+		docs := &Docs{}
+		err := m.dgraph.Query(query, nil, docs)
+		if err != nil {
+			return nil, err
+		}
+		hashUIDs := make(map[string][]string)
+		for _, doc := range docs.Docs {
+			hashUIDs[doc.Hash] = append(hashUIDs[doc.Hash], doc.UID)
+		}
+		for _, hash := range hashes[start:end] {
+			uids := hashUIDs[hash]
+			if dryRun {
+				uids = append(uids, normalized[hash]...)
+			}
+			if len(uids) > 1 {
+				sort.Slice(uids, func(i, j int) bool {
+					return uidLess(uids[i], uids[j])
+				})
+				duplicates[hash] = uids
+			}
+		}
+	}
+	return duplicates, nil
+}
+
+//normalizePredicate returns the uids of the nodes whose predicate was not in canonical form by normalized value
+func (m *Doccache) normalizePredicate(dType, filter, predicate string, dryRun bool) (map[string][]string, error) {
+	normalized := make(map[string][]string)
+	after := ""
+	for {
+		afterClause := ""
+		if after != "" {
+			afterClause = fmt.Sprintf(", after: %v", after)
+		}
+		query := fmt.Sprintf(`
+			{
+				nodes(func: type(%v), first: %v%v) %v {
+					uid
+					value: %v
+				}
+			}
+		`, dType, normalizePageSize, afterClause, filter, predicate)
+		result := &struct {
+			Nodes []*struct {
+				UID   string `json:"uid,omitempty"`
+				Value string `json:"value,omitempty"`
+			} `json:"nodes,omitempty"`
+		}{}
+		err := m.dgraph.Query(query, nil, result)
+		if err != nil {
+			return nil, err
+		}
+		var nquads strings.Builder
+		for _, node := range result.Nodes {
+			if !IsNormalizedHash(node.Value) {
+				log.Infof("Normalizing %v of <%v>: %v", predicate, node.UID, node.Value)
+				value := NormalizeHash(node.Value)
+				fmt.Fprintf(&nquads, "<%v> <%v> %q .\n", node.UID, predicate, value)
+				normalized[value] = append(normalized[value], node.UID)
+			}
+		}
+		if nquads.Len() > 0 && !dryRun {
+			_, err = m.dgraph.MutateNQuads(nquads.String(), false)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if len(result.Nodes) < normalizePageSize {
+			break
+		}
+		after = result.Nodes[len(result.Nodes)-1].UID
+	}
+	return normalized, nil
+}
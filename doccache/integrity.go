@@ -0,0 +1,651 @@
+package doccache
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var coreDocumentFields = map[string]bool{
+	"hash":           true,
+	"created_date":   true,
+	"creator":        true,
+	"doc_type":       true,
+	"node_label":     true,
+	"ballot_id":      true,
+	"content_groups": true,
+	"certificates":   true,
+}
+
+//DanglingEdge edge pointing to a node that is not a Document
+type DanglingEdge struct {
+	FromUID   string `json:"from_uid,omitempty"`
+	FromHash  string `json:"from_hash,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Predicate string `json:"predicate,omitempty"`
+	ToUID     string `json:"to_uid,omitempty"`
+}
+
+func (m *DanglingEdge) String() string {
+	return fmt.Sprintf("DanglingEdge{FromUID: %v, FromHash: %v, Name: %v, Predicate: %v, ToUID: %v}", m.FromUID, m.FromHash, m.Name, m.Predicate, m.ToUID)
+}
+
+//UnlinkedChecksum checksum content whose target document exists but is not linked
+type UnlinkedChecksum struct {
+	ContentUID  string `json:"content_uid,omitempty"`
+	Hash        string `json:"hash,omitempty"`
+	DocumentUID string `json:"document_uid,omitempty"`
+}
+
+func (m *UnlinkedChecksum) String() string {
+	return fmt.Sprintf("UnlinkedChecksum{ContentUID: %v, Hash: %v, DocumentUID: %v}", m.ContentUID, m.Hash, m.DocumentUID)
+}
+
+//IntegrityReport structural problems found in the cache
+type IntegrityReport struct {
+	OrphanContentGroups []string            `json:"orphan_content_groups"`
+	OrphanContents      []string            `json:"orphan_contents"`
+	OrphanCertificates  []string            `json:"orphan_certificates"`
+	DanglingEdges       []*DanglingEdge     `json:"dangling_edges"`
+	DuplicateDocuments  map[string][]string `json:"duplicate_documents"`
+	ExtraCursors        []*Cursor           `json:"extra_cursors"`
+	UnlinkedChecksums   []*UnlinkedChecksum `json:"unlinked_checksums"`
+}
+
+//IsClean indicates if no problems were found
+func (m *IntegrityReport) IsClean() bool {
+	return len(m.OrphanContentGroups) == 0 &&
+		len(m.OrphanContents) == 0 &&
+		len(m.OrphanCertificates) == 0 &&
+		len(m.DanglingEdges) == 0 &&
+		len(m.DuplicateDocuments) == 0 &&
+		len(m.ExtraCursors) == 0 &&
+		len(m.UnlinkedChecksums) == 0
+}
+
+func (m *IntegrityReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Orphan content groups: %v\n", len(m.OrphanContentGroups))
+	for _, uid := range m.OrphanContentGroups {
+		fmt.Fprintf(&b, "\t%v\n", uid)
+	}
+	fmt.Fprintf(&b, "Orphan contents: %v\n", len(m.OrphanContents))
+	for _, uid := range m.OrphanContents {
+		fmt.Fprintf(&b, "\t%v\n", uid)
+	}
+	fmt.Fprintf(&b, "Orphan certificates: %v\n", len(m.OrphanCertificates))
+	for _, uid := range m.OrphanCertificates {
+		fmt.Fprintf(&b, "\t%v\n", uid)
+	}
+	fmt.Fprintf(&b, "Dangling edges: %v\n", len(m.DanglingEdges))
+	for _, edge := range m.DanglingEdges {
+		fmt.Fprintf(&b, "\t%v\n", edge)
+	}
+	fmt.Fprintf(&b, "Duplicate documents: %v\n", len(m.DuplicateDocuments))
+	for hash, uids := range m.DuplicateDocuments {
+		fmt.Fprintf(&b, "\t%v: %v\n", hash, uids)
+	}
+	fmt.Fprintf(&b, "Extra cursors: %v\n", len(m.ExtraCursors))
+	for _, cursor := range m.ExtraCursors {
+		fmt.Fprintf(&b, "\t%v\n", cursor)
+	}
+	fmt.Fprintf(&b, "Unlinked checksums: %v\n", len(m.UnlinkedChecksums))
+	for _, checksum := range m.UnlinkedChecksums {
+		fmt.Fprintf(&b, "\t%v\n", checksum)
+	}
+	return b.String()
+}
+
+//RepairConfig indicates which classes of problems should be fixed
+type RepairConfig struct {
+	Orphans    bool
+	Edges      bool
+	Duplicates bool
+	Cursors    bool
+	Checksums  bool
+}
+
+//RepairAll returns a config that fixes every class of problem
+func RepairAll() *RepairConfig {
+	return &RepairConfig{
+		Orphans:    true,
+		Edges:      true,
+		Duplicates: true,
+		Cursors:    true,
+		Checksums:  true,
+	}
+}
+
+type uidNode struct {
+	UID  string `json:"uid,omitempty"`
+	Hash string `json:"hash,omitempty"`
+}
+
+//CheckIntegrity scans the cache for structural problems
+func (m *Doccache) CheckIntegrity() (*IntegrityReport, error) {
+	report := &IntegrityReport{}
+	var err error
+
+	report.OrphanContentGroups, report.OrphanContents, report.OrphanCertificates, err = m.findOrphans()
+	if err != nil {
+		return nil, err
+	}
+	report.DanglingEdges, err = m.findDanglingEdges()
+	if err != nil {
+		return nil, err
+	}
+	report.DuplicateDocuments, err = m.findDuplicateDocuments()
+	if err != nil {
+		return nil, err
+	}
+	report.ExtraCursors, err = m.findExtraCursors()
+	if err != nil {
+		return nil, err
+	}
+	report.UnlinkedChecksums, err = m.findUnlinkedChecksums()
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (m *Doccache) findOrphans() ([]string, []string, []string, error) {
+	query := `
+		{
+			var(func: type(Document)){
+				cg as content_groups {
+					c as contents
+				}
+				certs as certificates
+			}
+			groups(func: type(ContentGroup)) @filter(NOT uid(cg)){
+				uid
+			}
+			contents(func: type(Content)) @filter(NOT uid(c)){
+				uid
+			}
+			certificates(func: type(Certificate)) @filter(NOT uid(certs)){
+				uid
+			}
+		}
+	`
+	result := &struct {
+		Groups       []*uidNode `json:"groups,omitempty"`
+		Contents     []*uidNode `json:"contents,omitempty"`
+		Certificates []*uidNode `json:"certificates,omitempty"`
+	}{}
+	err := m.dgraph.Query(query, nil, result)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return toUIDs(result.Groups), toUIDs(result.Contents), toUIDs(result.Certificates), nil
+}
+
+func (m *Doccache) findDanglingEdges() ([]*DanglingEdge, error) {
+	dangling := make([]*DanglingEdge, 0)
+	for _, edgeName := range m.EdgeNames() {
+		edge := m.GetEdgeDefinition(edgeName)
+		query := fmt.Sprintf(`
+			{
+				docs(func: type(Document)) @filter(has(<%v>)){
+					uid
+					hash
+					targets: <%v> @filter(NOT type(Document)){
+						uid
+					}
+				}
+			}
+		`, edge.Predicate, edge.Predicate)
+		result := &struct {
+			Docs []*struct {
+				UID     string     `json:"uid,omitempty"`
+				Hash    string     `json:"hash,omitempty"`
+				Targets []*uidNode `json:"targets,omitempty"`
+			} `json:"docs,omitempty"`
+		}{}
+		err := m.dgraph.Query(query, nil, result)
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range result.Docs {
+			for _, target := range doc.Targets {
+				dangling = append(dangling, &DanglingEdge{
+					FromUID:   doc.UID,
+					FromHash:  doc.Hash,
+					Name:      edge.Name,
+					Predicate: edge.Predicate,
+					ToUID:     target.UID,
+				})
+			}
+		}
+	}
+	return dangling, nil
+}
+
+func (m *Doccache) findDuplicateDocuments() (map[string][]string, error) {
+	query := `
+		{
+			docs(func: type(Document), orderasc: hash){
+				uid
+				hash
+			}
+		}
+	`
+	docs := &Docs{}
+	err := m.dgraph.Query(query, nil, docs)
+	if err != nil {
+		return nil, err
+	}
+	hashUIDs := make(map[string][]string)
+	for _, doc := range docs.Docs {
+		hashUIDs[doc.Hash] = append(hashUIDs[doc.Hash], doc.UID)
+	}
+	duplicates := make(map[string][]string)
+	for hash, uids := range hashUIDs {
+		if len(uids) > 1 {
+			sort.Slice(uids, func(i, j int) bool {
+				return uidLess(uids[i], uids[j])
+			})
+			duplicates[hash] = uids
+		}
+	}
+	return duplicates, nil
+}
+
+func (m *Doccache) findExtraCursors() ([]*Cursor, error) {
+	query := `
+		{
+			cursors(func: type(Cursor)){
+				uid
+				cursor
+				dgraph.type
+			}
+		}
+	`
+	cursors := &Cursors{}
+	err := m.dgraph.Query(query, nil, cursors)
+	if err != nil {
+		return nil, err
+	}
+	extra := make([]*Cursor, 0)
+	for _, cursor := range cursors.Cursors {
+		if cursor.UID != m.Cursor.UID {
+			extra = append(extra, cursor)
+		}
+	}
+	return extra, nil
+}
+
+func (m *Doccache) findUnlinkedChecksums() ([]*UnlinkedChecksum, error) {
+	query := `
+		{
+			contents(func: type(Content)) @filter(eq(type, "checksum256") AND NOT has(document)){
+				uid
+				value
+			}
+		}
+	`
+	result := &struct {
+		Contents []*Content `json:"contents,omitempty"`
+	}{}
+	err := m.dgraph.Query(query, nil, result)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, 0, len(result.Contents))
+	for _, content := range result.Contents {
+		hashes = append(hashes, content.Value)
+	}
+	hashUIDMap, err := m.GetHashUIDMap(hashes)
+	if err != nil {
+		return nil, err
+	}
+	unlinked := make([]*UnlinkedChecksum, 0)
+	for _, content := range result.Contents {
+		if uid, ok := hashUIDMap[content.Value]; ok {
+			unlinked = append(unlinked, &UnlinkedChecksum{
+				ContentUID:  content.UID,
+				Hash:        content.Value,
+				DocumentUID: uid,
+			})
+		}
+	}
+	return unlinked, nil
+}
+
+//Repair fixes the problems found in the report, for the classes enabled in the config
+func (m *Doccache) Repair(report *IntegrityReport, config *RepairConfig) error {
+	if config.Orphans {
+		err := m.repairOrphans(report)
+		if err != nil {
+			return fmt.Errorf("failed repairing orphans: %v", err)
+		}
+	}
+	if config.Duplicates {
+		err := m.repairDuplicates(report)
+		if err != nil {
+			return fmt.Errorf("failed repairing duplicates: %v", err)
+		}
+	}
+	if config.Edges {
+		err := m.repairDanglingEdges(report)
+		if err != nil {
+			return fmt.Errorf("failed repairing dangling edges: %v", err)
+		}
+	}
+	if config.Cursors {
+		err := m.repairCursors(report)
+		if err != nil {
+			return fmt.Errorf("failed repairing cursors: %v", err)
+		}
+	}
+	if config.Checksums {
+		err := m.repairChecksums(report)
+		if err != nil {
+			return fmt.Errorf("failed repairing checksums: %v", err)
+		}
+	}
+	return nil
+}
+
+func (m *Doccache) repairOrphans(report *IntegrityReport) error {
+	uids := make([]string, 0, len(report.OrphanContentGroups)+len(report.OrphanContents)+len(report.OrphanCertificates))
+	uids = append(uids, report.OrphanContentGroups...)
+	uids = append(uids, report.OrphanContents...)
+	uids = append(uids, report.OrphanCertificates...)
+	log.Infof("Deleting %v orphan nodes", len(uids))
+	return m.deleteNodes(uids)
+}
+
+func (m *Doccache) repairDanglingEdges(report *IntegrityReport) error {
+	if len(report.DanglingEdges) == 0 {
+		return nil
+	}
+	var nquads strings.Builder
+	for _, edge := range report.DanglingEdges {
+		fmt.Fprintf(&nquads, "<%v> <%v> <%v> .\n", edge.FromUID, edge.Predicate, edge.ToUID)
+	}
+	log.Infof("Deleting %v dangling edges", len(report.DanglingEdges))
+	_, err := m.dgraph.DeleteNQuads(nquads.String())
+	return err
+}
+
+//repairDuplicates keeps the oldest node for each hash, repoints references to the duplicates,
+//merges their outgoing edges and certificates into the kept node and deletes them along with
+//their content groups and contents
+func (m *Doccache) repairDuplicates(report *IntegrityReport) error {
+	for hash, uids := range report.DuplicateDocuments {
+		keep, duplicates := uids[0], uids[1:]
+		log.Infof("Keeping <%v> for hash: %v, removing duplicates: %v", keep, hash, duplicates)
+		for _, duplicate := range duplicates {
+			err := m.repointReferences(duplicate, keep)
+			if err != nil {
+				return err
+			}
+			err = m.copyOutgoingEdges(duplicate, keep)
+			if err != nil {
+				return err
+			}
+			moved, err := m.mergeCertificates(duplicate, keep)
+			if err != nil {
+				return err
+			}
+			nested, err := m.nestedNodes(duplicate)
+			if err != nil {
+				return err
+			}
+			remove := make([]string, 0, len(nested)+1)
+			for _, uid := range nested {
+				if !moved[uid] {
+					remove = append(remove, uid)
+				}
+			}
+			err = m.deleteNodes(append(remove, duplicate))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//copyOutgoingEdges adds the edges of the duplicate to the kept node, edges pointing to the duplicate itself point to the kept node
+func (m *Doccache) copyOutgoingEdges(fromUID, toUID string) error {
+	predicates := m.edgePredicates()
+	if len(predicates) == 0 {
+		return nil
+	}
+	var fields strings.Builder
+	for i, predicate := range predicates {
+		fmt.Fprintf(&fields, "e%v: <%v> { uid }\n", i, predicate)
+	}
+	query := fmt.Sprintf(`
+		{
+			docs(func: uid(%v)){
+				%v
+			}
+		}
+	`, fromUID, fields.String())
+	result := &struct {
+		Docs []map[string][]*uidNode `json:"docs,omitempty"`
+	}{}
+	err := m.dgraph.Query(query, nil, result)
+	if err != nil {
+		return err
+	}
+	var set strings.Builder
+	for _, doc := range result.Docs {
+		for i, predicate := range predicates {
+			for _, target := range doc[fmt.Sprintf("e%v", i)] {
+				targetUID := target.UID
+				if targetUID == fromUID {
+					targetUID = toUID
+				}
+				fmt.Fprintf(&set, "<%v> <%v> <%v> .\n", toUID, predicate, targetUID)
+			}
+		}
+	}
+	if set.Len() == 0 {
+		return nil
+	}
+	_, err = m.dgraph.MutateNQuads(set.String(), false)
+	return err
+}
+
+//mergeCertificates moves the certificates of the duplicate the kept node does not have to the kept node,
+//appending them to its certificates, returns the uids of the moved certificates
+func (m *Doccache) mergeCertificates(fromUID, toUID string) (map[string]bool, error) {
+	query := fmt.Sprintf(`
+		{
+			docs(func: uid(%v, %v)){
+				uid
+				certificates(orderasc: certification_sequence) {
+					uid
+					certifier
+					notes
+					certification_date
+				}
+			}
+		}
+	`, fromUID, toUID)
+	docs := &Docs{}
+	err := m.dgraph.Query(query, nil, docs)
+	if err != nil {
+		return nil, err
+	}
+	var from, to []*Certificate
+	for _, doc := range docs.Docs {
+		if doc.UID == fromUID {
+			from = doc.Certificates
+		} else {
+			to = doc.Certificates
+		}
+	}
+	moved := make(map[string]bool)
+	var set strings.Builder
+	for _, certificate := range from {
+		if containsCertificate(to, certificate) {
+			continue
+		}
+		to = append(to, certificate)
+		moved[certificate.UID] = true
+		fmt.Fprintf(&set, "<%v> <certificates> <%v> .\n", toUID, certificate.UID)
+		fmt.Fprintf(&set, "<%v> <certification_sequence> \"%v\" .\n", certificate.UID, len(to))
+	}
+	if set.Len() == 0 {
+		return moved, nil
+	}
+	log.Infof("Moving %v certificates from <%v> to <%v>", len(moved), fromUID, toUID)
+	_, err = m.dgraph.MutateNQuads(set.String(), false)
+	if err != nil {
+		return nil, err
+	}
+	return moved, nil
+}
+
+func containsCertificate(certificates []*Certificate, certificate *Certificate) bool {
+	for _, c := range certificates {
+		if c.Certifier == certificate.Certifier && c.Notes == certificate.Notes && sameTime(c.CertificationDate, certificate.CertificationDate) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func (m *Doccache) repointReferences(fromUID, toUID string) error {
+	predicates := append(m.edgePredicates(), "document")
+	var set, del strings.Builder
+	for _, predicate := range predicates {
+		query := fmt.Sprintf(`
+			{
+				refs(func: has(<%v>)) @filter(uid_in(<%v>, %v)){
+					uid
+				}
+			}
+		`, predicate, predicate, fromUID)
+		result := &struct {
+			Refs []*uidNode `json:"refs,omitempty"`
+		}{}
+		err := m.dgraph.Query(query, nil, result)
+		if err != nil {
+			return err
+		}
+		for _, ref := range result.Refs {
+			fmt.Fprintf(&del, "<%v> <%v> <%v> .\n", ref.UID, predicate, fromUID)
+			fmt.Fprintf(&set, "<%v> <%v> <%v> .\n", ref.UID, predicate, toUID)
+		}
+	}
+	if set.Len() == 0 {
+		return nil
+	}
+	_, err := m.dgraph.Mutate(
+		m.dgraph.DeleteNQuadsMutation(del.String()),
+		m.dgraph.NQuadsMutation(set.String(), false),
+	)
+	return err
+}
+
+func (m *Doccache) nestedNodes(docUID string) ([]string, error) {
+	query := fmt.Sprintf(`
+		{
+			docs(func: uid(%v)){
+				content_groups {
+					uid
+					contents {
+						uid
+					}
+				}
+				certificates {
+					uid
+				}
+			}
+		}
+	`, docUID)
+	docs := &Docs{}
+	err := m.dgraph.Query(query, nil, docs)
+	if err != nil {
+		return nil, err
+	}
+	uids := make([]string, 0)
+	for _, doc := range docs.Docs {
+		for _, contentGroup := range doc.ContentGroups {
+			uids = append(uids, contentGroup.UID)
+			for _, content := range contentGroup.Contents {
+				uids = append(uids, content.UID)
+			}
+		}
+		for _, certificate := range doc.Certificates {
+			uids = append(uids, certificate.UID)
+		}
+	}
+	return uids, nil
+}
+
+func (m *Doccache) repairCursors(report *IntegrityReport) error {
+	uids := make([]string, 0, len(report.ExtraCursors))
+	for _, cursor := range report.ExtraCursors {
+		uids = append(uids, cursor.UID)
+	}
+	log.Infof("Deleting %v extra cursors", len(uids))
+	return m.deleteNodes(uids)
+}
+
+func (m *Doccache) repairChecksums(report *IntegrityReport) error {
+	if len(report.UnlinkedChecksums) == 0 {
+		return nil
+	}
+	var nquads strings.Builder
+	for _, checksum := range report.UnlinkedChecksums {
+		fmt.Fprintf(&nquads, "<%v> <document> <%v> .\n", checksum.ContentUID, checksum.DocumentUID)
+	}
+	log.Infof("Linking %v checksum contents", len(report.UnlinkedChecksums))
+	_, err := m.dgraph.MutateNQuads(nquads.String(), false)
+	return err
+}
+
+func (m *Doccache) deleteNodes(uids []string) error {
+	if len(uids) == 0 {
+		return nil
+	}
+	var nquads strings.Builder
+	for _, uid := range uids {
+		fmt.Fprintf(&nquads, "<%v> * * .\n", uid)
+	}
+	_, err := m.dgraph.DeleteNQuads(nquads.String())
+	return err
+}
+
+//edgePredicates returns the document predicates that store chain edges, including the legacy ones
+//when the edge predicates have not been namespaced yet, as when the hash normalization migration merges duplicates
+func (m *Doccache) edgePredicates() []string {
+	edgeNames := m.EdgeNames()
+	predicates := make([]string, 0, len(edgeNames))
+	for _, edgeName := range edgeNames {
+		predicates = append(predicates, m.GetEdgeDefinition(edgeName).Predicate)
+	}
+	return append(predicates, m.legacyEdgePredicates()...)
+}
+
+func toUIDs(nodes []*uidNode) []string {
+	uids := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		uids = append(uids, node.UID)
+	}
+	return uids
+}
+
+//uidLess compares hex uids (0x...) numerically
+func uidLess(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
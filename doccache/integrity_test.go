@@ -0,0 +1,261 @@
+package doccache
+
+import (
+	"fmt"
+	"testing"
+)
+
+//integrityFixtures problems introduced by the integrity test, the report is checked for these
+//instead of exact counts as other tests share the database
+type integrityFixtures struct {
+	orphanContentGroup string
+	orphanContent      string
+	duplicate          string
+	extraCursor        string
+	danglingEdge       string
+	parentUID          string
+}
+
+func containsUID(uids []string, uid string) bool {
+	for _, u := range uids {
+		if u == uid {
+			return true
+		}
+	}
+	return false
+}
+
+func TestIntegrity(t *testing.T) {
+	parentHash := "a1c0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c01"
+	childHash := "b2c0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c02"
+	err := doccache.StoreDocument(&ChainDocument{
+		Hash:        parentHash,
+		CreatedDate: "2021-01-15T19:17:44",
+		Creator:     "dao.hypha",
+		ContentGroups: [][]*ChainContent{
+			{
+				{
+					Label: "root_node",
+					Value: []interface{}{"name", "dao.hypha"},
+				},
+			},
+		},
+		Certificates: []*ChainCertificate{
+			{Certifier: "kept", Notes: "kept", CertificationDate: "2021-01-16T19:17:44"},
+		},
+	}, "integrity1")
+	if err != nil {
+		t.Fatalf("StoreDocument failed: %v", err)
+	}
+	err = doccache.StoreDocument(&ChainDocument{
+		Hash:        childHash,
+		CreatedDate: "2021-01-15T19:17:44",
+		Creator:     "dao.hypha",
+		ContentGroups: [][]*ChainContent{
+			{
+				{
+					Label: "parent",
+					Value: []interface{}{"checksum256", parentHash},
+				},
+			},
+		},
+	}, "integrity2")
+	if err != nil {
+		t.Fatalf("StoreDocument failed: %v", err)
+	}
+	fixtures := &integrityFixtures{}
+	fixtures.parentUID, err = doccache.GetUID(parentHash)
+	if err != nil {
+		t.Fatalf("GetUID failed: %v", err)
+	}
+
+	t.Log("Corrupting graph")
+	resp, err := dg.MutateJSON(&ContentGroup{
+		UID:                  "_:group",
+		ContentGroupSequence: 1,
+		DType:                []string{"ContentGroup"},
+		Contents: []*Content{
+			{
+				UID:             "_:content",
+				Label:           "orphan",
+				Type:            "string",
+				Value:           "orphan",
+				ContentSequence: 1,
+				DType:           []string{"Content"},
+			},
+		},
+	}, false)
+	if err != nil {
+		t.Fatalf("Failed to create orphan content group: %v", err)
+	}
+	fixtures.orphanContentGroup, fixtures.orphanContent = resp.GetUids()["group"], resp.GetUids()["content"]
+	resp, err = dg.MutateJSON(&Document{
+		UID:   "_:duplicate",
+		Hash:  parentHash,
+		DType: []string{"Document"},
+		Certificates: []*Certificate{
+			{
+				Certifier:             "kept",
+				Notes:                 "kept",
+				CertificationDate:     ToTime("2021-01-16T19:17:44"),
+				CertificationSequence: 1,
+				DType:                 []string{"Certificate"},
+			},
+			{
+				Certifier:             "merged",
+				Notes:                 "merged",
+				CertificationDate:     ToTime("2021-01-17T19:17:44"),
+				CertificationSequence: 2,
+				DType:                 []string{"Certificate"},
+			},
+		},
+	}, false)
+	if err != nil {
+		t.Fatalf("Failed to create duplicate document: %v", err)
+	}
+	fixtures.duplicate = resp.GetUids()["duplicate"]
+	resp, err = dg.MutateJSON(&Cursor{UID: "_:cursor", Cursor: "extra", DType: []string{"Cursor"}}, false)
+	if err != nil {
+		t.Fatalf("Failed to create extra cursor: %v", err)
+	}
+	fixtures.extraCursor = resp.GetUids()["cursor"]
+	edge, err := doccache.registerEdge("dangling")
+	if err != nil {
+		t.Fatalf("Failed to register edge: %v", err)
+	}
+	resp, err = dg.MutateNQuads(fmt.Sprintf("<%v> <%v> _:target .\n _:target <label> \"not a document\" .", fixtures.parentUID, edge.Predicate), false)
+	if err != nil {
+		t.Fatalf("Failed to create dangling edge: %v", err)
+	}
+	fixtures.danglingEdge = resp.GetUids()["target"]
+	childUID, err := doccache.GetUID(childHash)
+	if err != nil {
+		t.Fatalf("GetUID failed: %v", err)
+	}
+	duplicateEdge, err := doccache.registerEdge("duplicateedge")
+	if err != nil {
+		t.Fatalf("Failed to register edge: %v", err)
+	}
+	_, err = dg.MutateNQuads(fmt.Sprintf("<%v> <%v> <%v> .", fixtures.duplicate, duplicateEdge.Predicate, childUID), false)
+	if err != nil {
+		t.Fatalf("Failed to create duplicate outgoing edge: %v", err)
+	}
+	nested, err := doccache.nestedNodes(childUID)
+	if err != nil {
+		t.Fatalf("nestedNodes failed: %v", err)
+	}
+	for _, uid := range nested {
+		_, err = dg.DeleteNQuads(fmt.Sprintf("<%v> <document> * .", uid))
+		if err != nil {
+			t.Fatalf("Failed to unlink checksum: %v", err)
+		}
+	}
+
+	report, err := doccache.CheckIntegrity()
+	if err != nil {
+		t.Fatalf("CheckIntegrity failed: %v", err)
+	}
+	t.Logf("Report: %v", report)
+	if !containsUID(report.OrphanContentGroups, fixtures.orphanContentGroup) {
+		t.Fatalf("Expected orphan content group: %v, found: %v", fixtures.orphanContentGroup, report.OrphanContentGroups)
+	}
+	if !containsUID(report.OrphanContents, fixtures.orphanContent) {
+		t.Fatalf("Expected orphan content: %v, found: %v", fixtures.orphanContent, report.OrphanContents)
+	}
+	if !hasDanglingEdge(report, fixtures) {
+		t.Fatalf("Expected dangling edge from: %v, found: %v", parentHash, report.DanglingEdges)
+	}
+	if uids, ok := report.DuplicateDocuments[parentHash]; !ok || len(uids) != 2 || uids[0] != fixtures.parentUID || uids[1] != fixtures.duplicate {
+		t.Fatalf("Expected duplicate: %v for hash: %v keeping: %v, found: %v", fixtures.duplicate, parentHash, fixtures.parentUID, report.DuplicateDocuments)
+	}
+	if !hasExtraCursor(report, fixtures) {
+		t.Fatalf("Expected extra cursor: %v, found: %v", fixtures.extraCursor, report.ExtraCursors)
+	}
+	if !hasUnlinkedChecksum(report, fixtures) {
+		t.Fatalf("Expected unlinked checksum pointing to: %v, found: %v", fixtures.parentUID, report.UnlinkedChecksums)
+	}
+
+	t.Log("Repairing graph")
+	err = doccache.Repair(fixtureReport(report, fixtures, parentHash), RepairAll())
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	report, err = doccache.CheckIntegrity()
+	if err != nil {
+		t.Fatalf("CheckIntegrity failed: %v", err)
+	}
+	if containsUID(report.OrphanContentGroups, fixtures.orphanContentGroup) || containsUID(report.OrphanContents, fixtures.orphanContent) ||
+		hasDanglingEdge(report, fixtures) || report.DuplicateDocuments[parentHash] != nil || hasExtraCursor(report, fixtures) || hasUnlinkedChecksum(report, fixtures) {
+		t.Fatalf("Expected the introduced problems to be repaired, found: %v", report)
+	}
+	doc, err := doccache.GetByHash(childHash, &RequestConfig{ContentGroups: true})
+	if err != nil {
+		t.Fatalf("GetByHash failed: %v", err)
+	}
+	checksums := doc.GetChecksumContents()
+	if len(checksums) != 1 || len(checksums[0].Document) != 1 || checksums[0].Document[0].Hash != parentHash {
+		t.Fatalf("Expected checksum content to be linked to: %v, found: %v", parentHash, checksums)
+	}
+	parent, err := doccache.GetByHash(parentHash, &RequestConfig{Certificates: true, Edges: []string{"duplicateedge"}})
+	if err != nil {
+		t.Fatalf("GetByHash failed: %v", err)
+	}
+	if parent.UID != fixtures.parentUID || len(parent.Certificates) != 2 || parent.Certificates[1].Certifier != "merged" || parent.Certificates[1].CertificationSequence != 2 {
+		t.Fatalf("Expected the certificate of the duplicate to be merged into: %v, found: %v", fixtures.parentUID, parent.Certificates)
+	}
+	if targets := parent.Edges["duplicateedge"]; len(targets) != 1 || targets[0].Hash != childHash {
+		t.Fatalf("Expected the outgoing edge of the duplicate to be copied to: %v, found: %v", fixtures.parentUID, parent.Edges)
+	}
+}
+
+//fixtureReport keeps only the problems introduced by the fixtures, so that the repair does not touch the data of other tests
+func fixtureReport(report *IntegrityReport, fixtures *integrityFixtures, parentHash string) *IntegrityReport {
+	fixtureReport := &IntegrityReport{
+		OrphanContentGroups: []string{fixtures.orphanContentGroup},
+		OrphanContents:      []string{fixtures.orphanContent},
+		DuplicateDocuments:  map[string][]string{parentHash: report.DuplicateDocuments[parentHash]},
+	}
+	for _, edge := range report.DanglingEdges {
+		if edge.FromUID == fixtures.parentUID && edge.ToUID == fixtures.danglingEdge {
+			fixtureReport.DanglingEdges = append(fixtureReport.DanglingEdges, edge)
+		}
+	}
+	for _, cursor := range report.ExtraCursors {
+		if cursor.UID == fixtures.extraCursor {
+			fixtureReport.ExtraCursors = append(fixtureReport.ExtraCursors, cursor)
+		}
+	}
+	for _, checksum := range report.UnlinkedChecksums {
+		if checksum.DocumentUID == fixtures.parentUID {
+			fixtureReport.UnlinkedChecksums = append(fixtureReport.UnlinkedChecksums, checksum)
+		}
+	}
+	return fixtureReport
+}
+
+func hasDanglingEdge(report *IntegrityReport, fixtures *integrityFixtures) bool {
+	for _, edge := range report.DanglingEdges {
+		if edge.FromUID == fixtures.parentUID && edge.ToUID == fixtures.danglingEdge && edge.Name == "dangling" {
+			return true
+		}
+	}
+	return false
+}
+
+func hasExtraCursor(report *IntegrityReport, fixtures *integrityFixtures) bool {
+	for _, cursor := range report.ExtraCursors {
+		if cursor.UID == fixtures.extraCursor {
+			return true
+		}
+	}
+	return false
+}
+
+func hasUnlinkedChecksum(report *IntegrityReport, fixtures *integrityFixtures) bool {
+	for _, checksum := range report.UnlinkedChecksums {
+		if checksum.DocumentUID == fixtures.parentUID {
+			return true
+		}
+	}
+	return false
+}
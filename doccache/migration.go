@@ -0,0 +1,322 @@
+package doccache
+
+import (
+	"fmt"
+
+	"github.com/sebastianmontero/dgraph-go-client/dgraph"
+)
+
+const schemaVersionSchema = `
+      type SchemaVersion {
+        schema_version
+      }
+
+      schema_version: int .
+    `
+
+//baseSchema document schema as it was before migrations were introduced, the Document type
+//is not included as its fields are added through DocumentFields to preserve edges
+const baseSchema = `
+      type ContentGroup {
+        content_group_sequence
+        contents
+      }
+
+      type Content {
+        label
+        value
+        type
+        content_sequence
+        document
+      }
+
+      type Certificate {
+        certifier
+        notes
+        certification_date
+        certification_sequence
+      }
+
+      type Cursor {
+        cursor
+      }
+
+      hash: string @index(exact) .
+      created_date: datetime .
+      creator: string @index(term) .
+      content_groups: [uid] .
+      certificates: [uid] .
+
+      content_group_sequence: int .
+      contents: [uid] .
+
+      label: string @index(term) .
+      value: string @index(term) .
+      type: string @index(term) .
+      content_sequence: int .
+      document: [uid] .
+
+      certifier: string @index(term) .
+      notes: string .
+      certification_date: datetime .
+      certification_sequence: int .
+
+      cursor: string @index(term) .
+    `
+
+const contentSchema = `
+      type Content {
+        label
+        value
+        type
+        int_value
+        time_value
+        asset_amount
+        asset_symbol
+        asset_precision
+        name_value
+        content_sequence
+        document
+      }
+
+      int_value: int @index(int) .
+      time_value: datetime @index(hour) .
+      asset_amount: float @index(float) .
+      asset_symbol: string @index(exact) .
+      asset_precision: int .
+      name_value: string @index(exact) .
+    `
+
+const invalidRowSchema = `
+      type InvalidRow {
+        table_name
+        operation
+        row_data
+        validation_errors
+        row_cursor
+        block_num
+      }
+
+      table_name: string @index(exact) .
+      operation: string .
+      row_data: string .
+      validation_errors: string .
+      row_cursor: string .
+      block_num: int @index(int) .
+    `
+
+//Migration numbered schema change, optionally adding fields to the Document type and backfilling data
+type Migration struct {
+	Version        int
+	Description    string
+	Schema         string
+	DocumentFields []string
+	Backfill       func(m *Doccache) error
+}
+
+func (m *Migration) String() string {
+	return fmt.Sprintf("Migration{Version: %v, Description: %v, DocumentFields: %v, Backfill: %v}", m.Version, m.Description, m.DocumentFields, m.Backfill != nil)
+}
+
+//Migrations schema migrations in the order they have to be applied, new migrations must be appended
+//with the next version number and existing ones must not be modified
+var Migrations = []*Migration{
+	{
+		Version:        1,
+		Description:    "Base document schema",
+		Schema:         baseSchema,
+		DocumentFields: []string{"hash", "created_date", "creator", "content_groups", "certificates"},
+	},
+	{
+		Version:     2,
+		Description: "Typed content values",
+		Schema:      contentSchema,
+		Backfill: func(m *Doccache) error {
+			_, err := m.BackfillTypedValues()
+			return err
+		},
+	},
+	{
+		Version:     3,
+		Description: "Invalid row quarantine",
+		Schema:      invalidRowSchema,
+	},
+	{
+		Version:        4,
+		Description:    "Promote system content group fields to document predicates",
+		Schema:         systemFieldsSchema,
+		DocumentFields: systemFields,
+		Backfill: func(m *Doccache) error {
+			_, err := m.BackfillSystemFields()
+			return err
+		},
+	},
+	{
+		Version:     5,
+		Description: "Normalize stored hashes",
+		Backfill: func(m *Doccache) error {
+			_, err := m.NormalizeStoredHashes(false)
+			return err
+		},
+	},
+	{
+		Version:     6,
+		Description: "Namespace edge predicates",
+		Schema:      edgeDefinitionSchema,
+		Backfill: func(m *Doccache) error {
+			err := m.loadEdges()
+			if err != nil {
+				return err
+			}
+			return m.namespaceLegacyEdges()
+		},
+	},
+	{
+		Version:     7,
+		Description: "Edge schema options",
+		Schema:      edgeOptionsSchema,
+		Backfill: func(m *Doccache) error {
+			err := m.loadEdges()
+			if err != nil {
+				return err
+			}
+			//existing definitions have no options stored yet, they get the configured or default ones
+			return m.applyEdgeOptions(m.edgeOptions)
+		},
+	},
+	{
+		Version:     8,
+		Description: "Document search indexes",
+		Schema:      searchSchema,
+	},
+	{
+		Version:     9,
+		Description: "Webhook delivery queue",
+		Schema:      webhookSchema,
+	},
+	{
+		Version:     10,
+		Description: "Document and edge versions",
+		Schema:      historySchema,
+		Backfill: func(m *Doccache) error {
+			err := m.loadEdges()
+			if err != nil {
+				return err
+			}
+			started, err := m.historyStarted()
+			if err != nil || started {
+				return err
+			}
+			cursor, err := m.getCursor()
+			if err != nil {
+				return err
+			}
+			return m.startHistory(cursor)
+		},
+	},
+	{
+		Version:     11,
+		Description: "Document revision transactions",
+		Schema:      revisionSchema,
+	},
+}
+
+//LatestSchemaVersion version of the last migration
+func LatestSchemaVersion() int {
+	return Migrations[len(Migrations)-1].Version
+}
+
+//PendingMigrations returns the current schema version and the migrations that have not been applied yet
+func PendingMigrations(dg *dgraph.Dgraph) (int, []*Migration, error) {
+	schemaVersion, err := getSchemaVersion(dg)
+	if err != nil {
+		return 0, nil, err
+	}
+	return schemaVersion.Version, pendingMigrations(schemaVersion.Version), nil
+}
+
+func pendingMigrations(version int) []*Migration {
+	pending := make([]*Migration, 0)
+	for _, migration := range Migrations {
+		if migration.Version > version {
+			pending = append(pending, migration)
+		}
+	}
+	return pending
+}
+
+func getSchemaVersion(dg *dgraph.Dgraph) (*SchemaVersion, error) {
+	query := `
+		{
+			versions(func: type(SchemaVersion)){
+				uid
+				schema_version
+				dgraph.type
+			}
+		}
+	`
+	versions := &SchemaVersions{}
+	err := dg.Query(query, nil, versions)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions.Versions) > 0 {
+		return versions.Versions[0], nil
+	}
+	return &SchemaVersion{
+		DType: []string{"SchemaVersion"},
+	}, nil
+}
+
+//GetSchemaVersion returns the version of the last applied migration
+func (m *Doccache) GetSchemaVersion() (int, error) {
+	schemaVersion, err := getSchemaVersion(m.dgraph)
+	if err != nil {
+		return 0, err
+	}
+	return schemaVersion.Version, nil
+}
+
+//migrate applies the pending migrations in order, storing the schema version after each one
+func (m *Doccache) migrate() error {
+	err := m.dgraph.UpdateSchema(schemaVersionSchema)
+	if err != nil {
+		return err
+	}
+	schemaVersion, err := getSchemaVersion(m.dgraph)
+	if err != nil {
+		return err
+	}
+	for _, migration := range pendingMigrations(schemaVersion.Version) {
+		log.Infof("Applying migration %v: %v", migration.Version, migration.Description)
+		err = m.applyMigration(migration)
+		if err != nil {
+			return fmt.Errorf("failed applying migration %v: %v, error: %v", migration.Version, migration.Description, err)
+		}
+		schemaVersion.Version = migration.Version
+		resp, err := m.dgraph.MutateJSON(schemaVersion, false)
+		if err != nil {
+			return err
+		}
+		for _, uid := range resp.GetUids() {
+			schemaVersion.UID = uid
+		}
+	}
+	return nil
+}
+
+func (m *Doccache) applyMigration(migration *Migration) error {
+	var err error
+	if len(migration.DocumentFields) > 0 {
+		err = m.addDocumentFields(migration.Schema, migration.DocumentFields...)
+	} else if migration.Schema != "" {
+		err = m.dgraph.UpdateSchema(migration.Schema)
+	}
+	if err != nil {
+		return err
+	}
+	if migration.Backfill != nil {
+		return migration.Backfill(m)
+	}
+	return nil
+}
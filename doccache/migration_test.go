@@ -0,0 +1,161 @@
+package doccache
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMigrations(t *testing.T) {
+	version, pending, err := PendingMigrations(dg)
+	if err != nil {
+		t.Fatalf("PendingMigrations failed: %v", err)
+	}
+	if version != LatestSchemaVersion() || len(pending) != 0 {
+		t.Fatalf("Expected schema to be at version: %v with no pending migrations, found version: %v, pending: %v", LatestSchemaVersion(), version, pending)
+	}
+
+	t.Log("Reapplying all migrations preserves edges and the history")
+	_, err = doccache.registerEdge("migrationedge")
+	if err != nil {
+		t.Fatalf("Failed to register edge: %v", err)
+	}
+	countVersions := func() int {
+		t.Helper()
+		result := &struct {
+			Versions []*struct {
+				Count int `json:"count"`
+			} `json:"versions,omitempty"`
+		}{}
+		err := dg.Query(`{versions(func: has(valid_from)){count(uid)}}`, nil, result)
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if len(result.Versions) == 0 {
+			return 0
+		}
+		return result.Versions[0].Count
+	}
+	versionCount := countVersions()
+	cursor, err := doccache.getCursor()
+	if err != nil {
+		t.Fatalf("getCursor failed: %v", err)
+	}
+	schemaVersion, err := getSchemaVersion(dg)
+	if err != nil {
+		t.Fatalf("getSchemaVersion failed: %v", err)
+	}
+	schemaVersion.Version = 0
+	_, err = dg.MutateJSON(schemaVersion, false)
+	if err != nil {
+		t.Fatalf("Failed to reset schema version: %v", err)
+	}
+	version, pending, err = PendingMigrations(dg)
+	if err != nil {
+		t.Fatalf("PendingMigrations failed: %v", err)
+	}
+	if version != 0 || len(pending) != len(Migrations) {
+		t.Fatalf("Expected all migrations to be pending, found version: %v, pending: %v", version, pending)
+	}
+	cache, err := New(dg, nil)
+	if err != nil {
+		t.Fatalf("Failed creating docCache: %v", err)
+	}
+	version, pending, err = PendingMigrations(dg)
+	if err != nil {
+		t.Fatalf("PendingMigrations failed: %v", err)
+	}
+	if version != LatestSchemaVersion() || len(pending) != 0 {
+		t.Fatalf("Expected migrations to be applied, found version: %v, pending: %v", version, pending)
+	}
+	fieldMap, err := dg.GetTypeFieldMap("Document")
+	if err != nil {
+		t.Fatalf("GetTypeFieldMap failed: %v", err)
+	}
+	for _, field := range []string{"hash", "doc_type", EdgePredicate("migrationedge")} {
+		if _, ok := fieldMap[field]; !ok {
+			t.Fatalf("Expected Document type to have field: %v, found: %v", field, fieldMap)
+		}
+	}
+	if cache.GetEdgeDefinition("migrationedge") == nil {
+		t.Fatalf("Expected edge: migrationedge to be registered, found: %v", cache.EdgeNames())
+	}
+	if count := countVersions(); count != versionCount {
+		t.Fatalf("Expected the %v recorded versions to be kept, found: %v", versionCount, count)
+	}
+	if cache.Cursor.HistoryStart != cursor.HistoryStart {
+		t.Fatalf("Expected the history to start at block: %v, found: %v", cursor.HistoryStart, cache.Cursor.HistoryStart)
+	}
+	versions := &SchemaVersions{}
+	err = dg.Query(`{versions(func: type(SchemaVersion)){uid}}`, nil, versions)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(versions.Versions) != 1 {
+		t.Fatalf("Expected a single schema version node, found: %v", versions.Versions)
+	}
+}
+
+func TestNamespaceLegacyEdges(t *testing.T) {
+	fromHash := "c3c0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c03"
+	toHash := "d4c0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c04"
+	for i, hash := range []string{fromHash, toHash} {
+		err := doccache.StoreDocument(&ChainDocument{
+			Hash:        hash,
+			CreatedDate: "2021-01-15T19:17:44",
+			Creator:     "dao.hypha",
+			ContentGroups: [][]*ChainContent{
+				{
+					{
+						Label: "legacy",
+						Value: []interface{}{"int64", int64(i)},
+					},
+				},
+			},
+		}, fmt.Sprintf("legacyedge%v", i))
+		if err != nil {
+			t.Fatalf("StoreDocument failed: %v", err)
+		}
+	}
+	fromUID, err := doccache.GetUID(fromHash)
+	if err != nil {
+		t.Fatalf("GetUID failed: %v", err)
+	}
+	toUID, err := doccache.GetUID(toHash)
+	if err != nil {
+		t.Fatalf("GetUID failed: %v", err)
+	}
+
+	t.Log("Storing edge under its chain name as before namespacing")
+	err = doccache.addDocumentFields("legacyedge: [uid] .", "legacyedge")
+	if err != nil {
+		t.Fatalf("Failed to add legacy edge to schema: %v", err)
+	}
+	_, err = dg.MutateNQuads(fmt.Sprintf("<%v> <legacyedge> <%v> .", fromUID, toUID), false)
+	if err != nil {
+		t.Fatalf("Failed to create legacy edge: %v", err)
+	}
+	schemaVersion, err := getSchemaVersion(dg)
+	if err != nil {
+		t.Fatalf("getSchemaVersion failed: %v", err)
+	}
+	schemaVersion.Version = 5
+	_, err = dg.MutateJSON(schemaVersion, false)
+	if err != nil {
+		t.Fatalf("Failed to reset schema version: %v", err)
+	}
+	cache, err := New(dg, nil)
+	if err != nil {
+		t.Fatalf("Failed creating docCache: %v", err)
+	}
+	if _, ok := cache.documentFieldMap["legacyedge"]; ok {
+		t.Fatalf("Expected legacy edge to be removed from Document type, found: %v", cache.documentFieldMap)
+	}
+	docMap, err := cache.GetByHashAsMap(fromHash, &RequestConfig{Edges: []string{"legacyedge"}})
+	if err != nil {
+		t.Fatalf("GetByHashAsMap failed: %v", err)
+	}
+	targets, ok := docMap["legacyedge"].([]interface{})
+	if !ok || len(targets) != 1 || targets[0].(map[string]interface{})["hash"] != toHash {
+		t.Fatalf("Expected edge legacyedge to point to: %v, found: %v", toHash, docMap)
+	}
+}
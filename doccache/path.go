@@ -0,0 +1,230 @@
+package doccache
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+//DefaultPathDepth maximum number of edges of a path when the depth is not specified
+const DefaultPathDepth = 10
+
+//MaxPathDepth maximum number of edges of a path
+const MaxPathDepth = 20
+
+//MaxNumPaths maximum number of paths that can be requested
+const MaxNumPaths = 10
+
+//shortestPathWeightKey key of the weight of a path in the shortest path query response
+const shortestPathWeightKey = "_weight_"
+
+//PathQuery finds the shortest paths between two documents
+type PathQuery struct {
+	From string
+	To   string
+	//Edges names of the edges the paths can use, all edges if empty
+	Edges []string
+	//Undirected also traverses the reverse indexed edges from their target to their source
+	Undirected bool
+	//NumPaths number of shortest paths to find, defaults to 1
+	NumPaths int
+	//Depth maximum number of edges of a path, defaults to DefaultPathDepth
+	Depth int
+	//RequestConfig fields to return for the documents in the paths, edges are not supported
+	RequestConfig *RequestConfig
+}
+
+func (m *PathQuery) String() string {
+	return fmt.Sprintf("PathQuery{From: %v, To: %v, Edges: %v, Undirected: %v, NumPaths: %v, Depth: %v, RequestConfig: %v}", m.From, m.To, m.Edges, m.Undirected, m.NumPaths, m.Depth, m.RequestConfig)
+}
+
+//PathStep document in a path, Edge is the name of the edge traversed to reach the document,
+//empty for the first document, Inbound indicates that the edge was traversed from its target to its source
+type PathStep struct {
+	Edge     string    `json:"edge,omitempty"`
+	Inbound  bool      `json:"inbound,omitempty"`
+	Document *Document `json:"document"`
+}
+
+func (m *PathStep) String() string {
+	return fmt.Sprintf("PathStep{Edge: %v, Inbound: %v, Document: %v}", m.Edge, m.Inbound, m.Document)
+}
+
+//Path sequence of documents that connects two documents
+type Path struct {
+	Steps  []*PathStep `json:"steps"`
+	Weight float64     `json:"weight"`
+}
+
+func (m *Path) String() string {
+	return fmt.Sprintf("Path{Steps: %v, Weight: %v}", m.Steps, m.Weight)
+}
+
+//DocumentNotFoundError indicates that a document required by the request does not exist
+type DocumentNotFoundError struct {
+	Hash string
+}
+
+func (m *DocumentNotFoundError) Error() string {
+	return fmt.Sprintf("document: %v not found", m.Hash)
+}
+
+//ShortestPaths finds the shortest paths between the documents ordered by length, no paths are returned
+//if the documents are not connected
+func (m *Doccache) ShortestPaths(q *PathQuery) ([]*Path, error) {
+	numPaths := q.NumPaths
+	if numPaths == 0 {
+		numPaths = 1
+	}
+	if numPaths < 0 || numPaths > MaxNumPaths {
+		return nil, invalidRequest("number of paths: %v must be between 1 and %v", numPaths, MaxNumPaths)
+	}
+	depth := q.Depth
+	if depth == 0 {
+		depth = DefaultPathDepth
+	}
+	if depth < 0 || depth > MaxPathDepth {
+		return nil, invalidRequest("path depth: %v must be between 1 and %v", depth, MaxPathDepth)
+	}
+	rc := q.RequestConfig
+	if rc == nil {
+		rc = &RequestConfig{}
+	}
+	if len(rc.Edges) > 0 || len(rc.InboundEdges) > 0 || len(rc.Traversals) > 0 {
+		return nil, invalidRequest("edges can not be requested for the documents of a path")
+	}
+	for _, hash := range []string{q.From, q.To} {
+		if !IsValidHash(hash) {
+			return nil, invalidRequest("invalid hash: %v, expected 64 hex characters", hash)
+		}
+	}
+	predicateEdges, predicates, err := m.pathPredicates(q.Edges, q.Undirected)
+	if err != nil {
+		return nil, err
+	}
+	hashUIDMap, err := m.GetHashUIDMap([]string{q.From, q.To})
+	if err != nil {
+		return nil, err
+	}
+	for _, hash := range []string{q.From, q.To} {
+		if _, ok := hashUIDMap[hash]; !ok {
+			return nil, &DocumentNotFoundError{Hash: hash}
+		}
+	}
+	request, err := configureRequest(rc)
+	if err != nil {
+		return nil, err
+	}
+	from, to := hashUIDMap[q.From], hashUIDMap[q.To]
+	if from == to {
+		docs, err := m.GetByHashes([]string{q.From}, rc)
+		if err != nil {
+			return nil, err
+		}
+		return []*Path{{Steps: []*PathStep{{Document: docs[0]}}}}, nil
+	}
+	query := fmt.Sprintf(`
+		{
+			path as shortest(from: %v, to: %v, numpaths: %v, depth: %v) {
+				%v
+			}
+			docs(func: uid(path))
+				%v
+		}
+	`, from, to, numPaths, depth, strings.Join(predicates, "\n"), request)
+	log.Debugf("Shortest path query: %v", query)
+
+	response := &struct {
+		Paths []map[string]interface{} `json:"_path_"`
+		Docs  []*Document              `json:"docs"`
+	}{}
+	err = m.dgraph.Query(query, nil, response)
+	if err != nil {
+		return nil, err
+	}
+	docs := make(map[string]*Document, len(response.Docs))
+	for _, doc := range response.Docs {
+		docs[doc.UID] = doc
+	}
+	paths := make([]*Path, 0, len(response.Paths))
+	for _, node := range response.Paths {
+		path, err := decodePath(node, docs, predicateEdges)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+//pathPredicates returns the edge names by predicate and the predicates the shortest path query can traverse
+func (m *Doccache) pathPredicates(edgeNames []string, undirected bool) (map[string]string, []string, error) {
+	if len(edgeNames) == 0 {
+		edgeNames = m.EdgeNames()
+	}
+	predicateEdges := make(map[string]string, len(edgeNames))
+	predicates := make([]string, 0, len(edgeNames)*2)
+	for _, edgeName := range edgeNames {
+		edge := m.GetEdgeDefinition(edgeName)
+		if edge == nil {
+			return nil, nil, invalidRequest("unknown edge: %v", edgeName)
+		}
+		predicateEdges[edge.Predicate] = edgeName
+		predicates = append(predicates, fmt.Sprintf("<%v>", edge.Predicate))
+		if undirected && edge.Reverse {
+			predicates = append(predicates, fmt.Sprintf("<~%v>", edge.Predicate))
+		}
+	}
+	if len(predicates) == 0 {
+		return nil, nil, invalidRequest("there are no edges to find paths through")
+	}
+	return predicateEdges, predicates, nil
+}
+
+//decodePath converts the nested path returned by the shortest path query, in which each document
+//contains the next one under the traversed predicate, into its steps
+func decodePath(node map[string]interface{}, docs map[string]*Document, predicateEdges map[string]string) (*Path, error) {
+	path := &Path{
+		Steps: make([]*PathStep, 0),
+	}
+	if weight, ok := node[shortestPathWeightKey].(float64); ok {
+		path.Weight = weight
+	}
+	step := &PathStep{}
+	for node != nil {
+		uid, _ := node["uid"].(string)
+		doc, ok := docs[uid]
+		if !ok {
+			data, _ := json.Marshal(node)
+			return nil, fmt.Errorf("document with uid: %v in path: %v not found", uid, string(data))
+		}
+		step.Document = doc
+		path.Steps = append(path.Steps, step)
+		step, node = nextPathNode(node, predicateEdges)
+	}
+	return path, nil
+}
+
+//nextPathNode finds the edge predicate under which the next document of the path is nested
+func nextPathNode(node map[string]interface{}, predicateEdges map[string]string) (*PathStep, map[string]interface{}) {
+	for key, value := range node {
+		edgeName, ok := predicateEdges[strings.TrimPrefix(key, "~")]
+		if !ok {
+			continue
+		}
+		step := &PathStep{
+			Edge:    edgeName,
+			Inbound: strings.HasPrefix(key, "~"),
+		}
+		switch next := value.(type) {
+		case map[string]interface{}:
+			return step, next
+		case []interface{}:
+			if len(next) > 0 {
+				nextNode, _ := next[0].(map[string]interface{})
+				return step, nextNode
+			}
+		}
+	}
+	return nil, nil
+}
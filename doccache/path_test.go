@@ -0,0 +1,145 @@
+package doccache
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDecodePath(t *testing.T) {
+	docs := map[string]*Document{
+		"0x1": {UID: "0x1", Hash: "h1"},
+		"0x2": {UID: "0x2", Hash: "h2"},
+		"0x3": {UID: "0x3", Hash: "h3"},
+	}
+	node := map[string]interface{}{
+		"uid":      "0x1",
+		"_weight_": float64(2),
+		"edge.owns": map[string]interface{}{
+			"uid": "0x2",
+			"~edge.member": []interface{}{
+				map[string]interface{}{"uid": "0x3"},
+			},
+		},
+	}
+	path, err := decodePath(node, docs, map[string]string{"edge.owns": "owns", "edge.member": "member"})
+	if err != nil {
+		t.Fatalf("decodePath failed: %v", err)
+	}
+	if path.Weight != 2 || len(path.Steps) != 3 {
+		t.Fatalf("Expected path with weight 2 and 3 steps, found: %v", path)
+	}
+	expected := []*PathStep{
+		{Document: docs["0x1"]},
+		{Edge: "owns", Document: docs["0x2"]},
+		{Edge: "member", Inbound: true, Document: docs["0x3"]},
+	}
+	for i, step := range path.Steps {
+		if step.Edge != expected[i].Edge || step.Inbound != expected[i].Inbound || step.Document != expected[i].Document {
+			t.Fatalf("Expected step: %v, found: %v", expected[i], step)
+		}
+	}
+	_, err = decodePath(map[string]interface{}{"uid": "0x9"}, docs, nil)
+	if err == nil {
+		t.Fatalf("Expected error decoding path with unknown document")
+	}
+}
+
+func TestShortestPaths(t *testing.T) {
+	hashes := []string{
+		"b1f0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c30",
+		"b2f0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c31",
+		"b3f0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c32",
+		"b4f0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c33",
+	}
+	for i, hash := range hashes {
+		err := doccache.StoreDocument(&ChainDocument{
+			Hash:        hash,
+			CreatedDate: fmt.Sprintf("2021-05-1%vT10:00:00", i),
+			Creator:     "pathfinder",
+		}, fmt.Sprintf("path%v", i))
+		if err != nil {
+			t.Fatalf("StoreDocument failed: %v", err)
+		}
+	}
+	edges := []*ChainEdge{
+		{Name: "ppayout", From: hashes[0], To: hashes[1]},
+		{Name: "precipient", From: hashes[1], To: hashes[2]},
+		{Name: "pshortcut", From: hashes[0], To: hashes[2]},
+	}
+	for i, edge := range edges {
+		err := doccache.MutateEdge(edge, false, fmt.Sprintf("pathedge%v", i))
+		if err != nil {
+			t.Fatalf("MutateEdge failed: %v", err)
+		}
+	}
+
+	paths, err := doccache.ShortestPaths(&PathQuery{From: hashes[0], To: hashes[2], Edges: []string{"ppayout", "precipient", "pshortcut"}})
+	if err != nil {
+		t.Fatalf("ShortestPaths failed: %v", err)
+	}
+	if len(paths) != 1 || len(paths[0].Steps) != 2 || paths[0].Steps[1].Edge != "pshortcut" || paths[0].Steps[1].Document.Hash != hashes[2] {
+		t.Fatalf("Expected shortcut path, found: %v", paths)
+	}
+	paths, err = doccache.ShortestPaths(&PathQuery{From: hashes[0], To: hashes[2], Edges: []string{"ppayout", "precipient", "pshortcut"}, NumPaths: 2})
+	if err != nil {
+		t.Fatalf("ShortestPaths failed: %v", err)
+	}
+	if len(paths) != 2 || len(paths[1].Steps) != 3 {
+		t.Fatalf("Expected shortcut and 2 hop paths, found: %v", paths)
+	}
+	paths, err = doccache.ShortestPaths(&PathQuery{From: hashes[0], To: hashes[2], Edges: []string{"ppayout", "precipient"}})
+	if err != nil {
+		t.Fatalf("ShortestPaths failed: %v", err)
+	}
+	if len(paths) != 1 || len(paths[0].Steps) != 3 || paths[0].Steps[1].Document.Hash != hashes[1] || paths[0].Steps[2].Edge != "precipient" {
+		t.Fatalf("Expected path through: %v, found: %v", hashes[1], paths)
+	}
+
+	paths, err = doccache.ShortestPaths(&PathQuery{From: hashes[2], To: hashes[0], Edges: []string{"pshortcut"}})
+	if err != nil {
+		t.Fatalf("ShortestPaths failed: %v", err)
+	}
+	if len(paths) != 0 {
+		t.Fatalf("Expected documents not to be connected against the edge direction, found: %v", paths)
+	}
+	paths, err = doccache.ShortestPaths(&PathQuery{From: hashes[2], To: hashes[0], Edges: []string{"pshortcut"}, Undirected: true})
+	if err != nil {
+		t.Fatalf("ShortestPaths failed: %v", err)
+	}
+	if len(paths) != 1 || len(paths[0].Steps) != 2 || !paths[0].Steps[1].Inbound {
+		t.Fatalf("Expected undirected path through inbound edge, found: %v", paths)
+	}
+	paths, err = doccache.ShortestPaths(&PathQuery{From: hashes[0], To: hashes[3]})
+	if err != nil {
+		t.Fatalf("ShortestPaths failed: %v", err)
+	}
+	if len(paths) != 0 {
+		t.Fatalf("Expected documents not to be connected, found: %v", paths)
+	}
+	paths, err = doccache.ShortestPaths(&PathQuery{From: hashes[3], To: hashes[3]})
+	if err != nil {
+		t.Fatalf("ShortestPaths failed: %v", err)
+	}
+	if len(paths) != 1 || len(paths[0].Steps) != 1 {
+		t.Fatalf("Expected single step path to itself, found: %v", paths)
+	}
+
+	_, err = doccache.ShortestPaths(&PathQuery{From: hashes[0], To: strings.Repeat("a", 64)})
+	if _, ok := err.(*DocumentNotFoundError); !ok {
+		t.Fatalf("Expected document not found error, found: %v", err)
+	}
+	for _, q := range []*PathQuery{
+		{From: hashes[0], To: "aaaa"},
+		{From: hashes[0], To: `a"]) OR has(hash`},
+		{From: hashes[0], To: hashes[2], Edges: []string{"unknown"}},
+		{From: hashes[0], To: hashes[2], NumPaths: MaxNumPaths + 1},
+		{From: hashes[0], To: hashes[2], Depth: MaxPathDepth + 1},
+		{From: hashes[0], To: hashes[2], RequestConfig: &RequestConfig{Edges: []string{"ppayout"}}},
+	} {
+		_, err = doccache.ShortestPaths(q)
+		if _, ok := err.(*InvalidRequestError); !ok {
+			t.Fatalf("Expected invalid request error for: %v, found: %v", q, err)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package doccache
+
+import (
+	"fmt"
+	"sort"
+)
+
+//revisionSchema adds the transaction that recorded each document version
+const revisionSchema = `
+      type DocumentVersion {
+        version_hash
+        version_creator
+        version_created_date
+        version_doc_type
+        version_document
+        version_trx_id
+        valid_from
+        valid_to
+      }
+
+      version_trx_id: string @index(exact) .
+    `
+
+//Revision version of a document numbered from 1 in the order they were recorded, BlockNum and TrxID identify
+//the change that recorded it, ValidTo is the block it was replaced at, or the document deleted at if it is the last one
+type Revision struct {
+	Number   int            `json:"number"`
+	Hash     string         `json:"hash"`
+	BlockNum uint64         `json:"block_num"`
+	TrxID    string         `json:"trx_id,omitempty"`
+	ValidTo  *uint64        `json:"valid_to,omitempty"`
+	Document *ChainDocument `json:"document,omitempty"`
+}
+
+func (m *Revision) String() string {
+	return fmt.Sprintf("Revision{Number: %v, Hash: %v, BlockNum: %v, TrxID: %v, ValidTo: %v, Document: %v}", m.Number, m.Hash, m.BlockNum, m.TrxID, m.ValidTo, m.Document)
+}
+
+//SetTrxID sets the transaction of the changes that follow, it is recorded with the document revisions
+func (m *Doccache) SetTrxID(trxID string) {
+	m.trxID = trxID
+}
+
+//loadRevisions finds the revisions of the document, the chain documents are only decoded if withDocuments is set
+func (m *Doccache) loadRevisions(hash string, withDocuments bool) ([]*Revision, error) {
+	hash = NormalizeHash(hash)
+	query := fmt.Sprintf(`
+		query versions($hash: string){
+			versions(func: eq(version_hash, $hash)) @filter(type(DocumentVersion)){
+				%v
+			}
+		}
+	`, documentVersionFields)
+	versions := &DocumentVersions{}
+	err := m.dgraph.Query(query, map[string]string{"$hash": hash}, versions)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions.Versions) == 0 {
+		return nil, &DocumentNotFoundError{Hash: hash}
+	}
+	//versions recorded at the same block are in the order their uids were assigned
+	sort.Slice(versions.Versions, func(i, j int) bool {
+		a, b := versions.Versions[i], versions.Versions[j]
+		if a.ValidFrom != b.ValidFrom {
+			return a.ValidFrom < b.ValidFrom
+		}
+		return uidLess(a.UID, b.UID)
+	})
+	revisions := make([]*Revision, 0, len(versions.Versions))
+	for i, version := range versions.Versions {
+		revision := &Revision{
+			Number:   i + 1,
+			Hash:     version.Hash,
+			BlockNum: version.ValidFrom,
+			TrxID:    version.TrxID,
+			ValidTo:  version.ValidTo,
+		}
+		if withDocuments {
+			revision.Document, err = version.ChainDocument()
+			if err != nil {
+				return nil, err
+			}
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, nil
+}
+
+//GetRevisions lists the revisions of the document without their contents, including those of deleted documents
+func (m *Doccache) GetRevisions(hash string) ([]*Revision, error) {
+	return m.loadRevisions(hash, false)
+}
+
+//GetRevision finds the revision of the document by number, with its contents and certificates
+func (m *Doccache) GetRevision(hash string, number int) (*Revision, error) {
+	revisions, err := m.loadRevisions(hash, true)
+	if err != nil {
+		return nil, err
+	}
+	if number < 1 || number > len(revisions) {
+		return nil, invalidRequest("revision: %v of document: %v does not exist, the document has %v revisions", number, hash, len(revisions))
+	}
+	return revisions[number-1], nil
+}
+
+//Change kind of difference between two revisions
+type Change string
+
+//Changes
+const (
+	ChangeAdded    Change = "added"
+	ChangeRemoved  Change = "removed"
+	ChangeModified Change = "modified"
+)
+
+//ContentValue type and value of a chain content
+type ContentValue struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+func newContentValue(chainContent *ChainContent) *ContentValue {
+	value := &ContentValue{}
+	if len(chainContent.Value) > 0 {
+		value.Type = fmt.Sprintf("%v", chainContent.Value[0])
+	}
+	if len(chainContent.Value) > 1 {
+		value.Value = fmt.Sprintf("%v", chainContent.Value[1])
+	}
+	return value
+}
+
+func (m *ContentValue) String() string {
+	return fmt.Sprintf("ContentValue{Type: %v, Value: %v}", m.Type, m.Value)
+}
+
+//ContentDiff change of a content, contents are matched by label and by Occurrence, their position among
+//the contents of the group with the same label
+type ContentDiff struct {
+	Label      string        `json:"label"`
+	Occurrence int           `json:"occurrence,omitempty"`
+	Change     Change        `json:"change"`
+	From       *ContentValue `json:"from,omitempty"`
+	To         *ContentValue `json:"to,omitempty"`
+}
+
+func (m *ContentDiff) String() string {
+	return fmt.Sprintf("ContentDiff{Label: %v, Occurrence: %v, Change: %v, From: %v, To: %v}", m.Label, m.Occurrence, m.Change, m.From, m.To)
+}
+
+//ContentGroupDiff changes of a content group, content groups are matched by sequence
+type ContentGroupDiff struct {
+	Sequence int            `json:"sequence"`
+	Label    string         `json:"label,omitempty"`
+	Change   Change         `json:"change"`
+	Contents []*ContentDiff `json:"contents"`
+}
+
+func (m *ContentGroupDiff) String() string {
+	return fmt.Sprintf("ContentGroupDiff{Sequence: %v, Label: %v, Change: %v, Contents: %v}", m.Sequence, m.Label, m.Change, m.Contents)
+}
+
+//CertificateDiff change of a certificate, certificates are matched by sequence
+type CertificateDiff struct {
+	Sequence int               `json:"sequence"`
+	Change   Change            `json:"change"`
+	From     *ChainCertificate `json:"from,omitempty"`
+	To       *ChainCertificate `json:"to,omitempty"`
+}
+
+func (m *CertificateDiff) String() string {
+	return fmt.Sprintf("CertificateDiff{Sequence: %v, Change: %v, From: %v, To: %v}", m.Sequence, m.Change, m.From, m.To)
+}
+
+//RevisionDiff differences between two revisions of a document, From and To do not include their contents
+type RevisionDiff struct {
+	Hash          string              `json:"hash"`
+	From          *Revision           `json:"from"`
+	To            *Revision           `json:"to"`
+	ContentGroups []*ContentGroupDiff `json:"content_groups"`
+	Certificates  []*CertificateDiff  `json:"certificates"`
+}
+
+func (m *RevisionDiff) String() string {
+	return fmt.Sprintf("RevisionDiff{Hash: %v, From: %v, To: %v, ContentGroups: %v, Certificates: %v}", m.Hash, m.From, m.To, m.ContentGroups, m.Certificates)
+}
+
+//DiffRevisions compares two revisions of the document by number, if to is 0 the last revision is used
+//and if from is 0 the one before to
+func (m *Doccache) DiffRevisions(hash string, from, to int) (*RevisionDiff, error) {
+	revisions, err := m.loadRevisions(hash, true)
+	if err != nil {
+		return nil, err
+	}
+	if to == 0 {
+		to = len(revisions)
+	}
+	if from == 0 {
+		from = to - 1
+	}
+	for _, number := range []int{from, to} {
+		if number < 1 || number > len(revisions) {
+			return nil, invalidRequest("revision: %v of document: %v does not exist, the document has %v revisions", number, hash, len(revisions))
+		}
+	}
+	fromRevision, toRevision := revisions[from-1], revisions[to-1]
+	diff := &RevisionDiff{
+		Hash: fromRevision.Hash,
+	}
+	diff.ContentGroups, diff.Certificates = DiffChainDocuments(fromRevision.Document, toRevision.Document)
+	fromSummary, toSummary := *fromRevision, *toRevision
+	fromSummary.Document, toSummary.Document = nil, nil
+	diff.From, diff.To = &fromSummary, &toSummary
+	return diff, nil
+}
+
+//DiffChainDocuments returns the content groups and certificates that changed between the chain documents
+func DiffChainDocuments(from, to *ChainDocument) ([]*ContentGroupDiff, []*CertificateDiff) {
+	contentGroups := make([]*ContentGroupDiff, 0)
+	for i := 0; i < len(from.ContentGroups) || i < len(to.ContentGroups); i++ {
+		var fromGroup, toGroup []*ChainContent
+		if i < len(from.ContentGroups) {
+			fromGroup = from.ContentGroups[i]
+		}
+		if i < len(to.ContentGroups) {
+			toGroup = to.ContentGroups[i]
+		}
+		groupDiff := &ContentGroupDiff{
+			Sequence: i + 1,
+			Label:    chainContentGroupLabel(toGroup),
+			Change:   ChangeModified,
+			Contents: diffContents(fromGroup, toGroup),
+		}
+		switch {
+		case i >= len(from.ContentGroups):
+			groupDiff.Change = ChangeAdded
+		case i >= len(to.ContentGroups):
+			groupDiff.Change = ChangeRemoved
+			groupDiff.Label = chainContentGroupLabel(fromGroup)
+		case len(groupDiff.Contents) == 0:
+			continue
+		}
+		contentGroups = append(contentGroups, groupDiff)
+	}
+
+	certificates := make([]*CertificateDiff, 0)
+	for i := 0; i < len(from.Certificates) || i < len(to.Certificates); i++ {
+		certificateDiff := &CertificateDiff{
+			Sequence: i + 1,
+			Change:   ChangeModified,
+		}
+		if i < len(from.Certificates) {
+			certificateDiff.From = from.Certificates[i]
+		}
+		if i < len(to.Certificates) {
+			certificateDiff.To = to.Certificates[i]
+		}
+		switch {
+		case certificateDiff.From == nil:
+			certificateDiff.Change = ChangeAdded
+		case certificateDiff.To == nil:
+			certificateDiff.Change = ChangeRemoved
+		case *certificateDiff.From == *certificateDiff.To:
+			continue
+		}
+		certificates = append(certificates, certificateDiff)
+	}
+	return contentGroups, certificates
+}
+
+//chainContentKey identifies a content within its group by label and occurrence of the label
+type chainContentKey struct {
+	label      string
+	occurrence int
+}
+
+//diffContents compares the contents of two content groups, in the order of the contents of the first and then the added ones
+func diffContents(from, to []*ChainContent) []*ContentDiff {
+	keys := func(contents []*ChainContent) ([]chainContentKey, map[chainContentKey]*ContentValue) {
+		ordered := make([]chainContentKey, 0, len(contents))
+		values := make(map[chainContentKey]*ContentValue, len(contents))
+		occurrences := make(map[string]int)
+		for _, content := range contents {
+			key := chainContentKey{label: content.Label, occurrence: occurrences[content.Label]}
+			occurrences[content.Label]++
+			ordered = append(ordered, key)
+			values[key] = newContentValue(content)
+		}
+		return ordered, values
+	}
+	fromKeys, fromValues := keys(from)
+	toKeys, toValues := keys(to)
+	diffs := make([]*ContentDiff, 0)
+	for _, key := range fromKeys {
+		fromValue, toValue := fromValues[key], toValues[key]
+		diff := &ContentDiff{Label: key.label, Occurrence: key.occurrence, From: fromValue, To: toValue, Change: ChangeModified}
+		if toValue == nil {
+			diff.Change = ChangeRemoved
+		} else if *fromValue == *toValue {
+			continue
+		}
+		diffs = append(diffs, diff)
+	}
+	for _, key := range toKeys {
+		if _, ok := fromValues[key]; !ok {
+			diffs = append(diffs, &ContentDiff{Label: key.label, Occurrence: key.occurrence, To: toValues[key], Change: ChangeAdded})
+		}
+	}
+	return diffs
+}
+
+//chainContentGroupLabel returns the value of the content_group_label content, empty if not present
+func chainContentGroupLabel(contents []*ChainContent) string {
+	for _, content := range contents {
+		if content.Label == "content_group_label" {
+			return newContentValue(content).Value
+		}
+	}
+	return ""
+}
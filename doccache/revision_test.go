@@ -0,0 +1,153 @@
+package doccache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffChainDocuments(t *testing.T) {
+	from := &ChainDocument{
+		ContentGroups: [][]*ChainContent{
+			{
+				{Label: "content_group_label", Value: []interface{}{"string", "settings"}},
+				{Label: "quorum", Value: []interface{}{"int64", 20}},
+				{Label: "voter", Value: []interface{}{"name", "alice"}},
+				{Label: "voter", Value: []interface{}{"name", "bob"}},
+			},
+			{
+				{Label: "content_group_label", Value: []interface{}{"string", "details"}},
+				{Label: "title", Value: []interface{}{"string", "unchanged"}},
+			},
+			{
+				{Label: "content_group_label", Value: []interface{}{"string", "legacy"}},
+			},
+		},
+		Certificates: []*ChainCertificate{
+			{Certifier: "auditor", Notes: "first", CertificationDate: "2021-07-01T10:00:00.000"},
+		},
+	}
+	to := &ChainDocument{
+		ContentGroups: [][]*ChainContent{
+			{
+				{Label: "content_group_label", Value: []interface{}{"string", "settings"}},
+				{Label: "quorum", Value: []interface{}{"int64", 25}},
+				{Label: "voter", Value: []interface{}{"name", "alice"}},
+				{Label: "threshold", Value: []interface{}{"int64", 50}},
+			},
+			{
+				{Label: "content_group_label", Value: []interface{}{"string", "details"}},
+				{Label: "title", Value: []interface{}{"string", "unchanged"}},
+			},
+		},
+		Certificates: []*ChainCertificate{
+			{Certifier: "auditor", Notes: "first", CertificationDate: "2021-07-01T10:00:00.000"},
+			{Certifier: "judge", Notes: "second", CertificationDate: "2021-07-02T10:00:00.000"},
+		},
+	}
+	contentGroups, certificates := DiffChainDocuments(from, to)
+	expectedGroups := []*ContentGroupDiff{
+		{
+			Sequence: 1,
+			Label:    "settings",
+			Change:   ChangeModified,
+			Contents: []*ContentDiff{
+				{Label: "quorum", Change: ChangeModified, From: &ContentValue{Type: "int64", Value: "20"}, To: &ContentValue{Type: "int64", Value: "25"}},
+				{Label: "voter", Occurrence: 1, Change: ChangeRemoved, From: &ContentValue{Type: "name", Value: "bob"}},
+				{Label: "threshold", Change: ChangeAdded, To: &ContentValue{Type: "int64", Value: "50"}},
+			},
+		},
+		{
+			Sequence: 3,
+			Label:    "legacy",
+			Change:   ChangeRemoved,
+			Contents: []*ContentDiff{
+				{Label: "content_group_label", Change: ChangeRemoved, From: &ContentValue{Type: "string", Value: "legacy"}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(expectedGroups, contentGroups) {
+		t.Fatalf("Expected content group diffs: %v, found: %v", expectedGroups, contentGroups)
+	}
+	if len(certificates) != 1 || certificates[0].Sequence != 2 || certificates[0].Change != ChangeAdded || certificates[0].To.Certifier != "judge" {
+		t.Fatalf("Expected added certificate, found: %v", certificates)
+	}
+	contentGroups, certificates = DiffChainDocuments(to, to)
+	if len(contentGroups) != 0 || len(certificates) != 0 {
+		t.Fatalf("Expected no differences, found: %v, %v", contentGroups, certificates)
+	}
+}
+
+func TestRevisions(t *testing.T) {
+	defer doccache.SetBlockNum(0)
+	defer doccache.SetTrxID("")
+	hash := "e1f0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c70"
+	chainDoc := &ChainDocument{
+		Hash:        hash,
+		CreatedDate: "2021-07-01T10:00:00.000",
+		Creator:     "reviser",
+		ContentGroups: [][]*ChainContent{
+			{
+				{Label: "content_group_label", Value: []interface{}{"string", "details"}},
+				{Label: "title", Value: []interface{}{"string", "revised"}},
+			},
+		},
+	}
+	doccache.SetBlockNum(500)
+	doccache.SetTrxID("trx1")
+	err := doccache.StoreDocument(chainDoc, "rev0")
+	if err != nil {
+		t.Fatalf("StoreDocument failed: %v", err)
+	}
+	err = doccache.StoreDocument(chainDoc, "rev1")
+	if err != nil {
+		t.Fatalf("StoreDocument failed: %v", err)
+	}
+	doccache.SetBlockNum(600)
+	doccache.SetTrxID("trx2")
+	chainDoc.Certificates = []*ChainCertificate{{Certifier: "auditor", Notes: "ok", CertificationDate: "2021-07-05T10:00:00.000"}}
+	err = doccache.StoreDocument(chainDoc, "rev2")
+	if err != nil {
+		t.Fatalf("StoreDocument failed: %v", err)
+	}
+
+	revisions, err := doccache.GetRevisions(hash)
+	if err != nil {
+		t.Fatalf("GetRevisions failed: %v", err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("Expected 2 revisions, unchanged stores are not recorded, found: %v", revisions)
+	}
+	if revisions[0].BlockNum != 500 || revisions[0].TrxID != "trx1" || revisions[0].ValidTo == nil || *revisions[0].ValidTo != 600 || revisions[0].Document != nil {
+		t.Fatalf("Expected first revision replaced at block 600 without document, found: %v", revisions[0])
+	}
+	if revisions[1].Number != 2 || revisions[1].TrxID != "trx2" || revisions[1].ValidTo != nil {
+		t.Fatalf("Expected current second revision, found: %v", revisions[1])
+	}
+	revision, err := doccache.GetRevision(hash, 1)
+	if err != nil {
+		t.Fatalf("GetRevision failed: %v", err)
+	}
+	if revision.Document == nil || len(revision.Document.ContentGroups) != 1 || len(revision.Document.Certificates) != 0 {
+		t.Fatalf("Expected first revision without certificates, found: %v", revision)
+	}
+	diff, err := doccache.DiffRevisions(hash, 0, 0)
+	if err != nil {
+		t.Fatalf("DiffRevisions failed: %v", err)
+	}
+	if diff.From.Number != 1 || diff.To.Number != 2 || len(diff.ContentGroups) != 0 || len(diff.Certificates) != 1 || diff.Certificates[0].Change != ChangeAdded {
+		t.Fatalf("Expected added certificate between revisions 1 and 2, found: %v", diff)
+	}
+
+	_, err = doccache.GetRevision(hash, 3)
+	if _, ok := err.(*InvalidRequestError); !ok {
+		t.Fatalf("Expected invalid request error, found: %v", err)
+	}
+	_, err = doccache.DiffRevisions(hash, 1, 5)
+	if _, ok := err.(*InvalidRequestError); !ok {
+		t.Fatalf("Expected invalid request error, found: %v", err)
+	}
+	_, err = doccache.GetRevisions("aaaa")
+	if _, ok := err.(*DocumentNotFoundError); !ok {
+		t.Fatalf("Expected document not found error, found: %v", err)
+	}
+}
@@ -0,0 +1,658 @@
+package doccache
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//DefaultSearchPageSize number of documents returned when the page size is not specified
+const DefaultSearchPageSize = 100
+
+//MaxSearchPageSize maximum number of documents returned per page
+const MaxSearchPageSize = 1000
+
+//searchSchema indexes required to filter and order documents, the reverse edges enable
+//finding the documents that contain a content
+const searchSchema = `
+      created_date: datetime @index(hour) .
+      content_groups: [uid] @reverse .
+      contents: [uid] @reverse .
+    `
+
+//SearchOrder document predicate to order the search results by
+type SearchOrder string
+
+//Search orders
+const (
+	OrderByCreatedDate SearchOrder = "created_date"
+	OrderByHash        SearchOrder = "hash"
+	//OrderByContent orders by the typed value of the content specified by the OrderContent of the query
+	OrderByContent SearchOrder = "content"
+)
+
+//TypedValue typed content predicate that can be filtered by range and ordered by
+type TypedValue string
+
+//Typed values
+const (
+	TypedInt   TypedValue = "int_value"
+	TypedTime  TypedValue = "time_value"
+	TypedAsset TypedValue = "asset_amount"
+)
+
+//parseBound validates a range bound of the typed value and returns it in the format stored in dgraph
+func (m TypedValue) parseBound(bound string) (string, error) {
+	var err error
+	switch m {
+	case TypedInt:
+		_, err = strconv.ParseInt(bound, 10, 64)
+	case TypedTime:
+		var t *time.Time
+		t, err = ParseTime(bound)
+		if err == nil {
+			bound = t.Format(time.RFC3339Nano)
+		}
+	case TypedAsset:
+		_, err = strconv.ParseFloat(bound, 64)
+	default:
+		return "", invalidSearch("invalid typed value: %v, expected %v, %v or %v", m, TypedInt, TypedTime, TypedAsset)
+	}
+	if err != nil {
+		return "", invalidSearch("invalid %v bound: %v", m, bound)
+	}
+	return bound, nil
+}
+
+//ContentFilter matches documents that have a content with the label and value,
+//if GroupLabel is set the content has to be in the content group with that label.
+//If Type is set the typed value of the content has to be greater or equal than From and less than To,
+//either bound can be empty, and for asset amounts the asset has to be of the Symbol if it is set
+type ContentFilter struct {
+	GroupLabel string
+	Label      string
+	Value      string
+	Type       TypedValue
+	From       string
+	To         string
+	Symbol     string
+}
+
+func (m *ContentFilter) String() string {
+	return fmt.Sprintf("ContentFilter{GroupLabel: %v, Label: %v, Value: %v, Type: %v, From: %v, To: %v, Symbol: %v}", m.GroupLabel, m.Label, m.Value, m.Type, m.From, m.To, m.Symbol)
+}
+
+//validate checks the filter, returns the range bounds in the format stored in dgraph
+func (m *ContentFilter) validate() (string, string, error) {
+	if m.Label == "" {
+		return "", "", invalidSearch("content filter: %v must have a label", m)
+	}
+	if m.Type == "" {
+		if m.From != "" || m.To != "" || m.Symbol != "" {
+			return "", "", invalidSearch("content filter: %v must have a type to filter by range", m)
+		}
+		return "", "", nil
+	}
+	if m.From == "" && m.To == "" {
+		return "", "", invalidSearch("content filter: %v must have a from or to bound", m)
+	}
+	if m.Symbol != "" && m.Type != TypedAsset {
+		return "", "", invalidSearch("content filter: %v can only have a symbol for %v", m, TypedAsset)
+	}
+	var from, to string
+	var err error
+	if m.From != "" {
+		from, err = m.Type.parseBound(m.From)
+		if err != nil {
+			return "", "", err
+		}
+	}
+	if m.To != "" {
+		to, err = m.Type.parseBound(m.To)
+		if err != nil {
+			return "", "", err
+		}
+	}
+	return from, to, nil
+}
+
+//matches indicates if the content passes the filter, the group label has to be checked by the caller
+func (m *ContentFilter) matches(content *Content) bool {
+	if content.Label != m.Label || (m.Value != "" && content.Value != m.Value) {
+		return false
+	}
+	switch m.Type {
+	case TypedInt:
+		return content.IntValue != nil && inRange(m.From, m.To, func(bound string) int {
+			value, _ := strconv.ParseInt(bound, 10, 64)
+			return compareInts(*content.IntValue, value)
+		})
+	case TypedTime:
+		return content.TimeValue != nil && inRange(m.From, m.To, func(bound string) int {
+			value, _ := ParseTime(bound)
+			return compareInts(content.TimeValue.UnixNano(), value.UnixNano())
+		})
+	case TypedAsset:
+		return content.AssetAmount != nil && (m.Symbol == "" || content.AssetSymbol == m.Symbol) && inRange(m.From, m.To, func(bound string) int {
+			value, _ := strconv.ParseFloat(bound, 64)
+			if *content.AssetAmount < value {
+				return -1
+			} else if *content.AssetAmount > value {
+				return 1
+			}
+			return 0
+		})
+	}
+	return true
+}
+
+//inRange indicates if a value is greater or equal than from and less than to, compare returns the
+//sign of the value minus the bound, empty bounds are not checked
+func inRange(from, to string, compare func(bound string) int) bool {
+	return (from == "" || compare(from) >= 0) && (to == "" || compare(to) < 0)
+}
+
+func compareInts(a, b int64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+//ContentOrder content whose typed value orders the documents when ordering by content, documents without the
+//content are not returned and if a document has several contents with the label the highest value is used
+type ContentOrder struct {
+	GroupLabel string
+	Label      string
+	Type       TypedValue
+}
+
+func (m *ContentOrder) String() string {
+	return fmt.Sprintf("ContentOrder{GroupLabel: %v, Label: %v, Type: %v}", m.GroupLabel, m.Label, m.Type)
+}
+
+//SearchQuery filters, order and page of a document search, all filters have to match
+type SearchQuery struct {
+	Creator string
+	//CreatedFrom inclusive lower bound of the created date
+	CreatedFrom *time.Time
+	//CreatedTo exclusive upper bound of the created date
+	CreatedTo *time.Time
+	DocType   string
+	Contents  []*ContentFilter
+	//HasEdges names of the edges the documents must have
+	HasEdges []string
+	//HasInboundEdges names of the edges that must point to the documents, requires reverse indexing
+	HasInboundEdges []string
+	OrderBy         SearchOrder
+	//OrderContent content to order by, required when ordering by content
+	OrderContent *ContentOrder
+	Desc         bool
+	First        int
+	//After cursor returned by the previous page
+	After string
+	//AsOfBlock searches the documents and edges as they were at the end of the block, the current state if 0
+	AsOfBlock uint64
+	//RequestConfig fields to return for each document
+	RequestConfig *RequestConfig
+}
+
+func (m *SearchQuery) String() string {
+	return fmt.Sprintf("SearchQuery{Creator: %v, CreatedFrom: %v, CreatedTo: %v, DocType: %v, Contents: %v, HasEdges: %v, HasInboundEdges: %v, OrderBy: %v, OrderContent: %v, Desc: %v, First: %v, After: %v, AsOfBlock: %v}", m.Creator, m.CreatedFrom, m.CreatedTo, m.DocType, m.Contents, m.HasEdges, m.HasInboundEdges, m.OrderBy, m.OrderContent, m.Desc, m.First, m.After, m.AsOfBlock)
+}
+
+//SearchResult page of documents, Cursor is empty if there are no more pages
+type SearchResult struct {
+	Docs   []*Document
+	Cursor string
+}
+
+//SearchResultAsMap page of documents as maps, Cursor is empty if there are no more pages
+type SearchResultAsMap struct {
+	Docs   []map[string]interface{}
+	Cursor string
+}
+
+//InvalidSearchError indicates that the search query is not valid
+type InvalidSearchError struct {
+	Reason string
+}
+
+func (m *InvalidSearchError) Error() string {
+	return m.Reason
+}
+
+func invalidSearch(format string, a ...interface{}) error {
+	return &InvalidSearchError{
+		Reason: fmt.Sprintf(format, a...),
+	}
+}
+
+//searchCursor position of the last document of a page, Value is the typed value of the content when ordering by content
+type searchCursor struct {
+	CreatedDate string `json:"c,omitempty"`
+	Value       string `json:"v,omitempty"`
+	Hash        string `json:"h"`
+}
+
+func encodeSearchCursor(createdDate, hash string) string {
+	data, _ := json.Marshal(&searchCursor{CreatedDate: createdDate, Hash: hash})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func encodeContentSearchCursor(value, hash string) string {
+	data, _ := json.Marshal(&searchCursor{Value: value, Hash: hash})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSearchCursor(cursor string) (*searchCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, invalidSearch("invalid search cursor: %v", cursor)
+	}
+	decoded := &searchCursor{}
+	err = json.Unmarshal(data, decoded)
+	if err != nil || decoded.Hash == "" {
+		return nil, invalidSearch("invalid search cursor: %v", cursor)
+	}
+	return decoded, nil
+}
+
+//Search finds the documents that match the query
+func (m *Doccache) Search(q *SearchQuery) (*SearchResult, error) {
+	if q.AsOfBlock > 0 {
+		return m.searchAsOf(q)
+	}
+	if q.OrderBy == OrderByContent {
+		return m.searchByContent(q)
+	}
+	query, vars, first, err := m.searchQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	docs := &Docs{}
+	err = m.dgraph.Query(query, vars, docs)
+	if err != nil {
+		return nil, err
+	}
+	result := &SearchResult{
+		Docs: docs.Docs,
+	}
+	if len(docs.Docs) > first {
+		result.Docs = docs.Docs[:first]
+		last := result.Docs[first-1]
+		createdDate := ""
+		if last.CreatedDate != nil {
+			createdDate = last.CreatedDate.Format(time.RFC3339Nano)
+		}
+		result.Cursor = encodeSearchCursor(createdDate, last.Hash)
+	}
+	return result, nil
+}
+
+//SearchAsMap finds the documents that match the query returns maps
+func (m *Doccache) SearchAsMap(q *SearchQuery) (*SearchResultAsMap, error) {
+	if q.AsOfBlock > 0 {
+		return m.searchAsOfAsMap(q)
+	}
+	if q.OrderBy == OrderByContent {
+		result, err := m.searchByContent(q)
+		if err != nil {
+			return nil, err
+		}
+		return searchResultAsMap(result)
+	}
+	query, vars, first, err := m.searchQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	docs := &struct {
+		Docs []map[string]interface{} `json:"docs,omitempty"`
+	}{}
+	err = m.dgraph.Query(query, vars, docs)
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range docs.Docs {
+		decodeDocumentMap(doc)
+	}
+	result := &SearchResultAsMap{
+		Docs: docs.Docs,
+	}
+	if len(docs.Docs) > first {
+		result.Docs = docs.Docs[:first]
+		last := result.Docs[first-1]
+		createdDate, _ := last["created_date"].(string)
+		hash, _ := last["hash"].(string)
+		result.Cursor = encodeSearchCursor(createdDate, hash)
+	}
+	return result, nil
+}
+
+//searchQuery builds the search query and its variables, one more document than the page size
+//is requested to know if there is a next page
+func (m *Doccache) searchQuery(q *SearchQuery) (string, map[string]string, int, error) {
+	first := q.First
+	if first <= 0 {
+		first = DefaultSearchPageSize
+	}
+	if first > MaxSearchPageSize {
+		return "", nil, 0, invalidSearch("page size: %v exceeds the maximum: %v", first, MaxSearchPageSize)
+	}
+	orderBy := q.OrderBy
+	if orderBy == "" {
+		orderBy = OrderByCreatedDate
+	}
+	if orderBy != OrderByCreatedDate && orderBy != OrderByHash && orderBy != OrderByContent {
+		return "", nil, 0, invalidSearch("invalid search order: %v", orderBy)
+	}
+	if orderBy == OrderByContent {
+		if q.OrderContent == nil || q.OrderContent.Label == "" {
+			return "", nil, 0, invalidSearch("ordering by content requires the label of the content")
+		}
+		if q.OrderContent.Type != TypedInt && q.OrderContent.Type != TypedTime && q.OrderContent.Type != TypedAsset {
+			return "", nil, 0, invalidSearch("invalid typed value: %v, expected %v, %v or %v", q.OrderContent.Type, TypedInt, TypedTime, TypedAsset)
+		}
+	}
+	rc := q.RequestConfig
+	if rc == nil {
+		rc = &RequestConfig{}
+	}
+
+	vars := make(map[string]string)
+	declarations := make([]string, 0)
+	addVar := func(name, value string) string {
+		name = "$" + name
+		vars[name] = value
+		declarations = append(declarations, name+": string")
+		return name
+	}
+	blocks := make([]string, 0)
+	filters := make([]string, 0)
+
+	if q.Creator != "" {
+		filters = append(filters, fmt.Sprintf("eq(creator, %v)", addVar("creator", q.Creator)))
+	}
+	if q.CreatedFrom != nil {
+		filters = append(filters, fmt.Sprintf("ge(created_date, %v)", addVar("createdFrom", q.CreatedFrom.UTC().Format(time.RFC3339Nano))))
+	}
+	if q.CreatedTo != nil {
+		filters = append(filters, fmt.Sprintf("lt(created_date, %v)", addVar("createdTo", q.CreatedTo.UTC().Format(time.RFC3339Nano))))
+	}
+	if q.DocType != "" {
+		filters = append(filters, fmt.Sprintf("eq(doc_type, %v)", addVar("docType", q.DocType)))
+	}
+	for i, content := range q.Contents {
+		from, to, err := content.validate()
+		if err != nil {
+			return "", nil, 0, err
+		}
+		groupFilter := ""
+		if content.GroupLabel != "" {
+			blocks = append(blocks, fmt.Sprintf(`
+				var(func: eq(label, "content_group_label")) @filter(eq(value, %v)){
+					group%v as ~contents
+				}
+			`, addVar(fmt.Sprintf("group%v", i), content.GroupLabel), i))
+			groupFilter = fmt.Sprintf("@filter(uid(group%v))", i)
+		}
+		valueFilters := make([]string, 0)
+		if content.Value != "" {
+			valueFilters = append(valueFilters, fmt.Sprintf("eq(value, %v)", addVar(fmt.Sprintf("value%v", i), content.Value)))
+		}
+		if from != "" {
+			valueFilters = append(valueFilters, fmt.Sprintf("ge(%v, %v)", content.Type, addVar(fmt.Sprintf("from%v", i), from)))
+		}
+		if to != "" {
+			valueFilters = append(valueFilters, fmt.Sprintf("lt(%v, %v)", content.Type, addVar(fmt.Sprintf("to%v", i), to)))
+		}
+		if content.Symbol != "" {
+			valueFilters = append(valueFilters, fmt.Sprintf("eq(asset_symbol, %v)", addVar(fmt.Sprintf("symbol%v", i), content.Symbol)))
+		}
+		valueFilter := ""
+		if len(valueFilters) > 0 {
+			valueFilter = fmt.Sprintf("@filter(%v)", strings.Join(valueFilters, " AND "))
+		}
+		blocks = append(blocks, fmt.Sprintf(`
+			var(func: eq(label, %v)) %v {
+				~contents %v {
+					content%v as ~content_groups
+				}
+			}
+		`, addVar(fmt.Sprintf("label%v", i), content.Label), valueFilter, groupFilter, i))
+		filters = append(filters, fmt.Sprintf("uid(content%v)", i))
+	}
+	for _, edgeName := range q.HasEdges {
+		edge := m.GetEdgeDefinition(edgeName)
+		if edge == nil {
+			return "", nil, 0, invalidSearch("unknown edge: %v", edgeName)
+		}
+		filters = append(filters, fmt.Sprintf("has(<%v>)", edge.Predicate))
+	}
+	for _, edgeName := range q.HasInboundEdges {
+		edge := m.GetEdgeDefinition(edgeName)
+		if edge == nil {
+			return "", nil, 0, invalidSearch("unknown edge: %v", edgeName)
+		}
+		if !edge.Reverse {
+			return "", nil, 0, invalidSearch("edge: %v does not have reverse indexing enabled", edgeName)
+		}
+		filters = append(filters, fmt.Sprintf("has(<~%v>)", edge.Predicate))
+	}
+
+	if orderBy == OrderByContent {
+		blocks = append(blocks, contentOrderBlock(q.OrderContent, addVar))
+		filters = append(filters, "type(Document)")
+		header := fmt.Sprintf("query search(%v)", strings.Join(declarations, ", "))
+		query := fmt.Sprintf(`
+			%v{
+				%v
+				docs(func: uid(orderValue)) @filter(%v){
+					hash
+					search_order_value: val(orderValue)
+				}
+			}
+		`, header, strings.Join(blocks, "\n"), strings.Join(filters, " AND "))
+		log.Debugf("Search by content query: %v, vars: %v", query, vars)
+		return query, vars, first, nil
+	}
+
+	direction, comparator := "orderasc", "gt"
+	if q.Desc {
+		direction, comparator = "orderdesc", "lt"
+	}
+	order := fmt.Sprintf("%v: hash", direction)
+	if orderBy == OrderByCreatedDate {
+		order = fmt.Sprintf("%v: created_date, %v", direction, order)
+	}
+	if q.After != "" {
+		cursor, err := decodeSearchCursor(q.After)
+		if err != nil {
+			return "", nil, 0, err
+		}
+		hashFilter := fmt.Sprintf("%v(hash, %v)", comparator, addVar("afterHash", cursor.Hash))
+		if orderBy == OrderByCreatedDate && cursor.CreatedDate != "" {
+			createdDate := addVar("afterCreatedDate", cursor.CreatedDate)
+			hashFilter = fmt.Sprintf("(%v(created_date, %v) OR (eq(created_date, %v) AND %v))", comparator, createdDate, createdDate, hashFilter)
+		}
+		filters = append(filters, hashFilter)
+	}
+
+	filter := ""
+	if len(filters) > 0 {
+		filter = fmt.Sprintf("@filter(%v)", strings.Join(filters, " AND "))
+	}
+	header := ""
+	if len(declarations) > 0 {
+		header = fmt.Sprintf("query search(%v)", strings.Join(declarations, ", "))
+	}
+	request, err := configureRequest(rc)
+	if err != nil {
+		return "", nil, 0, err
+	}
+	query := fmt.Sprintf(`
+		%v{
+			%v
+			docs(func: type(Document), first: %v, %v) %v
+				%v
+		}
+	`, header, strings.Join(blocks, "\n"), first+1, order, filter, request)
+	log.Debugf("Search query: %v, vars: %v", query, vars)
+	return query, vars, first, nil
+}
+
+//contentOrderBlock sets the orderValue variable of the documents to the highest typed value of their contents with the label,
+//the value is aggregated per content group and then per document
+func contentOrderBlock(order *ContentOrder, addVar func(name, value string) string) string {
+	label := addVar("orderLabel", order.Label)
+	groupBlock, groupFilter := "", ""
+	if order.GroupLabel != "" {
+		groupBlock = fmt.Sprintf(`
+			var(func: eq(label, "content_group_label")) @filter(eq(value, %v)){
+				orderGroups as ~contents
+			}
+		`, addVar("orderGroup", order.GroupLabel))
+		groupFilter = "@filter(uid(orderGroups))"
+	}
+	return fmt.Sprintf(`
+		%v
+		var(func: eq(label, %v)) @filter(has(%v)){
+			~contents %v {
+				orderDocs as ~content_groups
+			}
+		}
+		var(func: uid(orderDocs)){
+			content_groups %v {
+				contents @filter(eq(label, %v)){
+					orderContent as %v
+				}
+				orderGroupValue as max(val(orderContent))
+			}
+			orderValue as max(val(orderGroupValue))
+		}
+	`, groupBlock, label, order.Type, groupFilter, groupFilter, label, order.Type)
+}
+
+//contentOrderValue typed value of the content a document is ordered by
+type contentOrderValue struct {
+	Hash  string          `json:"hash"`
+	Value json.RawMessage `json:"search_order_value"`
+}
+
+//compareTypedValues returns the sign of a minus b, a and b are typed values as returned by dgraph
+func compareTypedValues(valueType TypedValue, a, b string) int {
+	switch valueType {
+	case TypedInt:
+		aInt, _ := strconv.ParseInt(a, 10, 64)
+		bInt, _ := strconv.ParseInt(b, 10, 64)
+		return compareInts(aInt, bInt)
+	case TypedAsset:
+		aFloat, _ := strconv.ParseFloat(a, 64)
+		bFloat, _ := strconv.ParseFloat(b, 64)
+		if aFloat < bFloat {
+			return -1
+		} else if aFloat > bFloat {
+			return 1
+		}
+		return 0
+	}
+	aTime, aErr := time.Parse(time.RFC3339Nano, a)
+	bTime, bErr := time.Parse(time.RFC3339Nano, b)
+	if aErr != nil || bErr != nil {
+		return strings.Compare(a, b)
+	}
+	return compareInts(aTime.UnixNano(), bTime.UnixNano())
+}
+
+//searchByContent finds the documents ordered by the typed value of a content, the values of all the documents
+//that match the filters are read and sorted by value and hash, as dgraph can not order by a value variable and
+//a predicate together, which is required for stable pages
+func (m *Doccache) searchByContent(q *SearchQuery) (*SearchResult, error) {
+	query, vars, first, err := m.searchQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	response := &struct {
+		Docs []*contentOrderValue `json:"docs,omitempty"`
+	}{}
+	err = m.dgraph.Query(query, vars, response)
+	if err != nil {
+		return nil, err
+	}
+	values := response.Docs
+	for _, value := range values {
+		value.Value = json.RawMessage(strings.Trim(string(value.Value), `"`))
+	}
+	valueType := q.OrderContent.Type
+	less := func(a, b *contentOrderValue) bool {
+		cmp := compareTypedValues(valueType, string(a.Value), string(b.Value))
+		if cmp == 0 {
+			cmp = strings.Compare(a.Hash, b.Hash)
+		}
+		if q.Desc {
+			return cmp > 0
+		}
+		return cmp < 0
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return less(values[i], values[j])
+	})
+	if q.After != "" {
+		cursor, err := decodeSearchCursor(q.After)
+		if err != nil {
+			return nil, err
+		}
+		after := &contentOrderValue{Hash: cursor.Hash, Value: json.RawMessage(cursor.Value)}
+		start := sort.Search(len(values), func(i int) bool {
+			return less(after, values[i])
+		})
+		values = values[start:]
+	}
+	result := &SearchResult{}
+	if len(values) > first {
+		values = values[:first]
+		last := values[first-1]
+		result.Cursor = encodeContentSearchCursor(string(last.Value), last.Hash)
+	}
+	hashes := make([]string, 0, len(values))
+	for _, value := range values {
+		hashes = append(hashes, value.Hash)
+	}
+	docs, err := m.GetByHashes(hashes, q.RequestConfig)
+	if err != nil {
+		return nil, err
+	}
+	docMap := make(map[string]*Document, len(docs))
+	for _, doc := range docs {
+		docMap[doc.Hash] = doc
+	}
+	result.Docs = make([]*Document, 0, len(hashes))
+	for _, hash := range hashes {
+		if doc, ok := docMap[hash]; ok {
+			result.Docs = append(result.Docs, doc)
+		}
+	}
+	return result, nil
+}
+
+//searchResultAsMap converts the documents of the result to maps
+func searchResultAsMap(result *SearchResult) (*SearchResultAsMap, error) {
+	docs := make([]map[string]interface{}, 0, len(result.Docs))
+	for _, doc := range result.Docs {
+		docMap, err := DocumentAsMap(doc)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, docMap)
+	}
+	return &SearchResultAsMap{
+		Docs:   docs,
+		Cursor: result.Cursor,
+	}, nil
+}
@@ -0,0 +1,278 @@
+package doccache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSearchCursor(t *testing.T) {
+	cursor := encodeSearchCursor("2021-02-15T19:17:44Z", "a1")
+	decoded, err := decodeSearchCursor(cursor)
+	if err != nil {
+		t.Fatalf("decodeSearchCursor failed: %v", err)
+	}
+	if decoded.CreatedDate != "2021-02-15T19:17:44Z" || decoded.Hash != "a1" {
+		t.Fatalf("Expected cursor to be decoded, found: %v", decoded)
+	}
+	for _, invalid := range []string{"not a cursor", encodeSearchCursor("2021-02-15T19:17:44Z", "")} {
+		_, err = decodeSearchCursor(invalid)
+		if err == nil {
+			t.Fatalf("Expected error decoding invalid cursor: %v", invalid)
+		}
+	}
+}
+
+func TestContentFilter(t *testing.T) {
+	for _, invalid := range []*ContentFilter{
+		{Value: "even"},
+		{Label: "score", From: "10"},
+		{Label: "score", Type: TypedInt},
+		{Label: "score", Type: TypedInt, From: "ten"},
+		{Label: "score", Type: TypedInt, Symbol: "USD", From: "10"},
+		{Label: "score", Type: "float_value", From: "10"},
+	} {
+		if _, _, err := invalid.validate(); err == nil {
+			t.Fatalf("Expected error validating content filter: %v", invalid)
+		}
+	}
+	from, to, err := (&ContentFilter{Label: "updated_date", Type: TypedTime, From: "2021-01-11T21:52:32", To: "2021-02-11T21:52:32Z"}).validate()
+	if err != nil {
+		t.Fatalf("validate failed: %v", err)
+	}
+	if from != "2021-01-11T21:52:32Z" || to != "2021-02-11T21:52:32Z" {
+		t.Fatalf("Expected time bounds to be normalized, found: %v, %v", from, to)
+	}
+
+	score, amount := int64(20), 190000.0
+	content := &Content{Label: "score", Value: "20", IntValue: &score}
+	asset := &Content{Label: "salary", Value: "190000.00 USD", AssetAmount: &amount, AssetSymbol: "USD"}
+	for filter, expected := range map[*ContentFilter]bool{
+		{Label: "score", Type: TypedInt, From: "20"}:                          true,
+		{Label: "score", Type: TypedInt, From: "10", To: "20"}:                false,
+		{Label: "score", Type: TypedInt, To: "21"}:                            true,
+		{Label: "score", Type: TypedTime, From: "2021-01-11T21:52:32"}:        false,
+		{Label: "other", Type: TypedInt, From: "10"}:                          false,
+		{Label: "salary", Type: TypedAsset, Symbol: "USD", From: "100000"}:    true,
+		{Label: "salary", Type: TypedAsset, Symbol: "HVOICE", From: "100000"}: false,
+		{Label: "salary", Type: TypedAsset, From: "200000"}:                   false,
+	} {
+		matched := filter.matches(content) || filter.matches(asset)
+		if matched != expected {
+			t.Fatalf("Expected content filter: %v to match: %v, found: %v", filter, expected, matched)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	hashes := []string{
+		"b8c0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c08",
+		"c9c0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c09",
+		"d0c0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c10",
+	}
+	scores := []float64{30, 10, 20}
+	for i, hash := range hashes {
+		err := doccache.StoreDocument(&ChainDocument{
+			Hash:        hash,
+			CreatedDate: fmt.Sprintf("2021-02-1%vT19:17:44", i+5),
+			Creator:     "searcher",
+			ContentGroups: [][]*ChainContent{
+				{
+					{
+						Label: "content_group_label",
+						Value: []interface{}{"string", "system"},
+					},
+					{
+						Label: "type",
+						Value: []interface{}{"name", "searchable"},
+					},
+				},
+				{
+					{
+						Label: "content_group_label",
+						Value: []interface{}{"string", "details"},
+					},
+					{
+						Label: "parity",
+						Value: []interface{}{"string", []string{"even", "odd"}[i%2]},
+					},
+					{
+						Label: "score",
+						Value: []interface{}{"int64", scores[i]},
+					},
+					{
+						Label: "budget",
+						Value: []interface{}{"asset", fmt.Sprintf("%v.00 USD", scores[i]*10)},
+					},
+				},
+			},
+		}, fmt.Sprintf("search%v", i))
+		if err != nil {
+			t.Fatalf("StoreDocument failed: %v", err)
+		}
+	}
+	err := doccache.MutateEdge(&ChainEdge{Name: "searchedge", From: hashes[0], To: hashes[1]}, false, "search3")
+	if err != nil {
+		t.Fatalf("MutateEdge failed: %v", err)
+	}
+
+	assertHashes := func(docs []*Document, expected ...string) {
+		t.Helper()
+		if len(docs) != len(expected) {
+			t.Fatalf("Expected %v documents, found: %v", len(expected), docs)
+		}
+		for i, doc := range docs {
+			if doc.Hash != expected[i] {
+				t.Fatalf("Expected document %v to have hash: %v, found: %v", i, expected[i], doc.Hash)
+			}
+		}
+	}
+
+	result, err := doccache.Search(&SearchQuery{Creator: "searcher"})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	assertHashes(result.Docs, hashes...)
+	if result.Cursor != "" {
+		t.Fatalf("Expected no cursor for last page, found: %v", result.Cursor)
+	}
+
+	t.Log("Filtering by created date and type")
+	from := time.Date(2021, 2, 16, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2021, 2, 17, 19, 17, 44, 0, time.UTC)
+	result, err = doccache.Search(&SearchQuery{DocType: "searchable", CreatedFrom: &from, CreatedTo: &to})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	assertHashes(result.Docs, hashes[1])
+
+	t.Log("Filtering by content scoped by content group")
+	result, err = doccache.Search(&SearchQuery{
+		Creator:  "searcher",
+		Contents: []*ContentFilter{{GroupLabel: "details", Label: "parity", Value: "even"}},
+		Desc:     true,
+	})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	assertHashes(result.Docs, hashes[2], hashes[0])
+	result, err = doccache.Search(&SearchQuery{
+		Creator:  "searcher",
+		Contents: []*ContentFilter{{GroupLabel: "system", Label: "parity", Value: "even"}},
+	})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	assertHashes(result.Docs)
+
+	t.Log("Filtering by typed content ranges")
+	result, err = doccache.Search(&SearchQuery{
+		Creator:  "searcher",
+		Contents: []*ContentFilter{{GroupLabel: "details", Label: "score", Type: TypedInt, From: "20"}},
+	})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	assertHashes(result.Docs, hashes[0], hashes[2])
+	result, err = doccache.Search(&SearchQuery{
+		Creator:  "searcher",
+		Contents: []*ContentFilter{{Label: "budget", Type: TypedAsset, Symbol: "USD", From: "100", To: "300"}},
+	})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	assertHashes(result.Docs, hashes[1], hashes[2])
+	result, err = doccache.Search(&SearchQuery{
+		Creator:  "searcher",
+		Contents: []*ContentFilter{{Label: "budget", Type: TypedAsset, Symbol: "HVOICE", From: "100"}},
+	})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	assertHashes(result.Docs)
+	_, err = doccache.Search(&SearchQuery{Contents: []*ContentFilter{{Label: "score", Type: TypedInt, From: "high"}}})
+	if _, ok := err.(*InvalidSearchError); !ok {
+		t.Fatalf("Expected invalid search error for invalid bound, found: %v", err)
+	}
+
+	t.Log("Ordering by typed content")
+	for _, desc := range []bool{false, true} {
+		found := make([]*Document, 0)
+		after := ""
+		for {
+			page, err := doccache.Search(&SearchQuery{
+				Creator:      "searcher",
+				OrderBy:      OrderByContent,
+				OrderContent: &ContentOrder{GroupLabel: "details", Label: "score", Type: TypedInt},
+				Desc:         desc,
+				First:        2,
+				After:        after,
+			})
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			found = append(found, page.Docs...)
+			if page.Cursor == "" {
+				break
+			}
+			after = page.Cursor
+		}
+		if desc {
+			assertHashes(found, hashes[0], hashes[2], hashes[1])
+		} else {
+			assertHashes(found, hashes[1], hashes[2], hashes[0])
+		}
+	}
+	_, err = doccache.Search(&SearchQuery{OrderBy: OrderByContent})
+	if _, ok := err.(*InvalidSearchError); !ok {
+		t.Fatalf("Expected invalid search error ordering by content without content, found: %v", err)
+	}
+
+	t.Log("Filtering by edge existence")
+	result, err = doccache.Search(&SearchQuery{Creator: "searcher", HasEdges: []string{"searchedge"}})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	assertHashes(result.Docs, hashes[0])
+	result, err = doccache.Search(&SearchQuery{Creator: "searcher", HasInboundEdges: []string{"searchedge"}})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	assertHashes(result.Docs, hashes[1])
+	_, err = doccache.Search(&SearchQuery{HasEdges: []string{"unknownedge"}})
+	if err == nil {
+		t.Fatalf("Expected error searching by unknown edge")
+	}
+
+	t.Log("Paginating")
+	for _, orderBy := range []SearchOrder{OrderByCreatedDate, OrderByHash} {
+		found := make([]*Document, 0)
+		after := ""
+		for {
+			page, err := doccache.Search(&SearchQuery{Creator: "searcher", OrderBy: orderBy, First: 2, After: after})
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			found = append(found, page.Docs...)
+			if page.Cursor == "" {
+				break
+			}
+			after = page.Cursor
+		}
+		assertHashes(found, hashes...)
+	}
+	page, err := doccache.SearchAsMap(&SearchQuery{Creator: "searcher", First: 2, Desc: true, RequestConfig: &RequestConfig{Edges: []string{"searchedge"}}})
+	if err != nil {
+		t.Fatalf("SearchAsMap failed: %v", err)
+	}
+	if len(page.Docs) != 2 || page.Docs[0]["hash"] != hashes[2] || page.Cursor == "" {
+		t.Fatalf("Expected first page of 2 documents with cursor, found: %v", page)
+	}
+	page, err = doccache.SearchAsMap(&SearchQuery{Creator: "searcher", First: 2, Desc: true, After: page.Cursor, RequestConfig: &RequestConfig{Edges: []string{"searchedge"}}})
+	if err != nil {
+		t.Fatalf("SearchAsMap failed: %v", err)
+	}
+	if len(page.Docs) != 1 || page.Docs[0]["hash"] != hashes[0] || page.Docs[0]["searchedge"] == nil || page.Cursor != "" {
+		t.Fatalf("Expected last page with document: %v and its edge, found: %v", hashes[0], page)
+	}
+}
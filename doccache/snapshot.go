@@ -0,0 +1,723 @@
+package doccache
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//SnapshotVersion version of the snapshot file layout
+const SnapshotVersion = 1
+
+//scanPageSize number of documents queried per page when scanning
+const scanPageSize = 200
+
+//snapshotBatchSize number of documents or edges committed per transaction on import
+const snapshotBatchSize = 200
+
+//snapshotRDFHeader prefix of the comment line that holds the header of RDF snapshots
+const snapshotRDFHeader = "# doccache-snapshot "
+
+//chainTimeLayout layout used to export dates in the format the chain provides them
+const chainTimeLayout = "2006-01-02T15:04:05.000"
+
+//SnapshotFormat file format of a snapshot
+type SnapshotFormat string
+
+const (
+	//SnapshotJSON JSON lines, a header line followed by a line per chain document and chain edge,
+	//documents are rebuilt on import the same way they are when streamed
+	SnapshotJSON SnapshotFormat = "json"
+	//SnapshotRDF N-Quads of the stored nodes preceded by a header comment, can also be loaded with Dgraph tools
+	SnapshotRDF SnapshotFormat = "rdf"
+)
+
+//ParseSnapshotFormat validates the snapshot format
+func ParseSnapshotFormat(format string) (SnapshotFormat, error) {
+	switch SnapshotFormat(format) {
+	case SnapshotJSON, SnapshotRDF:
+		return SnapshotFormat(format), nil
+	}
+	return "", fmt.Errorf("invalid snapshot format: %v, expected %v or %v", format, SnapshotJSON, SnapshotRDF)
+}
+
+//SnapshotHeader describes the snapshot, Cursor is the chain cursor streaming resumes from after import
+//and BlockNum the block of that cursor, the history of the imported cache starts at it
+type SnapshotHeader struct {
+	Version       int            `json:"version"`
+	Format        SnapshotFormat `json:"format"`
+	SchemaVersion int            `json:"schema_version"`
+	Cursor        string         `json:"cursor"`
+	BlockNum      uint64         `json:"block_num,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+func (m *SnapshotHeader) String() string {
+	return fmt.Sprintf("SnapshotHeader{Version: %v, Format: %v, SchemaVersion: %v, Cursor: %v, BlockNum: %v, CreatedAt: %v}", m.Version, m.Format, m.SchemaVersion, m.Cursor, m.BlockNum, m.CreatedAt)
+}
+
+//snapshotRecord line of a JSON snapshot, only one of the fields is set
+type snapshotRecord struct {
+	Header   *SnapshotHeader `json:"snapshot,omitempty"`
+	Document *ChainDocument  `json:"document,omitempty"`
+	Edge     *ChainEdge      `json:"edge,omitempty"`
+}
+
+//SnapshotStats summary of an export or import
+type SnapshotStats struct {
+	Format       SnapshotFormat
+	Cursor       string
+	Documents    int
+	Edges        int
+	SkippedEdges int
+}
+
+func (m *SnapshotStats) String() string {
+	return fmt.Sprintf("SnapshotStats{Format: %v, Cursor: %v, Documents: %v, Edges: %v, SkippedEdges: %v}", m.Format, m.Cursor, m.Documents, m.Edges, m.SkippedEdges)
+}
+
+//ExportSnapshot writes the documents, their content groups, certificates and edges together with the cursor.
+//The cursor is read first, if the cache is being streamed to while exporting, replaying from the cursor
+//reapplies the changes made after it
+func (m *Doccache) ExportSnapshot(w io.Writer, format SnapshotFormat) (*SnapshotStats, error) {
+	cursor, err := m.StoredCursor()
+	if err != nil {
+		return nil, err
+	}
+	schemaVersion, err := m.GetSchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	header := &SnapshotHeader{
+		Version:       SnapshotVersion,
+		Format:        format,
+		SchemaVersion: schemaVersion,
+		Cursor:        cursor.Cursor,
+		BlockNum:      cursor.BlockNum,
+		CreatedAt:     time.Now().UTC(),
+	}
+	var writer snapshotWriter
+	switch format {
+	case SnapshotJSON:
+		writer = &jsonSnapshotWriter{encoder: json.NewEncoder(w)}
+	case SnapshotRDF:
+		writer = &rdfSnapshotWriter{writer: w}
+	default:
+		return nil, fmt.Errorf("invalid snapshot format: %v", format)
+	}
+	log.Infof("Exporting snapshot: %v", header)
+	err = writer.header(header)
+	if err != nil {
+		return nil, err
+	}
+	stats := &SnapshotStats{
+		Format: format,
+		Cursor: header.Cursor,
+	}
+	//edges are written after all the documents so that their endpoints exist when imported
+	edges := make([]*ChainEdge, 0)
+	edgeNames := m.EdgeNames()
+	err = m.ScanDocuments(func(doc *Document) error {
+		for _, edgeName := range edgeNames {
+			for _, to := range doc.Edges[edgeName] {
+				edges = append(edges, &ChainEdge{Name: edgeName, From: doc.Hash, To: to.Hash})
+			}
+		}
+		stats.Documents++
+		return writer.document(doc)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, edge := range edges {
+		err = writer.edge(edge)
+		if err != nil {
+			return nil, err
+		}
+	}
+	stats.Edges = len(edges)
+	log.Infof("Exported snapshot: %v", stats)
+	return stats, nil
+}
+
+//ScanDocuments calls fn for each document in uid order, the documents include their content groups without
+//the referenced documents, certificates and the hashes of the targets of their outgoing edges
+func (m *Doccache) ScanDocuments(fn func(doc *Document) error) error {
+	return m.scanDocuments("", m.EdgeNames(), fn)
+}
+
+//ScanDocumentsOfType calls fn for each document of the type in uid order, only the targets of the specified edges are included
+func (m *Doccache) ScanDocumentsOfType(docType string, edgeNames []string, fn func(doc *Document) error) error {
+	for _, edgeName := range edgeNames {
+		if m.GetEdgeDefinition(edgeName) == nil {
+			return invalidRequest("edge: %v does not exist", edgeName)
+		}
+	}
+	return m.scanDocuments(docType, edgeNames, fn)
+}
+
+func (m *Doccache) scanDocuments(docType string, edgeNames []string, fn func(doc *Document) error) error {
+	after := ""
+	for {
+		docs, err := m.scanPage(docType, edgeNames, after)
+		if err != nil {
+			return err
+		}
+		for _, doc := range docs {
+			err = fn(doc)
+			if err != nil {
+				return err
+			}
+		}
+		if len(docs) < scanPageSize {
+			return nil
+		}
+		after = docs[len(docs)-1].UID
+	}
+}
+
+func (m *Doccache) scanPage(docType string, edgeNames []string, after string) ([]*Document, error) {
+	var vars map[string]string
+	header, root := "", "type(Document)"
+	if docType != "" {
+		header, root, vars = "query docs($docType: string)", "eq(doc_type, $docType)", map[string]string{"$docType": docType}
+	}
+	afterClause := ""
+	if after != "" {
+		afterClause = fmt.Sprintf(", after: %v", after)
+	}
+	edgeRequest := ""
+	for _, edgeName := range edgeNames {
+		edgeRequest += fmt.Sprintf(`
+			%v: <%v> @filter(type(Document)) {
+				hash
+			}
+		`, queryAlias(edgeName), EdgePredicate(edgeName))
+	}
+	query := fmt.Sprintf(`
+		%v{
+			docs(func: %v, first: %v%v){
+				uid
+				hash
+				creator
+				created_date
+				doc_type
+				node_label
+				ballot_id
+				dgraph.type
+				content_groups (orderasc: content_group_sequence){
+					content_group_sequence
+					dgraph.type
+					contents (orderasc: content_sequence){
+						content_sequence
+						label
+						value
+						type
+						int_value
+						time_value
+						asset_amount
+						asset_symbol
+						asset_precision
+						name_value
+						dgraph.type
+					}
+				}
+				certificates (orderasc: certification_sequence){
+					certifier
+					notes
+					certification_date
+					certification_sequence
+					dgraph.type
+				}
+				%v
+			}
+		}
+	`, header, root, scanPageSize, afterClause, edgeRequest)
+	docs := &Docs{}
+	err := m.dgraph.Query(query, vars, docs)
+	if err != nil {
+		return nil, err
+	}
+	return docs.Docs, nil
+}
+
+//ToChainDocument rebuilds the chain document the document was created from
+func (m *Document) ToChainDocument() *ChainDocument {
+	chainDoc := &ChainDocument{
+		Hash:          m.Hash,
+		Creator:       m.Creator,
+		ContentGroups: make([][]*ChainContent, 0, len(m.ContentGroups)),
+		Certificates:  make([]*ChainCertificate, 0, len(m.Certificates)),
+	}
+	if m.CreatedDate != nil {
+		chainDoc.CreatedDate = m.CreatedDate.UTC().Format(chainTimeLayout)
+	}
+	for _, contentGroup := range m.ContentGroups {
+		chainContentGroup := make([]*ChainContent, 0, len(contentGroup.Contents))
+		for _, content := range contentGroup.Contents {
+			chainContentGroup = append(chainContentGroup, &ChainContent{
+				Label: content.Label,
+				Value: []interface{}{content.Type, content.Value},
+			})
+		}
+		chainDoc.ContentGroups = append(chainDoc.ContentGroups, chainContentGroup)
+	}
+	for _, certificate := range m.Certificates {
+		chainCertificate := &ChainCertificate{
+			Certifier: certificate.Certifier,
+			Notes:     certificate.Notes,
+		}
+		if certificate.CertificationDate != nil {
+			chainCertificate.CertificationDate = certificate.CertificationDate.UTC().Format(chainTimeLayout)
+		}
+		chainDoc.Certificates = append(chainDoc.Certificates, chainCertificate)
+	}
+	return chainDoc
+}
+
+type snapshotWriter interface {
+	header(header *SnapshotHeader) error
+	document(doc *Document) error
+	edge(edge *ChainEdge) error
+}
+
+type jsonSnapshotWriter struct {
+	encoder *json.Encoder
+}
+
+func (m *jsonSnapshotWriter) header(header *SnapshotHeader) error {
+	return m.encoder.Encode(&snapshotRecord{Header: header})
+}
+
+func (m *jsonSnapshotWriter) document(doc *Document) error {
+	return m.encoder.Encode(&snapshotRecord{Document: doc.ToChainDocument()})
+}
+
+func (m *jsonSnapshotWriter) edge(edge *ChainEdge) error {
+	return m.encoder.Encode(&snapshotRecord{Edge: edge})
+}
+
+//rdfSnapshotWriter writes the nodes of a document with blank node labels derived from the document hash,
+//e.g. _:d<hash>, _:d<hash>_g1, _:d<hash>_g1_c2 and _:d<hash>_cert1
+type rdfSnapshotWriter struct {
+	writer io.Writer
+}
+
+func (m *rdfSnapshotWriter) header(header *SnapshotHeader) error {
+	data, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(m.writer, "%v%v\n", snapshotRDFHeader, string(data))
+	return err
+}
+
+func (m *rdfSnapshotWriter) document(doc *Document) error {
+	var quads strings.Builder
+	node := documentBlankNode(doc.Hash)
+	writeLiteral(&quads, node, "hash", doc.Hash)
+	writeLiteral(&quads, node, "creator", doc.Creator)
+	writeTime(&quads, node, "created_date", doc.CreatedDate)
+	writeLiteral(&quads, node, "doc_type", doc.DocType)
+	writeLiteral(&quads, node, "node_label", doc.NodeLabel)
+	writeLiteral(&quads, node, "ballot_id", doc.BallotID)
+	writeTypes(&quads, node, doc.DType)
+	for _, contentGroup := range doc.ContentGroups {
+		groupNode := fmt.Sprintf("%v_g%v", node, contentGroup.ContentGroupSequence)
+		fmt.Fprintf(&quads, "%v <content_groups> %v .\n", node, groupNode)
+		writeTyped(&quads, groupNode, "content_group_sequence", strconv.Itoa(contentGroup.ContentGroupSequence), "xs:int")
+		writeTypes(&quads, groupNode, contentGroup.DType)
+		for _, content := range contentGroup.Contents {
+			contentNode := fmt.Sprintf("%v_c%v", groupNode, content.ContentSequence)
+			fmt.Fprintf(&quads, "%v <contents> %v .\n", groupNode, contentNode)
+			writeTyped(&quads, contentNode, "content_sequence", strconv.Itoa(content.ContentSequence), "xs:int")
+			writeLiteral(&quads, contentNode, "label", content.Label)
+			writeLiteral(&quads, contentNode, "value", content.Value)
+			writeLiteral(&quads, contentNode, "type", content.Type)
+			if content.IntValue != nil {
+				writeTyped(&quads, contentNode, "int_value", strconv.FormatInt(*content.IntValue, 10), "xs:int")
+			}
+			writeTime(&quads, contentNode, "time_value", content.TimeValue)
+			if content.AssetAmount != nil {
+				writeTyped(&quads, contentNode, "asset_amount", strconv.FormatFloat(*content.AssetAmount, 'f', -1, 64), "xs:float")
+			}
+			writeLiteral(&quads, contentNode, "asset_symbol", content.AssetSymbol)
+			if content.AssetPrecision != nil {
+				writeTyped(&quads, contentNode, "asset_precision", strconv.Itoa(*content.AssetPrecision), "xs:int")
+			}
+			writeLiteral(&quads, contentNode, "name_value", content.NameValue)
+			writeTypes(&quads, contentNode, content.DType)
+		}
+	}
+	for _, certificate := range doc.Certificates {
+		certificateNode := fmt.Sprintf("%v_cert%v", node, certificate.CertificationSequence)
+		fmt.Fprintf(&quads, "%v <certificates> %v .\n", node, certificateNode)
+		writeLiteral(&quads, certificateNode, "certifier", certificate.Certifier)
+		writeLiteral(&quads, certificateNode, "notes", certificate.Notes)
+		writeTime(&quads, certificateNode, "certification_date", certificate.CertificationDate)
+		writeTyped(&quads, certificateNode, "certification_sequence", strconv.Itoa(certificate.CertificationSequence), "xs:int")
+		writeTypes(&quads, certificateNode, certificate.DType)
+	}
+	_, err := io.WriteString(m.writer, quads.String())
+	return err
+}
+
+func (m *rdfSnapshotWriter) edge(edge *ChainEdge) error {
+	_, err := fmt.Fprintf(m.writer, "%v <%v> %v .\n", documentBlankNode(edge.From), EdgePredicate(edge.Name), documentBlankNode(edge.To))
+	return err
+}
+
+func documentBlankNode(hash string) string {
+	return "_:d" + hash
+}
+
+//blankNodeHash returns the hash of the document the blank node belongs to
+func blankNodeHash(node string) string {
+	return strings.SplitN(strings.TrimPrefix(node, "_:d"), "_", 2)[0]
+}
+
+func writeLiteral(quads *strings.Builder, node, predicate, value string) {
+	if value != "" {
+		fmt.Fprintf(quads, "%v <%v> \"%v\" .\n", node, predicate, escapeLiteral(value))
+	}
+}
+
+func writeTyped(quads *strings.Builder, node, predicate, value, dataType string) {
+	fmt.Fprintf(quads, "%v <%v> \"%v\"^^<%v> .\n", node, predicate, value, dataType)
+}
+
+func writeTime(quads *strings.Builder, node, predicate string, value *time.Time) {
+	if value != nil {
+		writeTyped(quads, node, predicate, value.UTC().Format(time.RFC3339Nano), "xs:dateTime")
+	}
+}
+
+func writeTypes(quads *strings.Builder, node string, dTypes []string) {
+	for _, dType := range dTypes {
+		writeLiteral(quads, node, "dgraph.type", dType)
+	}
+}
+
+//escapeLiteral escapes the characters that are not allowed in an N-Quads string literal
+func escapeLiteral(value string) string {
+	var escaped strings.Builder
+	for _, r := range value {
+		switch r {
+		case '\\':
+			escaped.WriteString(`\\`)
+		case '"':
+			escaped.WriteString(`\"`)
+		case '\n':
+			escaped.WriteString(`\n`)
+		case '\r':
+			escaped.WriteString(`\r`)
+		case '\t':
+			escaped.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&escaped, `\u%04X`, r)
+			} else {
+				escaped.WriteRune(r)
+			}
+		}
+	}
+	return escaped.String()
+}
+
+//ImportSnapshot loads a snapshot into an empty cache and sets the cursor, so that streaming resumes
+//where the exported cache was, the format is detected from the first line
+func (m *Doccache) ImportSnapshot(r io.Reader) (*SnapshotStats, error) {
+	empty, err := m.isEmpty()
+	if err != nil {
+		return nil, err
+	}
+	if !empty {
+		return nil, fmt.Errorf("snapshots can only be imported into an empty cache")
+	}
+	reader := bufio.NewReader(r)
+	line, err := readSnapshotLine(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot header: %v", err)
+	}
+	header := &SnapshotHeader{}
+	if strings.HasPrefix(line, snapshotRDFHeader) {
+		err = json.Unmarshal([]byte(strings.TrimPrefix(line, snapshotRDFHeader)), header)
+	} else {
+		record := &snapshotRecord{}
+		err = json.Unmarshal([]byte(line), record)
+		if err == nil && record.Header == nil {
+			err = fmt.Errorf("first line is not a header")
+		}
+		if record.Header != nil {
+			header = record.Header
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid snapshot header: %v", err)
+	}
+	if header.Version != SnapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version: %v, expected: %v", header.Version, SnapshotVersion)
+	}
+	if header.SchemaVersion > LatestSchemaVersion() {
+		return nil, fmt.Errorf("snapshot schema version: %v is newer than the latest supported: %v", header.SchemaVersion, LatestSchemaVersion())
+	}
+	log.Infof("Importing snapshot: %v", header)
+	stats := &SnapshotStats{
+		Format: header.Format,
+		Cursor: header.Cursor,
+	}
+	switch header.Format {
+	case SnapshotJSON:
+		err = m.importJSONSnapshot(reader, stats)
+	case SnapshotRDF:
+		err = m.importRDFSnapshot(reader, stats)
+	default:
+		err = fmt.Errorf("invalid snapshot format: %v", header.Format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	unlinked, err := m.findUnlinkedChecksums()
+	if err != nil {
+		return nil, err
+	}
+	err = m.repairChecksums(&IntegrityReport{UnlinkedChecksums: unlinked})
+	if err != nil {
+		return nil, err
+	}
+	//the history is not part of the snapshot, it starts with the imported state
+	m.SetBlockNum(header.BlockNum)
+	err = m.startHistory(m.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	err = m.UpdateCursor(header.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Imported snapshot: %v", stats)
+	return stats, nil
+}
+
+func (m *Doccache) isEmpty() (bool, error) {
+	query := `
+		{
+			docs(func: type(Document), first: 1){
+				uid
+			}
+		}
+	`
+	docs := &Docs{}
+	err := m.dgraph.Query(query, nil, docs)
+	if err != nil {
+		return false, err
+	}
+	return len(docs.Docs) == 0, nil
+}
+
+//readSnapshotLine returns the next non empty line without the line break, io.EOF when there are no more lines
+func readSnapshotLine(reader *bufio.Reader) (string, error) {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", err
+		}
+		line = strings.TrimSpace(line)
+		if line != "" {
+			return line, nil
+		}
+	}
+}
+
+func (m *Doccache) importJSONSnapshot(reader *bufio.Reader, stats *SnapshotStats) error {
+	docs := make([]*Document, 0, snapshotBatchSize)
+	edges := make([]*ChainEdge, 0, snapshotBatchSize)
+	for {
+		line, err := readSnapshotLine(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		record := &snapshotRecord{}
+		err = json.Unmarshal([]byte(line), record)
+		if err != nil {
+			return fmt.Errorf("invalid snapshot line: %v, error: %v", line, err)
+		}
+		switch {
+		case record.Document != nil:
+			record.Document.Normalize()
+			doc := NewDocument(record.Document)
+			err = m.assignKindType(doc)
+			if err != nil {
+				return err
+			}
+			docs = append(docs, doc)
+			if len(docs) == snapshotBatchSize {
+				err = m.importDocuments(docs, stats)
+				if err != nil {
+					return err
+				}
+				docs = docs[:0]
+			}
+		case record.Edge != nil:
+			edges = append(edges, record.Edge)
+			if len(edges) == snapshotBatchSize {
+				err = m.importSnapshotEdges(edges, stats)
+				if err != nil {
+					return err
+				}
+				edges = edges[:0]
+			}
+		}
+	}
+	err := m.importDocuments(docs, stats)
+	if err != nil {
+		return err
+	}
+	return m.importSnapshotEdges(edges, stats)
+}
+
+//importDocuments commits the documents, the checksum contents are linked once all documents are imported
+func (m *Doccache) importDocuments(docs []*Document, stats *SnapshotStats) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	_, err := m.dgraph.MutateJSON(docs, false)
+	if err != nil {
+		return err
+	}
+	stats.Documents += len(docs)
+	log.Infof("Imported %v documents", stats.Documents)
+	return nil
+}
+
+func (m *Doccache) importRDFSnapshot(reader *bufio.Reader, stats *SnapshotStats) error {
+	var quads strings.Builder
+	docs := 0
+	currentDoc := ""
+	edges := make([]*ChainEdge, 0, snapshotBatchSize)
+	for {
+		line, err := readSnapshotLine(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) < 3 || !strings.HasPrefix(fields[0], "_:d") {
+			return fmt.Errorf("invalid snapshot line: %v", line)
+		}
+		predicate := strings.Trim(fields[1], "<>")
+		if IsEdgePredicate(predicate) {
+			edges = append(edges, &ChainEdge{
+				Name: strings.TrimPrefix(predicate, edgeNamespace),
+				From: blankNodeHash(fields[0]),
+				To:   blankNodeHash(strings.TrimSuffix(strings.TrimSpace(fields[2]), " .")),
+			})
+			if len(edges) == snapshotBatchSize {
+				err = m.importSnapshotEdges(edges, stats)
+				if err != nil {
+					return err
+				}
+				edges = edges[:0]
+			}
+			continue
+		}
+		hash := blankNodeHash(fields[0])
+		if hash != currentDoc {
+			//batches are only cut between documents, blank nodes are resolved within a transaction
+			if docs == snapshotBatchSize {
+				err = m.importQuads(quads.String(), docs, stats)
+				if err != nil {
+					return err
+				}
+				quads.Reset()
+				docs = 0
+			}
+			currentDoc = hash
+			docs++
+		}
+		if predicate == "dgraph.type" {
+			err = m.ensureSnapshotType(strings.Trim(strings.TrimSuffix(fields[2], " ."), "\""))
+			if err != nil {
+				return err
+			}
+		}
+		quads.WriteString(line)
+		quads.WriteString("\n")
+	}
+	err := m.importQuads(quads.String(), docs, stats)
+	if err != nil {
+		return err
+	}
+	return m.importSnapshotEdges(edges, stats)
+}
+
+func (m *Doccache) importQuads(quads string, docs int, stats *SnapshotStats) error {
+	if docs == 0 {
+		return nil
+	}
+	_, err := m.dgraph.MutateNQuads(quads, false)
+	if err != nil {
+		return err
+	}
+	stats.Documents += docs
+	log.Infof("Imported %v documents", stats.Documents)
+	return nil
+}
+
+//ensureSnapshotType creates the kind types found in the snapshot
+func (m *Doccache) ensureSnapshotType(dType string) error {
+	switch dType {
+	case "Document", "ContentGroup", "Content", "Certificate":
+		return nil
+	}
+	return m.ensureKindType(dType)
+}
+
+//importSnapshotEdges registers the edges and commits them, edges whose endpoints are missing are skipped
+func (m *Doccache) importSnapshotEdges(edges []*ChainEdge, stats *SnapshotStats) error {
+	if len(edges) == 0 {
+		return nil
+	}
+	hashes := make([]string, 0, len(edges)*2)
+	for _, edge := range edges {
+		edge.Normalize()
+		hashes = append(hashes, edge.From, edge.To)
+	}
+	hashUIDMap, err := m.GetHashUIDMap(hashes)
+	if err != nil {
+		return err
+	}
+	var quads strings.Builder
+	for _, edge := range edges {
+		definition, err := m.registerEdge(edge.Name)
+		if err != nil {
+			return err
+		}
+		fromUID, fromOk := hashUIDMap[edge.From]
+		toUID, toOk := hashUIDMap[edge.To]
+		if !fromOk || !toOk {
+			log.Warnf("Skipping edge: %v, endpoints not found in snapshot", edge)
+			stats.SkippedEdges++
+			continue
+		}
+		fmt.Fprintf(&quads, "<%v> <%v> <%v> .\n", fromUID, definition.Predicate, toUID)
+		stats.Edges++
+	}
+	if quads.Len() == 0 {
+		return nil
+	}
+	_, err = m.dgraph.MutateNQuads(quads.String(), false)
+	return err
+}
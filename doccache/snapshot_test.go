@@ -0,0 +1,194 @@
+package doccache
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestEscapeLiteral(t *testing.T) {
+	escaped := escapeLiteral("say \"hi\"\nto C:\\dao\t\x01")
+	expected := `say \"hi\"\nto C:\\dao\t\u0001`
+	if escaped != expected {
+		t.Fatalf("Expected: %v, found: %v", expected, escaped)
+	}
+}
+
+func TestToChainDocument(t *testing.T) {
+	chainDoc := &ChainDocument{
+		Hash:        "d4ec74355830056924c83f20ffb1a22ad0c5145a96daddf6301897a092de951e",
+		CreatedDate: "2020-11-12T18:27:47.000",
+		Creator:     "dao.hypha",
+		ContentGroups: [][]*ChainContent{
+			{
+				{Label: "content_group_label", Value: []interface{}{"string", "details"}},
+				{Label: "amount", Value: []interface{}{"asset", "100.00 HUSD"}},
+				{Label: "periods", Value: []interface{}{"int64", "12"}},
+			},
+		},
+		Certificates: []*ChainCertificate{
+			{Certifier: "dao.hypha", Notes: "approved", CertificationDate: "2020-11-13T10:00:00.500"},
+		},
+	}
+	rebuilt := NewDocument(chainDoc).ToChainDocument()
+	if !reflect.DeepEqual(chainDoc, rebuilt) {
+		t.Fatalf("Expected: %v, found: %v", chainDoc, rebuilt)
+	}
+}
+
+func TestBlankNodeHash(t *testing.T) {
+	for _, node := range []string{"_:dabc", "_:dabc_g1", "_:dabc_g1_c2", "_:dabc_cert1"} {
+		if blankNodeHash(node) != "abc" {
+			t.Fatalf("Expected hash abc for node: %v, found: %v", node, blankNodeHash(node))
+		}
+	}
+}
+
+//snapshotBody returns the sorted lines of the snapshot without the header
+func snapshotBody(t *testing.T, format SnapshotFormat) []string {
+	var buffer bytes.Buffer
+	_, err := doccache.ExportSnapshot(&buffer, format)
+	if err != nil {
+		t.Fatalf("ExportSnapshot failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")[1:]
+	sort.Strings(lines)
+	return lines
+}
+
+func importSnapshot(t *testing.T, snapshot []byte) *SnapshotStats {
+	err := dg.DropAll()
+	if err != nil {
+		t.Fatalf("DropAll failed: %v", err)
+	}
+	doccache, err = New(dg, nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	stats, err := doccache.ImportSnapshot(bytes.NewReader(snapshot))
+	if err != nil {
+		t.Fatalf("ImportSnapshot failed: %v", err)
+	}
+	return stats
+}
+
+func TestSnapshot(t *testing.T) {
+	hashes := []string{
+		"a1f0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c40",
+		"a2f0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c41",
+	}
+	for i, hash := range hashes {
+		chainDoc := &ChainDocument{
+			Hash:        hash,
+			CreatedDate: "2021-07-01T10:00:00.000",
+			Creator:     "snapshotter",
+			ContentGroups: [][]*ChainContent{
+				{
+					{Label: "content_group_label", Value: []interface{}{"string", "system"}},
+					{Label: "type", Value: []interface{}{"name", "payout"}},
+					{Label: "description", Value: []interface{}{"string", "line one\n\"quoted\""}},
+					{Label: "parent", Value: []interface{}{"checksum256", hashes[1-i]}},
+				},
+			},
+			Certificates: []*ChainCertificate{
+				{Certifier: "snapshotter", Notes: "ok", CertificationDate: "2021-07-02T10:00:00.000"},
+			},
+		}
+		err := doccache.StoreDocument(chainDoc, "snapshot0")
+		if err != nil {
+			t.Fatalf("StoreDocument failed: %v", err)
+		}
+	}
+	doccache.SetBlockNum(40)
+	err := doccache.MutateEdge(&ChainEdge{Name: "snapshotedge", From: hashes[0], To: hashes[1]}, false, "snapshot1")
+	if err != nil {
+		t.Fatalf("MutateEdge failed: %v", err)
+	}
+
+	jsonBody := snapshotBody(t, SnapshotJSON)
+	rdfBody := snapshotBody(t, SnapshotRDF)
+	snapshots := make(map[SnapshotFormat][]byte)
+	for _, format := range []SnapshotFormat{SnapshotJSON, SnapshotRDF} {
+		var buffer bytes.Buffer
+		_, err = doccache.ExportSnapshot(&buffer, format)
+		if err != nil {
+			t.Fatalf("ExportSnapshot failed: %v", err)
+		}
+		snapshots[format] = buffer.Bytes()
+	}
+
+	for _, format := range []SnapshotFormat{SnapshotJSON, SnapshotRDF} {
+		stats := importSnapshot(t, snapshots[format])
+		if stats.Format != format || stats.Cursor != "snapshot1" || stats.Edges < 1 || stats.SkippedEdges != 0 {
+			t.Fatalf("Unexpected import stats: %v", stats)
+		}
+		if doccache.Cursor.Cursor != "snapshot1" || doccache.Cursor.BlockNum != 40 || doccache.Cursor.HistoryStart != 40 {
+			t.Fatalf("Expected cursor: snapshot1 at block 40 starting the history, found: %v", doccache.Cursor)
+		}
+		asOf, err := doccache.GetByHashAsOf(hashes[0], 40, nil)
+		if err != nil || asOf == nil {
+			t.Fatalf("Expected imported document as of the start of the history, found: %v, error: %v", asOf, err)
+		}
+		_, err = doccache.GetByHashAsOf(hashes[0], 39, nil)
+		if _, ok := err.(*InvalidRequestError); !ok {
+			t.Fatalf("Expected invalid request before the start of the history, found: %v", err)
+		}
+		doc, err := doccache.GetByHash(hashes[0], &RequestConfig{ContentGroups: true, Edges: []string{"snapshotedge"}})
+		if err != nil {
+			t.Fatalf("GetByHash failed: %v", err)
+		}
+		if doc == nil || doc.DocType != "payout" || len(doc.Edges["snapshotedge"]) != 1 || doc.Edges["snapshotedge"][0].Hash != hashes[1] {
+			t.Fatalf("Expected imported document with edge, found: %v", doc)
+		}
+		checksums := doc.GetChecksumContents()
+		if len(checksums) != 1 || len(checksums[0].Document) != 1 || checksums[0].Document[0].Hash != hashes[1] {
+			t.Fatalf("Expected checksum content to be linked, found: %v", checksums)
+		}
+		if !reflect.DeepEqual(jsonBody, snapshotBody(t, SnapshotJSON)) || !reflect.DeepEqual(rdfBody, snapshotBody(t, SnapshotRDF)) {
+			t.Fatalf("Expected export of the imported %v snapshot to match the original", format)
+		}
+	}
+
+	_, err = doccache.ImportSnapshot(bytes.NewReader(snapshots[SnapshotJSON]))
+	if err == nil {
+		t.Fatalf("Expected import into a non empty cache to fail")
+	}
+	doccache.SetBlockNum(0)
+}
+
+func TestRDFSnapshotWriter(t *testing.T) {
+	chainDoc := &ChainDocument{
+		Hash:        "abc",
+		CreatedDate: "2020-11-12T18:27:47.000",
+		ContentGroups: [][]*ChainContent{
+			{
+				{Label: "periods", Value: []interface{}{"int64", "12"}},
+			},
+		},
+	}
+	var buffer bytes.Buffer
+	writer := &rdfSnapshotWriter{writer: &buffer}
+	err := writer.document(NewDocument(chainDoc))
+	if err != nil {
+		t.Fatalf("document failed: %v", err)
+	}
+	err = writer.edge(&ChainEdge{Name: "parent", From: "abc", To: "def"})
+	if err != nil {
+		t.Fatalf("edge failed: %v", err)
+	}
+	for _, quad := range []string{
+		`_:dabc <hash> "abc" .`,
+		`_:dabc <created_date> "2020-11-12T18:27:47Z"^^<xs:dateTime> .`,
+		`_:dabc <content_groups> _:dabc_g1 .`,
+		`_:dabc_g1 <contents> _:dabc_g1_c1 .`,
+		`_:dabc_g1_c1 <int_value> "12"^^<xs:int> .`,
+		`_:dabc_g1_c1 <dgraph.type> "Content" .`,
+		`_:dabc <edge.parent> _:ddef .`,
+	} {
+		if !strings.Contains(buffer.String(), quad+"\n") {
+			t.Fatalf("Expected quad: %v, found: %v", quad, buffer.String())
+		}
+	}
+}
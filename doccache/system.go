@@ -0,0 +1,250 @@
+package doccache
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const backfillPageSize = 500
+
+//systemFieldsSchema predicates promoted from the system content group
+const systemFieldsSchema = `
+      doc_type: string @index(exact) .
+      node_label: string @index(term) .
+      ballot_id: string @index(exact) .
+    `
+
+//systemFields document predicates promoted from the system content group
+var systemFields = []string{"doc_type", "node_label", "ballot_id"}
+
+//reservedTypes Dgraph types that a document kind can not be mapped to
+var reservedTypes = map[string]bool{
+	"Document":        true,
+	"ContentGroup":    true,
+	"Content":         true,
+	"Certificate":     true,
+	"Cursor":          true,
+	"InvalidRow":      true,
+	"SchemaVersion":   true,
+	"EdgeDefinition":  true,
+	"Webhook":         true,
+	"WebhookDelivery": true,
+	"DocumentVersion": true,
+	"EdgeVersion":     true,
+}
+
+//KindTypeName returns the Dgraph type name for a document kind, e.g. role -> Role, assignment_payout -> AssignmentPayout
+func KindTypeName(docType string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range docType {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if b.Len() == 0 && unicode.IsDigit(r) {
+			b.WriteString("Kind")
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	name := b.String()
+	if name == "" {
+		return ""
+	}
+	if reservedTypes[name] {
+		name += "Document"
+	}
+	return name
+}
+
+func (m *Doccache) ensureKindType(typeName string) error {
+	if m.kindTypes[typeName] {
+		return nil
+	}
+	missing, err := m.dgraph.MissingTypes([]string{typeName})
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		log.Infof("Creating type for document kind: %v", typeName)
+		err = m.dgraph.UpdateSchema(fmt.Sprintf(`
+			type %v {
+				hash
+				created_date
+				creator
+				doc_type
+				node_label
+				ballot_id
+				content_groups
+				certificates
+			}
+		`, typeName))
+		if err != nil {
+			return err
+		}
+	}
+	m.kindTypes[typeName] = true
+	return nil
+}
+
+//assignKindType adds the document kind type to the document if kind types are enabled
+func (m *Doccache) assignKindType(doc *Document) error {
+	if !m.config.KindTypes || doc.DocType == "" {
+		return nil
+	}
+	typeName := KindTypeName(doc.DocType)
+	if typeName == "" {
+		return nil
+	}
+	err := m.ensureKindType(typeName)
+	if err != nil {
+		return err
+	}
+	for _, dType := range doc.DType {
+		if dType == typeName {
+			return nil
+		}
+	}
+	doc.DType = append(doc.DType, typeName)
+	return nil
+}
+
+//BackfillSystemFields promotes the system content group fields of already stored documents,
+//assigning kind types if enabled
+func (m *Doccache) BackfillSystemFields() (int, error) {
+	updated := 0
+	after := ""
+	for {
+		afterClause := ""
+		if after != "" {
+			afterClause = fmt.Sprintf(", after: %v", after)
+		}
+		query := fmt.Sprintf(`
+			{
+				docs(func: type(Document), first: %v%v){
+					uid
+					hash
+					dgraph.type
+					content_groups {
+						contents @filter(eq(label, ["content_group_label", "type", "node_label", "ballot_id"])){
+							label
+							value
+						}
+					}
+				}
+			}
+		`, backfillPageSize, afterClause)
+		docs := &Docs{}
+		err := m.dgraph.Query(query, nil, docs)
+		if err != nil {
+			return 0, err
+		}
+		toUpdate := make([]*Document, 0, len(docs.Docs))
+		for _, doc := range docs.Docs {
+			doc.PromoteSystemFields()
+			if doc.DocType == "" && doc.NodeLabel == "" && doc.BallotID == "" {
+				continue
+			}
+			err = m.assignKindType(doc)
+			if err != nil {
+				return 0, err
+			}
+			toUpdate = append(toUpdate, &Document{
+				UID:       doc.UID,
+				DocType:   doc.DocType,
+				NodeLabel: doc.NodeLabel,
+				BallotID:  doc.BallotID,
+				DType:     doc.DType,
+			})
+		}
+		if len(toUpdate) > 0 {
+			_, err = m.dgraph.MutateJSON(toUpdate, false)
+			if err != nil {
+				return 0, err
+			}
+			updated += len(toUpdate)
+		}
+		if len(docs.Docs) < backfillPageSize {
+			break
+		}
+		after = docs.Docs[len(docs.Docs)-1].UID
+	}
+	log.Infof("Backfilled system fields of %v documents", updated)
+	return updated, nil
+}
+
+//typedValue typed predicates of a content, set by the backfill of the contents stored before they existed
+type typedValue struct {
+	UID            string     `json:"uid"`
+	IntValue       *int64     `json:"int_value,omitempty"`
+	TimeValue      *time.Time `json:"time_value,omitempty"`
+	AssetAmount    *float64   `json:"asset_amount,omitempty"`
+	AssetSymbol    string     `json:"asset_symbol,omitempty"`
+	AssetPrecision *int       `json:"asset_precision,omitempty"`
+	NameValue      string     `json:"name_value,omitempty"`
+}
+
+//BackfillTypedValues sets the typed predicates of the contents stored before they were added,
+//returns the number of contents updated
+func (m *Doccache) BackfillTypedValues() (int, error) {
+	updated := 0
+	after := ""
+	for {
+		afterClause := ""
+		if after != "" {
+			afterClause = fmt.Sprintf(", after: %v", after)
+		}
+		query := fmt.Sprintf(`
+			{
+				contents(func: type(Content), first: %v%v) @filter(eq(type, ["int64", "time_point", "asset", "name"]) AND
+					NOT has(int_value) AND NOT has(time_value) AND NOT has(asset_amount) AND NOT has(name_value)){
+					uid
+					type
+					value
+				}
+			}
+		`, backfillPageSize, afterClause)
+		result := &struct {
+			Contents []*Content `json:"contents,omitempty"`
+		}{}
+		err := m.dgraph.Query(query, nil, result)
+		if err != nil {
+			return 0, err
+		}
+		toUpdate := make([]*typedValue, 0, len(result.Contents))
+		for _, content := range result.Contents {
+			content.setTypedValue(content.Value)
+			if content.IntValue == nil && content.TimeValue == nil && content.AssetAmount == nil && content.NameValue == "" {
+				continue
+			}
+			toUpdate = append(toUpdate, &typedValue{
+				UID:            content.UID,
+				IntValue:       content.IntValue,
+				TimeValue:      content.TimeValue,
+				AssetAmount:    content.AssetAmount,
+				AssetSymbol:    content.AssetSymbol,
+				AssetPrecision: content.AssetPrecision,
+				NameValue:      content.NameValue,
+			})
+		}
+		if len(toUpdate) > 0 {
+			_, err = m.dgraph.MutateJSON(toUpdate, false)
+			if err != nil {
+				return 0, err
+			}
+			updated += len(toUpdate)
+		}
+		if len(result.Contents) < backfillPageSize {
+			break
+		}
+		after = result.Contents[len(result.Contents)-1].UID
+	}
+	log.Infof("Backfilled typed values of %v contents", updated)
+	return updated, nil
+}
@@ -0,0 +1,121 @@
+package doccache
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestKindTypeName(t *testing.T) {
+	for docType, expected := range map[string]string{
+		"role":              "Role",
+		"assignment_payout": "AssignmentPayout",
+		"dao.member":        "DaoMember",
+		"content":           "ContentDocument",
+		"webhook_delivery":  "WebhookDeliveryDocument",
+		"edge_version":      "EdgeVersionDocument",
+		"1st":               "Kind1st",
+		"":                  "",
+	} {
+		if typeName := KindTypeName(docType); typeName != expected {
+			t.Fatalf("Expected type name for: %v to be: %v, found: %v", docType, expected, typeName)
+		}
+	}
+}
+
+func TestSystemFields(t *testing.T) {
+	cache, err := NewWithConfig(dg, &Config{KindTypes: true}, nil)
+	if err != nil {
+		t.Fatalf("Failed creating docCache: %v", err)
+	}
+	hash := "a8e0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c08"
+	err = cache.StoreDocument(&ChainDocument{
+		Hash:        hash,
+		CreatedDate: "2021-01-15T19:17:44.5",
+		Creator:     "mem2.hypha",
+		ContentGroups: [][]*ChainContent{
+			{
+				{
+					Label: "content_group_label",
+					Value: []interface{}{"string", "details"},
+				},
+				{
+					Label: "title",
+					Value: []interface{}{"string", "Member 2: Role 2"},
+				},
+			},
+			{
+				{
+					Label: "content_group_label",
+					Value: []interface{}{"string", "system"},
+				},
+				{
+					Label: "ballot_id",
+					Value: []interface{}{"name", "hypha1....1fh"},
+				},
+				{
+					Label: "node_label",
+					Value: []interface{}{"string", "Member 2: Role 2"},
+				},
+				{
+					Label: "type",
+					Value: []interface{}{"name", "role"},
+				},
+			},
+		},
+	}, "system1")
+	if err != nil {
+		t.Fatalf("StoreDocument failed: %v", err)
+	}
+	doc, err := cache.GetByHash(hash, &RequestConfig{})
+	if err != nil {
+		t.Fatalf("GetByHash failed: %v", err)
+	}
+	if doc.DocType != "role" || doc.NodeLabel != "Member 2: Role 2" || doc.BallotID != "hypha1....1fh" {
+		t.Fatalf("System fields were not promoted, found: %v", doc)
+	}
+	docs := &Docs{}
+	err = dg.Query(fmt.Sprintf(`{docs(func: type(Role)) @filter(eq(hash, "%v")){uid hash}}`, hash), nil, docs)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(docs.Docs) != 1 {
+		t.Fatalf("Expected to find document by kind type, found: %v", docs.Docs)
+	}
+
+	t.Log("Backfilling system fields")
+	legacyHash := "b9e0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c09"
+	legacy := NewDocument(&ChainDocument{
+		Hash:        legacyHash,
+		CreatedDate: "2021-01-15T19:17:44",
+		Creator:     "mem2.hypha",
+		ContentGroups: [][]*ChainContent{
+			{
+				{
+					Label: "content_group_label",
+					Value: []interface{}{"string", "system"},
+				},
+				{
+					Label: "type",
+					Value: []interface{}{"name", "assignment"},
+				},
+			},
+		},
+	})
+	legacy.DocType = ""
+	_, err = dg.MutateJSON(legacy, false)
+	if err != nil {
+		t.Fatalf("Failed to create legacy document: %v", err)
+	}
+	_, err = cache.BackfillSystemFields()
+	if err != nil {
+		t.Fatalf("BackfillSystemFields failed: %v", err)
+	}
+	docs = &Docs{}
+	err = dg.Query(fmt.Sprintf(`{docs(func: type(Assignment)) @filter(eq(hash, "%v")){uid hash doc_type}}`, legacyHash), nil, docs)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(docs.Docs) != 1 || docs.Docs[0].DocType != "assignment" {
+		t.Fatalf("Expected legacy document to be backfilled, found: %v", docs.Docs)
+	}
+}
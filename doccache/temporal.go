@@ -0,0 +1,917 @@
+package doccache
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/dgo/v2/protos/api"
+)
+
+//historySchema versions of the documents and edges, a version is valid from the block it was
+//recorded at until the block it was replaced or removed at, open versions do not have valid_to
+const historySchema = `
+      type Cursor {
+        cursor
+        block_num
+        history_start
+      }
+
+      type DocumentVersion {
+        version_hash
+        version_creator
+        version_created_date
+        version_doc_type
+        version_document
+        valid_from
+        valid_to
+      }
+
+      type EdgeVersion {
+        version_edge
+        version_from
+        version_to
+        valid_from
+        valid_to
+      }
+
+      version_hash: string @index(exact) .
+      version_creator: string @index(exact) .
+      version_created_date: datetime @index(hour) .
+      version_doc_type: string @index(exact) .
+      version_document: string .
+      version_edge: string @index(exact) .
+      version_from: string @index(exact) .
+      version_to: string @index(exact) .
+      valid_from: int @index(int) .
+      valid_to: int @index(int) .
+      history_start: int .
+    `
+
+//versionBatchSize number of versions recorded per mutation by the backfill
+const versionBatchSize = 200
+
+//DocumentVersions helper to enable document version decoding
+type DocumentVersions struct {
+	Versions []*DocumentVersion `json:"versions,omitempty"`
+}
+
+//DocumentVersion state of a document between two blocks, Document holds the chain document as json,
+//ValidTo is nil while the version is the current one
+type DocumentVersion struct {
+	UID         string     `json:"uid,omitempty"`
+	Hash        string     `json:"version_hash,omitempty"`
+	Creator     string     `json:"version_creator,omitempty"`
+	CreatedDate *time.Time `json:"version_created_date,omitempty"`
+	DocType     string     `json:"version_doc_type,omitempty"`
+	Document    string     `json:"version_document,omitempty"`
+	TrxID       string     `json:"version_trx_id,omitempty"`
+	ValidFrom   uint64     `json:"valid_from"`
+	ValidTo     *uint64    `json:"valid_to,omitempty"`
+	DType       []string   `json:"dgraph.type,omitempty"`
+}
+
+//newDocumentVersion creates the version of the chain document valid from the block, recorded by the transaction
+func newDocumentVersion(chainDoc *ChainDocument, docType string, blockNum uint64, trxID string) (*DocumentVersion, error) {
+	data, err := json.Marshal(chainDoc)
+	if err != nil {
+		return nil, err
+	}
+	return &DocumentVersion{
+		Hash:        chainDoc.Hash,
+		Creator:     chainDoc.Creator,
+		CreatedDate: ToTime(chainDoc.CreatedDate),
+		DocType:     docType,
+		Document:    string(data),
+		TrxID:       trxID,
+		ValidFrom:   blockNum,
+		DType:       []string{"DocumentVersion"},
+	}, nil
+}
+
+//ChainDocument decodes the chain document of the version
+func (m *DocumentVersion) ChainDocument() (*ChainDocument, error) {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(m.Document)))
+	decoder.UseNumber()
+	chainDoc := &ChainDocument{}
+	err := decoder.Decode(chainDoc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode version of document: %v, valid from: %v, error: %v", m.Hash, m.ValidFrom, err)
+	}
+	return chainDoc, nil
+}
+
+func (m *DocumentVersion) String() string {
+	return fmt.Sprintf("DocumentVersion{UID: %v, Hash: %v, Creator: %v, CreatedDate: %v, DocType: %v, TrxID: %v, ValidFrom: %v, ValidTo: %v, DType: %v}", m.UID, m.Hash, m.Creator, m.CreatedDate, m.DocType, m.TrxID, m.ValidFrom, m.ValidTo, m.DType)
+}
+
+//EdgeVersions helper to enable edge version decoding
+type EdgeVersions struct {
+	Versions []*EdgeVersion `json:"versions,omitempty"`
+}
+
+//EdgeVersion existence of a chain edge between two blocks, ValidTo is nil while the edge exists
+type EdgeVersion struct {
+	UID       string   `json:"uid,omitempty"`
+	Name      string   `json:"version_edge,omitempty"`
+	From      string   `json:"version_from,omitempty"`
+	To        string   `json:"version_to,omitempty"`
+	ValidFrom uint64   `json:"valid_from"`
+	ValidTo   *uint64  `json:"valid_to,omitempty"`
+	DType     []string `json:"dgraph.type,omitempty"`
+}
+
+func newEdgeVersion(chainEdge *ChainEdge, blockNum uint64) *EdgeVersion {
+	return &EdgeVersion{
+		Name:      chainEdge.Name,
+		From:      chainEdge.From,
+		To:        chainEdge.To,
+		ValidFrom: blockNum,
+		DType:     []string{"EdgeVersion"},
+	}
+}
+
+func (m *EdgeVersion) String() string {
+	return fmt.Sprintf("EdgeVersion{UID: %v, Name: %v, From: %v, To: %v, ValidFrom: %v, ValidTo: %v, DType: %v}", m.UID, m.Name, m.From, m.To, m.ValidFrom, m.ValidTo, m.DType)
+}
+
+//versionEnd closes an open version at a block
+type versionEnd struct {
+	UID     string `json:"uid"`
+	ValidTo uint64 `json:"valid_to"`
+}
+
+//SetBlockNum sets the block of the changes that follow, the versions they record are valid from it,
+//it is stored with the cursor by the next mutation
+func (m *Doccache) SetBlockNum(blockNum uint64) {
+	m.Cursor.BlockNum = blockNum
+}
+
+//validAt filter that matches the versions valid at the end of the block
+func validAt(blockNum uint64) string {
+	return fmt.Sprintf("le(valid_from, %v) AND (NOT has(valid_to) OR gt(valid_to, %v))", blockNum, blockNum)
+}
+
+//openVersionUIDs returns the uids of the versions found by the query under the versions key
+func (m *Doccache) openVersionUIDs(query string, vars map[string]string) ([]string, error) {
+	versions := &EdgeVersions{}
+	err := m.dgraph.Query(query, vars, versions)
+	if err != nil {
+		return nil, err
+	}
+	uids := make([]string, 0, len(versions.Versions))
+	for _, version := range versions.Versions {
+		uids = append(uids, version.UID)
+	}
+	return uids, nil
+}
+
+//documentVersionMutation closes the open version of the document at the current block and opens
+//a new one with the chain document, if chainDoc is nil the open versions of the document's edges are closed as well
+func (m *Doccache) documentVersionMutation(hash string, chainDoc *ChainDocument, docType string) (*api.Mutation, error) {
+	blockNum := m.Cursor.BlockNum
+	query := `
+		query versions($hash: string){
+			versions(func: eq(version_hash, $hash)) @filter(type(DocumentVersion) AND NOT has(valid_to)){
+				uid
+			}
+		}
+	`
+	if chainDoc == nil {
+		query = `
+			query versions($hash: string){
+				docVersions as var(func: eq(version_hash, $hash)) @filter(type(DocumentVersion))
+				fromVersions as var(func: eq(version_from, $hash)) @filter(type(EdgeVersion))
+				toVersions as var(func: eq(version_to, $hash)) @filter(type(EdgeVersion))
+				versions(func: uid(docVersions, fromVersions, toVersions)) @filter(NOT has(valid_to)){
+					uid
+				}
+			}
+		`
+	}
+	uids, err := m.openVersionUIDs(query, map[string]string{"$hash": hash})
+	if err != nil {
+		return nil, err
+	}
+	changes := make([]interface{}, 0, len(uids)+1)
+	for _, uid := range uids {
+		changes = append(changes, &versionEnd{UID: uid, ValidTo: blockNum})
+	}
+	if chainDoc != nil {
+		version, err := newDocumentVersion(chainDoc, docType, blockNum, m.trxID)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, version)
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return m.dgraph.JSONMutation(changes, false)
+}
+
+//edgeVersionMutation opens a version for a created edge or closes the open versions of a deleted one at the current block
+func (m *Doccache) edgeVersionMutation(chainEdge *ChainEdge, deleteOp bool) (*api.Mutation, error) {
+	query := `
+		query versions($name: string, $from: string, $to: string){
+			versions(func: eq(version_from, $from)) @filter(type(EdgeVersion) AND eq(version_to, $to) AND eq(version_edge, $name) AND NOT has(valid_to)){
+				uid
+			}
+		}
+	`
+	uids, err := m.openVersionUIDs(query, map[string]string{"$name": chainEdge.Name, "$from": chainEdge.From, "$to": chainEdge.To})
+	if err != nil {
+		return nil, err
+	}
+	if !deleteOp {
+		if len(uids) > 0 {
+			return nil, nil
+		}
+		return m.dgraph.JSONMutation(newEdgeVersion(chainEdge, m.Cursor.BlockNum), false)
+	}
+	if len(uids) == 0 {
+		return nil, nil
+	}
+	changes := make([]*versionEnd, 0, len(uids))
+	for _, uid := range uids {
+		changes = append(changes, &versionEnd{UID: uid, ValidTo: m.Cursor.BlockNum})
+	}
+	return m.dgraph.JSONMutation(changes, false)
+}
+
+//RecordVersions records the current state of every document and edge as versions valid from the block,
+//used to start the history of documents stored before versioning or imported from a snapshot, documents
+//and edges that already have an open version are skipped so that it can be run again
+func (m *Doccache) RecordVersions(blockNum uint64) (int, error) {
+	openDocs, openEdges, err := m.openVersions()
+	if err != nil {
+		return 0, err
+	}
+	changes := make([]interface{}, 0, versionBatchSize)
+	flush := func() error {
+		if len(changes) == 0 {
+			return nil
+		}
+		_, err := m.dgraph.MutateJSON(changes, false)
+		changes = changes[:0]
+		return err
+	}
+	recorded := 0
+	err = m.ScanDocuments(func(doc *Document) error {
+		if !openDocs[doc.Hash] {
+			version, err := newDocumentVersion(doc.ToChainDocument(), doc.DocType, blockNum, "")
+			if err != nil {
+				return err
+			}
+			changes = append(changes, version)
+			recorded++
+		}
+		for edgeName, targets := range doc.Edges {
+			for _, target := range targets {
+				edge := &ChainEdge{Name: edgeName, From: doc.Hash, To: target.Hash}
+				if !openEdges[edgeVersionKey(edge.Name, edge.From, edge.To)] {
+					changes = append(changes, newEdgeVersion(edge, blockNum))
+				}
+			}
+		}
+		if len(changes) >= versionBatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	err = flush()
+	if err != nil {
+		return 0, err
+	}
+	log.Infof("Recorded versions of %v documents valid from block: %v", recorded, blockNum)
+	return recorded, nil
+}
+
+//openVersions returns the hashes of the documents and the keys of the edges that have an open version
+func (m *Doccache) openVersions() (map[string]bool, map[string]bool, error) {
+	docs := make(map[string]bool)
+	edges := make(map[string]bool)
+	after := ""
+	for {
+		afterClause := ""
+		if after != "" {
+			afterClause = fmt.Sprintf(", after: %v", after)
+		}
+		query := fmt.Sprintf(`
+			{
+				versions(func: has(valid_from), first: %v%v) @filter(NOT has(valid_to)){
+					uid
+					version_hash
+					version_edge
+					version_from
+					version_to
+				}
+			}
+		`, backfillPageSize, afterClause)
+		result := &struct {
+			Versions []*struct {
+				UID  string `json:"uid,omitempty"`
+				Hash string `json:"version_hash,omitempty"`
+				Name string `json:"version_edge,omitempty"`
+				From string `json:"version_from,omitempty"`
+				To   string `json:"version_to,omitempty"`
+			} `json:"versions,omitempty"`
+		}{}
+		err := m.dgraph.Query(query, nil, result)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, version := range result.Versions {
+			if version.Hash != "" {
+				docs[version.Hash] = true
+			} else {
+				edges[edgeVersionKey(version.Name, version.From, version.To)] = true
+			}
+		}
+		if len(result.Versions) < backfillPageSize {
+			break
+		}
+		after = result.Versions[len(result.Versions)-1].UID
+	}
+	return docs, edges, nil
+}
+
+func edgeVersionKey(name, from, to string) string {
+	return fmt.Sprintf("%v %v %v", name, from, to)
+}
+
+//historyStarted indicates if any version has been recorded, the history must not be restarted when
+//the migrations are applied again, as its start would move to the current block
+func (m *Doccache) historyStarted() (bool, error) {
+	result := &struct {
+		Versions []*uidNode `json:"versions,omitempty"`
+	}{}
+	err := m.dgraph.Query(`{versions(func: has(valid_from), first: 1){uid}}`, nil, result)
+	if err != nil {
+		return false, err
+	}
+	return len(result.Versions) > 0, nil
+}
+
+//startHistory records the current state as versions valid from the block of the cursor and stores that block
+//as the start of the history, as the state before it is unknown
+func (m *Doccache) startHistory(cursor *Cursor) error {
+	_, err := m.RecordVersions(cursor.BlockNum)
+	if err != nil {
+		return err
+	}
+	cursor.HistoryStart = cursor.BlockNum
+	mutation, err := m.cursorMutation(cursor)
+	if err != nil {
+		return err
+	}
+	_, err = m.dgraph.Mutate(mutation)
+	return err
+}
+
+//documentVersionFields predicates requested for document versions
+const documentVersionFields = `
+	uid
+	version_hash
+	version_creator
+	version_created_date
+	version_doc_type
+	version_document
+	version_trx_id
+	valid_from
+	valid_to
+`
+
+//documentsAsOf returns the documents valid at the end of the block by hash
+func (m *Doccache) documentsAsOf(hashes []string, blockNum uint64) (map[string]*Document, error) {
+	docs := make(map[string]*Document, len(hashes))
+	if len(hashes) == 0 {
+		return docs, nil
+	}
+	quoted := make([]string, 0, len(hashes))
+	for _, hash := range hashes {
+		quoted = append(quoted, strconv.Quote(hash))
+	}
+	query := fmt.Sprintf(`
+		{
+			versions(func: eq(version_hash, [%v])) @filter(type(DocumentVersion) AND %v){
+				%v
+			}
+		}
+	`, strings.Join(quoted, ","), validAt(blockNum), documentVersionFields)
+	versions := &DocumentVersions{}
+	err := m.dgraph.Query(query, nil, versions)
+	if err != nil {
+		return nil, err
+	}
+	for _, version := range versions.Versions {
+		doc, err := version.toDocument()
+		if err != nil {
+			return nil, err
+		}
+		docs[doc.Hash] = doc
+	}
+	return docs, nil
+}
+
+//toDocument creates the document of the version, checksum contents are not linked to their documents
+func (m *DocumentVersion) toDocument() (*Document, error) {
+	chainDoc, err := m.ChainDocument()
+	if err != nil {
+		return nil, err
+	}
+	doc := NewDocument(chainDoc)
+	if m.DocType != "" {
+		doc.DocType = m.DocType
+	}
+	return doc, nil
+}
+
+//neighboursAsOf returns the hashes of the documents connected through the edge at the end of the block, by document hash
+func (m *Doccache) neighboursAsOf(hashes []string, edgeName string, inbound bool, blockNum uint64) (map[string][]string, error) {
+	neighbours := make(map[string][]string, len(hashes))
+	if len(hashes) == 0 {
+		return neighbours, nil
+	}
+	quoted := make([]string, 0, len(hashes))
+	for _, hash := range hashes {
+		quoted = append(quoted, strconv.Quote(hash))
+	}
+	source := "version_from"
+	if inbound {
+		source = "version_to"
+	}
+	query := fmt.Sprintf(`
+		query versions($name: string){
+			versions(func: eq(%v, [%v])) @filter(type(EdgeVersion) AND eq(version_edge, $name) AND %v){
+				version_from
+				version_to
+			}
+		}
+	`, source, strings.Join(quoted, ","), validAt(blockNum))
+	versions := &EdgeVersions{}
+	err := m.dgraph.Query(query, map[string]string{"$name": edgeName}, versions)
+	if err != nil {
+		return nil, err
+	}
+	for _, version := range versions.Versions {
+		hash, neighbour := version.From, version.To
+		if inbound {
+			hash, neighbour = version.To, version.From
+		}
+		neighbours[hash] = append(neighbours[hash], neighbour)
+	}
+	for _, targets := range neighbours {
+		sort.Strings(targets)
+	}
+	return neighbours, nil
+}
+
+//GetByHashAsOf finds the document as it was at the end of the block, the edges and traversals of the
+//request config are resolved with the edges that existed at that block, returns nil if the document did not exist
+func (m *Doccache) GetByHashAsOf(hash string, blockNum uint64, rc *RequestConfig) (*Document, error) {
+	if rc == nil {
+		rc = &RequestConfig{}
+	}
+	_, err := configureRequest(rc)
+	if err != nil {
+		return nil, err
+	}
+	if blockNum < m.Cursor.HistoryStart {
+		return nil, invalidRequest("block: %v is before the start of the history at block: %v", blockNum, m.Cursor.HistoryStart)
+	}
+	hash = NormalizeHash(hash)
+	docs, err := m.documentsAsOf([]string{hash}, blockNum)
+	if err != nil {
+		return nil, err
+	}
+	doc, ok := docs[hash]
+	if !ok {
+		return nil, nil
+	}
+	err = m.applyRequestAsOf([]*Document{doc}, rc, blockNum)
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+//GetByHashAsOfAsMap finds the document as it was at the end of the block returns a map
+func (m *Doccache) GetByHashAsOfAsMap(hash string, blockNum uint64, rc *RequestConfig) (map[string]interface{}, error) {
+	doc, err := m.GetByHashAsOf(hash, blockNum, rc)
+	if err != nil || doc == nil {
+		return nil, err
+	}
+	return DocumentAsMap(doc)
+}
+
+//applyRequestAsOf removes the fields that were not requested and adds the requested edges as they were at the block
+func (m *Doccache) applyRequestAsOf(docs []*Document, rc *RequestConfig, blockNum uint64) error {
+	for _, doc := range docs {
+		if !rc.ContentGroups {
+			doc.ContentGroups = nil
+		}
+		if !rc.Certificates {
+			doc.Certificates = nil
+		}
+	}
+	fieldsOnly := &RequestConfig{ContentGroups: rc.ContentGroups, Certificates: rc.Certificates}
+	for _, edgeName := range rc.Edges {
+		err := m.traverseAsOf(docs, &EdgeRequest{Name: edgeName, Request: fieldsOnly}, 1, blockNum)
+		if err != nil {
+			return err
+		}
+	}
+	for _, edgeName := range rc.InboundEdges {
+		err := m.traverseAsOf(docs, &EdgeRequest{Name: edgeName, Inbound: true, Request: fieldsOnly}, 1, blockNum)
+		if err != nil {
+			return err
+		}
+	}
+	for _, traversal := range rc.Traversals {
+		depth := traversal.Depth
+		if depth == 0 {
+			depth = 1
+		}
+		err := m.traverseAsOf(docs, traversal, depth, blockNum)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//traverseAsOf sets the neighbours of the documents through the edge request as they were at the block,
+//depth is the number of times the edge is still followed
+func (m *Doccache) traverseAsOf(docs []*Document, er *EdgeRequest, depth int, blockNum uint64) error {
+	if len(docs) == 0 || depth == 0 {
+		return nil
+	}
+	hashes := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		hashes = append(hashes, doc.Hash)
+	}
+	neighbourHashes, err := m.neighboursAsOf(hashes, er.Name, er.Inbound, blockNum)
+	if err != nil {
+		return err
+	}
+	unique := make([]string, 0)
+	for _, targets := range neighbourHashes {
+		unique = append(unique, targets...)
+	}
+	neighbourDocs, err := m.documentsAsOf(unique, blockNum)
+	if err != nil {
+		return err
+	}
+	docTypes := make(map[string]bool, len(er.DocTypes))
+	for _, docType := range er.DocTypes {
+		docTypes[docType] = true
+	}
+	rc := er.Request
+	if rc == nil {
+		rc = &RequestConfig{}
+	}
+	alias := er.EdgeAlias()
+	next := make([]*Document, 0)
+	for _, doc := range docs {
+		neighbours := make([]*Document, 0)
+		for _, hash := range neighbourHashes[doc.Hash] {
+			neighbour, ok := neighbourDocs[hash]
+			if !ok || (len(docTypes) > 0 && !docTypes[neighbour.DocType]) {
+				continue
+			}
+			//each neighbour is a copy as the same document can be reached through different paths
+			copied := *neighbour
+			neighbours = append(neighbours, &copied)
+		}
+		if er.Count {
+			if doc.EdgeCounts == nil {
+				doc.EdgeCounts = make(map[string]int)
+			}
+			doc.EdgeCounts[alias] = len(neighbours)
+		}
+		if er.Offset > 0 {
+			if er.Offset >= len(neighbours) {
+				neighbours = neighbours[:0]
+			} else {
+				neighbours = neighbours[er.Offset:]
+			}
+		}
+		if er.First > 0 && er.First < len(neighbours) {
+			neighbours = neighbours[:er.First]
+		}
+		if len(neighbours) == 0 {
+			continue
+		}
+		setNeighbours(doc, alias, neighbours)
+		next = append(next, neighbours...)
+	}
+	err = m.applyRequestAsOf(next, rc, blockNum)
+	if err != nil {
+		return err
+	}
+	return m.traverseAsOf(next, er, depth-1, blockNum)
+}
+
+//setNeighbours stores the neighbours as the document json decoding does for the alias
+func setNeighbours(doc *Document, alias string, neighbours []*Document) {
+	if strings.HasPrefix(alias, inboundEdgePrefix) {
+		if doc.InboundEdges == nil {
+			doc.InboundEdges = make(map[string][]*Document)
+		}
+		doc.InboundEdges[strings.TrimPrefix(alias, inboundEdgePrefix)] = neighbours
+		return
+	}
+	if doc.Edges == nil {
+		doc.Edges = make(map[string][]*Document)
+	}
+	doc.Edges[alias] = neighbours
+}
+
+//DocumentAsMap converts the document to the map returned by the AsMap queries, with the edges under their aliases
+func DocumentAsMap(doc *Document) (map[string]interface{}, error) {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	docMap := make(map[string]interface{})
+	err = json.Unmarshal(data, &docMap)
+	if err != nil {
+		return nil, err
+	}
+	addNeighbours := func(alias string, neighbours []*Document) error {
+		neighbourMaps := make([]interface{}, 0, len(neighbours))
+		for _, neighbour := range neighbours {
+			neighbourMap, err := DocumentAsMap(neighbour)
+			if err != nil {
+				return err
+			}
+			neighbourMaps = append(neighbourMaps, neighbourMap)
+		}
+		docMap[alias] = neighbourMaps
+		return nil
+	}
+	for edgeName, neighbours := range doc.Edges {
+		err = addNeighbours(edgeName, neighbours)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for edgeName, neighbours := range doc.InboundEdges {
+		err = addNeighbours(InboundEdgeAlias(edgeName), neighbours)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for alias, count := range doc.EdgeCounts {
+		docMap[EdgeCountAlias(alias)] = count
+	}
+	return docMap, nil
+}
+
+//searchAsOf finds the documents that matched the query at the end of the block, the content and edge filters
+//are applied to the versions read in order, so pages can require several reads
+func (m *Doccache) searchAsOf(q *SearchQuery) (*SearchResult, error) {
+	first := q.First
+	if first <= 0 {
+		first = DefaultSearchPageSize
+	}
+	if first > MaxSearchPageSize {
+		return nil, invalidSearch("page size: %v exceeds the maximum: %v", first, MaxSearchPageSize)
+	}
+	if q.AsOfBlock < m.Cursor.HistoryStart {
+		return nil, invalidSearch("as of block: %v is before the start of the history at block: %v", q.AsOfBlock, m.Cursor.HistoryStart)
+	}
+	orderBy := q.OrderBy
+	if orderBy == "" {
+		orderBy = OrderByCreatedDate
+	}
+	if orderBy != OrderByCreatedDate && orderBy != OrderByHash {
+		return nil, invalidSearch("invalid search order: %v, as of searches can only be ordered by %v or %v", orderBy, OrderByCreatedDate, OrderByHash)
+	}
+	for _, content := range q.Contents {
+		if _, _, err := content.validate(); err != nil {
+			return nil, err
+		}
+	}
+	for _, edgeName := range q.HasEdges {
+		if m.GetEdgeDefinition(edgeName) == nil {
+			return nil, invalidSearch("unknown edge: %v", edgeName)
+		}
+	}
+	for _, edgeName := range q.HasInboundEdges {
+		if m.GetEdgeDefinition(edgeName) == nil {
+			return nil, invalidSearch("unknown edge: %v", edgeName)
+		}
+	}
+	rc := q.RequestConfig
+	if rc == nil {
+		rc = &RequestConfig{}
+	}
+	_, err := configureRequest(rc)
+	if err != nil {
+		return nil, err
+	}
+	var after *searchCursor
+	if q.After != "" {
+		after, err = decodeSearchCursor(q.After)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := &SearchResult{
+		Docs: make([]*Document, 0, first),
+	}
+	for {
+		versions, err := m.searchVersionsPage(q, orderBy, after)
+		if err != nil {
+			return nil, err
+		}
+		docs, err := m.filterAsOf(versions, q)
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			if len(result.Docs) == first {
+				last := result.Docs[first-1]
+				createdDate := ""
+				if last.CreatedDate != nil {
+					createdDate = last.CreatedDate.Format(time.RFC3339Nano)
+				}
+				result.Cursor = encodeSearchCursor(createdDate, last.Hash)
+				break
+			}
+			result.Docs = append(result.Docs, doc)
+		}
+		if result.Cursor != "" || len(versions) < scanPageSize {
+			break
+		}
+		last := versions[len(versions)-1]
+		after = &searchCursor{Hash: last.Hash}
+		if orderBy == OrderByCreatedDate && last.CreatedDate != nil {
+			after.CreatedDate = last.CreatedDate.Format(time.RFC3339Nano)
+		}
+	}
+	err = m.applyRequestAsOf(result.Docs, rc, q.AsOfBlock)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+//searchAsOfAsMap finds the documents that matched the query at the end of the block returns maps
+func (m *Doccache) searchAsOfAsMap(q *SearchQuery) (*SearchResultAsMap, error) {
+	result, err := m.searchAsOf(q)
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]map[string]interface{}, 0, len(result.Docs))
+	for _, doc := range result.Docs {
+		docMap, err := DocumentAsMap(doc)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, docMap)
+	}
+	return &SearchResultAsMap{
+		Docs:   docs,
+		Cursor: result.Cursor,
+	}, nil
+}
+
+//searchVersionsPage reads the next versions valid at the block that match the creator, doc type and created date filters
+func (m *Doccache) searchVersionsPage(q *SearchQuery, orderBy SearchOrder, after *searchCursor) ([]*DocumentVersion, error) {
+	vars := make(map[string]string)
+	declarations := make([]string, 0)
+	addVar := func(name, value string) string {
+		name = "$" + name
+		vars[name] = value
+		declarations = append(declarations, name+": string")
+		return name
+	}
+	filters := []string{"type(DocumentVersion)", validAt(q.AsOfBlock)}
+	if q.Creator != "" {
+		filters = append(filters, fmt.Sprintf("eq(version_creator, %v)", addVar("creator", q.Creator)))
+	}
+	if q.CreatedFrom != nil {
+		filters = append(filters, fmt.Sprintf("ge(version_created_date, %v)", addVar("createdFrom", q.CreatedFrom.UTC().Format(time.RFC3339Nano))))
+	}
+	if q.CreatedTo != nil {
+		filters = append(filters, fmt.Sprintf("lt(version_created_date, %v)", addVar("createdTo", q.CreatedTo.UTC().Format(time.RFC3339Nano))))
+	}
+	if q.DocType != "" {
+		filters = append(filters, fmt.Sprintf("eq(version_doc_type, %v)", addVar("docType", q.DocType)))
+	}
+	direction, comparator := "orderasc", "gt"
+	if q.Desc {
+		direction, comparator = "orderdesc", "lt"
+	}
+	order := fmt.Sprintf("%v: version_hash", direction)
+	if orderBy == OrderByCreatedDate {
+		order = fmt.Sprintf("%v: version_created_date, %v", direction, order)
+	}
+	if after != nil {
+		hashFilter := fmt.Sprintf("%v(version_hash, %v)", comparator, addVar("afterHash", after.Hash))
+		if orderBy == OrderByCreatedDate && after.CreatedDate != "" {
+			createdDate := addVar("afterCreatedDate", after.CreatedDate)
+			hashFilter = fmt.Sprintf("(%v(version_created_date, %v) OR (eq(version_created_date, %v) AND %v))", comparator, createdDate, createdDate, hashFilter)
+		}
+		filters = append(filters, hashFilter)
+	}
+	header := ""
+	if len(declarations) > 0 {
+		header = fmt.Sprintf("query search(%v)", strings.Join(declarations, ", "))
+	}
+	query := fmt.Sprintf(`
+		%v{
+			versions(func: type(DocumentVersion), first: %v, %v) @filter(%v){
+				%v
+			}
+		}
+	`, header, scanPageSize, order, strings.Join(filters, " AND "), documentVersionFields)
+	log.Debugf("Search as of block query: %v, vars: %v", query, vars)
+	versions := &DocumentVersions{}
+	err := m.dgraph.Query(query, vars, versions)
+	if err != nil {
+		return nil, err
+	}
+	return versions.Versions, nil
+}
+
+//filterAsOf returns the documents of the versions that match the content and edge filters, in the versions order
+func (m *Doccache) filterAsOf(versions []*DocumentVersion, q *SearchQuery) ([]*Document, error) {
+	docs := make([]*Document, 0, len(versions))
+	hashes := make([]string, 0, len(versions))
+	for _, version := range versions {
+		doc, err := version.toDocument()
+		if err != nil {
+			return nil, err
+		}
+		if matchesContents(doc, q.Contents) {
+			docs = append(docs, doc)
+			hashes = append(hashes, doc.Hash)
+		}
+	}
+	edgeFilters := make(map[string]map[string][]string)
+	for _, edgeName := range q.HasEdges {
+		neighbours, err := m.neighboursAsOf(hashes, edgeName, false, q.AsOfBlock)
+		if err != nil {
+			return nil, err
+		}
+		edgeFilters[edgeName] = neighbours
+	}
+	for _, edgeName := range q.HasInboundEdges {
+		neighbours, err := m.neighboursAsOf(hashes, edgeName, true, q.AsOfBlock)
+		if err != nil {
+			return nil, err
+		}
+		edgeFilters[InboundEdgeAlias(edgeName)] = neighbours
+	}
+	matched := make([]*Document, 0, len(docs))
+	for _, doc := range docs {
+		hasEdges := true
+		for _, neighbours := range edgeFilters {
+			if len(neighbours[doc.Hash]) == 0 {
+				hasEdges = false
+				break
+			}
+		}
+		if hasEdges {
+			matched = append(matched, doc)
+		}
+	}
+	return matched, nil
+}
+
+//matchesContents indicates if the document has a content for each of the filters
+func matchesContents(doc *Document, filters []*ContentFilter) bool {
+	for _, filter := range filters {
+		found := false
+		for _, contentGroup := range doc.ContentGroups {
+			if filter.GroupLabel != "" && contentGroup.Label() != filter.GroupLabel {
+				continue
+			}
+			for _, content := range contentGroup.Contents {
+				if filter.matches(content) {
+					found = true
+					break
+				}
+			}
+			if found {
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
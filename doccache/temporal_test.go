@@ -0,0 +1,195 @@
+package doccache
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMatchesContents(t *testing.T) {
+	doc := NewDocument(&ChainDocument{
+		Hash:        "aa01",
+		CreatedDate: "2021-08-01T10:00:00.000",
+		ContentGroups: [][]*ChainContent{
+			{
+				{Label: "content_group_label", Value: []interface{}{"string", "details"}},
+				{Label: "title", Value: []interface{}{"string", "role"}},
+			},
+		},
+	})
+	for _, test := range []struct {
+		filters  []*ContentFilter
+		expected bool
+	}{
+		{nil, true},
+		{[]*ContentFilter{{Label: "title"}}, true},
+		{[]*ContentFilter{{GroupLabel: "details", Label: "title", Value: "role"}}, true},
+		{[]*ContentFilter{{GroupLabel: "system", Label: "title"}}, false},
+		{[]*ContentFilter{{Label: "title", Value: "other"}}, false},
+		{[]*ContentFilter{{Label: "title"}, {Label: "missing"}}, false},
+	} {
+		if matched := matchesContents(doc, test.filters); matched != test.expected {
+			t.Fatalf("Expected: %v for filters: %v, found: %v", test.expected, test.filters, matched)
+		}
+	}
+}
+
+func TestDocumentAsMap(t *testing.T) {
+	doc := &Document{Hash: "aa01", DocType: "dho"}
+	setNeighbours(doc, "member", []*Document{{Hash: "bb01"}})
+	setNeighbours(doc, InboundEdgeAlias("owner"), []*Document{{Hash: "cc01"}})
+	doc.EdgeCounts = map[string]int{"member": 1}
+	if len(doc.Edges["member"]) != 1 || len(doc.InboundEdges["owner"]) != 1 {
+		t.Fatalf("Expected neighbours to be set by alias, found: %v", doc)
+	}
+	docMap, err := DocumentAsMap(doc)
+	if err != nil {
+		t.Fatalf("DocumentAsMap failed: %v", err)
+	}
+	if docMap["hash"] != "aa01" || docMap["doc_type"] != "dho" || docMap[EdgeCountAlias("member")] != 1 {
+		t.Fatalf("Expected document fields and edge count, found: %v", docMap)
+	}
+	members, ok := docMap["member"].([]interface{})
+	if !ok || len(members) != 1 || members[0].(map[string]interface{})["hash"] != "bb01" {
+		t.Fatalf("Expected member neighbour, found: %v", docMap)
+	}
+	owners, ok := docMap[InboundEdgeAlias("owner")].([]interface{})
+	if !ok || len(owners) != 1 || owners[0].(map[string]interface{})["hash"] != "cc01" {
+		t.Fatalf("Expected inbound owner neighbour, found: %v", docMap)
+	}
+}
+
+func TestPointInTime(t *testing.T) {
+	defer doccache.SetBlockNum(0)
+	hashes := []string{
+		"d1f0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c60",
+		"d2f0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c61",
+		"d3f0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c62",
+	}
+	chainDocs := make([]*ChainDocument, 0, len(hashes))
+	for i, hash := range hashes {
+		chainDocs = append(chainDocs, &ChainDocument{
+			Hash:        hash,
+			CreatedDate: fmt.Sprintf("2021-07-0%vT10:00:00.000", i+1),
+			Creator:     "timetraveller",
+			ContentGroups: [][]*ChainContent{
+				{
+					{Label: "content_group_label", Value: []interface{}{"string", "system"}},
+					{Label: "type", Value: []interface{}{"name", "pitdoc"}},
+					{Label: "node_label", Value: []interface{}{"string", fmt.Sprintf("doc %v", i)}},
+				},
+			},
+		})
+	}
+	doccache.SetBlockNum(100)
+	for i, chainDoc := range chainDocs {
+		err := doccache.StoreDocument(chainDoc, fmt.Sprintf("pit%v", i))
+		if err != nil {
+			t.Fatalf("StoreDocument failed: %v", err)
+		}
+	}
+	err := doccache.MutateEdge(&ChainEdge{Name: "pitholder", From: hashes[0], To: hashes[1]}, false, "pit3")
+	if err != nil {
+		t.Fatalf("MutateEdge failed: %v", err)
+	}
+
+	doccache.SetBlockNum(200)
+	chainDocs[0].Certificates = []*ChainCertificate{{Certifier: "auditor", Notes: "approved", CertificationDate: "2021-07-10T10:00:00.000"}}
+	err = doccache.StoreDocument(chainDocs[0], "pit4")
+	if err != nil {
+		t.Fatalf("StoreDocument failed: %v", err)
+	}
+	err = doccache.MutateEdge(&ChainEdge{Name: "pitholder", From: hashes[0], To: hashes[1]}, true, "pit5")
+	if err != nil {
+		t.Fatalf("MutateEdge failed: %v", err)
+	}
+	err = doccache.MutateEdge(&ChainEdge{Name: "pitholder", From: hashes[0], To: hashes[2]}, false, "pit6")
+	if err != nil {
+		t.Fatalf("MutateEdge failed: %v", err)
+	}
+
+	doccache.SetBlockNum(300)
+	err = doccache.DeleteDocument(chainDocs[1], "pit7")
+	if err != nil {
+		t.Fatalf("DeleteDocument failed: %v", err)
+	}
+	cursor, err := doccache.StoredCursor()
+	if err != nil {
+		t.Fatalf("StoredCursor failed: %v", err)
+	}
+	if cursor.BlockNum != 300 {
+		t.Fatalf("Expected block to be stored with the cursor, found: %v", cursor)
+	}
+
+	rc := &RequestConfig{Certificates: true, Edges: []string{"pitholder"}}
+	doc, err := doccache.GetByHashAsOf(hashes[0], 99, rc)
+	if err != nil {
+		t.Fatalf("GetByHashAsOf failed: %v", err)
+	}
+	if doc != nil {
+		t.Fatalf("Expected document not to exist before block 100, found: %v", doc)
+	}
+	doc, err = doccache.GetByHashAsOf(hashes[0], 150, rc)
+	if err != nil {
+		t.Fatalf("GetByHashAsOf failed: %v", err)
+	}
+	if doc == nil || len(doc.Certificates) != 0 || len(doc.Edges["pitholder"]) != 1 || doc.Edges["pitholder"][0].Hash != hashes[1] || doc.NodeLabel != "doc 0" {
+		t.Fatalf("Expected uncertified document holding: %v at block 150, found: %v", hashes[1], doc)
+	}
+	doc, err = doccache.GetByHashAsOf(hashes[0], 200, rc)
+	if err != nil {
+		t.Fatalf("GetByHashAsOf failed: %v", err)
+	}
+	if len(doc.Certificates) != 1 || len(doc.Edges["pitholder"]) != 1 || doc.Edges["pitholder"][0].Hash != hashes[2] {
+		t.Fatalf("Expected certified document holding: %v at block 200, found: %v", hashes[2], doc)
+	}
+	doc, err = doccache.GetByHashAsOf(hashes[1], 250, &RequestConfig{InboundEdges: []string{"pitholder"}})
+	if err != nil {
+		t.Fatalf("GetByHashAsOf failed: %v", err)
+	}
+	if doc == nil || len(doc.InboundEdges["pitholder"]) != 0 {
+		t.Fatalf("Expected document without holders at block 250, found: %v", doc)
+	}
+	doc, err = doccache.GetByHashAsOf(hashes[1], 300, nil)
+	if err != nil {
+		t.Fatalf("GetByHashAsOf failed: %v", err)
+	}
+	if doc != nil {
+		t.Fatalf("Expected deleted document not to exist at block 300, found: %v", doc)
+	}
+	doc, err = doccache.GetByHashAsOf(hashes[2], 250, &RequestConfig{Traversals: []*EdgeRequest{{Name: "pitholder", Inbound: true, Count: true}}})
+	if err != nil {
+		t.Fatalf("GetByHashAsOf failed: %v", err)
+	}
+	if len(doc.InboundEdges["pitholder"]) != 1 || doc.EdgeCounts[InboundEdgeAlias("pitholder")] != 1 {
+		t.Fatalf("Expected inbound traversal with count, found: %v", doc)
+	}
+
+	result, err := doccache.Search(&SearchQuery{DocType: "pitdoc", HasEdges: []string{"pitholder"}, AsOfBlock: 150})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(result.Docs) != 1 || result.Docs[0].Hash != hashes[0] {
+		t.Fatalf("Expected holder at block 150, found: %v", result.Docs)
+	}
+	result, err = doccache.Search(&SearchQuery{Creator: "timetraveller", First: 1, AsOfBlock: 250})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(result.Docs) != 1 || result.Docs[0].Hash != hashes[0] || result.Cursor == "" {
+		t.Fatalf("Expected first page with cursor at block 250, found: %v", result)
+	}
+	result, err = doccache.Search(&SearchQuery{Creator: "timetraveller", After: result.Cursor, AsOfBlock: 250})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(result.Docs) != 2 || result.Cursor != "" {
+		t.Fatalf("Expected last page with 2 documents at block 250, found: %v", result)
+	}
+	mapResult, err := doccache.SearchAsMap(&SearchQuery{Creator: "timetraveller", AsOfBlock: 300})
+	if err != nil {
+		t.Fatalf("SearchAsMap failed: %v", err)
+	}
+	if len(mapResult.Docs) != 2 {
+		t.Fatalf("Expected deleted document to be excluded at block 300, found: %v", mapResult.Docs)
+	}
+}
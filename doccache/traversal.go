@@ -0,0 +1,156 @@
+package doccache
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//MaxTraversalDepth maximum number of edge levels a request can traverse
+const MaxTraversalDepth = 8
+
+//edgeCountPrefix prefix of the alias under which edge counts are requested
+const edgeCountPrefix = "count."
+
+//edgeAliasRegexp valid custom aliases of edge requests
+var edgeAliasRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+//EdgeRequest traversal of an edge, Request specifies the fields and the edges to traverse from the documents
+//at the end of the edge, e.g. dao -> member -> assignment -> role is expressed by nesting edge requests
+type EdgeRequest struct {
+	Name string `json:"name"`
+	//Inbound traverses the edge from the documents that point to the document, requires reverse indexing
+	Inbound bool `json:"inbound,omitempty"`
+	//Alias key under which the documents are returned, defaults to the edge name or its inbound alias
+	Alias string `json:"alias,omitempty"`
+	//Depth number of times the edge is followed recursively, defaults to 1
+	Depth int `json:"depth,omitempty"`
+	//DocTypes only traverse to documents of these doc types
+	DocTypes []string `json:"doc_types,omitempty"`
+	First    int      `json:"first,omitempty"`
+	Offset   int      `json:"offset,omitempty"`
+	//Count returns the number of documents at the end of the edge under count.<alias>
+	Count   bool           `json:"count,omitempty"`
+	Request *RequestConfig `json:"request,omitempty"`
+}
+
+//EdgeAlias returns the key under which the edge documents are returned
+func (m *EdgeRequest) EdgeAlias() string {
+	if m.Alias != "" {
+		return m.Alias
+	}
+	if m.Inbound {
+		return InboundEdgeAlias(m.Name)
+	}
+	return m.Name
+}
+
+func (m *EdgeRequest) String() string {
+	return fmt.Sprintf("EdgeRequest{Name: %v, Inbound: %v, Alias: %v, Depth: %v, DocTypes: %v, First: %v, Offset: %v, Count: %v, Request: %v}", m.Name, m.Inbound, m.Alias, m.Depth, m.DocTypes, m.First, m.Offset, m.Count, m.Request)
+}
+
+//ValidateEdgeAlias checks that the custom alias of an edge request is an identifier that does not
+//collide with the document fields
+func ValidateEdgeAlias(alias string) error {
+	if !edgeAliasRegexp.MatchString(alias) {
+		return invalidRequest("invalid alias: %q, it must start with a letter or underscore followed by letters, digits or underscores", alias)
+	}
+	if documentJSONFields[alias] {
+		return invalidRequest("alias: %v is reserved for a document field", alias)
+	}
+	return nil
+}
+
+//EdgeCountAlias returns the key under which the edge count is returned
+func EdgeCountAlias(alias string) string {
+	return edgeCountPrefix + alias
+}
+
+//InvalidRequestError indicates that the request config is not valid
+type InvalidRequestError struct {
+	Reason string
+}
+
+func (m *InvalidRequestError) Error() string {
+	return m.Reason
+}
+
+func invalidRequest(format string, a ...interface{}) error {
+	return &InvalidRequestError{
+		Reason: fmt.Sprintf(format, a...),
+	}
+}
+
+//traversalRequest builds the blocks that traverse the edge, levels is the number of edge levels left
+func traversalRequest(er *EdgeRequest, levels int) (string, error) {
+	if er.Name == "" {
+		return "", invalidRequest("edge request name is required")
+	}
+	if er.Alias != "" {
+		err := ValidateEdgeAlias(er.Alias)
+		if err != nil {
+			return "", err
+		}
+	}
+	depth := er.Depth
+	if depth == 0 {
+		depth = 1
+	}
+	if depth < 0 || depth > levels {
+		return "", invalidRequest("traversal of edge: %v exceeds the maximum depth: %v", er.Name, MaxTraversalDepth)
+	}
+	if er.First < 0 || er.First > MaxSearchPageSize {
+		return "", invalidRequest("invalid first: %v for edge: %v", er.First, er.Name)
+	}
+	if er.Offset < 0 {
+		return "", invalidRequest("invalid offset: %v for edge: %v", er.Offset, er.Name)
+	}
+	rc := er.Request
+	if rc == nil {
+		rc = &RequestConfig{}
+	}
+	fields, err := requestFields(rc, levels-depth)
+	if err != nil {
+		return "", err
+	}
+	predicate := fmt.Sprintf("<%v>", EdgePredicate(er.Name))
+	if er.Inbound {
+		predicate = fmt.Sprintf("<~%v>", EdgePredicate(er.Name))
+	}
+	args := make([]string, 0, 2)
+	if er.First > 0 {
+		args = append(args, fmt.Sprintf("first: %v", er.First))
+	}
+	if er.Offset > 0 {
+		args = append(args, fmt.Sprintf("offset: %v", er.Offset))
+	}
+	pagination := ""
+	if len(args) > 0 {
+		pagination = fmt.Sprintf("(%v)", strings.Join(args, ", "))
+	}
+	filter := ""
+	if len(er.DocTypes) > 0 {
+		docTypes := make([]string, 0, len(er.DocTypes))
+		for _, docType := range er.DocTypes {
+			docTypes = append(docTypes, strconv.Quote(docType))
+		}
+		filter = fmt.Sprintf("@filter(eq(doc_type, [%v]))", strings.Join(docTypes, ","))
+	}
+	alias := er.EdgeAlias()
+	block := ""
+	for level := 0; level < depth; level++ {
+		count := ""
+		if er.Count {
+			count = fmt.Sprintf("%v: count(%v %v)", queryAlias(EdgeCountAlias(alias)), predicate, filter)
+		}
+		block = fmt.Sprintf(`
+			%v: %v %v %v {
+				%v
+				%v
+			}
+			%v
+		`, queryAlias(alias), predicate, pagination, filter, fields, block, count)
+	}
+	return block, nil
+}
@@ -0,0 +1,160 @@
+package doccache
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTraversalValidation(t *testing.T) {
+	nested := &RequestConfig{}
+	for i := 0; i < MaxTraversalDepth; i++ {
+		nested = &RequestConfig{Traversals: []*EdgeRequest{{Name: "next", Request: nested}}}
+	}
+	invalid := []*RequestConfig{
+		{Traversals: []*EdgeRequest{{Name: ""}}},
+		{Traversals: []*EdgeRequest{{Name: "member", Depth: MaxTraversalDepth + 1}}},
+		{Traversals: []*EdgeRequest{{Name: "member", First: MaxSearchPageSize + 1}}},
+		{Traversals: []*EdgeRequest{{Name: "member", Offset: -1}}},
+		{Edges: []string{"member"}, Traversals: []*EdgeRequest{{Name: "member"}}},
+		{Traversals: []*EdgeRequest{{Name: "member", Depth: 2, Request: &RequestConfig{Traversals: []*EdgeRequest{{Name: "role", Depth: MaxTraversalDepth - 1}}}}}},
+		{Traversals: []*EdgeRequest{{Name: "first", Request: nested}}},
+		{Traversals: []*EdgeRequest{{Name: "member", Alias: "x: <webhook_secret> { uid } y: <edge.member>"}}},
+		{Traversals: []*EdgeRequest{{Name: "member", Alias: "content_groups"}}},
+		{Traversals: []*EdgeRequest{{Name: "member", Alias: "inbound.member"}}},
+	}
+	for _, rc := range invalid {
+		_, err := configureRequest(rc)
+		if _, ok := err.(*InvalidRequestError); !ok {
+			t.Fatalf("Expected invalid request error for: %v, found: %v", rc, err)
+		}
+	}
+	_, err := configureRequest(&RequestConfig{
+		Edges: []string{"member"},
+		Traversals: []*EdgeRequest{
+			{Name: "member", Alias: "members", Count: true},
+			{Name: "member", Inbound: true, Depth: MaxTraversalDepth},
+		},
+	})
+	if err != nil {
+		t.Fatalf("configureRequest failed: %v", err)
+	}
+}
+
+func TestTraversal(t *testing.T) {
+	hashes := []string{
+		"a1f0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c20",
+		"a2f0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c21",
+		"a3f0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c22",
+		"a4f0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c23",
+		"a5f0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c24",
+	}
+	docTypes := []string{"dao", "member", "member", "assignment", "role"}
+	for i, hash := range hashes {
+		err := doccache.StoreDocument(&ChainDocument{
+			Hash:        hash,
+			CreatedDate: fmt.Sprintf("2021-04-1%vT10:00:00", i),
+			Creator:     "traverser",
+			ContentGroups: [][]*ChainContent{
+				{
+					{
+						Label: "content_group_label",
+						Value: []interface{}{"string", "system"},
+					},
+					{
+						Label: "type",
+						Value: []interface{}{"name", docTypes[i]},
+					},
+				},
+			},
+		}, fmt.Sprintf("traversal%v", i))
+		if err != nil {
+			t.Fatalf("StoreDocument failed: %v", err)
+		}
+	}
+	edges := []*ChainEdge{
+		{Name: "tmember", From: hashes[0], To: hashes[1]},
+		{Name: "tmember", From: hashes[0], To: hashes[2]},
+		{Name: "tmember", From: hashes[0], To: hashes[3]},
+		{Name: "tassigned", From: hashes[1], To: hashes[3]},
+		{Name: "trole", From: hashes[3], To: hashes[4]},
+		{Name: "tchild", From: hashes[1], To: hashes[2]},
+		{Name: "tchild", From: hashes[2], To: hashes[3]},
+	}
+	for i, edge := range edges {
+		err := doccache.MutateEdge(edge, false, fmt.Sprintf("traversaledge%v", i))
+		if err != nil {
+			t.Fatalf("MutateEdge failed: %v", err)
+		}
+	}
+
+	doc, err := doccache.GetByHash(hashes[0], &RequestConfig{
+		Traversals: []*EdgeRequest{
+			{
+				Name:     "tmember",
+				DocTypes: []string{"member"},
+				Count:    true,
+				Request: &RequestConfig{
+					Traversals: []*EdgeRequest{
+						{
+							Name: "tassigned",
+							Request: &RequestConfig{
+								ContentGroups: true,
+								Traversals:    []*EdgeRequest{{Name: "trole"}},
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("GetByHash failed: %v", err)
+	}
+	members := doc.Edges["tmember"]
+	if len(members) != 2 || doc.EdgeCounts["tmember"] != 2 {
+		t.Fatalf("Expected 2 members, found: %v, count: %v", members, doc.EdgeCounts)
+	}
+	var assignment *Document
+	for _, member := range members {
+		if member.DocType != "member" {
+			t.Fatalf("Expected only member documents, found: %v", member)
+		}
+		if assigned := member.Edges["tassigned"]; len(assigned) > 0 {
+			assignment = assigned[0]
+		}
+	}
+	if assignment == nil || assignment.Hash != hashes[3] || len(assignment.ContentGroups) != 1 {
+		t.Fatalf("Expected assignment: %v with content groups, found: %v", hashes[3], assignment)
+	}
+	if roles := assignment.Edges["trole"]; len(roles) != 1 || roles[0].Hash != hashes[4] {
+		t.Fatalf("Expected role: %v, found: %v", hashes[4], roles)
+	}
+
+	doc, err = doccache.GetByHash(hashes[1], &RequestConfig{
+		Traversals: []*EdgeRequest{{Name: "tchild", Depth: 2}},
+	})
+	if err != nil {
+		t.Fatalf("GetByHash failed: %v", err)
+	}
+	children := doc.Edges["tchild"]
+	if len(children) != 1 || children[0].Hash != hashes[2] {
+		t.Fatalf("Expected child: %v, found: %v", hashes[2], children)
+	}
+	grandChildren := children[0].Edges["tchild"]
+	if len(grandChildren) != 1 || grandChildren[0].Hash != hashes[3] {
+		t.Fatalf("Expected grand child: %v, found: %v", hashes[3], grandChildren)
+	}
+
+	doc, err = doccache.GetByHash(hashes[0], &RequestConfig{
+		Traversals: []*EdgeRequest{
+			{Name: "tmember", Alias: "page1", First: 2},
+			{Name: "tmember", Alias: "page2", First: 2, Offset: 2, Count: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("GetByHash failed: %v", err)
+	}
+	if len(doc.Edges["page1"]) != 2 || len(doc.Edges["page2"]) != 1 || doc.EdgeCounts["page2"] != 3 {
+		t.Fatalf("Expected pages of 2 and 1 members out of 3, found: %v, %v, count: %v", doc.Edges["page1"], doc.Edges["page2"], doc.EdgeCounts)
+	}
+}
@@ -1,7 +1,11 @@
 package doccache
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -16,6 +20,12 @@ type Content struct {
 	Label           string      `json:"label,omitempty"`
 	Value           string      `json:"value,omitempty"`
 	Type            string      `json:"type,omitempty"`
+	IntValue        *int64      `json:"int_value,omitempty"`
+	TimeValue       *time.Time  `json:"time_value,omitempty"`
+	AssetAmount     *float64    `json:"asset_amount,omitempty"`
+	AssetSymbol     string      `json:"asset_symbol,omitempty"`
+	AssetPrecision  *int        `json:"asset_precision,omitempty"`
+	NameValue       string      `json:"name_value,omitempty"`
 	ContentSequence int         `json:"content_sequence"`
 	Document        []*Document `json:"document,omitempty"`
 	DType           []string    `json:"dgraph.type,omitempty"`
@@ -23,13 +33,50 @@ type Content struct {
 
 //NewContent Creates a Content object based on a ChainContent
 func NewContent(chainContent *ChainContent, sequence int) *Content {
-	return &Content{
+	content := &Content{
 		Label:           chainContent.Label,
-		Type:            fmt.Sprintf("%v", chainContent.Value[0]),
-		Value:           fmt.Sprintf("%v", chainContent.Value[1]),
 		ContentSequence: sequence,
 		DType:           []string{"Content"},
 	}
+	if len(chainContent.Value) > 0 {
+		content.Type = fmt.Sprintf("%v", chainContent.Value[0])
+	}
+	if len(chainContent.Value) > 1 {
+		content.Value = fmt.Sprintf("%v", chainContent.Value[1])
+		content.setTypedValue(chainContent.Value[1])
+	}
+	return content
+}
+
+//setTypedValue populates the typed predicate that corresponds to the variant type
+func (m *Content) setTypedValue(value interface{}) {
+	switch m.Type {
+	case "int64":
+		intValue, err := ToInt64(value)
+		if err != nil {
+			log.Errorf(err, "Failed to parse int64 content value: %v", value)
+			return
+		}
+		m.IntValue = &intValue
+	case "time_point":
+		timeValue, err := ParseTime(m.Value)
+		if err != nil {
+			log.Errorf(err, "Failed to parse time_point content value: %v", m.Value)
+			return
+		}
+		m.TimeValue = timeValue
+	case "asset":
+		asset, err := ParseAsset(m.Value)
+		if err != nil {
+			log.Errorf(err, "Failed to parse asset content value: %v", m.Value)
+			return
+		}
+		m.AssetAmount = &asset.Amount
+		m.AssetSymbol = asset.Symbol
+		m.AssetPrecision = &asset.Precision
+	case "name":
+		m.NameValue = m.Value
+	}
 }
 
 //IsChecksum indicates if Content is of type Checksum
@@ -38,7 +85,7 @@ func (m *Content) IsChecksum() bool {
 }
 
 func (m *Content) String() string {
-	return fmt.Sprintf("Content{UID: %v, Label: %v, Value: %v, ContentSequence: %v, Document: %v, DType: %v}", m.UID, m.Label, m.Value, m.ContentSequence, m.Document, m.DType)
+	return fmt.Sprintf("Content{UID: %v, Label: %v, Value: %v, Type: %v, IntValue: %v, TimeValue: %v, AssetAmount: %v, AssetSymbol: %v, AssetPrecision: %v, NameValue: %v, ContentSequence: %v, Document: %v, DType: %v}", m.UID, m.Label, m.Value, m.Type, m.IntValue, m.TimeValue, m.AssetAmount, m.AssetSymbol, m.AssetPrecision, m.NameValue, m.ContentSequence, m.Document, m.DType)
 }
 
 //ContentGroup domain object
@@ -62,6 +109,16 @@ func NewContentGroup(chainContentGroup []*ChainContent, sequence int) *ContentGr
 	}
 }
 
+//Label returns the value of the content_group_label content, empty if not present
+func (m *ContentGroup) Label() string {
+	for _, content := range m.Contents {
+		if content.Label == "content_group_label" {
+			return content.Value
+		}
+	}
+	return ""
+}
+
 //GetChecksumContents returns Contents with checksum type
 func (m *ContentGroup) GetChecksumContents() []*Content {
 	found := make([]*Content, 0)
@@ -108,9 +165,82 @@ type Document struct {
 	Hash          string          `json:"hash,omitempty"`
 	CreatedDate   *time.Time      `json:"created_date,omitempty"`
 	Creator       string          `json:"creator,omitempty"`
+	DocType       string          `json:"doc_type,omitempty"`
+	NodeLabel     string          `json:"node_label,omitempty"`
+	BallotID      string          `json:"ballot_id,omitempty"`
 	ContentGroups []*ContentGroup `json:"content_groups,omitempty"`
 	Certificates  []*Certificate  `json:"certificates,omitempty"`
 	DType         []string        `json:"dgraph.type,omitempty"`
+	//Edges outgoing neighbours by edge name, only populated when requested
+	Edges map[string][]*Document `json:"-"`
+	//InboundEdges incoming neighbours by edge name, only populated when requested
+	InboundEdges map[string][]*Document `json:"-"`
+	//EdgeCounts number of neighbours by edge alias, only populated for edge requests with count enabled
+	EdgeCounts map[string]int `json:"-"`
+}
+
+//documentJSONFields keys of the document json that are not edges
+var documentJSONFields = map[string]bool{
+	"uid":             true,
+	"hash":            true,
+	"created_date":    true,
+	"creator":         true,
+	"doc_type":        true,
+	"node_label":      true,
+	"ballot_id":       true,
+	"content_groups":  true,
+	"certificates":    true,
+	"dgraph.type":     true,
+	contentMatchesKey: true,
+}
+
+//UnmarshalJSON decodes the document, collecting the requested edges which are returned under their alias
+func (m *Document) UnmarshalJSON(data []byte) error {
+	type document Document
+	err := json.Unmarshal(data, (*document)(m))
+	if err != nil {
+		return err
+	}
+	fields := make(map[string]json.RawMessage)
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		return err
+	}
+	for alias, raw := range fields {
+		if documentJSONFields[alias] {
+			continue
+		}
+		key := decodeQueryAlias(alias)
+		if strings.HasPrefix(key, edgeCountPrefix) {
+			var count int
+			err = json.Unmarshal(raw, &count)
+			if err != nil {
+				return fmt.Errorf("failed to decode edge count: %v, error: %v", key, err)
+			}
+			if m.EdgeCounts == nil {
+				m.EdgeCounts = make(map[string]int)
+			}
+			m.EdgeCounts[strings.TrimPrefix(key, edgeCountPrefix)] = count
+			continue
+		}
+		var neighbours []*Document
+		err = json.Unmarshal(raw, &neighbours)
+		if err != nil {
+			return fmt.Errorf("failed to decode edge: %v, error: %v", key, err)
+		}
+		if strings.HasPrefix(key, inboundEdgePrefix) {
+			if m.InboundEdges == nil {
+				m.InboundEdges = make(map[string][]*Document)
+			}
+			m.InboundEdges[strings.TrimPrefix(key, inboundEdgePrefix)] = neighbours
+		} else {
+			if m.Edges == nil {
+				m.Edges = make(map[string][]*Document)
+			}
+			m.Edges[key] = neighbours
+		}
+	}
+	return nil
 }
 
 //NewDocument creates a new document from a ChainDocument
@@ -126,7 +256,7 @@ func NewDocument(chainDoc *ChainDocument) *Document {
 		certificates = append(certificates, NewCertificate(chainCertificate, i+1))
 	}
 
-	return &Document{
+	doc := &Document{
 		Hash:          chainDoc.Hash,
 		CreatedDate:   ToTime(chainDoc.CreatedDate),
 		Creator:       chainDoc.Creator,
@@ -134,6 +264,27 @@ func NewDocument(chainDoc *ChainDocument) *Document {
 		Certificates:  certificates,
 		DType:         []string{"Document"},
 	}
+	doc.PromoteSystemFields()
+	return doc
+}
+
+//PromoteSystemFields sets the document predicates for the fields of the system content group
+func (m *Document) PromoteSystemFields() {
+	for _, contentGroup := range m.ContentGroups {
+		if contentGroup.Label() != "system" {
+			continue
+		}
+		for _, content := range contentGroup.Contents {
+			switch content.Label {
+			case "type":
+				m.DocType = content.Value
+			case "node_label":
+				m.NodeLabel = content.Value
+			case "ballot_id":
+				m.BallotID = content.Value
+			}
+		}
+	}
 }
 
 //GetChecksumContents returns Contents with checksum type
@@ -153,7 +304,7 @@ func (m *Document) UpdateCertificates(chainCertificates []*ChainCertificate) {
 }
 
 func (m *Document) String() string {
-	return fmt.Sprintf("Document{UID: %v, Hash: %v, CreatedDate: %v, Creator: %v, ContentGroups: %v, Certificates: %v, DType: %v}", m.UID, m.Hash, m.CreatedDate, m.Creator, m.ContentGroups, m.Certificates, m.DType)
+	return fmt.Sprintf("Document{UID: %v, Hash: %v, CreatedDate: %v, Creator: %v, DocType: %v, NodeLabel: %v, BallotID: %v, ContentGroups: %v, Certificates: %v, DType: %v, Edges: %v, InboundEdges: %v}", m.UID, m.Hash, m.CreatedDate, m.Creator, m.DocType, m.NodeLabel, m.BallotID, m.ContentGroups, m.Certificates, m.DType, m.Edges, m.InboundEdges)
 }
 
 //ChainDocs helper to enable chain docs decoding
@@ -167,15 +318,12 @@ type ChainContent struct {
 	Value []interface{} `json:"value,omitempty"`
 }
 
-// func (m *ChainContent) UnmarshalJSON(b []byte) error {
-// 	if err := json.Unmarshal(b, m); err != nil {
-// 		return err
-// 	}
-// 	if fmt.Sprintf("%v", m.Value[0]) == "checksum256" {
-// 		m.Value[1] = strings.ToUpper(fmt.Sprintf("%v", m.Value[1]))
-// 	}
-// 	return nil
-// }
+//Normalize puts checksum values in canonical form
+func (m *ChainContent) Normalize() {
+	if len(m.Value) > 1 && fmt.Sprintf("%v", m.Value[0]) == "checksum256" {
+		m.Value[1] = NormalizeHash(fmt.Sprintf("%v", m.Value[1]))
+	}
+}
 
 func (m *ChainContent) String() string {
 	return fmt.Sprintf("ChainContent{Label: %v, Value: %v}", m.Label, m.Value)
@@ -202,13 +350,15 @@ type ChainDocument struct {
 	Certificates  []*ChainCertificate `json:"certificates,omitempty"`
 }
 
-// func (m *ChainDocument) UnmarshalJSON(b []byte) error {
-// 	if err := json.Unmarshal(b, m); err != nil {
-// 		return err
-// 	}
-// 	m.Hash = strings.ToUpper(m.Hash)
-// 	return nil
-// }
+//Normalize puts the document hash and checksum contents in canonical form
+func (m *ChainDocument) Normalize() {
+	m.Hash = NormalizeHash(m.Hash)
+	for _, contentGroup := range m.ContentGroups {
+		for _, content := range contentGroup {
+			content.Normalize()
+		}
+	}
+}
 
 func (m *ChainDocument) String() string {
 	return fmt.Sprintf("ChainDocument{ID: %v, Hash: %v, CreatedDate: %v, Creator: %v, Contents: %v, Certificates: %v}", m.ID, m.Hash, m.CreatedDate, m.Creator, m.ContentGroups, m.Certificates)
@@ -221,43 +371,178 @@ type ChainEdge struct {
 	To   string `json:"to_node,omitempty"`
 }
 
-// func (m *ChainEdge) UnmarshalJSON(b []byte) error {
-// 	if err := json.Unmarshal(b, m); err != nil {
-// 		return err
-// 	}
-// 	m.From = strings.ToUpper(m.From)
-// 	m.To = strings.ToUpper(m.To)
-// 	return nil
-// }
+//Normalize puts the node hashes in canonical form
+func (m *ChainEdge) Normalize() {
+	m.From = NormalizeHash(m.From)
+	m.To = NormalizeHash(m.To)
+}
 
 func (m *ChainEdge) String() string {
 	return fmt.Sprintf("ChainEdge{Name: %v, From: %v, To: %v}", m.Name, m.From, m.To)
 }
 
+//InvalidRow chain row that failed validation and was quarantined
+type InvalidRow struct {
+	UID              string   `json:"uid,omitempty"`
+	TableName        string   `json:"table_name,omitempty"`
+	Operation        string   `json:"operation,omitempty"`
+	RowData          string   `json:"row_data,omitempty"`
+	ValidationErrors string   `json:"validation_errors,omitempty"`
+	RowCursor        string   `json:"row_cursor,omitempty"`
+	BlockNum         uint64   `json:"block_num,omitempty"`
+	DType            []string `json:"dgraph.type,omitempty"`
+}
+
+func (m *InvalidRow) String() string {
+	return fmt.Sprintf("InvalidRow{UID: %v, TableName: %v, Operation: %v, RowData: %v, ValidationErrors: %v, RowCursor: %v, BlockNum: %v, DType: %v}", m.UID, m.TableName, m.Operation, m.RowData, m.ValidationErrors, m.RowCursor, m.BlockNum, m.DType)
+}
+
 //Cursors helper to enable cursor decoding
 type Cursors struct {
 	Cursors []*Cursor `json:"cursors,omitempty"`
 }
 
-//Cursor domain object
+//Cursor domain object, BlockNum is the block of the last change
 type Cursor struct {
-	UID    string   `json:"uid,omitempty"`
-	Cursor string   `json:"cursor,omitempty"`
-	DType  []string `json:"dgraph.type,omitempty"`
+	UID          string   `json:"uid,omitempty"`
+	Cursor       string   `json:"cursor,omitempty"`
+	BlockNum     uint64   `json:"block_num,omitempty"`
+	HistoryStart uint64   `json:"history_start,omitempty"`
+	DType        []string `json:"dgraph.type,omitempty"`
 }
 
 func (m *Cursor) String() string {
-	return fmt.Sprintf("Cursor{UID: %v, Cursor: %v, DType: %v}", m.UID, m.Cursor, m.DType)
+	return fmt.Sprintf("Cursor{UID: %v, Cursor: %v, BlockNum: %v, HistoryStart: %v, DType: %v}", m.UID, m.Cursor, m.BlockNum, m.HistoryStart, m.DType)
+}
+
+//EdgeDefinitions helper to enable edge definition decoding
+type EdgeDefinitions struct {
+	Edges []*EdgeDefinition `json:"edges,omitempty"`
+}
+
+//EdgeDefinition domain object, maps a chain edge name to the predicate that stores it
+type EdgeDefinition struct {
+	UID       string   `json:"uid,omitempty"`
+	Name      string   `json:"edge_name,omitempty"`
+	Predicate string   `json:"edge_predicate,omitempty"`
+	Reverse   bool     `json:"edge_reverse"`
+	Count     bool     `json:"edge_count"`
+	DType     []string `json:"dgraph.type,omitempty"`
+}
+
+//Options returns the schema options the edge predicate was created with
+func (m *EdgeDefinition) Options() *EdgeOptions {
+	return &EdgeOptions{
+		Reverse: m.Reverse,
+		Count:   m.Count,
+	}
+}
+
+func (m *EdgeDefinition) String() string {
+	return fmt.Sprintf("EdgeDefinition{UID: %v, Name: %v, Predicate: %v, Reverse: %v, Count: %v, DType: %v}", m.UID, m.Name, m.Predicate, m.Reverse, m.Count, m.DType)
+}
+
+//SchemaVersions helper to enable schema version decoding
+type SchemaVersions struct {
+	Versions []*SchemaVersion `json:"versions,omitempty"`
+}
+
+//SchemaVersion domain object, stores the version of the last applied migration
+type SchemaVersion struct {
+	UID     string   `json:"uid,omitempty"`
+	Version int      `json:"schema_version"`
+	DType   []string `json:"dgraph.type,omitempty"`
+}
+
+func (m *SchemaVersion) String() string {
+	return fmt.Sprintf("SchemaVersion{UID: %v, Version: %v, DType: %v}", m.UID, m.Version, m.DType)
+}
+
+//Asset domain object
+type Asset struct {
+	Amount    float64
+	Symbol    string
+	Precision int
+}
+
+func (m *Asset) String() string {
+	return fmt.Sprintf("Asset{Amount: %v, Symbol: %v, Precision: %v}", m.Amount, m.Symbol, m.Precision)
+}
+
+//ParseAsset Parses an asset of the form "190000.00 USD"
+func ParseAsset(strAsset string) (*Asset, error) {
+	parts := strings.Fields(strAsset)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid asset: %v, expected amount and symbol", strAsset)
+	}
+	amount, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid asset amount: %v, error: %v", strAsset, err)
+	}
+	precision := 0
+	if dot := strings.Index(parts[0], "."); dot >= 0 {
+		precision = len(parts[0]) - dot - 1
+	}
+	return &Asset{
+		Amount:    amount,
+		Symbol:    parts[1],
+		Precision: precision,
+	}, nil
+}
+
+//maxExactFloatInt largest magnitude up to which every integer can be represented exactly as a float64
+const maxExactFloatInt = 1 << 53
+
+//ToInt64 Converts a decoded chain value to int64, floats must be integers within ±2^53
+func ToInt64(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, fmt.Errorf("value: %v is not an integer", v)
+		}
+		if math.Abs(v) > maxExactFloatInt {
+			return 0, fmt.Errorf("value: %v is out of the range that can be represented exactly as a float, it may have lost precision", v)
+		}
+		return int64(v), nil
+	case json.Number:
+		return v.Int64()
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	}
+	return 0, fmt.Errorf("value: %v of type: %T can not be converted to int64", value, value)
+}
+
+//chainTimeLayouts accept any fractional second precision, with or without zone
+var chainTimeLayouts = []string{
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+}
+
+//ParseTime Parses a chain time string
+func ParseTime(strTime string) (*time.Time, error) {
+	var err error
+	for _, layout := range chainTimeLayouts {
+		var t time.Time
+		t, err = time.Parse(layout, strTime)
+		if err == nil {
+			t = t.UTC()
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to parse time: %v, error: %v", strTime, err)
 }
 
 //ToTime Converts string time to time.Time
 func ToTime(strTime string) *time.Time {
-	t, err := time.Parse("2006-01-02T15:04:05", strTime)
+	t, err := ParseTime(strTime)
 	if err != nil {
-		t, err = time.Parse("2006-01-02T15:04:05.000", strTime)
-		if err != nil {
-			log.Errorf(err, "Failed to parse datetime: %v", strTime)
-		}
+		log.Errorf(err, "Failed to parse datetime: %v", strTime)
+		return &time.Time{}
 	}
-	return &t
+	return t
 }
@@ -0,0 +1,234 @@
+package doccache
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+//edgeNameRegexp valid chain edge names, they become part of a predicate
+var edgeNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_.\-]+$`)
+
+//ErrorPolicy determines what happens with chain rows that fail validation
+type ErrorPolicy string
+
+const (
+	//ErrorPolicyFail stops processing
+	ErrorPolicyFail ErrorPolicy = "fail"
+	//ErrorPolicySkip logs the row and continues
+	ErrorPolicySkip ErrorPolicy = "skip"
+	//ErrorPolicyQuarantine stores the row as an InvalidRow node and continues
+	ErrorPolicyQuarantine ErrorPolicy = "quarantine"
+)
+
+//ParseErrorPolicy parses an error policy, defaults to fail
+func ParseErrorPolicy(policy string) (ErrorPolicy, error) {
+	switch ErrorPolicy(policy) {
+	case "", ErrorPolicyFail:
+		return ErrorPolicyFail, nil
+	case ErrorPolicySkip, ErrorPolicyQuarantine:
+		return ErrorPolicy(policy), nil
+	}
+	return "", fmt.Errorf("unknown error policy: %v, valid policies are: %v, %v, %v", policy, ErrorPolicyFail, ErrorPolicySkip, ErrorPolicyQuarantine)
+}
+
+//ValidationError a field of a chain row that is not valid
+type ValidationError struct {
+	Field  string `json:"field"`
+	Value  string `json:"value"`
+	Reason string `json:"reason"`
+}
+
+func (m *ValidationError) String() string {
+	return fmt.Sprintf("ValidationError{Field: %v, Value: %v, Reason: %v}", m.Field, m.Value, m.Reason)
+}
+
+//ValidationErrors all the validation errors found for a chain row
+type ValidationErrors []*ValidationError
+
+func (m ValidationErrors) Error() string {
+	reasons := make([]string, 0, len(m))
+	for _, err := range m {
+		reasons = append(reasons, fmt.Sprintf("%v: %v (value: %v)", err.Field, err.Reason, err.Value))
+	}
+	return fmt.Sprintf("validation failed: %v", strings.Join(reasons, "; "))
+}
+
+func (m *ValidationErrors) add(field string, value interface{}, reason string, v ...interface{}) {
+	*m = append(*m, &ValidationError{
+		Field:  field,
+		Value:  fmt.Sprintf("%v", value),
+		Reason: fmt.Sprintf(reason, v...),
+	})
+}
+
+func (m ValidationErrors) toError() error {
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
+
+//DecodeChainDocument decodes, normalizes and validates a document row
+func DecodeChainDocument(data []byte) (*ChainDocument, error) {
+	chainDoc := &ChainDocument{}
+	err := decodeChainRow(data, chainDoc)
+	if err != nil {
+		return nil, err
+	}
+	chainDoc.Normalize()
+	return chainDoc, chainDoc.Validate()
+}
+
+//DecodeChainEdge decodes, normalizes and validates an edge row
+func DecodeChainEdge(data []byte) (*ChainEdge, error) {
+	chainEdge := &ChainEdge{}
+	err := decodeChainRow(data, chainEdge)
+	if err != nil {
+		return nil, err
+	}
+	chainEdge.Normalize()
+	return chainEdge, chainEdge.Validate()
+}
+
+//DecodeChainCertificate decodes and validates a certificate
+func DecodeChainCertificate(data []byte) (*ChainCertificate, error) {
+	chainCertificate := &ChainCertificate{}
+	err := decodeChainRow(data, chainCertificate)
+	if err != nil {
+		return nil, err
+	}
+	return chainCertificate, chainCertificate.Validate()
+}
+
+//decodeChainRow keeps numbers as json.Number so int64 values do not lose precision
+func decodeChainRow(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err := decoder.Decode(v)
+	if err != nil {
+		return ValidationErrors{
+			{
+				Field:  "row",
+				Value:  string(data),
+				Reason: fmt.Sprintf("invalid json: %v", err),
+			},
+		}
+	}
+	return nil
+}
+
+//Validate validates the document, returns ValidationErrors if not valid
+func (m *ChainDocument) Validate() error {
+	errs := make(ValidationErrors, 0)
+	validateHash(&errs, "hash", m.Hash)
+	if _, err := ParseTime(m.CreatedDate); err != nil {
+		errs.add("created_date", m.CreatedDate, "invalid time: %v", err)
+	}
+	if m.Creator == "" {
+		errs.add("creator", m.Creator, "is required")
+	}
+	for i, contentGroup := range m.ContentGroups {
+		for j, content := range contentGroup {
+			if content == nil {
+				errs.add(fmt.Sprintf("content_groups[%v][%v]", i, j), nil, "is null")
+				continue
+			}
+			content.validate(&errs, fmt.Sprintf("content_groups[%v][%v]", i, j))
+		}
+	}
+	for i, certificate := range m.Certificates {
+		if certificate == nil {
+			errs.add(fmt.Sprintf("certificates[%v]", i), nil, "is null")
+			continue
+		}
+		certificate.validate(&errs, fmt.Sprintf("certificates[%v]", i))
+	}
+	return errs.toError()
+}
+
+//Validate validates the content, returns ValidationErrors if not valid
+func (m *ChainContent) Validate() error {
+	errs := make(ValidationErrors, 0)
+	m.validate(&errs, "")
+	return errs.toError()
+}
+
+func (m *ChainContent) validate(errs *ValidationErrors, path string) {
+	if m.Label == "" {
+		errs.add(fieldPath(path, "label"), m.Label, "is required")
+	}
+	if len(m.Value) != 2 {
+		errs.add(fieldPath(path, "value"), m.Value, "expected [type, value] pair, found %v elements", len(m.Value))
+		return
+	}
+	valueType, ok := m.Value[0].(string)
+	if !ok || valueType == "" {
+		errs.add(fieldPath(path, "value[0]"), m.Value[0], "type must be a non empty string")
+		return
+	}
+	value := fmt.Sprintf("%v", m.Value[1])
+	switch valueType {
+	case "int64":
+		if _, err := ToInt64(m.Value[1]); err != nil {
+			errs.add(fieldPath(path, "value[1]"), value, "invalid int64: %v", err)
+		}
+	case "time_point":
+		if _, err := ParseTime(value); err != nil {
+			errs.add(fieldPath(path, "value[1]"), value, "invalid time_point: %v", err)
+		}
+	case "asset":
+		if _, err := ParseAsset(value); err != nil {
+			errs.add(fieldPath(path, "value[1]"), value, "invalid asset: %v", err)
+		}
+	case "checksum256":
+		validateHash(errs, fieldPath(path, "value[1]"), value)
+	}
+}
+
+//Validate validates the certificate, returns ValidationErrors if not valid
+func (m *ChainCertificate) Validate() error {
+	errs := make(ValidationErrors, 0)
+	m.validate(&errs, "")
+	return errs.toError()
+}
+
+func (m *ChainCertificate) validate(errs *ValidationErrors, path string) {
+	if m.Certifier == "" {
+		errs.add(fieldPath(path, "certifier"), m.Certifier, "is required")
+	}
+	if _, err := ParseTime(m.CertificationDate); err != nil {
+		errs.add(fieldPath(path, "certification_date"), m.CertificationDate, "invalid time: %v", err)
+	}
+}
+
+//Validate validates the edge, returns ValidationErrors if not valid
+func (m *ChainEdge) Validate() error {
+	errs := make(ValidationErrors, 0)
+	if !edgeNameRegexp.MatchString(m.Name) {
+		errs.add("edge_name", m.Name, "must be non empty and contain only letters, digits, '_', '.' or '-'")
+	}
+	validateHash(&errs, "from_node", m.From)
+	validateHash(&errs, "to_node", m.To)
+	return errs.toError()
+}
+
+func fieldPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+func validateHash(errs *ValidationErrors, field, hash string) {
+	if len(hash) != 64 {
+		errs.add(field, hash, "expected 64 hex characters, found %v", len(hash))
+		return
+	}
+	if _, err := hex.DecodeString(hash); err != nil {
+		errs.add(field, hash, "invalid hex: %v", err)
+	}
+}
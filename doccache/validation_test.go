@@ -0,0 +1,110 @@
+package doccache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	expected := time.Date(2021, 1, 15, 19, 17, 44, 500000000, time.UTC)
+	for _, strTime := range []string{
+		"2021-01-15T19:17:44.5",
+		"2021-01-15T19:17:44.500",
+		"2021-01-15T19:17:44.500000",
+		"2021-01-15T19:17:44.5Z",
+		"2021-01-15 19:17:44.5",
+	} {
+		parsed, err := ParseTime(strTime)
+		if err != nil {
+			t.Fatalf("ParseTime failed for: %v, error: %v", strTime, err)
+		}
+		if !parsed.Equal(expected) {
+			t.Fatalf("Expected: %v, found: %v, for: %v", expected, parsed, strTime)
+		}
+	}
+	_, err := ParseTime("15/01/2021")
+	if err == nil {
+		t.Fatal("Expected ParseTime to fail for invalid layout")
+	}
+}
+
+func TestDecodeChainDocument(t *testing.T) {
+	chainDocJSON := `{"certificates":[],"content_groups":[[{"label":"content_group_label","value":["string","details"]},{"label":"annual_usd_salary","value":["asset","190000.00 USD"]},{"label":"fulltime_capacity_x100","value":["int64",100]}],[{"label":"content_group_label","value":["string","system"]},{"label":"ballot_id","value":["name","hypha1....1fh"]},{"label":"type","value":["name","role"]}]],"contract":"dao.hypha","created_date":"2021-01-15T19:17:44.5","creator":"mem2.hypha","hash":"EA5E92E5D1456DE0B0859960AAB7211B39C6695266ED86B33B15A5C461CB92DD","id":5071}`
+	chainDoc, err := DecodeChainDocument([]byte(chainDocJSON))
+	if err != nil {
+		t.Fatalf("DecodeChainDocument failed: %v", err)
+	}
+	if chainDoc.Hash != "ea5e92e5d1456de0b0859960aab7211b39c6695266ed86b33b15a5c461cb92dd" {
+		t.Fatalf("Expected hash to be normalized, found: %v", chainDoc.Hash)
+	}
+
+	invalidDocJSON := `{"certificates":[{"certifier":"","notes":"","certification_date":"yesterday"}],"content_groups":[[{"label":"title","value":["string"]},{"label":"amount","value":["int64",1.5]},{"label":"parent","value":["checksum256","abc"]}]],"created_date":"2021-01-15","creator":"","hash":"xyz"}`
+	_, err = DecodeChainDocument([]byte(invalidDocJSON))
+	if err == nil {
+		t.Fatal("Expected DecodeChainDocument to fail")
+	}
+	validationErrors, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("Expected ValidationErrors, found: %T", err)
+	}
+	expectedFields := []string{
+		"hash",
+		"created_date",
+		"creator",
+		"content_groups[0][0].value",
+		"content_groups[0][1].value[1]",
+		"content_groups[0][2].value[1]",
+		"certificates[0].certifier",
+		"certificates[0].certification_date",
+	}
+	if len(validationErrors) != len(expectedFields) {
+		t.Fatalf("Expected %v validation errors, found: %v", len(expectedFields), validationErrors)
+	}
+	for i, field := range expectedFields {
+		if validationErrors[i].Field != field {
+			t.Fatalf("Expected validation error for field: %v, found: %v", field, validationErrors[i])
+		}
+	}
+}
+
+func TestDecodeChainEdge(t *testing.T) {
+	chainEdgeJSON := `{"contract":"dao.hypha","created_date":"2021-01-11T21:52:32","creator":"dao.hypha","edge_name":"settings","from_node":"52a7ff82bd6f53b31285e97d6806d886eefb650e79754784e9d923d3df347c91","id":2475211255,"to_node":"3e06f9f93fb27ad04a2e97dfce9796c2d51b73721d6270e1c0ea6bf7e79c944b"}`
+	_, err := DecodeChainEdge([]byte(chainEdgeJSON))
+	if err != nil {
+		t.Fatalf("DecodeChainEdge failed: %v", err)
+	}
+	_, err = DecodeChainEdge([]byte(`{"edge_name":"","from_node":"52a7","to_node":"3e06f9f93fb27ad04a2e97dfce9796c2d51b73721d6270e1c0ea6bf7e79c944b"}`))
+	validationErrors, ok := err.(ValidationErrors)
+	if !ok || len(validationErrors) != 2 {
+		t.Fatalf("Expected 2 validation errors, found: %v", err)
+	}
+	_, err = DecodeChainEdge([]byte(`{"edge_name":`))
+	if _, ok := err.(ValidationErrors); !ok {
+		t.Fatalf("Expected invalid json to be reported as ValidationErrors, found: %v", err)
+	}
+}
+
+func TestQuarantineRow(t *testing.T) {
+	cursor := "quarantine1"
+	err := doccache.QuarantineRow(&InvalidRow{
+		TableName:        "documents",
+		Operation:        "OPERATION_INSERT",
+		RowData:          `{"hash":"xyz"}`,
+		ValidationErrors: "validation failed: hash: expected 64 hex characters, found 3 (value: xyz)",
+		BlockNum:         136860100,
+	}, cursor)
+	if err != nil {
+		t.Fatalf("QuarantineRow failed: %v", err)
+	}
+	validateCursor(cursor, t)
+	result := &struct {
+		Rows []*InvalidRow `json:"rows,omitempty"`
+	}{}
+	err = dg.Query(`{rows(func: type(InvalidRow)) @filter(eq(block_num, 136860100)){uid table_name row_data}}`, nil, result)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(result.Rows) != 1 || result.Rows[0].RowData != `{"hash":"xyz"}` {
+		t.Fatalf("Expected quarantined row to be stored, found: %v", result.Rows)
+	}
+}
@@ -0,0 +1,459 @@
+package doccache
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/dgo/v2/protos/api"
+)
+
+//WebhookRefreshInterval maximum time webhooks registered by other processes take to receive deliveries
+const WebhookRefreshInterval = 30 * time.Second
+
+//Delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+//webhookSchema webhooks and their delivery queue, deliveries are stored in the same transaction
+//as the change that caused them so that none are lost if the process stops
+const webhookSchema = `
+      type Webhook {
+        webhook_name
+        webhook_url
+        webhook_secret
+        webhook_filter
+      }
+
+      type WebhookDelivery {
+        delivery_webhook
+        delivery_event_type
+        delivery_payload
+        delivery_status
+        delivery_attempts
+        delivery_next_attempt
+        delivery_last_error
+        delivery_last_status
+        delivery_created_at
+        delivery_delivered_at
+      }
+
+      webhook_name: string @index(exact) @upsert .
+      webhook_url: string .
+      webhook_secret: string .
+      webhook_filter: string .
+
+      delivery_webhook: uid @reverse .
+      delivery_event_type: string @index(exact) .
+      delivery_payload: string .
+      delivery_status: string @index(exact) .
+      delivery_attempts: int .
+      delivery_next_attempt: datetime @index(hour) .
+      delivery_last_error: string .
+      delivery_last_status: int .
+      delivery_created_at: datetime @index(hour) .
+      delivery_delivered_at: datetime .
+    `
+
+//Webhook HTTP endpoint that receives the events that match its filter, Filter is the JSON encoded EventFilter
+type Webhook struct {
+	UID    string   `json:"uid,omitempty"`
+	Name   string   `json:"webhook_name,omitempty"`
+	URL    string   `json:"webhook_url,omitempty"`
+	Secret string   `json:"webhook_secret,omitempty"`
+	Filter string   `json:"webhook_filter,omitempty"`
+	DType  []string `json:"dgraph.type,omitempty"`
+	filter *EventFilter
+}
+
+func (m *Webhook) String() string {
+	return fmt.Sprintf("Webhook{UID: %v, Name: %v, URL: %v, Filter: %v}", m.UID, m.Name, m.URL, m.Filter)
+}
+
+//EventFilter decodes the filter of the webhook
+func (m *Webhook) EventFilter() (*EventFilter, error) {
+	if m.filter == nil {
+		m.filter = &EventFilter{}
+		if m.Filter != "" {
+			err := json.Unmarshal([]byte(m.Filter), m.filter)
+			if err != nil {
+				return nil, fmt.Errorf("invalid filter of webhook: %v, error: %v", m.Name, err)
+			}
+		}
+	}
+	return m.filter, nil
+}
+
+//WebhookDelivery delivery of an event to a webhook, Payload is the JSON encoded event
+type WebhookDelivery struct {
+	UID         string     `json:"uid,omitempty"`
+	Webhook     *Webhook   `json:"delivery_webhook,omitempty"`
+	EventType   EventType  `json:"delivery_event_type,omitempty"`
+	Payload     string     `json:"delivery_payload,omitempty"`
+	Status      string     `json:"delivery_status,omitempty"`
+	Attempts    int        `json:"delivery_attempts"`
+	NextAttempt *time.Time `json:"delivery_next_attempt,omitempty"`
+	LastError   string     `json:"delivery_last_error,omitempty"`
+	LastStatus  int        `json:"delivery_last_status,omitempty"`
+	CreatedAt   *time.Time `json:"delivery_created_at,omitempty"`
+	DeliveredAt *time.Time `json:"delivery_delivered_at,omitempty"`
+	DType       []string   `json:"dgraph.type,omitempty"`
+}
+
+func (m *WebhookDelivery) String() string {
+	return fmt.Sprintf("WebhookDelivery{UID: %v, Webhook: %v, EventType: %v, Status: %v, Attempts: %v, NextAttempt: %v, LastError: %v, LastStatus: %v}", m.UID, m.Webhook, m.EventType, m.Status, m.Attempts, m.NextAttempt, m.LastError, m.LastStatus)
+}
+
+//WebhookStatus webhook with the number of deliveries by status
+type WebhookStatus struct {
+	Name      string `json:"name"`
+	URL       string `json:"url"`
+	Filter    string `json:"filter"`
+	Pending   int    `json:"pending"`
+	Delivered int    `json:"delivered"`
+	Failed    int    `json:"failed"`
+}
+
+const webhookRequest = `
+	uid
+	webhook_name
+	webhook_url
+	webhook_secret
+	webhook_filter
+	dgraph.type
+`
+
+const deliveryRequest = `
+	uid
+	delivery_event_type
+	delivery_payload
+	delivery_status
+	delivery_attempts
+	delivery_next_attempt
+	delivery_last_error
+	delivery_last_status
+	delivery_created_at
+	delivery_delivered_at
+	dgraph.type
+`
+
+//SaveWebhook registers the webhook or updates the one with the same name
+func (m *Doccache) SaveWebhook(name, url, secret string, filter *EventFilter) (*Webhook, error) {
+	if name == "" || url == "" {
+		return nil, invalidRequest("webhook name and url are required")
+	}
+	if filter == nil {
+		filter = &EventFilter{}
+	}
+	filterJSON, err := json.Marshal(filter)
+	if err != nil {
+		return nil, err
+	}
+	webhook, err := m.GetWebhook(name)
+	if err != nil {
+		return nil, err
+	}
+	if webhook == nil {
+		webhook = &Webhook{
+			Name:  name,
+			DType: []string{"Webhook"},
+		}
+	}
+	webhook.URL = url
+	webhook.Secret = secret
+	webhook.Filter = string(filterJSON)
+	webhook.filter = nil
+	response, err := m.dgraph.MutateJSON(webhook, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, uid := range response.Uids {
+		webhook.UID = uid
+	}
+	m.invalidateWebhooks()
+	return webhook, nil
+}
+
+//RemoveWebhook deletes the webhook and its deliveries
+func (m *Doccache) RemoveWebhook(name string) error {
+	query := `
+		query webhooks($name: string){
+			webhooks(func: eq(webhook_name, $name)){
+				uid
+				deliveries: ~delivery_webhook {
+					uid
+				}
+			}
+		}
+	`
+	response := &struct {
+		Webhooks []struct {
+			UID        string `json:"uid"`
+			Deliveries []struct {
+				UID string `json:"uid"`
+			} `json:"deliveries"`
+		} `json:"webhooks"`
+	}{}
+	err := m.dgraph.Query(query, map[string]string{"$name": name}, response)
+	if err != nil {
+		return err
+	}
+	if len(response.Webhooks) == 0 {
+		return invalidRequest("webhook: %v does not exist", name)
+	}
+	mutations := make([]*api.Mutation, 0)
+	for _, webhook := range response.Webhooks {
+		mutations = append(mutations, m.dgraph.DeleteNodeMutation(webhook.UID))
+		for _, delivery := range webhook.Deliveries {
+			mutations = append(mutations, m.dgraph.DeleteNodeMutation(delivery.UID))
+		}
+	}
+	_, err = m.dgraph.Mutate(mutations...)
+	if err != nil {
+		return err
+	}
+	m.invalidateWebhooks()
+	return nil
+}
+
+//GetWebhook finds a webhook by name, returns nil if it does not exist
+func (m *Doccache) GetWebhook(name string) (*Webhook, error) {
+	query := fmt.Sprintf(`
+		query webhooks($name: string){
+			webhooks(func: eq(webhook_name, $name)){
+				%v
+			}
+		}
+	`, webhookRequest)
+	response := &struct {
+		Webhooks []*Webhook `json:"webhooks"`
+	}{}
+	err := m.dgraph.Query(query, map[string]string{"$name": name}, response)
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Webhooks) == 0 {
+		return nil, nil
+	}
+	return response.Webhooks[0], nil
+}
+
+//GetWebhooks returns all the registered webhooks
+func (m *Doccache) GetWebhooks() ([]*Webhook, error) {
+	query := fmt.Sprintf(`
+		{
+			webhooks(func: type(Webhook), orderasc: webhook_name){
+				%v
+			}
+		}
+	`, webhookRequest)
+	response := &struct {
+		Webhooks []*Webhook `json:"webhooks"`
+	}{}
+	err := m.dgraph.Query(query, nil, response)
+	if err != nil {
+		return nil, err
+	}
+	return response.Webhooks, nil
+}
+
+//GetWebhookStatuses returns the webhooks with the number of deliveries by status
+func (m *Doccache) GetWebhookStatuses() ([]*WebhookStatus, error) {
+	query := fmt.Sprintf(`
+		{
+			webhooks(func: type(Webhook), orderasc: webhook_name){
+				name: webhook_name
+				url: webhook_url
+				filter: webhook_filter
+				pending: count(~delivery_webhook @filter(eq(delivery_status, %q)))
+				delivered: count(~delivery_webhook @filter(eq(delivery_status, %q)))
+				failed: count(~delivery_webhook @filter(eq(delivery_status, %q)))
+			}
+		}
+	`, DeliveryPending, DeliveryDelivered, DeliveryFailed)
+	response := &struct {
+		Webhooks []*WebhookStatus `json:"webhooks"`
+	}{}
+	err := m.dgraph.Query(query, nil, response)
+	if err != nil {
+		return nil, err
+	}
+	if response.Webhooks == nil {
+		response.Webhooks = make([]*WebhookStatus, 0)
+	}
+	return response.Webhooks, nil
+}
+
+//GetDeliveries returns the most recent deliveries of the webhook, optionally only those with the status
+func (m *Doccache) GetDeliveries(webhookName, status string, first int) ([]*WebhookDelivery, error) {
+	if first <= 0 {
+		first = DefaultSearchPageSize
+	}
+	if first > MaxSearchPageSize {
+		return nil, invalidRequest("page size: %v exceeds the maximum: %v", first, MaxSearchPageSize)
+	}
+	vars := map[string]string{"$name": webhookName}
+	declarations := "$name: string"
+	filter := ""
+	if status != "" {
+		vars["$status"] = status
+		declarations += ", $status: string"
+		filter = "@filter(eq(delivery_status, $status))"
+	}
+	query := fmt.Sprintf(`
+		query deliveries(%v){
+			webhooks(func: eq(webhook_name, $name)){
+				deliveries: ~delivery_webhook (orderdesc: delivery_created_at, first: %v) %v {
+					%v
+				}
+			}
+		}
+	`, declarations, first, filter, deliveryRequest)
+	response := &struct {
+		Webhooks []struct {
+			Deliveries []*WebhookDelivery `json:"deliveries"`
+		} `json:"webhooks"`
+	}{}
+	err := m.dgraph.Query(query, vars, response)
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Webhooks) == 0 {
+		return nil, invalidRequest("webhook: %v does not exist", webhookName)
+	}
+	deliveries := response.Webhooks[0].Deliveries
+	if deliveries == nil {
+		deliveries = make([]*WebhookDelivery, 0)
+	}
+	return deliveries, nil
+}
+
+//DueDeliveries returns the pending deliveries whose next attempt is due, ordered by next attempt
+func (m *Doccache) DueDeliveries(now time.Time, limit int) ([]*WebhookDelivery, error) {
+	query := fmt.Sprintf(`
+		query deliveries($now: string){
+			deliveries(func: eq(delivery_status, %q), orderasc: delivery_next_attempt, first: %v) @filter(le(delivery_next_attempt, $now)) {
+				%v
+				delivery_webhook {
+					%v
+				}
+			}
+		}
+	`, DeliveryPending, limit, deliveryRequest, webhookRequest)
+	response := &struct {
+		Deliveries []*WebhookDelivery `json:"deliveries"`
+	}{}
+	err := m.dgraph.Query(query, map[string]string{"$now": now.UTC().Format(time.RFC3339Nano)}, response)
+	if err != nil {
+		return nil, err
+	}
+	return response.Deliveries, nil
+}
+
+//UpdateDelivery stores the status of the delivery after an attempt
+func (m *Doccache) UpdateDelivery(delivery *WebhookDelivery) error {
+	update := &WebhookDelivery{
+		UID:         delivery.UID,
+		Status:      delivery.Status,
+		Attempts:    delivery.Attempts,
+		NextAttempt: delivery.NextAttempt,
+		LastError:   delivery.LastError,
+		LastStatus:  delivery.LastStatus,
+		DeliveredAt: delivery.DeliveredAt,
+	}
+	mutation, err := m.dgraph.JSONMutation(update, false)
+	if err != nil {
+		return err
+	}
+	mutations := []*api.Mutation{mutation}
+	if delivery.LastError == "" {
+		//LastError is omitted when empty, so the error of a previous attempt has to be deleted explicitly
+		mutations = append(mutations, m.dgraph.DeleteNQuadsMutation(fmt.Sprintf("<%v> <delivery_last_error> * .", delivery.UID)))
+	}
+	_, err = m.dgraph.Mutate(mutations...)
+	return err
+}
+
+//RetryFailedDeliveries queues the failed deliveries of the webhook again, returns the number of deliveries queued
+func (m *Doccache) RetryFailedDeliveries(webhookName string) (int, error) {
+	deliveries, err := m.GetDeliveries(webhookName, DeliveryFailed, MaxSearchPageSize)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now().UTC()
+	for _, delivery := range deliveries {
+		delivery.Status = DeliveryPending
+		delivery.Attempts = 0
+		delivery.NextAttempt = &now
+		err = m.UpdateDelivery(delivery)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(deliveries), nil
+}
+
+func (m *Doccache) invalidateWebhooks() {
+	m.webhooksLock.Lock()
+	defer m.webhooksLock.Unlock()
+	m.webhooks = nil
+}
+
+//loadedWebhooks returns the registered webhooks, reloading them if they are older than WebhookRefreshInterval
+func (m *Doccache) loadedWebhooks() ([]*Webhook, error) {
+	m.webhooksLock.Lock()
+	defer m.webhooksLock.Unlock()
+	if m.webhooks != nil && time.Since(m.webhooksLoaded) < WebhookRefreshInterval {
+		return m.webhooks, nil
+	}
+	webhooks, err := m.GetWebhooks()
+	if err != nil {
+		return nil, err
+	}
+	if webhooks == nil {
+		webhooks = make([]*Webhook, 0)
+	}
+	m.webhooks = webhooks
+	m.webhooksLoaded = time.Now()
+	return m.webhooks, nil
+}
+
+//deliveriesMutation queues a delivery of the event for each webhook whose filter matches it,
+//returns nil if there are no matching webhooks
+func (m *Doccache) deliveriesMutation(event *Event) (*api.Mutation, error) {
+	webhooks, err := m.loadedWebhooks()
+	if err != nil {
+		return nil, err
+	}
+	deliveries := make([]*WebhookDelivery, 0)
+	for _, webhook := range webhooks {
+		filter, err := webhook.EventFilter()
+		if err != nil {
+			log.Errorf(err, "Skipping webhook: %v", webhook.Name)
+			continue
+		}
+		if !filter.Matches(event) {
+			continue
+		}
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, &WebhookDelivery{
+			Webhook:     &Webhook{UID: webhook.UID},
+			EventType:   event.Type,
+			Payload:     string(payload),
+			Status:      DeliveryPending,
+			NextAttempt: &event.Time,
+			CreatedAt:   &event.Time,
+			DType:       []string{"WebhookDelivery"},
+		})
+	}
+	if len(deliveries) == 0 {
+		return nil, nil
+	}
+	return m.dgraph.JSONMutation(deliveries, false)
+}
@@ -7,9 +7,15 @@ require (
 	github.com/dfuse-io/dfuse-eosio v0.1.1-docker.0.20210106190033-47b917933e19
 	github.com/dfuse-io/pbgo v0.0.6-0.20210108215028-712d6889e94a
 	github.com/dgraph-io/dgo/v2 v2.2.0
+	github.com/golang/protobuf v1.4.2
+	github.com/gorilla/websocket v1.4.2
+	github.com/graphql-go/graphql v0.8.1
+	github.com/mattn/go-sqlite3 v1.14.6
 	github.com/prometheus/client_golang v1.2.1
 	github.com/rs/zerolog v1.20.0
 	github.com/sebastianmontero/dfuse-firehose-client v0.0.0-20210326205105-b2a7b2ba2c5c
 	github.com/sebastianmontero/dgraph-go-client v0.0.0-20210213215931-344d1e456654
 	github.com/sebastianmontero/slog-go v0.0.0-20210213204103-60eda76e8d74
+	google.golang.org/grpc v1.35.0
+	google.golang.org/protobuf v1.25.0
 )
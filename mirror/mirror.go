@@ -0,0 +1,320 @@
+package mirror
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	//registers the sqlite3 driver
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/sebastianmontero/hypha-document-cache-go/doccache"
+	"github.com/sebastianmontero/slog-go/slog"
+)
+
+var log = slog.New(nil, "mirror")
+
+//TimeLayout layout of the date columns, understood by the SQLite date and time functions
+const TimeLayout = "2006-01-02 15:04:05.000"
+
+//schema tables of the mirror, contents have a typed column per content type so that
+//numeric and date comparisons can be done in SQL, e.g. WHERE int_value > 5 or date(time_value) < '2021-01-01'
+const schema = `
+	CREATE TABLE IF NOT EXISTS documents (
+		hash TEXT PRIMARY KEY,
+		creator TEXT NOT NULL,
+		created_date TEXT,
+		doc_type TEXT,
+		node_label TEXT,
+		ballot_id TEXT
+	);
+	CREATE INDEX IF NOT EXISTS documents_doc_type ON documents (doc_type);
+	CREATE INDEX IF NOT EXISTS documents_creator ON documents (creator);
+
+	CREATE TABLE IF NOT EXISTS content_groups (
+		document_hash TEXT NOT NULL,
+		content_group_sequence INTEGER NOT NULL,
+		label TEXT,
+		PRIMARY KEY (document_hash, content_group_sequence)
+	);
+
+	CREATE TABLE IF NOT EXISTS contents (
+		document_hash TEXT NOT NULL,
+		content_group_sequence INTEGER NOT NULL,
+		content_sequence INTEGER NOT NULL,
+		label TEXT NOT NULL,
+		type TEXT,
+		value TEXT,
+		int_value INTEGER,
+		time_value TEXT,
+		asset_amount REAL,
+		asset_symbol TEXT,
+		asset_precision INTEGER,
+		name_value TEXT,
+		PRIMARY KEY (document_hash, content_group_sequence, content_sequence)
+	);
+	CREATE INDEX IF NOT EXISTS contents_label ON contents (label);
+	CREATE INDEX IF NOT EXISTS contents_int_value ON contents (label, int_value);
+	CREATE INDEX IF NOT EXISTS contents_time_value ON contents (label, time_value);
+	CREATE INDEX IF NOT EXISTS contents_asset_amount ON contents (label, asset_symbol, asset_amount);
+
+	CREATE TABLE IF NOT EXISTS certificates (
+		document_hash TEXT NOT NULL,
+		certification_sequence INTEGER NOT NULL,
+		certifier TEXT,
+		notes TEXT,
+		certification_date TEXT,
+		PRIMARY KEY (document_hash, certification_sequence)
+	);
+
+	CREATE TABLE IF NOT EXISTS edges (
+		name TEXT NOT NULL,
+		from_hash TEXT NOT NULL,
+		to_hash TEXT NOT NULL,
+		PRIMARY KEY (name, from_hash, to_hash)
+	);
+	CREATE INDEX IF NOT EXISTS edges_to_hash ON edges (to_hash, name);
+
+	CREATE TABLE IF NOT EXISTS cursor (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		cursor TEXT NOT NULL
+	);
+`
+
+//tables in the order they are cleared on rebuild
+var tables = []string{"edges", "certificates", "contents", "content_groups", "documents", "cursor"}
+
+//Mirror relational copy of the document graph in an embedded SQLite database, it is updated after each
+//doccache mutation and keeps its own cursor, when it does not match the doccache cursor the mirror is rebuilt
+type Mirror struct {
+	db *sql.DB
+}
+
+//New opens or creates the SQLite database at path and creates the tables
+func New(path string, logConfig *slog.Config) (*Mirror, error) {
+	log = slog.New(logConfig, "mirror")
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%v?_journal_mode=WAL&_busy_timeout=5000", path))
+	if err != nil {
+		return nil, err
+	}
+	//a single connection serializes the writes, analysts read the database from other processes
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec(schema)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Mirror{db: db}, nil
+}
+
+//DB returns the underlying database
+func (m *Mirror) DB() *sql.DB {
+	return m.db
+}
+
+//Close closes the database
+func (m *Mirror) Close() error {
+	return m.db.Close()
+}
+
+//Cursor returns the cursor of the last change applied to the mirror, empty if none has been applied
+func (m *Mirror) Cursor() (string, error) {
+	var cursor string
+	err := m.db.QueryRow("SELECT cursor FROM cursor WHERE id = 1").Scan(&cursor)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return cursor, err
+}
+
+//Sync rebuilds the mirror if its cursor does not match the doccache cursor, which happens when the mirror
+//is new or a change was committed to the doccache but not to the mirror
+func (m *Mirror) Sync(cache *doccache.Doccache) error {
+	cursor, err := m.Cursor()
+	if err != nil {
+		return err
+	}
+	stored, err := cache.StoredCursor()
+	if err != nil {
+		return err
+	}
+	if cursor == stored.Cursor {
+		log.Infof("Mirror is in sync at cursor: %v", cursor)
+		return nil
+	}
+	log.Infof("Mirror cursor: %v does not match doccache cursor: %v, rebuilding", cursor, stored.Cursor)
+	return m.Rebuild(cache)
+}
+
+//Rebuild replaces the contents of the mirror with the documents and edges in the doccache, the doccache
+//should not be mutated while rebuilding
+func (m *Mirror) Rebuild(cache *doccache.Doccache) error {
+	cursor, err := cache.StoredCursor()
+	if err != nil {
+		return err
+	}
+	return m.transact(cursor.Cursor, func(tx *sql.Tx) error {
+		for _, table := range tables {
+			_, err := tx.Exec("DELETE FROM " + table)
+			if err != nil {
+				return err
+			}
+		}
+		documents, edges := 0, 0
+		edgeNames := cache.EdgeNames()
+		err := cache.ScanDocuments(func(doc *doccache.Document) error {
+			err := insertDocument(tx, doc)
+			if err != nil {
+				return err
+			}
+			documents++
+			for _, edgeName := range edgeNames {
+				for _, to := range doc.Edges[edgeName] {
+					err = insertEdge(tx, &doccache.ChainEdge{Name: edgeName, From: doc.Hash, To: to.Hash})
+					if err != nil {
+						return err
+					}
+					edges++
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		log.Infof("Rebuilt mirror with %v documents and %v edges at cursor: %v", documents, edges, cursor.Cursor)
+		return nil
+	})
+}
+
+//StoreDocument inserts the document or replaces it, which updates its certificates
+func (m *Mirror) StoreDocument(chainDoc *doccache.ChainDocument, cursor string) error {
+	chainDoc.Normalize()
+	doc := doccache.NewDocument(chainDoc)
+	return m.transact(cursor, func(tx *sql.Tx) error {
+		err := deleteDocumentRows(tx, doc.Hash)
+		if err != nil {
+			return err
+		}
+		return insertDocument(tx, doc)
+	})
+}
+
+//DeleteDocument deletes the document together with the edges from and to it
+func (m *Mirror) DeleteDocument(chainDoc *doccache.ChainDocument, cursor string) error {
+	chainDoc.Normalize()
+	return m.transact(cursor, func(tx *sql.Tx) error {
+		err := deleteDocumentRows(tx, chainDoc.Hash)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("DELETE FROM edges WHERE from_hash = ? OR to_hash = ?", chainDoc.Hash, chainDoc.Hash)
+		return err
+	})
+}
+
+//MutateEdge creates or deletes an edge
+func (m *Mirror) MutateEdge(chainEdge *doccache.ChainEdge, deleteOp bool, cursor string) error {
+	chainEdge.Normalize()
+	return m.transact(cursor, func(tx *sql.Tx) error {
+		if deleteOp {
+			_, err := tx.Exec("DELETE FROM edges WHERE name = ? AND from_hash = ? AND to_hash = ?", chainEdge.Name, chainEdge.From, chainEdge.To)
+			return err
+		}
+		return insertEdge(tx, chainEdge)
+	})
+}
+
+//UpdateCursor stores the cursor of a change that does not modify the mirror, e.g. a heart beat
+func (m *Mirror) UpdateCursor(cursor string) error {
+	return m.transact(cursor, func(tx *sql.Tx) error {
+		return nil
+	})
+}
+
+//transact runs fn and stores the cursor in the same transaction
+func (m *Mirror) transact(cursor string, fn func(tx *sql.Tx) error) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	err = fn(tx)
+	if err == nil {
+		_, err = tx.Exec("INSERT OR REPLACE INTO cursor (id, cursor) VALUES (1, ?)", cursor)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func deleteDocumentRows(tx *sql.Tx, hash string) error {
+	for _, table := range []string{"certificates", "contents", "content_groups"} {
+		_, err := tx.Exec("DELETE FROM "+table+" WHERE document_hash = ?", hash)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := tx.Exec("DELETE FROM documents WHERE hash = ?", hash)
+	return err
+}
+
+func insertDocument(tx *sql.Tx, doc *doccache.Document) error {
+	_, err := tx.Exec(
+		"INSERT INTO documents (hash, creator, created_date, doc_type, node_label, ballot_id) VALUES (?, ?, ?, ?, ?, ?)",
+		doc.Hash, doc.Creator, formatTime(doc.CreatedDate), nullString(doc.DocType), nullString(doc.NodeLabel), nullString(doc.BallotID),
+	)
+	if err != nil {
+		return err
+	}
+	for _, contentGroup := range doc.ContentGroups {
+		_, err = tx.Exec(
+			"INSERT INTO content_groups (document_hash, content_group_sequence, label) VALUES (?, ?, ?)",
+			doc.Hash, contentGroup.ContentGroupSequence, nullString(contentGroup.Label()),
+		)
+		if err != nil {
+			return err
+		}
+		for _, content := range contentGroup.Contents {
+			_, err = tx.Exec(
+				`INSERT INTO contents (document_hash, content_group_sequence, content_sequence, label, type, value, int_value, time_value, asset_amount, asset_symbol, asset_precision, name_value)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				doc.Hash, contentGroup.ContentGroupSequence, content.ContentSequence, content.Label, nullString(content.Type), content.Value,
+				content.IntValue, formatTime(content.TimeValue), content.AssetAmount, nullString(content.AssetSymbol), content.AssetPrecision, nullString(content.NameValue),
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, certificate := range doc.Certificates {
+		_, err = tx.Exec(
+			"INSERT INTO certificates (document_hash, certification_sequence, certifier, notes, certification_date) VALUES (?, ?, ?, ?, ?)",
+			doc.Hash, certificate.CertificationSequence, certificate.Certifier, certificate.Notes, formatTime(certificate.CertificationDate),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func insertEdge(tx *sql.Tx, chainEdge *doccache.ChainEdge) error {
+	_, err := tx.Exec("INSERT OR IGNORE INTO edges (name, from_hash, to_hash) VALUES (?, ?, ?)", chainEdge.Name, chainEdge.From, chainEdge.To)
+	return err
+}
+
+//formatTime returns the time in the layout of the date columns, nil for missing times
+func formatTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format(TimeLayout)
+}
+
+func nullString(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,221 @@
+package mirror
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sebastianmontero/dgraph-go-client/dgraph"
+	"github.com/sebastianmontero/hypha-document-cache-go/doccache"
+)
+
+var dg *dgraph.Dgraph
+var cache *doccache.Doccache
+
+func TestMain(m *testing.M) {
+	var err error
+	dg, err = dgraph.New("")
+	if err != nil {
+		panic(fmt.Sprintf("Unable to create dgraph: %v", err))
+	}
+	cache, err = doccache.New(dg, nil)
+	if err != nil {
+		panic(fmt.Sprintf("Failed creating docCache: %v", err))
+	}
+	retCode := m.Run()
+	dg.Close()
+	os.Exit(retCode)
+}
+
+func newMirror(t *testing.T) *Mirror {
+	dir, err := ioutil.TempDir("", "mirror")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	mirror, err := New(filepath.Join(dir, "mirror.db"), nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return mirror
+}
+
+func newChainDocument(hash, docType string, periods int, certificates int) *doccache.ChainDocument {
+	chainDoc := &doccache.ChainDocument{
+		Hash:        hash,
+		CreatedDate: "2021-08-01T10:00:00.000",
+		Creator:     "mirrorer",
+		ContentGroups: [][]*doccache.ChainContent{
+			{
+				{Label: "content_group_label", Value: []interface{}{"string", "system"}},
+				{Label: "type", Value: []interface{}{"name", docType}},
+			},
+			{
+				{Label: "content_group_label", Value: []interface{}{"string", "details"}},
+				{Label: "periods", Value: []interface{}{"int64", periods}},
+				{Label: "start", Value: []interface{}{"time_point", "2021-09-01T00:00:00.000"}},
+				{Label: "amount", Value: []interface{}{"asset", "1500.50 HUSD"}},
+			},
+		},
+	}
+	for i := 0; i < certificates; i++ {
+		chainDoc.Certificates = append(chainDoc.Certificates, &doccache.ChainCertificate{
+			Certifier:         "certifier",
+			Notes:             fmt.Sprintf("note %v", i),
+			CertificationDate: "2021-08-02T10:00:00.000",
+		})
+	}
+	return chainDoc
+}
+
+func count(t *testing.T, mirror *Mirror, query string, args ...interface{}) int {
+	var n int
+	err := mirror.DB().QueryRow(query, args...).Scan(&n)
+	if err != nil {
+		t.Fatalf("Query: %v failed: %v", query, err)
+	}
+	return n
+}
+
+func assertCursor(t *testing.T, mirror *Mirror, expected string) {
+	cursor, err := mirror.Cursor()
+	if err != nil {
+		t.Fatalf("Cursor failed: %v", err)
+	}
+	if cursor != expected {
+		t.Fatalf("Expected cursor: %v, found: %v", expected, cursor)
+	}
+}
+
+func TestMutations(t *testing.T) {
+	mirror := newMirror(t)
+	defer mirror.Close()
+	assertCursor(t, mirror, "")
+
+	err := mirror.StoreDocument(newChainDocument("AA01", "payout", 3, 1), "c1")
+	if err != nil {
+		t.Fatalf("StoreDocument failed: %v", err)
+	}
+	err = mirror.StoreDocument(newChainDocument("aa02", "assignment", 12, 0), "c2")
+	if err != nil {
+		t.Fatalf("StoreDocument failed: %v", err)
+	}
+	err = mirror.StoreDocument(newChainDocument("aa01", "payout", 3, 2), "c3")
+	if err != nil {
+		t.Fatalf("StoreDocument failed: %v", err)
+	}
+	assertCursor(t, mirror, "c3")
+	if n := count(t, mirror, "SELECT count(*) FROM documents WHERE doc_type = 'payout' AND created_date = '2021-08-01 10:00:00.000'"); n != 1 {
+		t.Fatalf("Expected 1 payout, found: %v", n)
+	}
+	if n := count(t, mirror, "SELECT count(*) FROM certificates WHERE document_hash = 'aa01'"); n != 2 {
+		t.Fatalf("Expected updated document to have 2 certificates, found: %v", n)
+	}
+	if n := count(t, mirror, "SELECT count(*) FROM content_groups WHERE label = 'details'"); n != 2 {
+		t.Fatalf("Expected 2 details content groups, found: %v", n)
+	}
+	if n := count(t, mirror, "SELECT count(*) FROM contents WHERE label = 'periods' AND int_value > 5"); n != 1 {
+		t.Fatalf("Expected 1 document with more than 5 periods, found: %v", n)
+	}
+	if n := count(t, mirror, "SELECT count(*) FROM contents WHERE label = 'start' AND date(time_value) = '2021-09-01'"); n != 2 {
+		t.Fatalf("Expected 2 documents starting on 2021-09-01, found: %v", n)
+	}
+	if n := count(t, mirror, "SELECT count(*) FROM contents WHERE asset_symbol = 'HUSD' AND asset_amount > 1500 AND asset_precision = 2"); n != 2 {
+		t.Fatalf("Expected 2 HUSD amounts, found: %v", n)
+	}
+
+	err = mirror.MutateEdge(&doccache.ChainEdge{Name: "payout", From: "aa02", To: "AA01"}, false, "c4")
+	if err != nil {
+		t.Fatalf("MutateEdge failed: %v", err)
+	}
+	err = mirror.MutateEdge(&doccache.ChainEdge{Name: "payout", From: "aa02", To: "aa01"}, false, "c5")
+	if err != nil {
+		t.Fatalf("MutateEdge failed: %v", err)
+	}
+	if n := count(t, mirror, "SELECT count(*) FROM edges WHERE name = 'payout' AND from_hash = 'aa02' AND to_hash = 'aa01'"); n != 1 {
+		t.Fatalf("Expected 1 edge, found: %v", n)
+	}
+	err = mirror.MutateEdge(&doccache.ChainEdge{Name: "payout", From: "aa02", To: "aa01"}, true, "c6")
+	if err != nil {
+		t.Fatalf("MutateEdge failed: %v", err)
+	}
+	if n := count(t, mirror, "SELECT count(*) FROM edges"); n != 0 {
+		t.Fatalf("Expected edge to be deleted, found: %v", n)
+	}
+
+	err = mirror.MutateEdge(&doccache.ChainEdge{Name: "payout", From: "aa02", To: "aa01"}, false, "c7")
+	if err != nil {
+		t.Fatalf("MutateEdge failed: %v", err)
+	}
+	err = mirror.DeleteDocument(newChainDocument("aa01", "payout", 3, 2), "c8")
+	if err != nil {
+		t.Fatalf("DeleteDocument failed: %v", err)
+	}
+	for _, table := range []string{"contents", "content_groups", "certificates"} {
+		if n := count(t, mirror, "SELECT count(*) FROM "+table+" WHERE document_hash = 'aa01'"); n != 0 {
+			t.Fatalf("Expected %v of deleted document to be removed, found: %v", table, n)
+		}
+	}
+	if n := count(t, mirror, "SELECT count(*) FROM edges"); n != 0 {
+		t.Fatalf("Expected edges of deleted document to be removed, found: %v", n)
+	}
+	err = mirror.UpdateCursor("c9")
+	if err != nil {
+		t.Fatalf("UpdateCursor failed: %v", err)
+	}
+	assertCursor(t, mirror, "c9")
+}
+
+func TestSync(t *testing.T) {
+	hashes := []string{
+		"b1f0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c40",
+		"b2f0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c41",
+	}
+	for i, hash := range hashes {
+		err := cache.StoreDocument(newChainDocument(hash, "payout", i, 1), fmt.Sprintf("sync%v", i))
+		if err != nil {
+			t.Fatalf("StoreDocument failed: %v", err)
+		}
+	}
+	err := cache.MutateEdge(&doccache.ChainEdge{Name: "mirrored", From: hashes[0], To: hashes[1]}, false, "sync2")
+	if err != nil {
+		t.Fatalf("MutateEdge failed: %v", err)
+	}
+
+	mirror := newMirror(t)
+	defer mirror.Close()
+	err = mirror.StoreDocument(newChainDocument("stale", "payout", 1, 0), "stale")
+	if err != nil {
+		t.Fatalf("StoreDocument failed: %v", err)
+	}
+	err = mirror.Sync(cache)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	assertCursor(t, mirror, "sync2")
+	if n := count(t, mirror, "SELECT count(*) FROM documents WHERE hash = 'stale'"); n != 0 {
+		t.Fatalf("Expected rebuild to remove stale documents, found: %v", n)
+	}
+	if n := count(t, mirror, "SELECT count(*) FROM documents WHERE hash IN (?, ?)", hashes[0], hashes[1]); n != 2 {
+		t.Fatalf("Expected 2 documents to be rebuilt, found: %v", n)
+	}
+	if n := count(t, mirror, "SELECT count(*) FROM contents WHERE document_hash = ? AND label = 'periods' AND int_value = 1", hashes[1]); n != 1 {
+		t.Fatalf("Expected typed content to be rebuilt, found: %v", n)
+	}
+	if n := count(t, mirror, "SELECT count(*) FROM edges WHERE name = 'mirrored' AND from_hash = ? AND to_hash = ?", hashes[0], hashes[1]); n != 1 {
+		t.Fatalf("Expected edge to be rebuilt, found: %v", n)
+	}
+
+	err = mirror.StoreDocument(newChainDocument("stale", "payout", 1, 0), "sync2")
+	if err != nil {
+		t.Fatalf("StoreDocument failed: %v", err)
+	}
+	err = mirror.Sync(cache)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if n := count(t, mirror, "SELECT count(*) FROM documents WHERE hash = 'stale'"); n != 1 {
+		t.Fatalf("Expected mirror in sync not to be rebuilt, found: %v", n)
+	}
+}
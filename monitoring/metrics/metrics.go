@@ -22,10 +22,18 @@ var (
 		Name: "hypha_graph_document_cache_deleted_edges",
 		Help: "# of deleted edges",
 	})
+	InvalidRows = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "hypha_graph_document_cache_invalid_rows",
+		Help: "# of rows that failed validation",
+	})
 	BlockNumber = promauto.NewGauge(prometheus.GaugeOpts{
 		Name: "hypha_graph_document_cache_block_number",
 		Help: "Block Number",
 	})
+	WebhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "hypha_graph_document_cache_webhook_deliveries",
+		Help: "# of webhook delivery attempts by outcome",
+	}, []string{"outcome"})
 )
 
 // func init() {
@@ -0,0 +1,341 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: doccache.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// DocumentCacheClient is the client API for DocumentCache service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type DocumentCacheClient interface {
+	// GetDocument finds a document by hash, returns NOT_FOUND if the document does not exist
+	GetDocument(ctx context.Context, in *GetDocumentRequest, opts ...grpc.CallOption) (*Document, error)
+	// BatchGetDocuments finds documents by hash, reports the hashes that were not found
+	BatchGetDocuments(ctx context.Context, in *BatchGetDocumentsRequest, opts ...grpc.CallOption) (*BatchGetDocumentsResponse, error)
+	// SearchDocuments streams all the documents that match the filters
+	SearchDocuments(ctx context.Context, in *SearchDocumentsRequest, opts ...grpc.CallOption) (DocumentCache_SearchDocumentsClient, error)
+	// ExportDocuments streams all the documents ordered by hash
+	ExportDocuments(ctx context.Context, in *ExportDocumentsRequest, opts ...grpc.CallOption) (DocumentCache_ExportDocumentsClient, error)
+	// ExportEdges streams all the edges of the requested edge names
+	ExportEdges(ctx context.Context, in *ExportEdgesRequest, opts ...grpc.CallOption) (DocumentCache_ExportEdgesClient, error)
+}
+
+type documentCacheClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDocumentCacheClient(cc grpc.ClientConnInterface) DocumentCacheClient {
+	return &documentCacheClient{cc}
+}
+
+func (c *documentCacheClient) GetDocument(ctx context.Context, in *GetDocumentRequest, opts ...grpc.CallOption) (*Document, error) {
+	out := new(Document)
+	err := c.cc.Invoke(ctx, "/doccache.v1.DocumentCache/GetDocument", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *documentCacheClient) BatchGetDocuments(ctx context.Context, in *BatchGetDocumentsRequest, opts ...grpc.CallOption) (*BatchGetDocumentsResponse, error) {
+	out := new(BatchGetDocumentsResponse)
+	err := c.cc.Invoke(ctx, "/doccache.v1.DocumentCache/BatchGetDocuments", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *documentCacheClient) SearchDocuments(ctx context.Context, in *SearchDocumentsRequest, opts ...grpc.CallOption) (DocumentCache_SearchDocumentsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &DocumentCache_ServiceDesc.Streams[0], "/doccache.v1.DocumentCache/SearchDocuments", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &documentCacheSearchDocumentsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type DocumentCache_SearchDocumentsClient interface {
+	Recv() (*Document, error)
+	grpc.ClientStream
+}
+
+type documentCacheSearchDocumentsClient struct {
+	grpc.ClientStream
+}
+
+func (x *documentCacheSearchDocumentsClient) Recv() (*Document, error) {
+	m := new(Document)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *documentCacheClient) ExportDocuments(ctx context.Context, in *ExportDocumentsRequest, opts ...grpc.CallOption) (DocumentCache_ExportDocumentsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &DocumentCache_ServiceDesc.Streams[1], "/doccache.v1.DocumentCache/ExportDocuments", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &documentCacheExportDocumentsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type DocumentCache_ExportDocumentsClient interface {
+	Recv() (*Document, error)
+	grpc.ClientStream
+}
+
+type documentCacheExportDocumentsClient struct {
+	grpc.ClientStream
+}
+
+func (x *documentCacheExportDocumentsClient) Recv() (*Document, error) {
+	m := new(Document)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *documentCacheClient) ExportEdges(ctx context.Context, in *ExportEdgesRequest, opts ...grpc.CallOption) (DocumentCache_ExportEdgesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &DocumentCache_ServiceDesc.Streams[2], "/doccache.v1.DocumentCache/ExportEdges", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &documentCacheExportEdgesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type DocumentCache_ExportEdgesClient interface {
+	Recv() (*Edge, error)
+	grpc.ClientStream
+}
+
+type documentCacheExportEdgesClient struct {
+	grpc.ClientStream
+}
+
+func (x *documentCacheExportEdgesClient) Recv() (*Edge, error) {
+	m := new(Edge)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// DocumentCacheServer is the server API for DocumentCache service.
+// All implementations must embed UnimplementedDocumentCacheServer
+// for forward compatibility
+type DocumentCacheServer interface {
+	// GetDocument finds a document by hash, returns NOT_FOUND if the document does not exist
+	GetDocument(context.Context, *GetDocumentRequest) (*Document, error)
+	// BatchGetDocuments finds documents by hash, reports the hashes that were not found
+	BatchGetDocuments(context.Context, *BatchGetDocumentsRequest) (*BatchGetDocumentsResponse, error)
+	// SearchDocuments streams all the documents that match the filters
+	SearchDocuments(*SearchDocumentsRequest, DocumentCache_SearchDocumentsServer) error
+	// ExportDocuments streams all the documents ordered by hash
+	ExportDocuments(*ExportDocumentsRequest, DocumentCache_ExportDocumentsServer) error
+	// ExportEdges streams all the edges of the requested edge names
+	ExportEdges(*ExportEdgesRequest, DocumentCache_ExportEdgesServer) error
+	mustEmbedUnimplementedDocumentCacheServer()
+}
+
+// UnimplementedDocumentCacheServer must be embedded to have forward compatible implementations.
+type UnimplementedDocumentCacheServer struct {
+}
+
+func (UnimplementedDocumentCacheServer) GetDocument(context.Context, *GetDocumentRequest) (*Document, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDocument not implemented")
+}
+func (UnimplementedDocumentCacheServer) BatchGetDocuments(context.Context, *BatchGetDocumentsRequest) (*BatchGetDocumentsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchGetDocuments not implemented")
+}
+func (UnimplementedDocumentCacheServer) SearchDocuments(*SearchDocumentsRequest, DocumentCache_SearchDocumentsServer) error {
+	return status.Errorf(codes.Unimplemented, "method SearchDocuments not implemented")
+}
+func (UnimplementedDocumentCacheServer) ExportDocuments(*ExportDocumentsRequest, DocumentCache_ExportDocumentsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ExportDocuments not implemented")
+}
+func (UnimplementedDocumentCacheServer) ExportEdges(*ExportEdgesRequest, DocumentCache_ExportEdgesServer) error {
+	return status.Errorf(codes.Unimplemented, "method ExportEdges not implemented")
+}
+func (UnimplementedDocumentCacheServer) mustEmbedUnimplementedDocumentCacheServer() {}
+
+// UnsafeDocumentCacheServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DocumentCacheServer will
+// result in compilation errors.
+type UnsafeDocumentCacheServer interface {
+	mustEmbedUnimplementedDocumentCacheServer()
+}
+
+func RegisterDocumentCacheServer(s grpc.ServiceRegistrar, srv DocumentCacheServer) {
+	s.RegisterService(&DocumentCache_ServiceDesc, srv)
+}
+
+func _DocumentCache_GetDocument_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDocumentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DocumentCacheServer).GetDocument(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/doccache.v1.DocumentCache/GetDocument",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DocumentCacheServer).GetDocument(ctx, req.(*GetDocumentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DocumentCache_BatchGetDocuments_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchGetDocumentsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DocumentCacheServer).BatchGetDocuments(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/doccache.v1.DocumentCache/BatchGetDocuments",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DocumentCacheServer).BatchGetDocuments(ctx, req.(*BatchGetDocumentsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DocumentCache_SearchDocuments_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SearchDocumentsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DocumentCacheServer).SearchDocuments(m, &documentCacheSearchDocumentsServer{stream})
+}
+
+type DocumentCache_SearchDocumentsServer interface {
+	Send(*Document) error
+	grpc.ServerStream
+}
+
+type documentCacheSearchDocumentsServer struct {
+	grpc.ServerStream
+}
+
+func (x *documentCacheSearchDocumentsServer) Send(m *Document) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _DocumentCache_ExportDocuments_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportDocumentsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DocumentCacheServer).ExportDocuments(m, &documentCacheExportDocumentsServer{stream})
+}
+
+type DocumentCache_ExportDocumentsServer interface {
+	Send(*Document) error
+	grpc.ServerStream
+}
+
+type documentCacheExportDocumentsServer struct {
+	grpc.ServerStream
+}
+
+func (x *documentCacheExportDocumentsServer) Send(m *Document) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _DocumentCache_ExportEdges_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportEdgesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DocumentCacheServer).ExportEdges(m, &documentCacheExportEdgesServer{stream})
+}
+
+type DocumentCache_ExportEdgesServer interface {
+	Send(*Edge) error
+	grpc.ServerStream
+}
+
+type documentCacheExportEdgesServer struct {
+	grpc.ServerStream
+}
+
+func (x *documentCacheExportEdgesServer) Send(m *Edge) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// DocumentCache_ServiceDesc is the grpc.ServiceDesc for DocumentCache service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var DocumentCache_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "doccache.v1.DocumentCache",
+	HandlerType: (*DocumentCacheServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetDocument",
+			Handler:    _DocumentCache_GetDocument_Handler,
+		},
+		{
+			MethodName: "BatchGetDocuments",
+			Handler:    _DocumentCache_BatchGetDocuments_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SearchDocuments",
+			Handler:       _DocumentCache_SearchDocuments_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ExportDocuments",
+			Handler:       _DocumentCache_ExportDocuments_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ExportEdges",
+			Handler:       _DocumentCache_ExportEdges_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "doccache.proto",
+}
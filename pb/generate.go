@@ -0,0 +1,4 @@
+//Package pb protobuf messages and gRPC service of the document cache
+package pb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative doccache.proto
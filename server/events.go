@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sebastianmontero/hypha-document-cache-go/doccache"
+)
+
+//eventKeepAlive interval at which idle event streams are pinged so that proxies keep them open
+const eventKeepAlive = 15 * time.Second
+
+//EventMessage message sent over the WebSocket, Error is only set when the subscription ends
+type EventMessage struct {
+	Event *doccache.Event `json:"event,omitempty"`
+	Error *Error          `json:"error,omitempty"`
+}
+
+var upgrader = websocket.Upgrader{
+	//the API is read only and public, so subscriptions are accepted from any origin
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+//parseEventFilter reads the event filter from the query parameters
+func parseEventFilter(r *http.Request) *doccache.EventFilter {
+	filter := &doccache.EventFilter{
+		DocTypes: parseList(r, "doc_types"),
+		Creators: parseList(r, "creators"),
+		Hashes:   parseList(r, "hashes"),
+		Edges:    parseList(r, "edges"),
+	}
+	for _, eventType := range parseList(r, "types") {
+		filter.Types = append(filter.Types, doccache.EventType(eventType))
+	}
+	return filter
+}
+
+//subscribe creates the subscription, resuming after the after param or the Last-Event-ID header
+func (m *Server) subscribe(r *http.Request) (*doccache.EventSubscription, error) {
+	after := r.URL.Query().Get("after")
+	if after == "" {
+		after = r.Header.Get("Last-Event-ID")
+	}
+	subscription, err := m.doccache.Events.Subscribe(parseEventFilter(r), after)
+	if expiredErr, ok := err.(*doccache.EventCursorExpiredError); ok {
+		return nil, &httpError{status: http.StatusGone, code: ErrorGone, message: expiredErr.Error()}
+	}
+	return subscription, err
+}
+
+//events streams the change events as Server-Sent Events
+func (m *Server) events(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSON(w, http.StatusMethodNotAllowed, &ErrorResponse{
+			Error: &Error{Code: ErrorMethodNotAllowed, Message: fmt.Sprintf("method: %v not allowed", r.Method)},
+		})
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, r, fmt.Errorf("streaming not supported"))
+		return
+	}
+	subscription, err := m.subscribe(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		ctx, cancel := context.WithTimeout(r.Context(), eventKeepAlive)
+		event, err := subscription.Next(ctx)
+		cancel()
+		if err == context.DeadlineExceeded {
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+			continue
+		}
+		if err != nil {
+			if r.Context().Err() == nil {
+				log.Infof("Closing event stream: %v", err)
+				fmt.Fprintf(w, "event: error\ndata: %v\n\n", err)
+				flusher.Flush()
+			}
+			return
+		}
+		data, err := json.Marshal(event)
+		if err != nil {
+			log.Errorf(err, "Failed to encode event: %v", event)
+			return
+		}
+		fmt.Fprintf(w, "id: %v\nevent: %v\ndata: %s\n\n", event.Cursor, event.Type, data)
+		flusher.Flush()
+	}
+}
+
+//eventsWebSocket streams the change events over a WebSocket, each message contains one event
+func (m *Server) eventsWebSocket(w http.ResponseWriter, r *http.Request) {
+	subscription, err := m.subscribe(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Infof("Failed to upgrade event subscription: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		//client messages are ignored, reading detects when the connection is closed
+		defer cancel()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		nextCtx, nextCancel := context.WithTimeout(ctx, eventKeepAlive)
+		event, err := subscription.Next(nextCtx)
+		nextCancel()
+		if err == context.DeadlineExceeded {
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(eventKeepAlive))
+			if err != nil {
+				return
+			}
+			continue
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Infof("Closing event subscription: %v", err)
+				conn.WriteJSON(&EventMessage{Error: &Error{Code: ErrorGone, Message: err.Error()}})
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			}
+			return
+		}
+		err = conn.WriteJSON(&EventMessage{Event: event})
+		if err != nil {
+			return
+		}
+	}
+}
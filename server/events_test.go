@@ -0,0 +1,86 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sebastianmontero/hypha-document-cache-go/doccache"
+)
+
+func TestEvents(t *testing.T) {
+	hash := "b4d0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c14"
+	marker := &doccache.Event{Type: doccache.EventDocumentCreated, Document: &doccache.EventDocument{Hash: "marker"}}
+	cache.Events.Publish(marker)
+	err := cache.StoreDocument(&doccache.ChainDocument{
+		Hash:        hash,
+		CreatedDate: "2021-03-20T10:00:00",
+		Creator:     "server.events",
+		ContentGroups: [][]*doccache.ChainContent{
+			{
+				{
+					Label: "content_group_label",
+					Value: []interface{}{"string", "details"},
+				},
+			},
+		},
+	}, "serverevents0")
+	if err != nil {
+		t.Fatalf("StoreDocument failed: %v", err)
+	}
+	err = cache.MutateEdge(&doccache.ChainEdge{Name: "servereventedge", From: hashes[0], To: hash}, false, "serverevents1")
+	if err != nil {
+		t.Fatalf("MutateEdge failed: %v", err)
+	}
+	query := fmt.Sprintf("after=%v&hashes=%v&types=edge_created", marker.Cursor, hash)
+
+	resp, err := http.Get(fmt.Sprintf("%v/v1/events?%v", server.URL, query))
+	if err != nil {
+		t.Fatalf("Event stream request failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected event stream, found status: %v, content type: %v", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	fields := make(map[string]string)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() && scanner.Text() != "" {
+		keyValue := strings.SplitN(scanner.Text(), ": ", 2)
+		fields[keyValue[0]] = keyValue[1]
+	}
+	resp.Body.Close()
+	event := &doccache.Event{}
+	err = json.Unmarshal([]byte(fields["data"]), event)
+	if err != nil {
+		t.Fatalf("Failed to decode event: %v, error: %v", fields, err)
+	}
+	if fields["event"] != "edge_created" || fields["id"] != event.Cursor || event.Edge.Name != "servereventedge" || event.ChainCursor != "serverevents1" {
+		t.Fatalf("Expected servereventedge created event, found: %v", fields)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws%v/v1/events/ws?after=%v&hashes=%v", strings.TrimPrefix(server.URL, "http"), marker.Cursor, hash), nil)
+	if err != nil {
+		t.Fatalf("WebSocket dial failed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	message := &EventMessage{}
+	err = conn.ReadJSON(message)
+	if err != nil {
+		t.Fatalf("Failed to read event message: %v", err)
+	}
+	if message.Event == nil || message.Event.Type != doccache.EventDocumentCreated || message.Event.Document.Hash != hash {
+		t.Fatalf("Expected document created event for: %v, found: %v", hash, message)
+	}
+
+	errorResponse := &ErrorResponse{}
+	get(t, "/v1/events?after=expired.1", http.StatusGone, errorResponse)
+	if errorResponse.Error.Code != ErrorGone {
+		t.Fatalf("Expected gone error, found: %v", errorResponse.Error)
+	}
+	get(t, "/v1/events/ws?after=invalid", http.StatusBadRequest, errorResponse)
+}
@@ -0,0 +1,766 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/sebastianmontero/hypha-document-cache-go/doccache"
+)
+
+//MaxGraphQLDepth maximum nesting of the fields selected by a GraphQL query
+const MaxGraphQLDepth = 10
+
+//MaxGraphQLComplexity maximum number of fields selected by a GraphQL query, fragments count each time they are spread
+const MaxGraphQLComplexity = 500
+
+//graphQLRequest body of a GraphQL request
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+//gqlDocument document being resolved and the request config it was loaded with,
+//fields that were not loaded are fetched when resolved, together with the rest of its batch
+type gqlDocument struct {
+	doc   *doccache.Document
+	rc    *doccache.RequestConfig
+	batch *gqlBatch
+}
+
+//gqlBatch documents resolved at the same level, e.g. a page of documents or the neighbours of a document,
+//the fields and edges they are missing are loaded for all of them with one query per request config
+type gqlBatch struct {
+	hashes []string
+	loaded map[string]map[string]*doccache.Document
+	lock   sync.Mutex
+}
+
+//gqlConnection page of documents
+type gqlConnection struct {
+	docs   []*gqlDocument
+	cursor string
+}
+
+//graphQLSchema schema built for a specific set of edges
+type graphQLSchema struct {
+	schema graphql.Schema
+	edges  string
+}
+
+func (m *Server) graphQL(w http.ResponseWriter, r *http.Request) {
+	request := &graphQLRequest{}
+	switch r.Method {
+	case http.MethodGet:
+		request.Query = r.URL.Query().Get("query")
+		request.OperationName = r.URL.Query().Get("operationName")
+		if variables := r.URL.Query().Get("variables"); variables != "" {
+			err := json.Unmarshal([]byte(variables), &request.Variables)
+			if err != nil {
+				writeError(w, r, badRequest("invalid variables: %v", err))
+				return
+			}
+		}
+	case http.MethodPost:
+		err := json.NewDecoder(r.Body).Decode(request)
+		if err != nil {
+			writeError(w, r, badRequest("invalid graphql request: %v", err))
+			return
+		}
+	default:
+		w.Header().Set("Allow", fmt.Sprintf("%v, %v", http.MethodGet, http.MethodPost))
+		writeJSON(w, http.StatusMethodNotAllowed, &ErrorResponse{
+			Error: &Error{Code: ErrorMethodNotAllowed, Message: fmt.Sprintf("method: %v not allowed", r.Method)},
+		})
+		return
+	}
+	if request.Query == "" {
+		writeError(w, r, badRequest("query is required"))
+		return
+	}
+	err := checkGraphQLLimits(request.Query)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	schema, err := m.getGraphQLSchema()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	result := graphql.Do(graphql.Params{
+		Schema:         schema,
+		RequestString:  request.Query,
+		OperationName:  request.OperationName,
+		VariableValues: request.Variables,
+		Context:        r.Context(),
+	})
+	writeJSON(w, http.StatusOK, result)
+}
+
+//getGraphQLSchema returns the schema for the registered edges, rebuilding it if new edges have been seen
+func (m *Server) getGraphQLSchema() (graphql.Schema, error) {
+	edgeNames := m.doccache.EdgeNames()
+	key := strings.Join(edgeNames, ",")
+	m.schemaLock.Lock()
+	defer m.schemaLock.Unlock()
+	if m.graphQLSchema != nil && m.graphQLSchema.edges == key {
+		return m.graphQLSchema.schema, nil
+	}
+	log.Infof("Building GraphQL schema for edges: %v", edgeNames)
+	schema, err := m.buildGraphQLSchema(edgeNames)
+	if err != nil {
+		return graphql.Schema{}, err
+	}
+	m.graphQLSchema = &graphQLSchema{
+		schema: schema,
+		edges:  key,
+	}
+	return schema, nil
+}
+
+func (m *Server) buildGraphQLSchema(edgeNames []string) (graphql.Schema, error) {
+	edgeFields := make(map[string]string, len(edgeNames))
+	for _, edgeName := range edgeNames {
+		fieldName := doccache.EdgeFieldName(edgeName)
+		if other, ok := edgeFields[fieldName]; ok {
+			log.Warnf("Edge: %v not exposed in GraphQL schema, field: %v already used by edge: %v", edgeName, fieldName, other)
+			continue
+		}
+		edgeFields[fieldName] = edgeName
+	}
+	certificateType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Certificate",
+		Fields: graphql.Fields{
+			"certifier": stringField(func(c *doccache.Certificate) string { return c.Certifier }),
+			"notes":     stringField(func(c *doccache.Certificate) string { return c.Notes }),
+			"certificationDate": &graphql.Field{
+				Type: graphql.DateTime,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return timeValue(p.Source.(*doccache.Certificate).CertificationDate), nil
+				},
+			},
+			"certificationSequence": intField(func(c *doccache.Certificate) int { return c.CertificationSequence }),
+		},
+	})
+
+	var documentType *graphql.Object
+	contentType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Content",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"label": stringField(func(c *doccache.Content) string { return c.Label }),
+				"value": stringField(func(c *doccache.Content) string { return c.Value }),
+				"type":  stringField(func(c *doccache.Content) string { return c.Type }),
+				"intValue": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						if value := p.Source.(*doccache.Content).IntValue; value != nil {
+							return fmt.Sprintf("%v", *value), nil
+						}
+						return nil, nil
+					},
+				},
+				"timeValue": &graphql.Field{
+					Type: graphql.DateTime,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return timeValue(p.Source.(*doccache.Content).TimeValue), nil
+					},
+				},
+				"assetAmount": &graphql.Field{
+					Type: graphql.Float,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						if value := p.Source.(*doccache.Content).AssetAmount; value != nil {
+							return *value, nil
+						}
+						return nil, nil
+					},
+				},
+				"assetSymbol": stringField(func(c *doccache.Content) string { return c.AssetSymbol }),
+				"assetPrecision": &graphql.Field{
+					Type: graphql.Int,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						if value := p.Source.(*doccache.Content).AssetPrecision; value != nil {
+							return *value, nil
+						}
+						return nil, nil
+					},
+				},
+				"nameValue":       stringField(func(c *doccache.Content) string { return c.NameValue }),
+				"contentSequence": intField(func(c *doccache.Content) int { return c.ContentSequence }),
+				"document": &graphql.Field{
+					Type:        documentType,
+					Description: "Document referenced by a checksum256 content",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						content := p.Source.(*doccache.Content)
+						if !content.IsChecksum() {
+							return nil, nil
+						}
+						return m.resolveDocument(content.Value, requestConfig(edgeFields, p.Info, p.Info.FieldASTs))
+					},
+				},
+			}
+		}),
+	})
+
+	contentGroupType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ContentGroup",
+		Fields: graphql.Fields{
+			"contentGroupSequence": intField(func(c *doccache.ContentGroup) int { return c.ContentGroupSequence }),
+			"label":                stringField(func(c *doccache.ContentGroup) string { return c.Label() }),
+			"contents": &graphql.Field{
+				Type: graphql.NewList(contentType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*doccache.ContentGroup).Contents, nil
+				},
+			},
+		},
+	})
+
+	documentType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Document",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			fields := graphql.Fields{
+				"uid":       stringField(func(d *gqlDocument) string { return d.doc.UID }),
+				"hash":      stringField(func(d *gqlDocument) string { return d.doc.Hash }),
+				"creator":   stringField(func(d *gqlDocument) string { return d.doc.Creator }),
+				"docType":   stringField(func(d *gqlDocument) string { return d.doc.DocType }),
+				"nodeLabel": stringField(func(d *gqlDocument) string { return d.doc.NodeLabel }),
+				"ballotId":  stringField(func(d *gqlDocument) string { return d.doc.BallotID }),
+				"createdDate": &graphql.Field{
+					Type: graphql.DateTime,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return timeValue(p.Source.(*gqlDocument).doc.CreatedDate), nil
+					},
+				},
+				"types": &graphql.Field{
+					Type: graphql.NewList(graphql.String),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return p.Source.(*gqlDocument).doc.DType, nil
+					},
+				},
+				"contentGroups": &graphql.Field{
+					Type: graphql.NewList(contentGroupType),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						d := p.Source.(*gqlDocument)
+						if !d.rc.ContentGroups {
+							loaded, err := m.loadBatched(d, &doccache.RequestConfig{ContentGroups: true})
+							if err != nil || loaded == nil {
+								return nil, err
+							}
+							d.doc.ContentGroups, d.rc.ContentGroups = loaded.ContentGroups, true
+						}
+						return d.doc.ContentGroups, nil
+					},
+				},
+				"certificates": &graphql.Field{
+					Type: graphql.NewList(certificateType),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						d := p.Source.(*gqlDocument)
+						if !d.rc.Certificates {
+							loaded, err := m.loadBatched(d, &doccache.RequestConfig{Certificates: true})
+							if err != nil || loaded == nil {
+								return nil, err
+							}
+							d.doc.Certificates, d.rc.Certificates = loaded.Certificates, true
+						}
+						return d.doc.Certificates, nil
+					},
+				},
+				"inbound": &graphql.Field{
+					Type:        graphql.NewList(documentType),
+					Description: "Documents that point to this document through the edge",
+					Args: graphql.FieldConfigArgument{
+						"edge": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					},
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						edgeName := p.Args["edge"].(string)
+						err := validateEdges(m.doccache, []string{edgeName}, true)
+						if err != nil {
+							return nil, err
+						}
+						d := p.Source.(*gqlDocument)
+						rc := requestConfig(edgeFields, p.Info, p.Info.FieldASTs)
+						rc.Edges = nil
+						rc.InboundEdges = []string{edgeName}
+						loaded, err := m.loadBatched(d, rc)
+						if err != nil || loaded == nil {
+							return nil, err
+						}
+						return neighbours(loaded.InboundEdges[edgeName], rc), nil
+					},
+				},
+			}
+			for fieldName, edgeName := range edgeFields {
+				fields[fieldName] = m.edgeField(documentType, edgeName, edgeFields)
+			}
+			return fields
+		}),
+	})
+
+	typedValueType := graphql.NewEnum(graphql.EnumConfig{
+		Name: "TypedValue",
+		Values: graphql.EnumValueConfigMap{
+			"intValue":    &graphql.EnumValueConfig{Value: string(doccache.TypedInt)},
+			"timeValue":   &graphql.EnumValueConfig{Value: string(doccache.TypedTime)},
+			"assetAmount": &graphql.EnumValueConfig{Value: string(doccache.TypedAsset)},
+		},
+	})
+	contentFilterType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "ContentFilter",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"groupLabel": &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"label":      &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"value":      &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"type": &graphql.InputObjectFieldConfig{
+				Type:        typedValueType,
+				Description: "Typed value the from and to bounds apply to",
+			},
+			"from": &graphql.InputObjectFieldConfig{
+				Type:        graphql.String,
+				Description: "Inclusive lower bound of the typed value",
+			},
+			"to": &graphql.InputObjectFieldConfig{
+				Type:        graphql.String,
+				Description: "Exclusive upper bound of the typed value",
+			},
+			"symbol": &graphql.InputObjectFieldConfig{
+				Type:        graphql.String,
+				Description: "Symbol of the asset, only for the assetAmount type",
+			},
+		},
+	})
+	contentOrderType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "ContentOrder",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"groupLabel": &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"label":      &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"type":       &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(typedValueType)},
+		},
+	})
+	documentFilterType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "DocumentFilter",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"creator":         &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"createdFrom":     &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+			"createdTo":       &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+			"docType":         &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"contents":        &graphql.InputObjectFieldConfig{Type: graphql.NewList(contentFilterType)},
+			"hasEdges":        &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.String)},
+			"hasInboundEdges": &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.String)},
+		},
+	})
+	orderType := graphql.NewEnum(graphql.EnumConfig{
+		Name: "DocumentOrder",
+		Values: graphql.EnumValueConfigMap{
+			"createdDate": &graphql.EnumValueConfig{Value: string(doccache.OrderByCreatedDate)},
+			"hash":        &graphql.EnumValueConfig{Value: string(doccache.OrderByHash)},
+			"content":     &graphql.EnumValueConfig{Value: string(doccache.OrderByContent)},
+		},
+	})
+	connectionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "DocumentConnection",
+		Fields: graphql.Fields{
+			"documents": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(documentType))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*gqlConnection).docs, nil
+				},
+			},
+			"cursor": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Cursor of the next page, null if there are no more pages",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if cursor := p.Source.(*gqlConnection).cursor; cursor != "" {
+						return cursor, nil
+					}
+					return nil, nil
+				},
+			},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"document": &graphql.Field{
+				Type: documentType,
+				Args: graphql.FieldConfigArgument{
+					"hash": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return m.resolveDocument(p.Args["hash"].(string), requestConfig(edgeFields, p.Info, p.Info.FieldASTs))
+				},
+			},
+			"documents": &graphql.Field{
+				Type: graphql.NewNonNull(connectionType),
+				Args: graphql.FieldConfigArgument{
+					"filter":  &graphql.ArgumentConfig{Type: documentFilterType},
+					"orderBy": &graphql.ArgumentConfig{Type: orderType},
+					"orderContent": &graphql.ArgumentConfig{
+						Type:        contentOrderType,
+						Description: "Content to order by, required when ordering by content",
+					},
+					"desc":  &graphql.ArgumentConfig{Type: graphql.Boolean},
+					"first": &graphql.ArgumentConfig{Type: graphql.Int},
+					"after": &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return m.resolveDocuments(p, edgeFields)
+				},
+			},
+		},
+	})
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query: queryType,
+	})
+}
+
+//edgeField resolves the outgoing edge, using the neighbours loaded with the document if available
+func (m *Server) edgeField(documentType *graphql.Object, edgeName string, edgeFields map[string]string) *graphql.Field {
+	return &graphql.Field{
+		Type:        graphql.NewList(documentType),
+		Description: fmt.Sprintf("Documents this document points to through the %v edge", edgeName),
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			d := p.Source.(*gqlDocument)
+			rc := requestConfig(edgeFields, p.Info, p.Info.FieldASTs)
+			if containsString(d.rc.Edges, edgeName) && len(rc.Edges) == 0 {
+				return neighbours(d.doc.Edges[edgeName], &doccache.RequestConfig{
+					ContentGroups: d.rc.ContentGroups,
+					Certificates:  d.rc.Certificates,
+				}), nil
+			}
+			rc.InboundEdges = nil
+			rc.Edges = []string{edgeName}
+			loaded, err := m.loadBatched(d, rc)
+			if err != nil || loaded == nil {
+				return nil, err
+			}
+			return neighbours(loaded.Edges[edgeName], rc), nil
+		},
+	}
+}
+
+func (m *Server) resolveDocuments(p graphql.ResolveParams, edgeFields map[string]string) (interface{}, error) {
+	q := &doccache.SearchQuery{}
+	if filter, ok := p.Args["filter"].(map[string]interface{}); ok {
+		q.Creator, _ = filter["creator"].(string)
+		q.DocType, _ = filter["docType"].(string)
+		if createdFrom, ok := filter["createdFrom"].(time.Time); ok {
+			q.CreatedFrom = &createdFrom
+		}
+		if createdTo, ok := filter["createdTo"].(time.Time); ok {
+			q.CreatedTo = &createdTo
+		}
+		q.HasEdges = toStrings(filter["hasEdges"])
+		q.HasInboundEdges = toStrings(filter["hasInboundEdges"])
+		if contents, ok := filter["contents"].([]interface{}); ok {
+			for _, content := range contents {
+				contentMap := content.(map[string]interface{})
+				contentFilter := &doccache.ContentFilter{}
+				contentFilter.GroupLabel, _ = contentMap["groupLabel"].(string)
+				contentFilter.Label, _ = contentMap["label"].(string)
+				contentFilter.Value, _ = contentMap["value"].(string)
+				if valueType, ok := contentMap["type"].(string); ok {
+					contentFilter.Type = doccache.TypedValue(valueType)
+				}
+				contentFilter.From, _ = contentMap["from"].(string)
+				contentFilter.To, _ = contentMap["to"].(string)
+				contentFilter.Symbol, _ = contentMap["symbol"].(string)
+				q.Contents = append(q.Contents, contentFilter)
+			}
+		}
+	}
+	if orderBy, ok := p.Args["orderBy"].(string); ok {
+		q.OrderBy = doccache.SearchOrder(orderBy)
+	}
+	if orderContent, ok := p.Args["orderContent"].(map[string]interface{}); ok {
+		q.OrderContent = &doccache.ContentOrder{}
+		q.OrderContent.GroupLabel, _ = orderContent["groupLabel"].(string)
+		q.OrderContent.Label, _ = orderContent["label"].(string)
+		if valueType, ok := orderContent["type"].(string); ok {
+			q.OrderContent.Type = doccache.TypedValue(valueType)
+		}
+	}
+	q.Desc, _ = p.Args["desc"].(bool)
+	q.First, _ = p.Args["first"].(int)
+	q.After, _ = p.Args["after"].(string)
+
+	q.RequestConfig = &doccache.RequestConfig{}
+	for _, field := range p.Info.FieldASTs {
+		for _, selection := range selectedFields(p.Info, field.SelectionSet) {
+			if selection.Name.Value == "documents" {
+				q.RequestConfig = requestConfig(edgeFields, p.Info, []*ast.Field{selection})
+			}
+		}
+	}
+	result, err := m.doccache.Search(q)
+	if err != nil {
+		return nil, err
+	}
+	return &gqlConnection{
+		docs:   neighbours(result.Docs, q.RequestConfig),
+		cursor: result.Cursor,
+	}, nil
+}
+
+//resolveDocument returns the document or nil if it does not exist
+func (m *Server) resolveDocument(hash string, rc *doccache.RequestConfig) (interface{}, error) {
+	doc, err := m.loadDocument(hash, rc)
+	if err != nil || doc == nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+//loadDocument fetches the document by hash, returns nil if it does not exist
+func (m *Server) loadDocument(hash string, rc *doccache.RequestConfig) (*gqlDocument, error) {
+	err := validateEdges(m.doccache, rc.InboundEdges, true)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := m.doccache.GetByHash(hash, rc)
+	if err != nil || doc == nil {
+		return nil, err
+	}
+	return &gqlDocument{
+		doc: doc,
+		rc:  rc,
+	}, nil
+}
+
+//loadBatched fetches the document with the request config, along with the rest of the documents of its batch
+//the first time one of them requires it, returns nil if it does not exist
+func (m *Server) loadBatched(d *gqlDocument, rc *doccache.RequestConfig) (*doccache.Document, error) {
+	if d.batch == nil {
+		loaded, err := m.loadDocument(d.doc.Hash, rc)
+		if err != nil || loaded == nil {
+			return nil, err
+		}
+		return loaded.doc, nil
+	}
+	err := validateEdges(m.doccache, rc.InboundEdges, true)
+	if err != nil {
+		return nil, err
+	}
+	key := rc.String()
+	d.batch.lock.Lock()
+	defer d.batch.lock.Unlock()
+	docs, ok := d.batch.loaded[key]
+	if !ok {
+		found, err := m.doccache.GetByHashes(d.batch.hashes, rc)
+		if err != nil {
+			return nil, err
+		}
+		docs = make(map[string]*doccache.Document, len(found))
+		for _, doc := range found {
+			docs[doc.Hash] = doc
+		}
+		d.batch.loaded[key] = docs
+	}
+	return docs[d.doc.Hash], nil
+}
+
+//requestConfig builds the request config for the fields selected on the documents resolved by the fields,
+//edgeFields maps the Document edge fields to their edge names
+func requestConfig(edgeFields map[string]string, info graphql.ResolveInfo, fields []*ast.Field) *doccache.RequestConfig {
+	rc := &doccache.RequestConfig{}
+	for _, field := range fields {
+		for _, selection := range selectedFields(info, field.SelectionSet) {
+			name := selection.Name.Value
+			switch name {
+			case "contentGroups":
+				rc.ContentGroups = true
+			case "certificates":
+				rc.Certificates = true
+			default:
+				if edgeName, ok := edgeFields[name]; ok && !containsString(rc.Edges, edgeName) {
+					rc.Edges = append(rc.Edges, edgeName)
+				}
+			}
+		}
+	}
+	return rc
+}
+
+//selectedFields returns the fields of the selection set, expanding fragments
+func selectedFields(info graphql.ResolveInfo, selectionSet *ast.SelectionSet) []*ast.Field {
+	fields := make([]*ast.Field, 0)
+	if selectionSet == nil {
+		return fields
+	}
+	for _, selection := range selectionSet.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			fields = append(fields, selection)
+		case *ast.InlineFragment:
+			fields = append(fields, selectedFields(info, selection.SelectionSet)...)
+		case *ast.FragmentSpread:
+			if fragment, ok := info.Fragments[selection.Name.Value].(*ast.FragmentDefinition); ok {
+				fields = append(fields, selectedFields(info, fragment.SelectionSet)...)
+			}
+		}
+	}
+	return fields
+}
+
+//neighbours wraps the documents loaded as part of another document in a batch, nested edges are not loaded
+func neighbours(docs []*doccache.Document, rc *doccache.RequestConfig) []*gqlDocument {
+	batch := &gqlBatch{
+		hashes: make([]string, 0, len(docs)),
+		loaded: make(map[string]map[string]*doccache.Document),
+	}
+	wrapped := make([]*gqlDocument, 0, len(docs))
+	for _, doc := range docs {
+		batch.hashes = append(batch.hashes, doc.Hash)
+		wrapped = append(wrapped, &gqlDocument{
+			doc: doc,
+			rc: &doccache.RequestConfig{
+				ContentGroups: rc.ContentGroups,
+				Certificates:  rc.Certificates,
+			},
+			batch: batch,
+		})
+	}
+	return wrapped
+}
+
+//checkGraphQLLimits rejects queries that select more than MaxGraphQLComplexity fields or nest them deeper
+//than MaxGraphQLDepth, syntax errors are left to be reported by the executor
+func checkGraphQLLimits(query string) error {
+	document, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		return nil
+	}
+	cost := &graphQLCost{
+		fragments: make(map[string]*ast.FragmentDefinition),
+	}
+	for _, definition := range document.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			cost.fragments[fragment.Name.Value] = fragment
+		}
+	}
+	for _, definition := range document.Definitions {
+		operation, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		depth := cost.depth(operation.SelectionSet, make(map[string]bool))
+		if cost.fields > MaxGraphQLComplexity {
+			return badRequest("query selects more than the maximum of: %v fields", MaxGraphQLComplexity)
+		}
+		if depth > MaxGraphQLDepth {
+			return badRequest("query depth: %v exceeds the maximum: %v", depth, MaxGraphQLDepth)
+		}
+	}
+	return nil
+}
+
+//graphQLCost counts the fields selected by a query
+type graphQLCost struct {
+	fragments map[string]*ast.FragmentDefinition
+	fields    int
+}
+
+//depth returns the nesting of the selection set, counting its fields, it stops once the complexity is exceeded,
+//spreading a fragment within itself is ignored as the query is invalid
+func (m *graphQLCost) depth(selectionSet *ast.SelectionSet, spreading map[string]bool) int {
+	maxDepth := 0
+	if selectionSet == nil {
+		return maxDepth
+	}
+	for _, selection := range selectionSet.Selections {
+		if m.fields > MaxGraphQLComplexity {
+			return maxDepth
+		}
+		depth := 0
+		switch selection := selection.(type) {
+		case *ast.Field:
+			m.fields++
+			depth = m.depth(selection.SelectionSet, spreading) + 1
+		case *ast.InlineFragment:
+			depth = m.depth(selection.SelectionSet, spreading)
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+			if fragment, ok := m.fragments[name]; ok && !spreading[name] {
+				spreading[name] = true
+				depth = m.depth(fragment.SelectionSet, spreading)
+				delete(spreading, name)
+			}
+		}
+		if depth > maxDepth {
+			maxDepth = depth
+		}
+	}
+	return maxDepth
+}
+
+func stringField(value interface{}) *graphql.Field {
+	return &graphql.Field{
+		Type: graphql.String,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			switch value := value.(type) {
+			case func(*gqlDocument) string:
+				return value(p.Source.(*gqlDocument)), nil
+			case func(*doccache.ContentGroup) string:
+				return value(p.Source.(*doccache.ContentGroup)), nil
+			case func(*doccache.Content) string:
+				return value(p.Source.(*doccache.Content)), nil
+			case func(*doccache.Certificate) string:
+				return value(p.Source.(*doccache.Certificate)), nil
+			}
+			return nil, fmt.Errorf("unsupported string field resolver: %T", value)
+		},
+	}
+}
+
+func intField(value interface{}) *graphql.Field {
+	return &graphql.Field{
+		Type: graphql.Int,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			switch value := value.(type) {
+			case func(*doccache.ContentGroup) int:
+				return value(p.Source.(*doccache.ContentGroup)), nil
+			case func(*doccache.Content) int:
+				return value(p.Source.(*doccache.Content)), nil
+			case func(*doccache.Certificate) int:
+				return value(p.Source.(*doccache.Certificate)), nil
+			}
+			return nil, fmt.Errorf("unsupported int field resolver: %T", value)
+		},
+	}
+}
+
+func timeValue(value *time.Time) interface{} {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
+
+func toStrings(value interface{}) []string {
+	values, _ := value.([]interface{})
+	strs := make([]string, 0, len(values))
+	for _, value := range values {
+		if str, ok := value.(string); ok {
+			strs = append(strs, str)
+		}
+	}
+	return strs
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
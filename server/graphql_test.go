@@ -0,0 +1,243 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestGraphQLSchemaEdges(t *testing.T) {
+	server := &Server{}
+	schema, err := server.buildGraphQLSchema([]string{"member", "owned.by", "owned-by", "hash"})
+	if err != nil {
+		t.Fatalf("buildGraphQLSchema failed: %v", err)
+	}
+	result := graphql.Do(graphql.Params{
+		Schema:        schema,
+		RequestString: `{__type(name: "Document"){fields{name}}}`,
+	})
+	if len(result.Errors) > 0 {
+		t.Fatalf("Introspection failed: %v", result.Errors)
+	}
+	data, _ := json.Marshal(result.Data)
+	fields := &struct {
+		Type struct {
+			Fields []struct {
+				Name string `json:"name"`
+			} `json:"fields"`
+		} `json:"__type"`
+	}{}
+	json.Unmarshal(data, fields)
+	names := make(map[string]bool)
+	for _, field := range fields.Type.Fields {
+		names[field.Name] = true
+	}
+	for _, expected := range []string{"hash", "contentGroups", "certificates", "inbound", "member", "owned_by", "edge_hash"} {
+		if !names[expected] {
+			t.Fatalf("Expected Document to have field: %v, found: %v", expected, names)
+		}
+	}
+}
+
+func postGraphQL(t *testing.T, query string, variables map[string]interface{}, data interface{}) []map[string]interface{} {
+	t.Helper()
+	body, _ := json.Marshal(&graphQLRequest{Query: query, Variables: variables})
+	resp, err := http.Post(server.URL+"/v1/graphql", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("GraphQL request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status ok, found: %v", resp.StatusCode)
+	}
+	result := &struct {
+		Data   json.RawMessage          `json:"data"`
+		Errors []map[string]interface{} `json:"errors"`
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(result)
+	if err != nil {
+		t.Fatalf("Failed to decode GraphQL response: %v", err)
+	}
+	if len(result.Data) > 0 && data != nil {
+		err = json.Unmarshal(result.Data, data)
+		if err != nil {
+			t.Fatalf("Failed to decode GraphQL data: %v", err)
+		}
+	}
+	return result.Errors
+}
+
+type gqlTestDocument struct {
+	Hash          string `json:"hash"`
+	CreatedDate   string `json:"createdDate"`
+	ContentGroups []struct {
+		Label    string `json:"label"`
+		Contents []struct {
+			Label string `json:"label"`
+			Value string `json:"value"`
+		} `json:"contents"`
+	} `json:"contentGroups"`
+	Serveredge []*gqlTestDocument `json:"serveredge"`
+	Inbound    []*gqlTestDocument `json:"inbound"`
+}
+
+func TestGraphQLDocument(t *testing.T) {
+	data := &struct {
+		Document *gqlTestDocument `json:"document"`
+	}{}
+	errs := postGraphQL(t, `
+		query doc($hash: String!){
+			document(hash: $hash){
+				hash
+				createdDate
+				contentGroups { label contents { label value } }
+				serveredge {
+					hash
+					...nested
+				}
+			}
+		}
+		fragment nested on Document {
+			contentGroups { label }
+			inbound(edge: "serveredge") { hash }
+		}
+	`, map[string]interface{}{"hash": hashes[0]}, data)
+	if len(errs) > 0 {
+		t.Fatalf("GraphQL query failed: %v", errs)
+	}
+	doc := data.Document
+	if doc == nil || doc.Hash != hashes[0] || doc.CreatedDate != "2021-03-11T10:00:00Z" {
+		t.Fatalf("Expected document: %v, found: %v", hashes[0], doc)
+	}
+	if len(doc.ContentGroups) != 1 || doc.ContentGroups[0].Label != "details" || len(doc.ContentGroups[0].Contents) != 2 {
+		t.Fatalf("Expected details content group with 2 contents, found: %v", doc.ContentGroups)
+	}
+	if len(doc.Serveredge) != 2 {
+		t.Fatalf("Expected 2 serveredge neighbours, found: %v", doc.Serveredge)
+	}
+	for _, neighbour := range doc.Serveredge {
+		if len(neighbour.ContentGroups) != 1 || len(neighbour.Inbound) != 1 || neighbour.Inbound[0].Hash != hashes[0] {
+			t.Fatalf("Expected neighbour with content groups pointed to by: %v, found: %v", hashes[0], neighbour)
+		}
+	}
+
+	errs = postGraphQL(t, `{document(hash: "aaaa"){hash}}`, nil, data)
+	if len(errs) > 0 || data.Document != nil {
+		t.Fatalf("Expected null document, found: %v, errors: %v", data.Document, errs)
+	}
+	errs = postGraphQL(t, fmt.Sprintf(`{document(hash: "%v"){inbound(edge: "unknown"){hash}}}`, hashes[0]), nil, nil)
+	if len(errs) == 0 {
+		t.Fatalf("Expected error resolving unknown inbound edge")
+	}
+}
+
+func TestGraphQLDocuments(t *testing.T) {
+	data := &struct {
+		Documents struct {
+			Documents []*gqlTestDocument `json:"documents"`
+			Cursor    *string            `json:"cursor"`
+		} `json:"documents"`
+	}{}
+	query := `
+		query docs($after: String){
+			documents(filter: {creator: "server.test", contents: [{groupLabel: "details", label: "title"}]}, orderBy: hash, first: 2, after: $after){
+				documents { hash serveredge { hash } }
+				cursor
+			}
+		}
+	`
+	errs := postGraphQL(t, query, nil, data)
+	if len(errs) > 0 {
+		t.Fatalf("GraphQL query failed: %v", errs)
+	}
+	if len(data.Documents.Documents) != 2 || data.Documents.Cursor == nil {
+		t.Fatalf("Expected first page of 2 documents with cursor, found: %v", data.Documents)
+	}
+	found := data.Documents.Documents
+	errs = postGraphQL(t, query, map[string]interface{}{"after": *data.Documents.Cursor}, data)
+	if len(errs) > 0 {
+		t.Fatalf("GraphQL query failed: %v", errs)
+	}
+	if len(data.Documents.Documents) != 1 || data.Documents.Cursor != nil {
+		t.Fatalf("Expected last page with 1 document, found: %v", data.Documents)
+	}
+	found = append(found, data.Documents.Documents...)
+	edges := 0
+	for _, doc := range found {
+		edges += len(doc.Serveredge)
+	}
+	if edges != 2 {
+		t.Fatalf("Expected 2 serveredge edges across documents, found: %v", edges)
+	}
+
+	errs = postGraphQL(t, `{
+		documents(filter: {creator: "server.test", contents: [{label: "title", type: intValue, from: "1"}]}){
+			documents { hash }
+		}
+	}`, nil, data)
+	if len(errs) > 0 {
+		t.Fatalf("GraphQL query failed: %v", errs)
+	}
+	if len(data.Documents.Documents) != 0 {
+		t.Fatalf("Expected no documents with an int title, found: %v", data.Documents.Documents)
+	}
+	errs = postGraphQL(t, `{documents(orderBy: content, orderContent: {label: "title", type: timeValue}){cursor}}`, nil, data)
+	if len(errs) > 0 {
+		t.Fatalf("GraphQL query failed: %v", errs)
+	}
+	errs = postGraphQL(t, `{documents(orderBy: content){cursor}}`, nil, nil)
+	if len(errs) == 0 {
+		t.Fatalf("Expected error ordering by content without the content")
+	}
+
+	errs = postGraphQL(t, `{documents(first: 5000){cursor}}`, nil, nil)
+	if len(errs) == 0 {
+		t.Fatalf("Expected error for page size above the maximum")
+	}
+}
+
+func TestGraphQLLimits(t *testing.T) {
+	deep := "hash"
+	for i := 0; i < MaxGraphQLDepth; i++ {
+		deep = fmt.Sprintf("serveredge { %v }", deep)
+	}
+	wide := ""
+	for i := 0; i <= MaxGraphQLComplexity; i++ {
+		wide += fmt.Sprintf("h%v: hash ", i)
+	}
+	fragments := "fragment f10 on Document { hash }"
+	for i := 0; i < 10; i++ {
+		fragments += fmt.Sprintf("\nfragment f%v on Document { ...f%v ...g%v }\nfragment g%v on Document { ...f%v hash }", i, i+1, i, i, i+1)
+	}
+	tests := []struct {
+		query string
+		valid bool
+	}{
+		{`{document(hash: "a"){hash serveredge { hash contentGroups { contents { document { hash } } } }}}`, true},
+		{fmt.Sprintf(`{document(hash: "a"){%v}}`, deep), false},
+		{fmt.Sprintf(`{document(hash: "a"){%v}}`, wide), false},
+		{fmt.Sprintf(`{document(hash: "a"){...f0}} %v`, fragments), false},
+		{`fragment loop on Document { hash ...loop } {document(hash: "a"){...loop}}`, true},
+		{`{document(hash: `, true},
+	}
+	for _, test := range tests {
+		err := checkGraphQLLimits(test.query)
+		if test.valid != (err == nil) {
+			t.Fatalf("Expected query: %v to be valid: %v, found error: %v", test.query, test.valid, err)
+		}
+	}
+
+	body, _ := json.Marshal(&graphQLRequest{Query: fmt.Sprintf(`{document(hash: "%v"){%v}}`, hashes[0], deep)})
+	resp, err := http.Post(server.URL+"/v1/graphql", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("GraphQL request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected bad request for query above the maximum depth, found: %v", resp.StatusCode)
+	}
+}
@@ -0,0 +1,354 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/sebastianmontero/hypha-document-cache-go/doccache"
+	"github.com/sebastianmontero/hypha-document-cache-go/pb"
+	"github.com/sebastianmontero/slog-go/slog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+//MaxBatchGetSize maximum number of hashes per batch get request
+const MaxBatchGetSize = doccache.MaxSearchPageSize
+
+//GRPCServer gRPC API over the document cache
+type GRPCServer struct {
+	pb.UnimplementedDocumentCacheServer
+	doccache *doccache.Doccache
+}
+
+//NewGRPC creates a new gRPC server
+func NewGRPC(cache *doccache.Doccache, logConfig *slog.Config) *GRPCServer {
+	log = slog.New(logConfig, "server")
+	return &GRPCServer{
+		doccache: cache,
+	}
+}
+
+//Register adds the document cache service to the gRPC server
+func (m *GRPCServer) Register(s *grpc.Server) {
+	pb.RegisterDocumentCacheServer(s, m)
+}
+
+//ListenAndServe serves the gRPC API on the specified port
+func (m *GRPCServer) ListenAndServe(port uint) error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%v", port))
+	if err != nil {
+		return err
+	}
+	s := grpc.NewServer()
+	m.Register(s)
+	log.Infof("Serving gRPC API on port: %v", port)
+	return s.Serve(listener)
+}
+
+//GetDocument finds a document by hash
+func (m *GRPCServer) GetDocument(ctx context.Context, req *pb.GetDocumentRequest) (*pb.Document, error) {
+	rc, err := m.requestConfig(req.Options)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	doc, err := m.doccache.GetByHash(req.Hash, rc)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	if doc == nil {
+		return nil, status.Errorf(codes.NotFound, "document: %v not found", req.Hash)
+	}
+	return toPBDocument(doc), nil
+}
+
+//BatchGetDocuments finds documents by hash, the documents are returned in the order requested
+func (m *GRPCServer) BatchGetDocuments(ctx context.Context, req *pb.BatchGetDocumentsRequest) (*pb.BatchGetDocumentsResponse, error) {
+	if len(req.Hashes) > MaxBatchGetSize {
+		return nil, status.Errorf(codes.InvalidArgument, "number of hashes: %v exceeds the maximum: %v", len(req.Hashes), MaxBatchGetSize)
+	}
+	rc, err := m.requestConfig(req.Options)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	docs, err := m.doccache.GetByHashes(req.Hashes, rc)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	found := make(map[string]*doccache.Document, len(docs))
+	for _, doc := range docs {
+		found[doc.Hash] = doc
+	}
+	resp := &pb.BatchGetDocumentsResponse{
+		Documents:     make([]*pb.Document, 0, len(docs)),
+		MissingHashes: make([]string, 0),
+	}
+	added := make(map[string]bool, len(req.Hashes))
+	for _, hash := range req.Hashes {
+		normalized := doccache.NormalizeHash(hash)
+		if added[normalized] {
+			continue
+		}
+		added[normalized] = true
+		if doc, ok := found[normalized]; ok {
+			resp.Documents = append(resp.Documents, toPBDocument(doc))
+		} else {
+			resp.MissingHashes = append(resp.MissingHashes, hash)
+		}
+	}
+	return resp, nil
+}
+
+//SearchDocuments streams the documents that match the filters, fetching them a page at a time
+func (m *GRPCServer) SearchDocuments(req *pb.SearchDocumentsRequest, stream pb.DocumentCache_SearchDocumentsServer) error {
+	if req.Limit < 0 {
+		return status.Errorf(codes.InvalidArgument, "invalid limit: %v", req.Limit)
+	}
+	rc, err := m.requestConfig(req.Options)
+	if err != nil {
+		return grpcError(err)
+	}
+	q := &doccache.SearchQuery{
+		Creator:         req.Creator,
+		DocType:         req.DocType,
+		HasEdges:        req.HasEdges,
+		HasInboundEdges: req.HasInboundEdges,
+		OrderBy:         doccache.OrderByCreatedDate,
+		Desc:            req.Desc,
+		First:           int(req.PageSize),
+		RequestConfig:   rc,
+	}
+	if req.OrderBy == pb.SearchOrder_HASH {
+		q.OrderBy = doccache.OrderByHash
+	}
+	if req.CreatedFrom != nil {
+		createdFrom := req.CreatedFrom.AsTime()
+		q.CreatedFrom = &createdFrom
+	}
+	if req.CreatedTo != nil {
+		createdTo := req.CreatedTo.AsTime()
+		q.CreatedTo = &createdTo
+	}
+	for _, content := range req.Contents {
+		q.Contents = append(q.Contents, &doccache.ContentFilter{
+			GroupLabel: content.GroupLabel,
+			Label:      content.Label,
+			Value:      content.Value,
+		})
+	}
+	return grpcError(m.streamSearch(stream.Context(), q, int(req.Limit), func(doc *doccache.Document) error {
+		return stream.Send(toPBDocument(doc))
+	}))
+}
+
+//ExportDocuments streams all the documents ordered by hash
+func (m *GRPCServer) ExportDocuments(req *pb.ExportDocumentsRequest, stream pb.DocumentCache_ExportDocumentsServer) error {
+	rc, err := m.requestConfig(req.Options)
+	if err != nil {
+		return grpcError(err)
+	}
+	q := &doccache.SearchQuery{
+		OrderBy:       doccache.OrderByHash,
+		First:         int(req.PageSize),
+		RequestConfig: rc,
+	}
+	return grpcError(m.streamSearch(stream.Context(), q, 0, func(doc *doccache.Document) error {
+		return stream.Send(toPBDocument(doc))
+	}))
+}
+
+//ExportEdges streams the edges with the requested names, all edges if no names are specified
+func (m *GRPCServer) ExportEdges(req *pb.ExportEdgesRequest, stream pb.DocumentCache_ExportEdgesServer) error {
+	edgeNames := req.Names
+	if len(edgeNames) == 0 {
+		edgeNames = m.doccache.EdgeNames()
+	}
+	err := validateEdges(m.doccache, edgeNames, false)
+	if err != nil {
+		return grpcError(err)
+	}
+	for _, edgeName := range edgeNames {
+		q := &doccache.SearchQuery{
+			HasEdges:      []string{edgeName},
+			OrderBy:       doccache.OrderByHash,
+			First:         int(req.PageSize),
+			RequestConfig: &doccache.RequestConfig{Edges: []string{edgeName}},
+		}
+		err = m.streamSearch(stream.Context(), q, 0, func(doc *doccache.Document) error {
+			for _, to := range doc.Edges[edgeName] {
+				err := stream.Send(&pb.Edge{
+					Name: edgeName,
+					From: doc.Hash,
+					To:   to.Hash,
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return grpcError(err)
+		}
+	}
+	return nil
+}
+
+//streamSearch sends the documents that match the query page by page until there are no more pages,
+//the limit is reached or the context is done, a limit of zero sends all documents
+func (m *GRPCServer) streamSearch(ctx context.Context, q *doccache.SearchQuery, limit int, send func(*doccache.Document) error) error {
+	sent := 0
+	for {
+		err := ctx.Err()
+		if err != nil {
+			return status.FromContextError(err).Err()
+		}
+		result, err := m.doccache.Search(q)
+		if err != nil {
+			return err
+		}
+		for _, doc := range result.Docs {
+			if limit > 0 && sent >= limit {
+				return nil
+			}
+			err = send(doc)
+			if err != nil {
+				return err
+			}
+			sent++
+		}
+		if result.Cursor == "" || (limit > 0 && sent >= limit) {
+			return nil
+		}
+		q.After = result.Cursor
+	}
+}
+
+func (m *GRPCServer) requestConfig(options *pb.RequestOptions) (*doccache.RequestConfig, error) {
+	rc := &doccache.RequestConfig{}
+	if options != nil {
+		rc.ContentGroups = options.ContentGroups
+		rc.Certificates = options.Certificates
+		rc.Edges = options.Edges
+		rc.InboundEdges = options.InboundEdges
+	}
+	err := validateRequestConfig(m.doccache, rc)
+	if err != nil {
+		return nil, err
+	}
+	return rc, nil
+}
+
+//grpcError maps the error to a gRPC status error, unexpected errors are logged and reported as internal
+func grpcError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if searchErr, ok := err.(*doccache.InvalidSearchError); ok {
+		return status.Error(codes.InvalidArgument, searchErr.Reason)
+	}
+	if requestErr, ok := err.(*doccache.InvalidRequestError); ok {
+		return status.Error(codes.InvalidArgument, requestErr.Reason)
+	}
+	if httpErr, ok := err.(*httpError); ok {
+		code := codes.InvalidArgument
+		if httpErr.code == ErrorNotFound {
+			code = codes.NotFound
+		}
+		return status.Error(code, httpErr.message)
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	log.Error(err, "Failed to handle gRPC request")
+	return status.Error(codes.Internal, "internal error")
+}
+
+func toPBDocument(doc *doccache.Document) *pb.Document {
+	pbDoc := &pb.Document{
+		Hash:          doc.Hash,
+		Creator:       doc.Creator,
+		CreatedDate:   toPBTimestamp(doc.CreatedDate),
+		DocType:       doc.DocType,
+		NodeLabel:     doc.NodeLabel,
+		BallotId:      doc.BallotID,
+		Types:         doc.DType,
+		ContentGroups: make([]*pb.ContentGroup, 0, len(doc.ContentGroups)),
+		Certificates:  make([]*pb.Certificate, 0, len(doc.Certificates)),
+		Edges:         toPBEdges(doc.Edges),
+		InboundEdges:  toPBEdges(doc.InboundEdges),
+	}
+	for _, contentGroup := range doc.ContentGroups {
+		pbContentGroup := &pb.ContentGroup{
+			ContentGroupSequence: int32(contentGroup.ContentGroupSequence),
+			Contents:             make([]*pb.Content, 0, len(contentGroup.Contents)),
+		}
+		for _, content := range contentGroup.Contents {
+			pbContentGroup.Contents = append(pbContentGroup.Contents, toPBContent(content))
+		}
+		pbDoc.ContentGroups = append(pbDoc.ContentGroups, pbContentGroup)
+	}
+	for _, certificate := range doc.Certificates {
+		pbDoc.Certificates = append(pbDoc.Certificates, &pb.Certificate{
+			Certifier:             certificate.Certifier,
+			Notes:                 certificate.Notes,
+			CertificationDate:     toPBTimestamp(certificate.CertificationDate),
+			CertificationSequence: int32(certificate.CertificationSequence),
+		})
+	}
+	return pbDoc
+}
+
+func toPBContent(content *doccache.Content) *pb.Content {
+	pbContent := &pb.Content{
+		Label:           content.Label,
+		Type:            content.Type,
+		Value:           content.Value,
+		TimeValue:       toPBTimestamp(content.TimeValue),
+		NameValue:       content.NameValue,
+		ContentSequence: int32(content.ContentSequence),
+	}
+	if content.IntValue != nil {
+		pbContent.IntValue = *content.IntValue
+	}
+	if content.AssetAmount != nil {
+		pbContent.AssetValue = &pb.Asset{
+			Amount: *content.AssetAmount,
+			Symbol: content.AssetSymbol,
+		}
+		if content.AssetPrecision != nil {
+			pbContent.AssetValue.Precision = int32(*content.AssetPrecision)
+		}
+	}
+	if len(content.Document) > 0 {
+		pbContent.DocumentHash = content.Document[0].Hash
+	}
+	return pbContent
+}
+
+func toPBEdges(edges map[string][]*doccache.Document) map[string]*pb.Documents {
+	if len(edges) == 0 {
+		return nil
+	}
+	pbEdges := make(map[string]*pb.Documents, len(edges))
+	for edgeName, docs := range edges {
+		pbDocs := &pb.Documents{
+			Documents: make([]*pb.Document, 0, len(docs)),
+		}
+		for _, doc := range docs {
+			pbDocs.Documents = append(pbDocs.Documents, toPBDocument(doc))
+		}
+		pbEdges[edgeName] = pbDocs
+	}
+	return pbEdges
+}
+
+func toPBTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
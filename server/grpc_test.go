@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/sebastianmontero/hypha-document-cache-go/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func grpcClient(t *testing.T) pb.DocumentCacheClient {
+	t.Helper()
+	listener := bufconn.Listen(1024 * 1024)
+	s := grpc.NewServer()
+	NewGRPC(cache, nil).Register(s)
+	go s.Serve(listener)
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(), grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return listener.Dial()
+	}))
+	if err != nil {
+		t.Fatalf("Failed to dial gRPC server: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		s.Stop()
+	})
+	return pb.NewDocumentCacheClient(conn)
+}
+
+func TestGRPCGetDocument(t *testing.T) {
+	client := grpcClient(t)
+	doc, err := client.GetDocument(context.Background(), &pb.GetDocumentRequest{
+		Hash:    hashes[0],
+		Options: &pb.RequestOptions{ContentGroups: true, Edges: []string{"serveredge"}},
+	})
+	if err != nil {
+		t.Fatalf("GetDocument failed: %v", err)
+	}
+	if doc.Hash != hashes[0] || doc.CreatedDate == nil || len(doc.ContentGroups) != 1 || len(doc.ContentGroups[0].Contents) != 2 {
+		t.Fatalf("Expected document: %v with content groups, found: %v", hashes[0], doc)
+	}
+	if doc.Edges["serveredge"] == nil || len(doc.Edges["serveredge"].Documents) != 2 {
+		t.Fatalf("Expected document to have 2 serveredge edges, found: %v", doc.Edges)
+	}
+
+	_, err = client.GetDocument(context.Background(), &pb.GetDocumentRequest{Hash: "aaaa"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("Expected not found error, found: %v", err)
+	}
+	_, err = client.GetDocument(context.Background(), &pb.GetDocumentRequest{Hash: hashes[0], Options: &pb.RequestOptions{Edges: []string{"unknown"}}})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("Expected not found error for unknown edge, found: %v", err)
+	}
+}
+
+func TestGRPCBatchGetDocuments(t *testing.T) {
+	client := grpcClient(t)
+	resp, err := client.BatchGetDocuments(context.Background(), &pb.BatchGetDocumentsRequest{
+		Hashes: []string{hashes[2], "aaaa", hashes[0], hashes[2]},
+	})
+	if err != nil {
+		t.Fatalf("BatchGetDocuments failed: %v", err)
+	}
+	if len(resp.Documents) != 2 || resp.Documents[0].Hash != hashes[2] || resp.Documents[1].Hash != hashes[0] {
+		t.Fatalf("Expected documents in requested order, found: %v", resp.Documents)
+	}
+	if len(resp.MissingHashes) != 1 || resp.MissingHashes[0] != "aaaa" {
+		t.Fatalf("Expected missing hash: aaaa, found: %v", resp.MissingHashes)
+	}
+}
+
+func TestGRPCSearchDocuments(t *testing.T) {
+	client := grpcClient(t)
+	receive := func(req *pb.SearchDocumentsRequest) ([]*pb.Document, error) {
+		stream, err := client.SearchDocuments(context.Background(), req)
+		if err != nil {
+			return nil, err
+		}
+		docs := make([]*pb.Document, 0)
+		for {
+			doc, err := stream.Recv()
+			if err == io.EOF {
+				return docs, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			docs = append(docs, doc)
+		}
+	}
+	docs, err := receive(&pb.SearchDocumentsRequest{Creator: "server.test", OrderBy: pb.SearchOrder_HASH, PageSize: 1})
+	if err != nil {
+		t.Fatalf("SearchDocuments failed: %v", err)
+	}
+	if len(docs) != 3 || docs[0].Hash != hashes[2] {
+		t.Fatalf("Expected 3 documents streamed across pages ordered by hash, found: %v", docs)
+	}
+	docs, err = receive(&pb.SearchDocumentsRequest{Creator: "server.test", PageSize: 2, Limit: 1})
+	if err != nil {
+		t.Fatalf("SearchDocuments failed: %v", err)
+	}
+	if len(docs) != 1 || docs[0].Hash != hashes[0] {
+		t.Fatalf("Expected only the first document, found: %v", docs)
+	}
+	_, err = receive(&pb.SearchDocumentsRequest{PageSize: 5000})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Expected invalid argument for page size above the maximum, found: %v", err)
+	}
+}
+
+func TestGRPCExportEdges(t *testing.T) {
+	client := grpcClient(t)
+	stream, err := client.ExportEdges(context.Background(), &pb.ExportEdgesRequest{Names: []string{"serveredge"}, PageSize: 1})
+	if err != nil {
+		t.Fatalf("ExportEdges failed: %v", err)
+	}
+	targets := make(map[string]bool)
+	for {
+		edge, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ExportEdges failed: %v", err)
+		}
+		if edge.Name != "serveredge" || edge.From != hashes[0] {
+			t.Fatalf("Expected serveredge from: %v, found: %v", hashes[0], edge)
+		}
+		targets[edge.To] = true
+	}
+	if len(targets) != 2 || !targets[hashes[1]] || !targets[hashes[2]] {
+		t.Fatalf("Expected edges to: %v, found: %v", hashes[1:], targets)
+	}
+	stream, err = client.ExportEdges(context.Background(), &pb.ExportEdgesRequest{Names: []string{"unknown"}})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("Expected not found error for unknown edge, found: %v", err)
+	}
+}
@@ -0,0 +1,826 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sebastianmontero/hypha-document-cache-go/doccache"
+	"github.com/sebastianmontero/slog-go/slog"
+)
+
+var log *slog.Log
+
+//APIPrefix prefix of all the routes
+const APIPrefix = "/v1"
+
+//Error codes
+const (
+	ErrorBadRequest       = "bad_request"
+	ErrorNotFound         = "not_found"
+	ErrorMethodNotAllowed = "method_not_allowed"
+	ErrorInternal         = "internal"
+	ErrorUnauthorized     = "unauthorized"
+	ErrorForbidden        = "forbidden"
+	//ErrorGone the events after the cursor are no longer available
+	ErrorGone = "gone"
+)
+
+//Error describes why a request failed
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+//ErrorResponse body of failed requests
+type ErrorResponse struct {
+	Error *Error `json:"error"`
+}
+
+//DocumentResponse body of the document route
+type DocumentResponse struct {
+	Document map[string]interface{} `json:"document"`
+}
+
+//DocumentsResponse body of the routes that return a list of documents, Cursor is only set if there are more pages
+type DocumentsResponse struct {
+	Documents []map[string]interface{} `json:"documents"`
+	Cursor    string                   `json:"cursor,omitempty"`
+}
+
+//RevisionsResponse body of the route that lists the revisions of a document
+type RevisionsResponse struct {
+	Revisions []*doccache.Revision `json:"revisions"`
+}
+
+//RevisionResponse body of the revision route
+type RevisionResponse struct {
+	Revision *doccache.Revision `json:"revision"`
+}
+
+//RevisionDiffResponse body of the route that compares two revisions of a document
+type RevisionDiffResponse struct {
+	Diff *doccache.RevisionDiff `json:"diff"`
+}
+
+//PathsResponse body of the paths route, Connected indicates if there is a path between the documents
+type PathsResponse struct {
+	Connected bool             `json:"connected"`
+	Paths     []*doccache.Path `json:"paths"`
+}
+
+//ContentSearchResponse body of the content search route, Cursor is only set if there are more pages
+type ContentSearchResponse struct {
+	Hits   []*doccache.ContentSearchHit `json:"hits"`
+	Cursor string                       `json:"cursor,omitempty"`
+}
+
+//WebhooksResponse body of the webhooks admin route
+type WebhooksResponse struct {
+	Webhooks []*doccache.WebhookStatus `json:"webhooks"`
+}
+
+//DeliveriesResponse body of the webhook deliveries admin route
+type DeliveriesResponse struct {
+	Deliveries []*doccache.WebhookDelivery `json:"deliveries"`
+}
+
+//CursorResponse body of the cursor route
+type CursorResponse struct {
+	Cursor string `json:"cursor"`
+}
+
+//StatusResponse body of the status route
+type StatusResponse struct {
+	Cursor              string   `json:"cursor"`
+	SchemaVersion       int      `json:"schema_version"`
+	LatestSchemaVersion int      `json:"latest_schema_version"`
+	Edges               []string `json:"edges"`
+}
+
+//httpError error with the status code and error code to respond with
+type httpError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (m *httpError) Error() string {
+	return m.message
+}
+
+func badRequest(format string, a ...interface{}) error {
+	return &httpError{status: http.StatusBadRequest, code: ErrorBadRequest, message: fmt.Sprintf(format, a...)}
+}
+
+func notFound(format string, a ...interface{}) error {
+	return &httpError{status: http.StatusNotFound, code: ErrorNotFound, message: fmt.Sprintf(format, a...)}
+}
+
+//Server HTTP/JSON API over the document cache
+type Server struct {
+	doccache      *doccache.Doccache
+	mux           *http.ServeMux
+	graphQLSchema *graphQLSchema
+	schemaLock    sync.Mutex
+	adminToken    string
+}
+
+//New creates a new server
+func New(cache *doccache.Doccache, logConfig *slog.Config) *Server {
+	log = slog.New(logConfig, "server")
+	m := &Server{
+		doccache: cache,
+		mux:      http.NewServeMux(),
+	}
+	m.mux.HandleFunc(APIPrefix+"/documents", m.handle(m.search))
+	m.mux.HandleFunc(APIPrefix+"/documents/", m.handle(m.document))
+	m.mux.HandleFunc(APIPrefix+"/paths", m.handle(m.paths))
+	m.mux.HandleFunc(APIPrefix+"/graph", m.graph)
+	m.mux.HandleFunc(APIPrefix+"/search/contents", m.handle(m.searchContents))
+	m.mux.HandleFunc(APIPrefix+"/events", m.events)
+	m.mux.HandleFunc(APIPrefix+"/events/ws", m.eventsWebSocket)
+	m.mux.HandleFunc(APIPrefix+"/admin/webhooks", m.handle(m.admin(m.webhooks)))
+	m.mux.HandleFunc(APIPrefix+"/admin/webhooks/", m.handle(m.admin(m.deliveries)))
+	m.mux.HandleFunc(APIPrefix+"/cursor", m.handle(m.cursor))
+	m.mux.HandleFunc(APIPrefix+"/status", m.handle(m.status))
+	m.mux.HandleFunc(APIPrefix+"/graphql", m.graphQL)
+	return m
+}
+
+//SetAdminToken sets the bearer token required by the admin routes, the admin routes are disabled while it is empty
+func (m *Server) SetAdminToken(token string) {
+	m.adminToken = token
+}
+
+//ServeHTTP routes the request
+func (m *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mux.ServeHTTP(w, r)
+}
+
+//ListenAndServe serves the API on the specified port
+func (m *Server) ListenAndServe(port uint) error {
+	log.Infof("Serving API on port: %v", port)
+	return http.ListenAndServe(fmt.Sprintf(":%v", port), m)
+}
+
+//admin only runs the handler if the request has the admin token as its bearer token
+func (m *Server) admin(handler func(r *http.Request) (interface{}, error)) func(r *http.Request) (interface{}, error) {
+	return func(r *http.Request) (interface{}, error) {
+		if m.adminToken == "" {
+			return nil, &httpError{status: http.StatusForbidden, code: ErrorForbidden, message: "admin routes are disabled, no admin token configured"}
+		}
+		authorization := r.Header.Get("Authorization")
+		if !strings.HasPrefix(authorization, "Bearer ") ||
+			subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(authorization, "Bearer ")), []byte(m.adminToken)) != 1 {
+			return nil, &httpError{status: http.StatusUnauthorized, code: ErrorUnauthorized, message: "invalid admin token"}
+		}
+		return handler(r)
+	}
+}
+
+//handle only accepts GET requests and writes the response or the error as JSON
+func (m *Server) handle(handler func(r *http.Request) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeJSON(w, http.StatusMethodNotAllowed, &ErrorResponse{
+				Error: &Error{Code: ErrorMethodNotAllowed, Message: fmt.Sprintf("method: %v not allowed", r.Method)},
+			})
+			return
+		}
+		response, err := handler(r)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, response)
+	}
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	if searchErr, ok := err.(*doccache.InvalidSearchError); ok {
+		err = badRequest("%v", searchErr.Reason)
+	}
+	if requestErr, ok := err.(*doccache.InvalidRequestError); ok {
+		err = badRequest("%v", requestErr.Reason)
+	}
+	if notFoundErr, ok := err.(*doccache.DocumentNotFoundError); ok {
+		err = notFound("%v", notFoundErr.Error())
+	}
+	if httpErr, ok := err.(*httpError); ok {
+		writeJSON(w, httpErr.status, &ErrorResponse{
+			Error: &Error{Code: httpErr.code, Message: httpErr.message},
+		})
+		return
+	}
+	log.Errorf(err, "Failed to handle request: %v", r.URL)
+	writeJSON(w, http.StatusInternalServerError, &ErrorResponse{
+		Error: &Error{Code: ErrorInternal, Message: "internal error"},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(body)
+	if err != nil {
+		log.Error(err, "Failed to write response")
+	}
+}
+
+//document routes /documents/{hash}, /documents/{hash}/edges/{name} and /documents/{hash}/inbound/{name}
+func (m *Server) document(r *http.Request) (interface{}, error) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, APIPrefix+"/documents/"), "/")
+	rc, err := parseRequestConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	asOfBlock, err := parseBlockNum(r, "as_of_block")
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case len(parts) == 1 && parts[0] != "":
+		return m.getDocument(parts[0], asOfBlock, rc)
+	case len(parts) == 3 && parts[0] != "" && parts[2] != "" && (parts[1] == "edges" || parts[1] == "inbound"):
+		return m.getNeighbours(parts[0], parts[2], parts[1] == "inbound", asOfBlock, rc)
+	case len(parts) == 2 && parts[0] != "" && parts[1] == "revisions":
+		revisions, err := m.doccache.GetRevisions(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		return &RevisionsResponse{Revisions: revisions}, nil
+	case len(parts) == 3 && parts[0] != "" && parts[1] == "revisions" && parts[2] == "diff":
+		return m.diffRevisions(r, parts[0])
+	case len(parts) == 3 && parts[0] != "" && parts[1] == "revisions" && parts[2] != "":
+		number, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return nil, badRequest("invalid revision: %v", parts[2])
+		}
+		revision, err := m.doccache.GetRevision(parts[0], number)
+		if err != nil {
+			return nil, err
+		}
+		return &RevisionResponse{Revision: revision}, nil
+	}
+	return nil, notFound("route: %v not found", r.URL.Path)
+}
+
+//getByHashAsMap finds the document as it is, or as it was at the end of the block if asOfBlock is set
+func (m *Server) getByHashAsMap(hash string, asOfBlock uint64, rc *doccache.RequestConfig) (map[string]interface{}, error) {
+	if asOfBlock > 0 {
+		return m.doccache.GetByHashAsOfAsMap(hash, asOfBlock, rc)
+	}
+	return m.doccache.GetByHashAsMap(hash, rc)
+}
+
+func (m *Server) getDocument(hash string, asOfBlock uint64, rc *doccache.RequestConfig) (*DocumentResponse, error) {
+	err := validateRequestConfig(m.doccache, rc)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := m.getByHashAsMap(hash, asOfBlock, rc)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, notFound("document: %v not found", hash)
+	}
+	return &DocumentResponse{
+		Document: doc,
+	}, nil
+}
+
+//getNeighbours returns the documents connected to the document through the edge, the request config applies to the neighbours
+func (m *Server) getNeighbours(hash, edgeName string, inbound bool, asOfBlock uint64, rc *doccache.RequestConfig) (*DocumentsResponse, error) {
+	err := validateEdges(m.doccache, []string{edgeName}, inbound)
+	if err != nil {
+		return nil, err
+	}
+	key := edgeName
+	edgeRequest := &doccache.RequestConfig{
+		ContentGroups: rc.ContentGroups,
+		Certificates:  rc.Certificates,
+		Edges:         []string{edgeName},
+	}
+	if inbound {
+		key = doccache.InboundEdgeAlias(edgeName)
+		edgeRequest.Edges = nil
+		edgeRequest.InboundEdges = []string{edgeName}
+	}
+	doc, err := m.getByHashAsMap(hash, asOfBlock, edgeRequest)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, notFound("document: %v not found", hash)
+	}
+	neighbours := make([]map[string]interface{}, 0)
+	if targets, ok := doc[key].([]interface{}); ok {
+		for _, target := range targets {
+			if neighbour, ok := target.(map[string]interface{}); ok {
+				neighbours = append(neighbours, neighbour)
+			}
+		}
+	}
+	return &DocumentsResponse{
+		Documents: neighbours,
+	}, nil
+}
+
+//diffRevisions compares the from and to revisions, the last revision and the one before it by default
+func (m *Server) diffRevisions(r *http.Request, hash string) (*RevisionDiffResponse, error) {
+	from, err := parseInt(r, "from")
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseInt(r, "to")
+	if err != nil {
+		return nil, err
+	}
+	diff, err := m.doccache.DiffRevisions(hash, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return &RevisionDiffResponse{Diff: diff}, nil
+}
+
+//validateRequestConfig checks that the requested edges and the edges of the nested traversals exist,
+//and that the traversal aliases are valid
+func validateRequestConfig(cache *doccache.Doccache, rc *doccache.RequestConfig) error {
+	err := validateEdges(cache, rc.Edges, false)
+	if err != nil {
+		return err
+	}
+	err = validateEdges(cache, rc.InboundEdges, true)
+	if err != nil {
+		return err
+	}
+	for _, traversal := range rc.Traversals {
+		if traversal.Alias != "" {
+			err = doccache.ValidateEdgeAlias(traversal.Alias)
+			if err != nil {
+				return err
+			}
+		}
+		err = validateEdges(cache, []string{traversal.Name}, traversal.Inbound)
+		if err != nil {
+			return err
+		}
+		if traversal.Request != nil {
+			err = validateRequestConfig(cache, traversal.Request)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateEdges(cache *doccache.Doccache, edgeNames []string, inbound bool) error {
+	for _, edgeName := range edgeNames {
+		edge := cache.GetEdgeDefinition(edgeName)
+		if edge == nil {
+			return notFound("edge: %v not found", edgeName)
+		}
+		if inbound && !edge.Reverse {
+			return badRequest("edge: %v does not have reverse indexing enabled", edgeName)
+		}
+	}
+	return nil
+}
+
+func (m *Server) search(r *http.Request) (interface{}, error) {
+	q, err := parseSearchQuery(r)
+	if err != nil {
+		return nil, err
+	}
+	err = validateRequestConfig(m.doccache, q.RequestConfig)
+	if err != nil {
+		return nil, err
+	}
+	result, err := m.doccache.SearchAsMap(q)
+	if err != nil {
+		return nil, err
+	}
+	docs := result.Docs
+	if docs == nil {
+		docs = make([]map[string]interface{}, 0)
+	}
+	return &DocumentsResponse{
+		Documents: docs,
+		Cursor:    result.Cursor,
+	}, nil
+}
+
+//paths finds the shortest paths between the from and to documents, optionally restricted to the edges
+func (m *Server) paths(r *http.Request) (interface{}, error) {
+	params := r.URL.Query()
+	from, to := params.Get("from"), params.Get("to")
+	if from == "" || to == "" {
+		return nil, badRequest("from and to are required")
+	}
+	undirected, err := parseBool(r, "undirected")
+	if err != nil {
+		return nil, err
+	}
+	contentGroups, err := parseBool(r, "content_groups")
+	if err != nil {
+		return nil, err
+	}
+	certificates, err := parseBool(r, "certificates")
+	if err != nil {
+		return nil, err
+	}
+	q := &doccache.PathQuery{
+		From:       from,
+		To:         to,
+		Edges:      parseList(r, "edges"),
+		Undirected: undirected,
+		RequestConfig: &doccache.RequestConfig{
+			ContentGroups: contentGroups,
+			Certificates:  certificates,
+		},
+	}
+	q.NumPaths, err = parseInt(r, "num_paths")
+	if err != nil {
+		return nil, err
+	}
+	q.Depth, err = parseInt(r, "depth")
+	if err != nil {
+		return nil, err
+	}
+	err = validateEdges(m.doccache, q.Edges, false)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := m.doccache.ShortestPaths(q)
+	if err != nil {
+		return nil, err
+	}
+	return &PathsResponse{
+		Connected: len(paths) > 0,
+		Paths:     paths,
+	}, nil
+}
+
+//graph writes the subgraph around the root document as Graphviz DOT or GraphML
+func (m *Server) graph(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSON(w, http.StatusMethodNotAllowed, &ErrorResponse{
+			Error: &Error{Code: ErrorMethodNotAllowed, Message: fmt.Sprintf("method: %v not allowed", r.Method)},
+		})
+		return
+	}
+	subgraph, format, err := m.subgraph(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", format.ContentType())
+	w.WriteHeader(http.StatusOK)
+	err = doccache.WriteGraph(w, subgraph, format)
+	if err != nil {
+		log.Errorf(err, "Failed to write graph for request: %v", r.URL)
+	}
+}
+
+func (m *Server) subgraph(r *http.Request) (*doccache.Subgraph, doccache.GraphFormat, error) {
+	params := r.URL.Query()
+	root := params.Get("root")
+	if root == "" {
+		return nil, "", badRequest("root is required")
+	}
+	format, err := doccache.ParseGraphFormat(params.Get("format"))
+	if err != nil {
+		return nil, "", err
+	}
+	undirected, err := parseBool(r, "undirected")
+	if err != nil {
+		return nil, "", err
+	}
+	q := &doccache.SubgraphQuery{
+		Root:       root,
+		Edges:      parseList(r, "edges"),
+		Undirected: undirected,
+	}
+	q.Depth, err = parseInt(r, "depth")
+	if err != nil {
+		return nil, "", err
+	}
+	err = validateEdges(m.doccache, q.Edges, false)
+	if err != nil {
+		return nil, "", err
+	}
+	subgraph, err := m.doccache.Subgraph(q)
+	if err != nil {
+		return nil, "", err
+	}
+	return subgraph, format, nil
+}
+
+//searchContents finds the documents with contents that match the text and highlights the matches
+func (m *Server) searchContents(r *http.Request) (interface{}, error) {
+	params := r.URL.Query()
+	caseInsensitive, err := parseBool(r, "case_insensitive")
+	if err != nil {
+		return nil, err
+	}
+	q := &doccache.ContentSearchQuery{
+		Text:            params.Get("q"),
+		Match:           doccache.ContentMatchMode(params.Get("match")),
+		Predicate:       params.Get("predicate"),
+		Labels:          parseList(r, "labels"),
+		CaseInsensitive: caseInsensitive,
+		HighlightPre:    params.Get("highlight_pre"),
+		HighlightPost:   params.Get("highlight_post"),
+		After:           params.Get("after"),
+	}
+	q.Distance, err = parseInt(r, "distance")
+	if err != nil {
+		return nil, err
+	}
+	q.First, err = parseInt(r, "first")
+	if err != nil {
+		return nil, err
+	}
+	q.RequestConfig, err = parseRequestConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	err = validateRequestConfig(m.doccache, q.RequestConfig)
+	if err != nil {
+		return nil, err
+	}
+	result, err := m.doccache.SearchContents(q)
+	if err != nil {
+		return nil, err
+	}
+	return &ContentSearchResponse{
+		Hits:   result.Hits,
+		Cursor: result.Cursor,
+	}, nil
+}
+
+//webhooks lists the registered webhooks with the number of deliveries by status
+func (m *Server) webhooks(r *http.Request) (interface{}, error) {
+	webhooks, err := m.doccache.GetWebhookStatuses()
+	if err != nil {
+		return nil, err
+	}
+	return &WebhooksResponse{
+		Webhooks: webhooks,
+	}, nil
+}
+
+//deliveries lists the most recent deliveries of a webhook, optionally filtered by status
+func (m *Server) deliveries(r *http.Request) (interface{}, error) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, APIPrefix+"/admin/webhooks/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] != "deliveries" {
+		return nil, notFound("route: %v not found", r.URL.Path)
+	}
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", doccache.DeliveryPending, doccache.DeliveryDelivered, doccache.DeliveryFailed:
+	default:
+		return nil, badRequest("invalid status: %v", status)
+	}
+	first, err := parseInt(r, "first")
+	if err != nil {
+		return nil, err
+	}
+	webhook, err := m.doccache.GetWebhook(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	if webhook == nil {
+		return nil, notFound("webhook: %v not found", parts[0])
+	}
+	deliveries, err := m.doccache.GetDeliveries(parts[0], status, first)
+	if err != nil {
+		return nil, err
+	}
+	return &DeliveriesResponse{
+		Deliveries: deliveries,
+	}, nil
+}
+
+func (m *Server) cursor(r *http.Request) (interface{}, error) {
+	cursor, err := m.doccache.StoredCursor()
+	if err != nil {
+		return nil, err
+	}
+	return &CursorResponse{
+		Cursor: cursor.Cursor,
+	}, nil
+}
+
+func (m *Server) status(r *http.Request) (interface{}, error) {
+	cursor, err := m.doccache.StoredCursor()
+	if err != nil {
+		return nil, err
+	}
+	schemaVersion, err := m.doccache.GetSchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	return &StatusResponse{
+		Cursor:              cursor.Cursor,
+		SchemaVersion:       schemaVersion,
+		LatestSchemaVersion: doccache.LatestSchemaVersion(),
+		Edges:               m.doccache.EdgeNames(),
+	}, nil
+}
+
+//parseRequestConfig reads the content_groups, certificates, edges and inbound_edges query parameters,
+//traverse is a JSON list of edge requests
+func parseRequestConfig(r *http.Request) (*doccache.RequestConfig, error) {
+	contentGroups, err := parseBool(r, "content_groups")
+	if err != nil {
+		return nil, err
+	}
+	certificates, err := parseBool(r, "certificates")
+	if err != nil {
+		return nil, err
+	}
+	rc := &doccache.RequestConfig{
+		ContentGroups: contentGroups,
+		Certificates:  certificates,
+		Edges:         parseList(r, "edges"),
+		InboundEdges:  parseList(r, "inbound_edges"),
+	}
+	if traverse := r.URL.Query().Get("traverse"); traverse != "" {
+		err = json.Unmarshal([]byte(traverse), &rc.Traversals)
+		if err != nil {
+			return nil, badRequest("invalid traverse: %v", err)
+		}
+	}
+	return rc, nil
+}
+
+//parseSearchQuery reads the search filters from the query parameters, content filters have the form [group:]label=value
+func parseSearchQuery(r *http.Request) (*doccache.SearchQuery, error) {
+	params := r.URL.Query()
+	rc, err := parseRequestConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	q := &doccache.SearchQuery{
+		Creator:         params.Get("creator"),
+		DocType:         params.Get("doc_type"),
+		HasEdges:        parseList(r, "has_edge"),
+		HasInboundEdges: parseList(r, "has_inbound_edge"),
+		OrderBy:         doccache.SearchOrder(params.Get("order_by")),
+		After:           params.Get("after"),
+		RequestConfig:   rc,
+	}
+	for _, param := range []string{"created_from", "created_to"} {
+		value := params.Get(param)
+		if value == "" {
+			continue
+		}
+		createdDate, err := doccache.ParseTime(value)
+		if err != nil {
+			return nil, badRequest("invalid %v: %v", param, value)
+		}
+		if param == "created_from" {
+			q.CreatedFrom = createdDate
+		} else {
+			q.CreatedTo = createdDate
+		}
+	}
+	for _, content := range params["content"] {
+		labelValue := strings.SplitN(content, "=", 2)
+		if len(labelValue) != 2 {
+			return nil, badRequest("invalid content filter: %v, expected [group:]label=value", content)
+		}
+		filter := &doccache.ContentFilter{
+			Value: labelValue[1],
+		}
+		filter.GroupLabel, filter.Label = splitGroupLabel(labelValue[0])
+		q.Contents = append(q.Contents, filter)
+	}
+	for _, contentRange := range params["content_range"] {
+		filter, err := parseContentRange(contentRange)
+		if err != nil {
+			return nil, err
+		}
+		q.Contents = append(q.Contents, filter)
+	}
+	if orderLabel := params.Get("order_label"); orderLabel != "" || q.OrderBy == doccache.OrderByContent {
+		q.OrderContent = &doccache.ContentOrder{
+			Type: doccache.TypedValue(params.Get("order_type")),
+		}
+		q.OrderContent.GroupLabel, q.OrderContent.Label = splitGroupLabel(orderLabel)
+	}
+	q.Desc, err = parseBool(r, "desc")
+	if err != nil {
+		return nil, err
+	}
+	q.AsOfBlock, err = parseBlockNum(r, "as_of_block")
+	if err != nil {
+		return nil, err
+	}
+	if first := params.Get("first"); first != "" {
+		q.First, err = strconv.Atoi(first)
+		if err != nil || q.First < 1 {
+			return nil, badRequest("invalid first: %v", first)
+		}
+	}
+	return q, nil
+}
+
+//parseContentRange parses a content range filter: [group:]label=type:from..to, the type of assets
+//can be followed by the symbol: asset_amount:SYMBOL:from..to, either bound can be empty
+func parseContentRange(contentRange string) (*doccache.ContentFilter, error) {
+	invalid := badRequest("invalid content range: %v, expected [group:]label=type[:symbol]:from..to", contentRange)
+	labelRange := strings.SplitN(contentRange, "=", 2)
+	if len(labelRange) != 2 {
+		return nil, invalid
+	}
+	typeBounds := strings.SplitN(labelRange[1], ":", 2)
+	if len(typeBounds) != 2 {
+		return nil, invalid
+	}
+	filter := &doccache.ContentFilter{
+		Type: doccache.TypedValue(typeBounds[0]),
+	}
+	filter.GroupLabel, filter.Label = splitGroupLabel(labelRange[0])
+	bounds := typeBounds[1]
+	if filter.Type == doccache.TypedAsset {
+		if symbolBounds := strings.SplitN(bounds, ":", 2); len(symbolBounds) == 2 {
+			filter.Symbol = symbolBounds[0]
+			bounds = symbolBounds[1]
+		}
+	}
+	fromTo := strings.SplitN(bounds, "..", 2)
+	if len(fromTo) != 2 {
+		return nil, invalid
+	}
+	filter.From, filter.To = fromTo[0], fromTo[1]
+	return filter, nil
+}
+
+//splitGroupLabel splits a [group:]label into the group label and the label
+func splitGroupLabel(value string) (string, string) {
+	if groupLabel := strings.SplitN(value, ":", 2); len(groupLabel) == 2 {
+		return groupLabel[0], groupLabel[1]
+	}
+	return "", value
+}
+
+func parseBool(r *http.Request, param string) (bool, error) {
+	value := r.URL.Query().Get(param)
+	if value == "" {
+		return false, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, badRequest("invalid %v: %v", param, value)
+	}
+	return parsed, nil
+}
+
+func parseInt(r *http.Request, param string) (int, error) {
+	value := r.URL.Query().Get(param)
+	if value == "" {
+		return 0, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 1 {
+		return 0, badRequest("invalid %v: %v", param, value)
+	}
+	return parsed, nil
+}
+
+//parseBlockNum reads a block number parameter, 0 if not set
+func parseBlockNum(r *http.Request, param string) (uint64, error) {
+	value := r.URL.Query().Get(param)
+	if value == "" {
+		return 0, nil
+	}
+	parsed, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || parsed < 1 {
+		return 0, badRequest("invalid %v: %v", param, value)
+	}
+	return parsed, nil
+}
+
+//parseList reads a list parameter that can be repeated or comma separated
+func parseList(r *http.Request, param string) []string {
+	list := make([]string, 0)
+	for _, value := range r.URL.Query()[param] {
+		for _, item := range strings.Split(value, ",") {
+			if item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
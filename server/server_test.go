@@ -0,0 +1,473 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sebastianmontero/dgraph-go-client/dgraph"
+	"github.com/sebastianmontero/hypha-document-cache-go/doccache"
+)
+
+var dg *dgraph.Dgraph
+var cache *doccache.Doccache
+var server *httptest.Server
+
+const adminToken = "servertoken"
+
+var hashes = []string{
+	"e1d0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c11",
+	"f2d0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c12",
+	"a3d0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c13",
+}
+
+func TestMain(m *testing.M) {
+	beforeAll()
+	retCode := m.Run()
+	afterAll()
+	os.Exit(retCode)
+}
+
+func beforeAll() {
+	var err error
+	dg, err = dgraph.New("")
+	if err != nil {
+		panic(fmt.Sprintf("Unable to create dgraph: %v", err))
+	}
+	cache, err = doccache.New(dg, nil)
+	if err != nil {
+		panic(fmt.Sprintf("Failed creating docCache: %v", err))
+	}
+	for i, hash := range hashes {
+		err = cache.StoreDocument(&doccache.ChainDocument{
+			Hash:        hash,
+			CreatedDate: fmt.Sprintf("2021-03-1%vT10:00:00", i+1),
+			Creator:     "server.test",
+			ContentGroups: [][]*doccache.ChainContent{
+				{
+					{
+						Label: "content_group_label",
+						Value: []interface{}{"string", "details"},
+					},
+					{
+						Label: "title",
+						Value: []interface{}{"string", fmt.Sprintf("title%v", i)},
+					},
+				},
+			},
+		}, fmt.Sprintf("server%v", i))
+		if err != nil {
+			panic(fmt.Sprintf("StoreDocument failed: %v", err))
+		}
+	}
+	for _, hash := range hashes[1:] {
+		err = cache.MutateEdge(&doccache.ChainEdge{Name: "serveredge", From: hashes[0], To: hash}, false, "server3")
+		if err != nil {
+			panic(fmt.Sprintf("MutateEdge failed: %v", err))
+		}
+	}
+	apiServer := New(cache, nil)
+	apiServer.SetAdminToken(adminToken)
+	server = httptest.NewServer(apiServer)
+}
+
+func afterAll() {
+	server.Close()
+	dg.Close()
+}
+
+func get(t *testing.T, path string, expectedStatus int, body interface{}) {
+	t.Helper()
+	getWithToken(t, path, "", expectedStatus, body)
+}
+
+func getWithToken(t *testing.T, path, token string, expectedStatus int, body interface{}) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request to: %v, error: %v", path, err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request to: %v failed: %v", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != expectedStatus {
+		t.Fatalf("Expected status: %v for: %v, found: %v", expectedStatus, path, resp.StatusCode)
+	}
+	if resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("Expected json content type for: %v, found: %v", path, resp.Header.Get("Content-Type"))
+	}
+	err = json.NewDecoder(resp.Body).Decode(body)
+	if err != nil {
+		t.Fatalf("Failed to decode response of: %v, error: %v", path, err)
+	}
+}
+
+func TestDocument(t *testing.T) {
+	response := &DocumentResponse{}
+	get(t, fmt.Sprintf("/v1/documents/%v?content_groups=true&edges=serveredge", hashes[0]), http.StatusOK, response)
+	if response.Document["hash"] != hashes[0] || response.Document["content_groups"] == nil {
+		t.Fatalf("Expected document: %v with content groups, found: %v", hashes[0], response.Document)
+	}
+	if edges, ok := response.Document["serveredge"].([]interface{}); !ok || len(edges) != 2 {
+		t.Fatalf("Expected document to have 2 serveredge edges, found: %v", response.Document)
+	}
+
+	errorResponse := &ErrorResponse{}
+	get(t, "/v1/documents/aaaa", http.StatusNotFound, errorResponse)
+	if errorResponse.Error.Code != ErrorNotFound {
+		t.Fatalf("Expected not found error, found: %v", errorResponse.Error)
+	}
+	get(t, fmt.Sprintf("/v1/documents/%v?content_groups=yes", hashes[0]), http.StatusBadRequest, errorResponse)
+	if errorResponse.Error.Code != ErrorBadRequest {
+		t.Fatalf("Expected bad request error, found: %v", errorResponse.Error)
+	}
+	get(t, fmt.Sprintf("/v1/documents/%v?edges=unknown", hashes[0]), http.StatusNotFound, errorResponse)
+
+	traverse := url.QueryEscape(`[{"name":"serveredge","alias":"targets","first":1,"count":true}]`)
+	get(t, fmt.Sprintf("/v1/documents/%v?traverse=%v", hashes[0], traverse), http.StatusOK, response)
+	if targets, ok := response.Document["targets"].([]interface{}); !ok || len(targets) != 1 || response.Document["count.targets"] != float64(2) {
+		t.Fatalf("Expected 1 of 2 serveredge targets, found: %v", response.Document)
+	}
+	for _, traverse := range []string{"invalid", `[{"name":"serveredge","depth":100}]`} {
+		get(t, fmt.Sprintf("/v1/documents/%v?traverse=%v", hashes[0], url.QueryEscape(traverse)), http.StatusBadRequest, errorResponse)
+	}
+	get(t, fmt.Sprintf("/v1/documents/%v?traverse=%v", hashes[0], url.QueryEscape(`[{"name":"unknown"}]`)), http.StatusNotFound, errorResponse)
+	for _, alias := range []string{
+		`x: webhook_secret } secrets(func: has(webhook_secret)) { webhook_secret } y: <edge.serveredge> {`,
+		"hash",
+		"uid",
+		"count.targets",
+		"inbound.serveredge",
+		"1targets",
+	} {
+		traverse, _ := json.Marshal([]*doccache.EdgeRequest{{Name: "serveredge", Alias: alias}})
+		get(t, fmt.Sprintf("/v1/documents/%v?traverse=%v", hashes[0], url.QueryEscape(string(traverse))), http.StatusBadRequest, errorResponse)
+		if errorResponse.Error.Code != ErrorBadRequest {
+			t.Fatalf("Expected bad request error for alias: %v, found: %v", alias, errorResponse.Error)
+		}
+	}
+
+	resp, err := http.Post(server.URL+"/v1/documents/"+hashes[0], "application/json", nil)
+	if err != nil {
+		t.Fatalf("Post failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("Expected method not allowed, found: %v", resp.StatusCode)
+	}
+}
+
+func TestNeighbours(t *testing.T) {
+	response := &DocumentsResponse{}
+	get(t, fmt.Sprintf("/v1/documents/%v/edges/serveredge?content_groups=true", hashes[0]), http.StatusOK, response)
+	if len(response.Documents) != 2 || response.Documents[0]["content_groups"] == nil {
+		t.Fatalf("Expected 2 outgoing neighbours with content groups, found: %v", response.Documents)
+	}
+	get(t, fmt.Sprintf("/v1/documents/%v/inbound/serveredge", hashes[2]), http.StatusOK, response)
+	if len(response.Documents) != 1 || response.Documents[0]["hash"] != hashes[0] {
+		t.Fatalf("Expected inbound neighbour: %v, found: %v", hashes[0], response.Documents)
+	}
+	get(t, fmt.Sprintf("/v1/documents/%v/edges/serveredge", hashes[2]), http.StatusOK, response)
+	if len(response.Documents) != 0 {
+		t.Fatalf("Expected no outgoing neighbours, found: %v", response.Documents)
+	}
+	errorResponse := &ErrorResponse{}
+	get(t, fmt.Sprintf("/v1/documents/%v/sideways/serveredge", hashes[2]), http.StatusNotFound, errorResponse)
+}
+
+func TestDocumentAsOfBlock(t *testing.T) {
+	hash := "b4d0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c14"
+	historyStart := cache.Cursor.HistoryStart
+	defer cache.SetBlockNum(cache.Cursor.BlockNum)
+	cache.SetBlockNum(historyStart + 20)
+	err := cache.StoreDocument(&doccache.ChainDocument{
+		Hash:        hash,
+		CreatedDate: "2021-03-20T10:00:00",
+		Creator:     "server.asof",
+		ContentGroups: [][]*doccache.ChainContent{
+			{
+				{
+					Label: "content_group_label",
+					Value: []interface{}{"string", "details"},
+				},
+			},
+		},
+	}, "serverasof")
+	if err != nil {
+		t.Fatalf("StoreDocument failed: %v", err)
+	}
+
+	response := &DocumentResponse{}
+	get(t, fmt.Sprintf("/v1/documents/%v?as_of_block=%v&content_groups=true", hash, historyStart+30), http.StatusOK, response)
+	if response.Document["hash"] != hash || response.Document["content_groups"] == nil {
+		t.Fatalf("Expected document: %v as of block: %v, found: %v", hash, historyStart+30, response.Document)
+	}
+	errorResponse := &ErrorResponse{}
+	get(t, fmt.Sprintf("/v1/documents/%v?as_of_block=%v", hash, historyStart+19), http.StatusNotFound, errorResponse)
+	get(t, fmt.Sprintf("/v1/documents/%v?as_of_block=latest", hash), http.StatusBadRequest, errorResponse)
+
+	cache.Cursor.HistoryStart = historyStart + 10
+	defer func() { cache.Cursor.HistoryStart = historyStart }()
+	get(t, fmt.Sprintf("/v1/documents/%v?as_of_block=%v", hash, historyStart+9), http.StatusBadRequest, errorResponse)
+	get(t, fmt.Sprintf("/v1/documents?creator=server.asof&as_of_block=%v", historyStart+9), http.StatusBadRequest, errorResponse)
+}
+
+func TestRevisions(t *testing.T) {
+	response := &RevisionsResponse{}
+	get(t, fmt.Sprintf("/v1/documents/%v/revisions", hashes[0]), http.StatusOK, response)
+	if len(response.Revisions) == 0 || response.Revisions[0].Number != 1 || response.Revisions[0].Hash != hashes[0] {
+		t.Fatalf("Expected revisions of document: %v, found: %v", hashes[0], response.Revisions)
+	}
+	revisionResponse := &RevisionResponse{}
+	get(t, fmt.Sprintf("/v1/documents/%v/revisions/1", hashes[0]), http.StatusOK, revisionResponse)
+	if revisionResponse.Revision.Document == nil || revisionResponse.Revision.Document.Hash != hashes[0] {
+		t.Fatalf("Expected first revision of document: %v, found: %v", hashes[0], revisionResponse.Revision)
+	}
+	diffResponse := &RevisionDiffResponse{}
+	get(t, fmt.Sprintf("/v1/documents/%v/revisions/diff?from=1&to=1", hashes[0]), http.StatusOK, diffResponse)
+	if len(diffResponse.Diff.ContentGroups) != 0 || len(diffResponse.Diff.Certificates) != 0 {
+		t.Fatalf("Expected no differences comparing a revision with itself, found: %v", diffResponse.Diff)
+	}
+	errorResponse := &ErrorResponse{}
+	for _, path := range []string{"revisions/first", "revisions/0", "revisions/diff?from=x", "revisions/diff?from=1&to=1000"} {
+		get(t, fmt.Sprintf("/v1/documents/%v/%v", hashes[0], path), http.StatusBadRequest, errorResponse)
+	}
+	get(t, "/v1/documents/aaaa/revisions", http.StatusNotFound, errorResponse)
+}
+
+func TestSearch(t *testing.T) {
+	response := &DocumentsResponse{}
+	get(t, "/v1/documents?creator=server.test&first=2", http.StatusOK, response)
+	if len(response.Documents) != 2 || response.Documents[0]["hash"] != hashes[0] || response.Cursor == "" {
+		t.Fatalf("Expected first page of 2 documents with cursor, found: %v", response)
+	}
+	get(t, "/v1/documents?creator=server.test&first=2&after="+response.Cursor, http.StatusOK, response)
+	if len(response.Documents) != 1 || response.Documents[0]["hash"] != hashes[2] || response.Cursor != "" {
+		t.Fatalf("Expected last page with document: %v, found: %v", hashes[2], response)
+	}
+	get(t, "/v1/documents?creator=server.test&content=details:title=title1", http.StatusOK, response)
+	if len(response.Documents) != 1 || response.Documents[0]["hash"] != hashes[1] {
+		t.Fatalf("Expected document: %v, found: %v", hashes[1], response.Documents)
+	}
+	get(t, "/v1/documents?creator=server.test&created_from=2021-03-12T00:00:00Z&has_inbound_edge=serveredge&desc=true", http.StatusOK, response)
+	if len(response.Documents) != 2 || response.Documents[0]["hash"] != hashes[2] {
+		t.Fatalf("Expected 2 documents in descending order, found: %v", response.Documents)
+	}
+	get(t, "/v1/documents?creator=nobody", http.StatusOK, response)
+	if response.Documents == nil || len(response.Documents) != 0 {
+		t.Fatalf("Expected empty document list, found: %v", response.Documents)
+	}
+
+	t.Log("Filtering and ordering by typed content")
+	ranked := []string{
+		"e1a0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c21",
+		"e2a0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c22",
+		"e3a0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c23",
+	}
+	for i, hash := range ranked {
+		err := cache.StoreDocument(&doccache.ChainDocument{
+			Hash:        hash,
+			CreatedDate: fmt.Sprintf("2021-03-2%vT10:00:00", i+1),
+			Creator:     "server.ranked",
+			ContentGroups: [][]*doccache.ChainContent{
+				{
+					{
+						Label: "content_group_label",
+						Value: []interface{}{"string", "details"},
+					},
+					{
+						Label: "rank",
+						Value: []interface{}{"int64", float64(3 - i)},
+					},
+					{
+						Label: "budget",
+						Value: []interface{}{"asset", fmt.Sprintf("%v.00 USD", (i+1)*100)},
+					},
+				},
+			},
+		}, fmt.Sprintf("ranked%v", i))
+		if err != nil {
+			t.Fatalf("StoreDocument failed: %v", err)
+		}
+	}
+	get(t, "/v1/documents?creator=server.ranked&content_range=details:rank=int_value:2..", http.StatusOK, response)
+	if len(response.Documents) != 2 || response.Documents[0]["hash"] != ranked[0] || response.Documents[1]["hash"] != ranked[1] {
+		t.Fatalf("Expected documents with rank of at least 2, found: %v", response.Documents)
+	}
+	get(t, "/v1/documents?creator=server.ranked&content_range=budget=asset_amount:USD:150..300", http.StatusOK, response)
+	if len(response.Documents) != 1 || response.Documents[0]["hash"] != ranked[1] {
+		t.Fatalf("Expected document: %v, found: %v", ranked[1], response.Documents)
+	}
+	get(t, "/v1/documents?creator=server.ranked&order_by=content&order_label=details:rank&order_type=int_value&first=2", http.StatusOK, response)
+	if len(response.Documents) != 2 || response.Documents[0]["hash"] != ranked[2] || response.Documents[1]["hash"] != ranked[1] || response.Cursor == "" {
+		t.Fatalf("Expected first page ordered by rank with cursor, found: %v", response)
+	}
+	get(t, "/v1/documents?creator=server.ranked&order_by=content&order_label=details:rank&order_type=int_value&first=2&after="+response.Cursor, http.StatusOK, response)
+	if len(response.Documents) != 1 || response.Documents[0]["hash"] != ranked[0] || response.Cursor != "" {
+		t.Fatalf("Expected last page with document: %v, found: %v", ranked[0], response)
+	}
+
+	errorResponse := &ErrorResponse{}
+	for _, query := range []string{
+		"order_by=creator", "first=0", "after=invalid", "content=novalue", "created_to=yesterday", "has_edge=unknown", "as_of_block=0",
+		"content_range=rank", "content_range=rank=int_value:2", "content_range=rank=float_value:1..2", "content_range=rank=int_value:..",
+		"order_by=content", "order_by=content&order_label=rank&order_type=name_value",
+	} {
+		get(t, "/v1/documents?"+query, http.StatusBadRequest, errorResponse)
+		if errorResponse.Error.Code != ErrorBadRequest {
+			t.Fatalf("Expected bad request error for: %v, found: %v", query, errorResponse.Error)
+		}
+	}
+}
+
+func TestSearchContents(t *testing.T) {
+	response := &ContentSearchResponse{}
+	get(t, "/v1/search/contents?q=title1&match=terms&labels=title", http.StatusOK, response)
+	if len(response.Hits) != 1 || response.Hits[0].Document.Hash != hashes[1] || response.Hits[0].Matches[0].Highlighted != "<em>title1</em>" {
+		t.Fatalf("Expected highlighted match in document: %v, found: %v", hashes[1], response.Hits)
+	}
+	errorResponse := &ErrorResponse{}
+	for _, query := range []string{"match=terms", "q=title1&match=alloftext", "q=title1&match=terms&distance=x", "q=title1&match=terms&after=invalid"} {
+		get(t, "/v1/search/contents?"+query, http.StatusBadRequest, errorResponse)
+	}
+}
+
+func TestPaths(t *testing.T) {
+	response := &PathsResponse{}
+	get(t, fmt.Sprintf("/v1/paths?from=%v&to=%v&edges=serveredge", hashes[0], hashes[1]), http.StatusOK, response)
+	if !response.Connected || len(response.Paths) != 1 || len(response.Paths[0].Steps) != 2 || response.Paths[0].Steps[1].Edge != "serveredge" {
+		t.Fatalf("Expected single hop path through serveredge, found: %v", response.Paths)
+	}
+	get(t, fmt.Sprintf("/v1/paths?from=%v&to=%v&edges=serveredge", hashes[1], hashes[2]), http.StatusOK, response)
+	if response.Connected || len(response.Paths) != 0 {
+		t.Fatalf("Expected documents not to be connected, found: %v", response.Paths)
+	}
+	get(t, fmt.Sprintf("/v1/paths?from=%v&to=%v&edges=serveredge&undirected=true&content_groups=true", hashes[1], hashes[2]), http.StatusOK, response)
+	if !response.Connected || len(response.Paths[0].Steps) != 3 || response.Paths[0].Steps[1].Document.Hash != hashes[0] || len(response.Paths[0].Steps[1].Document.ContentGroups) != 1 {
+		t.Fatalf("Expected undirected path through: %v, found: %v", hashes[0], response.Paths)
+	}
+
+	errorResponse := &ErrorResponse{}
+	for _, query := range []string{
+		"from=" + hashes[0], fmt.Sprintf("from=%v&to=%v&num_paths=0", hashes[0], hashes[1]), fmt.Sprintf("from=%v&to=%v&depth=100", hashes[0], hashes[1]),
+		fmt.Sprintf("from=%v&to=aaaa", hashes[0]), fmt.Sprintf("from=%v&to=%v", hashes[0], url.QueryEscape(`a"]) OR has(hash`)),
+	} {
+		get(t, "/v1/paths?"+query, http.StatusBadRequest, errorResponse)
+	}
+	get(t, fmt.Sprintf("/v1/paths?from=%v&to=%v", hashes[0], strings.Repeat("a", 64)), http.StatusNotFound, errorResponse)
+	get(t, fmt.Sprintf("/v1/paths?from=%v&to=%v&edges=unknown", hashes[0], hashes[1]), http.StatusNotFound, errorResponse)
+}
+
+func TestGraph(t *testing.T) {
+	path := fmt.Sprintf("/v1/graph?root=%v&edges=serveredge&depth=1", hashes[0])
+	resp, err := http.Get(server.URL + path)
+	if err != nil {
+		t.Fatalf("Request to: %v failed: %v", path, err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("Failed to read response of: %v, error: %v", path, err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/vnd.graphviz" {
+		t.Fatalf("Expected dot graph for: %v, found status: %v, content type: %v", path, resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	if !strings.HasPrefix(string(body), "digraph") || !strings.Contains(string(body), fmt.Sprintf("%q -> %q [label=\"serveredge\"]", hashes[0], hashes[1])) {
+		t.Fatalf("Expected serveredge in dot graph, found: %v", string(body))
+	}
+	resp, err = http.Get(server.URL + path + "&format=graphml")
+	if err != nil {
+		t.Fatalf("Request to: %v failed: %v", path, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/graphml+xml" {
+		t.Fatalf("Expected graphml for: %v, found status: %v, content type: %v", path, resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	errorResponse := &ErrorResponse{}
+	for _, query := range []string{
+		"depth=1", fmt.Sprintf("root=%v&format=png", hashes[0]), fmt.Sprintf("root=%v&depth=100", hashes[0]),
+		"root=aaaa", "root=" + url.QueryEscape(`a"]) OR has(hash`),
+	} {
+		get(t, "/v1/graph?"+query, http.StatusBadRequest, errorResponse)
+	}
+	get(t, "/v1/graph?root="+strings.Repeat("a", 64), http.StatusNotFound, errorResponse)
+	get(t, fmt.Sprintf("/v1/graph?root=%v&edges=unknown", hashes[0]), http.StatusNotFound, errorResponse)
+}
+
+func TestWebhooks(t *testing.T) {
+	_, err := cache.SaveWebhook("serverhook", "http://localhost:1/hook", "secret", &doccache.EventFilter{Edges: []string{"serverhookedge"}})
+	if err != nil {
+		t.Fatalf("SaveWebhook failed: %v", err)
+	}
+	defer cache.RemoveWebhook("serverhook")
+	err = cache.MutateEdge(&doccache.ChainEdge{Name: "serverhookedge", From: hashes[1], To: hashes[2]}, false, "serverhook0")
+	if err != nil {
+		t.Fatalf("MutateEdge failed: %v", err)
+	}
+
+	errorResponse := &ErrorResponse{}
+	get(t, "/v1/admin/webhooks", http.StatusUnauthorized, errorResponse)
+	getWithToken(t, "/v1/admin/webhooks", "wrongtoken", http.StatusUnauthorized, errorResponse)
+	get(t, "/v1/admin/webhooks/serverhook/deliveries", http.StatusUnauthorized, errorResponse)
+
+	response := &WebhooksResponse{}
+	getWithToken(t, "/v1/admin/webhooks", adminToken, http.StatusOK, response)
+	var status *doccache.WebhookStatus
+	for _, webhook := range response.Webhooks {
+		if webhook.Name == "serverhook" {
+			status = webhook
+		}
+	}
+	if status == nil || status.Pending != 1 || status.Delivered != 0 {
+		t.Fatalf("Expected serverhook with 1 pending delivery, found: %v", response.Webhooks)
+	}
+	deliveriesResponse := &DeliveriesResponse{}
+	getWithToken(t, "/v1/admin/webhooks/serverhook/deliveries?status=pending", adminToken, http.StatusOK, deliveriesResponse)
+	if len(deliveriesResponse.Deliveries) != 1 || deliveriesResponse.Deliveries[0].EventType != doccache.EventEdgeCreated {
+		t.Fatalf("Expected pending edge created delivery, found: %v", deliveriesResponse.Deliveries)
+	}
+
+	getWithToken(t, "/v1/admin/webhooks/serverhook/deliveries?status=lost", adminToken, http.StatusBadRequest, errorResponse)
+	getWithToken(t, "/v1/admin/webhooks/unknown/deliveries", adminToken, http.StatusNotFound, errorResponse)
+	getWithToken(t, "/v1/admin/webhooks/serverhook", adminToken, http.StatusNotFound, errorResponse)
+}
+
+func TestAdminDisabled(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	New(cache, nil).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1/admin/webhooks", nil))
+	if recorder.Code != http.StatusForbidden {
+		t.Fatalf("Expected admin routes to be disabled without an admin token, found status: %v", recorder.Code)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	response := &StatusResponse{}
+	get(t, "/v1/status", http.StatusOK, response)
+	if response.Cursor == "" || response.SchemaVersion != doccache.LatestSchemaVersion() {
+		t.Fatalf("Expected status with cursor at latest schema version, found: %v", response)
+	}
+	found := false
+	for _, edge := range response.Edges {
+		found = found || edge == "serveredge"
+	}
+	if !found {
+		t.Fatalf("Expected status to list edge: serveredge, found: %v", response.Edges)
+	}
+	cursorResponse := &CursorResponse{}
+	get(t, "/v1/cursor", http.StatusOK, cursorResponse)
+	if cursorResponse.Cursor == "" {
+		t.Fatalf("Expected cursor to be set, found: %v", cursorResponse)
+	}
+}
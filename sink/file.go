@@ -0,0 +1,142 @@
+package sink
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sebastianmontero/hypha-document-cache-go/doccache"
+)
+
+//DefaultFileMaxSize size after which the events file is rotated when not configured
+const DefaultFileMaxSize = 100 * 1024 * 1024
+
+//DefaultFileMaxFiles number of rotated files kept when not configured
+const DefaultFileMaxFiles = 10
+
+//currentFileName name of the file events are appended to, rotated files are named events-<timestamp>.jsonl
+const currentFileName = "events.jsonl"
+
+//FileSink appends each event as a JSON line to a file in the directory, syncing it to disk
+//before returning, the file is rotated when it exceeds the max size
+type FileSink struct {
+	dir      string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+	lock     sync.Mutex
+}
+
+//NewFileSink creates a file sink writing to the directory
+func NewFileSink(dir string, maxSize int64, maxFiles int) (*FileSink, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultFileMaxSize
+	}
+	if maxFiles <= 0 {
+		maxFiles = DefaultFileMaxFiles
+	}
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	m := &FileSink{
+		dir:      dir,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+	}
+	err = m.open()
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+//Name of the sink
+func (m *FileSink) Name() string {
+	return "file=" + m.dir
+}
+
+func (m *FileSink) open() error {
+	file, err := os.OpenFile(filepath.Join(m.dir, currentFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	m.file = file
+	m.size = info.Size()
+	return nil
+}
+
+//Write appends the event and syncs the file
+func (m *FileSink) Write(event *doccache.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.size > 0 && m.size+int64(len(data)) > m.maxSize {
+		err = m.rotate()
+		if err != nil {
+			return err
+		}
+	}
+	n, err := m.file.Write(data)
+	m.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return m.file.Sync()
+}
+
+//rotate renames the current file and removes the oldest rotated files beyond the max files
+func (m *FileSink) rotate() error {
+	err := m.file.Close()
+	if err != nil {
+		return err
+	}
+	rotated := fmt.Sprintf("events-%v.jsonl", time.Now().UTC().Format("20060102T150405.000000000"))
+	log.Infof("Rotating events file to: %v", rotated)
+	err = os.Rename(filepath.Join(m.dir, currentFileName), filepath.Join(m.dir, rotated))
+	if err != nil {
+		return err
+	}
+	files, err := ioutil.ReadDir(m.dir)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0)
+	for _, file := range files {
+		if strings.HasPrefix(file.Name(), "events-") && strings.HasSuffix(file.Name(), ".jsonl") {
+			names = append(names, file.Name())
+		}
+	}
+	sort.Strings(names)
+	for len(names) > m.maxFiles {
+		err = os.Remove(filepath.Join(m.dir, names[0]))
+		if err != nil {
+			return err
+		}
+		names = names[1:]
+	}
+	return m.open()
+}
+
+//Close closes the current file
+func (m *FileSink) Close() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.file.Close()
+}
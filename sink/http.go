@@ -0,0 +1,87 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/sebastianmontero/hypha-document-cache-go/doccache"
+)
+
+//DefaultHTTPMaxAttempts number of times a post is attempted when not configured
+const DefaultHTTPMaxAttempts = 5
+
+//httpTimeout timeout of each post
+const httpTimeout = 10 * time.Second
+
+//httpRetryWait wait after the first failed post, doubled after each failure
+const httpRetryWait = time.Second
+
+//HTTPSink posts each event as JSON to the url, any 2xx response acknowledges the event
+type HTTPSink struct {
+	url         string
+	maxAttempts int
+	retryWait   time.Duration
+	client      *http.Client
+}
+
+//NewHTTPSink creates a sink that posts to the url
+func NewHTTPSink(url string, maxAttempts int) *HTTPSink {
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultHTTPMaxAttempts
+	}
+	return &HTTPSink{
+		url:         url,
+		maxAttempts: maxAttempts,
+		retryWait:   httpRetryWait,
+		client:      &http.Client{Timeout: httpTimeout},
+	}
+}
+
+//Name of the sink
+func (m *HTTPSink) Name() string {
+	return "http=" + m.url
+}
+
+//Write posts the event, retrying with exponential backoff up to the max attempts
+func (m *HTTPSink) Write(event *doccache.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	wait := m.retryWait
+	for attempt := 1; ; attempt++ {
+		err = m.post(body)
+		if err == nil {
+			return nil
+		}
+		if attempt >= m.maxAttempts {
+			return err
+		}
+		log.Infof("Post to: %v failed: %v, attempt: %v, retrying in: %v", m.url, err, attempt, wait)
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+func (m *HTTPSink) post(body []byte) error {
+	response, err := m.client.Post(m.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("post to: %v responded with status: %v", m.url, response.StatusCode)
+	}
+	return nil
+}
+
+//Close does nothing as posts are not buffered
+func (m *HTTPSink) Close() error {
+	return nil
+}
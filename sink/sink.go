@@ -0,0 +1,65 @@
+package sink
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sebastianmontero/hypha-document-cache-go/doccache"
+	"github.com/sebastianmontero/slog-go/slog"
+)
+
+var log = slog.New(nil, "sink")
+
+//Config settings of the built-in sinks, zero values are replaced by the defaults
+type Config struct {
+	//FileMaxSize size in bytes after which the events file is rotated
+	FileMaxSize int64
+	//FileMaxFiles number of rotated files kept
+	FileMaxFiles int
+	//HTTPMaxAttempts number of times a post is attempted before the write fails
+	HTTPMaxAttempts int
+}
+
+func (m *Config) String() string {
+	return fmt.Sprintf("Config{FileMaxSize: %v, FileMaxFiles: %v, HTTPMaxAttempts: %v}", m.FileMaxSize, m.FileMaxFiles, m.HTTPMaxAttempts)
+}
+
+//Parse creates the sinks of the spec, a semicolon separated list of stdout, file=<directory>
+//and http=<url>, e.g. stdout;file=/data/events;http=http://analytics:8080/events
+func Parse(spec string, config *Config, logConfig *slog.Config) ([]doccache.EventSink, error) {
+	log = slog.New(logConfig, "sink")
+	if config == nil {
+		config = &Config{}
+	}
+	sinks := make([]doccache.EventSink, 0)
+	for _, sinkSpec := range strings.Split(spec, ";") {
+		sinkSpec = strings.TrimSpace(sinkSpec)
+		if sinkSpec == "" {
+			continue
+		}
+		kindTarget := strings.SplitN(sinkSpec, "=", 2)
+		var (
+			sink doccache.EventSink
+			err  error
+		)
+		switch {
+		case kindTarget[0] == "stdout" && len(kindTarget) == 1:
+			sink = NewWriterSink("stdout", os.Stdout)
+		case kindTarget[0] == "file" && len(kindTarget) == 2:
+			sink, err = NewFileSink(kindTarget[1], config.FileMaxSize, config.FileMaxFiles)
+		case kindTarget[0] == "http" && len(kindTarget) == 2:
+			sink = NewHTTPSink(kindTarget[1], config.HTTPMaxAttempts)
+		default:
+			err = fmt.Errorf("invalid sink: %v, expected stdout, file=<directory> or http=<url>", sinkSpec)
+		}
+		if err != nil {
+			for _, sink := range sinks {
+				sink.Close()
+			}
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
@@ -0,0 +1,138 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sebastianmontero/hypha-document-cache-go/doccache"
+)
+
+func newEvent(hash string) *doccache.Event {
+	return &doccache.Event{
+		Type:        doccache.EventDocumentCreated,
+		ChainCursor: "cursor-" + hash,
+		Document:    &doccache.EventDocument{Hash: hash, DocType: "payout"},
+	}
+}
+
+func TestParse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sinks")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	sinks, err := Parse("stdout; file="+dir+";http=http://localhost:1/events", nil, nil)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	names := make([]string, 0)
+	for _, sink := range sinks {
+		names = append(names, sink.Name())
+		sink.Close()
+	}
+	if strings.Join(names, ";") != "stdout;file="+dir+";http=http://localhost:1/events" {
+		t.Fatalf("Unexpected sinks: %v", names)
+	}
+	for _, spec := range []string{"stdout=1", "file", "kafka=localhost"} {
+		_, err = Parse(spec, nil, nil)
+		if err == nil {
+			t.Fatalf("Expected error for spec: %v", spec)
+		}
+	}
+}
+
+func TestWriterSink(t *testing.T) {
+	var buffer bytes.Buffer
+	sink := NewWriterSink("buffer", &buffer)
+	for _, hash := range []string{"a", "b"} {
+		err := sink.Write(newEvent(hash))
+		if err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	event := &doccache.Event{}
+	err := json.Unmarshal([]byte(lines[1]), event)
+	if len(lines) != 2 || err != nil || event.Document.Hash != "b" {
+		t.Fatalf("Expected 2 json lines, found: %v, error: %v", lines, err)
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filesink")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	line, _ := json.Marshal(newEvent("a"))
+	sink, err := NewFileSink(dir, int64(len(line)+1)*2, 2)
+	if err != nil {
+		t.Fatalf("NewFileSink failed: %v", err)
+	}
+	for _, hash := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		err = sink.Write(newEvent(hash))
+		if err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	sink.Close()
+	rotated, _ := filepath.Glob(filepath.Join(dir, "events-*.jsonl"))
+	if len(rotated) != 2 {
+		t.Fatalf("Expected 2 rotated files, found: %v", rotated)
+	}
+	current, err := ioutil.ReadFile(filepath.Join(dir, currentFileName))
+	if err != nil || !strings.Contains(string(current), `"hash":"g"`) || strings.Count(string(current), "\n") != 1 {
+		t.Fatalf("Expected current file to contain the last event, found: %v, error: %v", string(current), err)
+	}
+
+	sink, err = NewFileSink(dir, 0, 0)
+	if err != nil {
+		t.Fatalf("NewFileSink failed: %v", err)
+	}
+	defer sink.Close()
+	err = sink.Write(newEvent("h"))
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	current, _ = ioutil.ReadFile(filepath.Join(dir, currentFileName))
+	if strings.Count(string(current), "\n") != 2 {
+		t.Fatalf("Expected reopened file to be appended to, found: %v", string(current))
+	}
+}
+
+func TestHTTPSink(t *testing.T) {
+	var lock sync.Mutex
+	failures := 2
+	received := make([]*doccache.Event, 0)
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		event := &doccache.Event{}
+		json.NewDecoder(r.Body).Decode(event)
+		received = append(received, event)
+	}))
+	defer endpoint.Close()
+
+	sink := NewHTTPSink(endpoint.URL, 3)
+	sink.retryWait = time.Millisecond
+	err := sink.Write(newEvent("a"))
+	if err != nil || len(received) != 1 || received[0].ChainCursor != "cursor-a" {
+		t.Fatalf("Expected event to be posted after retries, found: %v, error: %v", received, err)
+	}
+	failures = 3
+	err = sink.Write(newEvent("b"))
+	if err == nil || len(received) != 1 {
+		t.Fatalf("Expected write to fail after max attempts, found: %v", received)
+	}
+}
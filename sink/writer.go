@@ -0,0 +1,41 @@
+package sink
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/sebastianmontero/hypha-document-cache-go/doccache"
+)
+
+//WriterSink writes each event as a JSON line
+type WriterSink struct {
+	name    string
+	encoder *json.Encoder
+	lock    sync.Mutex
+}
+
+//NewWriterSink creates a sink that writes to w
+func NewWriterSink(name string, w io.Writer) *WriterSink {
+	return &WriterSink{
+		name:    name,
+		encoder: json.NewEncoder(w),
+	}
+}
+
+//Name of the sink
+func (m *WriterSink) Name() string {
+	return m.name
+}
+
+//Write encodes the event
+func (m *WriterSink) Write(event *doccache.Event) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.encoder.Encode(event)
+}
+
+//Close does nothing as the writer is owned by the caller
+func (m *WriterSink) Close() error {
+	return nil
+}
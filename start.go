@@ -1,7 +1,7 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"os"
 	"strconv"
@@ -13,8 +13,12 @@ import (
 	"github.com/sebastianmontero/dfuse-firehose-client/dfclient"
 	"github.com/sebastianmontero/dgraph-go-client/dgraph"
 	"github.com/sebastianmontero/hypha-document-cache-go/doccache"
+	"github.com/sebastianmontero/hypha-document-cache-go/mirror"
 	"github.com/sebastianmontero/hypha-document-cache-go/monitoring"
 	"github.com/sebastianmontero/hypha-document-cache-go/monitoring/metrics"
+	"github.com/sebastianmontero/hypha-document-cache-go/server"
+	"github.com/sebastianmontero/hypha-document-cache-go/sink"
+	"github.com/sebastianmontero/hypha-document-cache-go/webhook"
 	"github.com/sebastianmontero/slog-go/slog"
 )
 
@@ -26,34 +30,51 @@ var (
 )
 
 type deltaStreamHandler struct {
-	cursor   string
-	doccache *doccache.Doccache
+	cursor      string
+	doccache    *doccache.Doccache
+	mirror      *mirror.Mirror
+	errorPolicy doccache.ErrorPolicy
 }
 
 func (m *deltaStreamHandler) OnDelta(delta *dfclient.TableDelta, cursor string, forkStep pbbstream.ForkStep) {
 	log.Debugf("On Delta: \nCursor: %v \nFork Step: %v \nDelta %v ", cursor, forkStep, delta)
+	m.doccache.SetBlockNum(uint64(delta.Block.Number))
+	m.doccache.SetTrxID(transactionID(delta))
 	if delta.TableName == docTable {
-		chainDoc := &doccache.ChainDocument{}
 		switch delta.Operation {
 		case pbcodec.DBOp_OPERATION_INSERT, pbcodec.DBOp_OPERATION_UPDATE:
-			err := json.Unmarshal(delta.NewData, chainDoc)
+			chainDoc, err := doccache.DecodeChainDocument(delta.NewData)
 			if err != nil {
-				log.Panicf(err, "Error unmarshalling doc new data: %v", string(delta.NewData))
+				m.onInvalidRow(delta, delta.NewData, err, cursor)
+				return
 			}
-			log.Tracef("Storing doc: ", chainDoc)
+			log.Tracef("Storing doc: %v", chainDoc)
 			err = m.doccache.StoreDocument(chainDoc, cursor)
 			if err != nil {
-				log.Panicf(err, "Failed to store doc: %", chainDoc)
+				log.Panicf(err, "Failed to store doc: %v", chainDoc)
+			}
+			if m.mirror != nil {
+				err = m.mirror.StoreDocument(chainDoc, cursor)
+				if err != nil {
+					log.Panicf(err, "Failed to store doc in mirror: %v", chainDoc)
+				}
 			}
 			metrics.CreatedDocs.Inc()
 		case pbcodec.DBOp_OPERATION_REMOVE:
-			err := json.Unmarshal(delta.OldData, chainDoc)
+			chainDoc, err := doccache.DecodeChainDocument(delta.OldData)
 			if err != nil {
-				log.Panicf(err, "Error unmarshalling doc old data: %v", string(delta.OldData))
+				m.onInvalidRow(delta, delta.OldData, err, cursor)
+				return
 			}
 			err = m.doccache.DeleteDocument(chainDoc, cursor)
 			if err != nil {
-				log.Panicf(err, "Failed to delete doc: ", chainDoc)
+				log.Panicf(err, "Failed to delete doc: %v", chainDoc)
+			}
+			if m.mirror != nil {
+				err = m.mirror.DeleteDocument(chainDoc, cursor)
+				if err != nil {
+					log.Panicf(err, "Failed to delete doc from mirror: %v", chainDoc)
+				}
 			}
 			metrics.DeletedDocs.Inc()
 		}
@@ -64,20 +85,27 @@ func (m *deltaStreamHandler) OnDelta(delta *dfclient.TableDelta, cursor string,
 				deltaData []byte
 				deleteOp  bool
 			)
-			chainEdge := &doccache.ChainEdge{}
 			if delta.Operation == pbcodec.DBOp_OPERATION_INSERT {
 				deltaData = delta.NewData
 			} else {
 				deltaData = delta.OldData
 				deleteOp = true
 			}
-			err := json.Unmarshal(deltaData, chainEdge)
+			chainEdge, err := doccache.DecodeChainEdge(deltaData)
 			if err != nil {
-				log.Panicf(err, "Error unmarshalling edge data: ", chainEdge)
+				m.onInvalidRow(delta, deltaData, err, cursor)
+				return
 			}
 			err = m.doccache.MutateEdge(chainEdge, deleteOp, cursor)
-			if err != nil {
-				log.Errorf(err, "Failed to mutate doc, deleteOp: %v, edge: %v", deleteOp, chainEdge)
+			if _, ok := err.(*doccache.DocumentNotFoundError); ok {
+				log.Errorf(err, "Failed to mutate edge, deleteOp: %v, edge: %v", deleteOp, chainEdge)
+			} else if err != nil {
+				log.Panicf(err, "Failed to mutate edge, deleteOp: %v, edge: %v", deleteOp, chainEdge)
+			} else if m.mirror != nil {
+				err = m.mirror.MutateEdge(chainEdge, deleteOp, cursor)
+				if err != nil {
+					log.Panicf(err, "Failed to mutate edge in mirror, deleteOp: %v, edge: %v", deleteOp, chainEdge)
+				}
 			}
 			if deleteOp {
 				metrics.DeletedEdges.Inc()
@@ -93,14 +121,63 @@ func (m *deltaStreamHandler) OnDelta(delta *dfclient.TableDelta, cursor string,
 	m.cursor = cursor
 }
 
+//transactionID finds the transaction that contains the table delta, empty if it is not found
+func transactionID(delta *dfclient.TableDelta) string {
+	for _, trace := range delta.Block.TransactionTraces() {
+		for _, dbOp := range trace.DbOps {
+			if dbOp == delta.DBOp {
+				return trace.Id
+			}
+		}
+	}
+	return ""
+}
+
+func (m *deltaStreamHandler) onInvalidRow(delta *dfclient.TableDelta, data []byte, err error, cursor string) {
+	metrics.InvalidRows.Inc()
+	switch m.errorPolicy {
+	case doccache.ErrorPolicySkip:
+		log.Errorf(err, "Skipping invalid row of table: %v, data: %v", delta.TableName, string(data))
+	case doccache.ErrorPolicyQuarantine:
+		qErr := m.doccache.QuarantineRow(&doccache.InvalidRow{
+			TableName:        delta.TableName,
+			Operation:        delta.Operation.String(),
+			RowData:          string(data),
+			ValidationErrors: err.Error(),
+			BlockNum:         uint64(delta.Block.Number),
+		}, cursor)
+		if qErr != nil {
+			log.Panicf(qErr, "Failed to quarantine row: %v", string(data))
+		}
+		m.updateMirrorCursor(cursor)
+	default:
+		log.Panicf(err, "Invalid row of table: %v, data: %v", delta.TableName, string(data))
+	}
+	metrics.BlockNumber.Set(float64(delta.Block.Number))
+	m.cursor = cursor
+}
+
 func (m *deltaStreamHandler) OnHeartBeat(block *pbcodec.Block, cursor string) {
+	m.doccache.SetBlockNum(uint64(block.Number))
 	err := m.doccache.UpdateCursor(cursor)
 	if err != nil {
-		log.Panicf(err, "Failed to update cursor: ", cursor)
+		log.Panicf(err, "Failed to update cursor: %v", cursor)
 	}
+	m.updateMirrorCursor(cursor)
 	metrics.BlockNumber.Set(float64(block.Number))
 }
 
+//updateMirrorCursor keeps the mirror cursor in step with the doccache cursor for changes that do not modify the mirror
+func (m *deltaStreamHandler) updateMirrorCursor(cursor string) {
+	if m.mirror == nil {
+		return
+	}
+	err := m.mirror.UpdateCursor(cursor)
+	if err != nil {
+		log.Panicf(err, "Failed to update mirror cursor: %v", cursor)
+	}
+}
+
 func (m *deltaStreamHandler) OnError(err error) {
 	log.Error(err, "On Error")
 }
@@ -131,6 +208,110 @@ func main() {
 		log.Panicf(err, "Unable to parse prometheus port: %v", os.Getenv("PROMETHEUS_PORT"))
 	}
 
+	errorPolicy, err := doccache.ParseErrorPolicy(os.Getenv("INVALID_ROW_POLICY"))
+	if err != nil {
+		log.Panicf(err, "Unable to parse invalid row policy: %v", os.Getenv("INVALID_ROW_POLICY"))
+	}
+
+	kindTypes := false
+	if os.Getenv("DOCUMENT_KIND_TYPES") != "" {
+		kindTypes, err = strconv.ParseBool(os.Getenv("DOCUMENT_KIND_TYPES"))
+		if err != nil {
+			log.Panicf(err, "Unable to parse document kind types: %v", os.Getenv("DOCUMENT_KIND_TYPES"))
+		}
+	}
+
+	edgeOptions := doccache.DefaultEdgeOptions()
+	if os.Getenv("EDGE_REVERSE") != "" {
+		edgeOptions.Reverse, err = strconv.ParseBool(os.Getenv("EDGE_REVERSE"))
+		if err != nil {
+			log.Panicf(err, "Unable to parse edge reverse: %v", os.Getenv("EDGE_REVERSE"))
+		}
+	}
+	if os.Getenv("EDGE_COUNT") != "" {
+		edgeOptions.Count, err = strconv.ParseBool(os.Getenv("EDGE_COUNT"))
+		if err != nil {
+			log.Panicf(err, "Unable to parse edge count: %v", os.Getenv("EDGE_COUNT"))
+		}
+	}
+
+	httpPort := int64(0)
+	if os.Getenv("HTTP_PORT") != "" {
+		httpPort, err = strconv.ParseInt(os.Getenv("HTTP_PORT"), 10, 64)
+		if err != nil {
+			log.Panicf(err, "Unable to parse http port: %v", os.Getenv("HTTP_PORT"))
+		}
+	}
+
+	grpcPort := int64(0)
+	if os.Getenv("GRPC_PORT") != "" {
+		grpcPort, err = strconv.ParseInt(os.Getenv("GRPC_PORT"), 10, 64)
+		if err != nil {
+			log.Panicf(err, "Unable to parse grpc port: %v", os.Getenv("GRPC_PORT"))
+		}
+	}
+
+	graphQLAdminURL := ""
+	if os.Getenv("GRAPHQL_SCHEMA_SYNC") != "" {
+		graphQLSchemaSync, err := strconv.ParseBool(os.Getenv("GRAPHQL_SCHEMA_SYNC"))
+		if err != nil {
+			log.Panicf(err, "Unable to parse graphql schema sync: %v", os.Getenv("GRAPHQL_SCHEMA_SYNC"))
+		}
+		if graphQLSchemaSync {
+			graphQLAdminURL = fmt.Sprintf("http://%v:%v", os.Getenv("DGRAPH_ALPHA_HOST"), os.Getenv("DGRAPH_ALPHA_HTTP_PORT"))
+		}
+	}
+
+	eventBufferSize := int64(0)
+	if os.Getenv("EVENT_BUFFER_SIZE") != "" {
+		eventBufferSize, err = strconv.ParseInt(os.Getenv("EVENT_BUFFER_SIZE"), 10, 64)
+		if err != nil {
+			log.Panicf(err, "Unable to parse event buffer size: %v", os.Getenv("EVENT_BUFFER_SIZE"))
+		}
+	}
+
+	webhookDelivery := false
+	if os.Getenv("WEBHOOK_DELIVERY") != "" {
+		webhookDelivery, err = strconv.ParseBool(os.Getenv("WEBHOOK_DELIVERY"))
+		if err != nil {
+			log.Panicf(err, "Unable to parse webhook delivery: %v", os.Getenv("WEBHOOK_DELIVERY"))
+		}
+	}
+
+	webhookMaxAttempts := int64(0)
+	if os.Getenv("WEBHOOK_MAX_ATTEMPTS") != "" {
+		webhookMaxAttempts, err = strconv.ParseInt(os.Getenv("WEBHOOK_MAX_ATTEMPTS"), 10, 64)
+		if err != nil {
+			log.Panicf(err, "Unable to parse webhook max attempts: %v", os.Getenv("WEBHOOK_MAX_ATTEMPTS"))
+		}
+	}
+
+	sinkConfig := &sink.Config{}
+	if os.Getenv("EVENT_SINK_FILE_MAX_SIZE") != "" {
+		sinkConfig.FileMaxSize, err = strconv.ParseInt(os.Getenv("EVENT_SINK_FILE_MAX_SIZE"), 10, 64)
+		if err != nil {
+			log.Panicf(err, "Unable to parse event sink file max size: %v", os.Getenv("EVENT_SINK_FILE_MAX_SIZE"))
+		}
+	}
+	if os.Getenv("EVENT_SINK_FILE_MAX_FILES") != "" {
+		fileMaxFiles, err := strconv.ParseInt(os.Getenv("EVENT_SINK_FILE_MAX_FILES"), 10, 64)
+		if err != nil {
+			log.Panicf(err, "Unable to parse event sink file max files: %v", os.Getenv("EVENT_SINK_FILE_MAX_FILES"))
+		}
+		sinkConfig.FileMaxFiles = int(fileMaxFiles)
+	}
+	eventSinks, err := sink.Parse(os.Getenv("EVENT_SINKS"), sinkConfig, nil)
+	if err != nil {
+		log.Panicf(err, "Unable to create event sinks: %v", os.Getenv("EVENT_SINKS"))
+	}
+
+	contentIndexes, err := doccache.ParseContentIndexes(os.Getenv("CONTENT_INDEXES"))
+	if err != nil {
+		log.Panicf(err, "Unable to parse content indexes: %v", os.Getenv("CONTENT_INDEXES"))
+	}
+
+	sqliteMirror := os.Getenv("SQLITE_MIRROR")
+
 	log.Infof(
 		`Env Vars
 		 contract: %v
@@ -141,7 +322,20 @@ func main() {
 		 dgraphEndpoint: %v
 		 startBlock: %v
 		 prometheusPort: %v
-		 heartBeatFrequency: %v`,
+		 heartBeatFrequency: %v
+		 errorPolicy: %v
+		 kindTypes: %v
+		 edgeOptions: %v
+		 httpPort: %v
+		 grpcPort: %v
+		 graphQLAdminURL: %v
+		 contentIndexes: %v
+		 eventBufferSize: %v
+		 webhookDelivery: %v
+		 webhookMaxAttempts: %v
+		 eventSinks: %v
+		 sinkConfig: %v
+		 sqliteMirror: %v`,
 		contract,
 		docTable,
 		edgeTable,
@@ -151,6 +345,19 @@ func main() {
 		startBlock,
 		prometheusPort,
 		heartBeatFrequency,
+		errorPolicy,
+		kindTypes,
+		edgeOptions,
+		httpPort,
+		grpcPort,
+		graphQLAdminURL,
+		contentIndexes,
+		eventBufferSize,
+		webhookDelivery,
+		webhookMaxAttempts,
+		os.Getenv("EVENT_SINKS"),
+		sinkConfig,
+		sqliteMirror,
 	)
 
 	go monitoring.SetupEndpoint(uint(prometheusPort))
@@ -166,11 +373,39 @@ func main() {
 	if err != nil {
 		log.Panic(err, "Error creating dgraph client")
 	}
-	cache, err := doccache.New(dg, nil)
+	cache, err := doccache.NewWithConfig(dg, &doccache.Config{KindTypes: kindTypes, EdgeOptions: edgeOptions, GraphQLAdminURL: graphQLAdminURL, ContentIndexes: contentIndexes, EventBufferSize: int(eventBufferSize), EventSinks: eventSinks}, nil)
 	if err != nil {
 		log.Panic(err, "Error creating doccache client")
 	}
 	log.Infof("Cursor: %v", cache.Cursor)
+	var sqlMirror *mirror.Mirror
+	if sqliteMirror != "" {
+		sqlMirror, err = mirror.New(sqliteMirror, nil)
+		if err != nil {
+			log.Panicf(err, "Error opening sqlite mirror: %v", sqliteMirror)
+		}
+		err = sqlMirror.Sync(cache)
+		if err != nil {
+			log.Panic(err, "Error syncing sqlite mirror")
+		}
+	}
+	if httpPort > 0 {
+		go func() {
+			apiServer := server.New(cache, nil)
+			apiServer.SetAdminToken(os.Getenv("ADMIN_TOKEN"))
+			err := apiServer.ListenAndServe(uint(httpPort))
+			log.Panic(err, "Error serving http api")
+		}()
+	}
+	if grpcPort > 0 {
+		go func() {
+			err := server.NewGRPC(cache, nil).ListenAndServe(uint(grpcPort))
+			log.Panic(err, "Error serving grpc api")
+		}()
+	}
+	if webhookDelivery {
+		go webhook.New(cache, &webhook.Config{MaxAttempts: int(webhookMaxAttempts)}, nil).Run(context.Background())
+	}
 	deltaRequest := &dfclient.DeltaStreamRequest{
 		StartBlockNum:      startBlock,
 		StartCursor:        cache.Cursor.Cursor,
@@ -182,6 +417,8 @@ func main() {
 	// deltaRequest.AddTables("eosio.token", []string{"balance"})
 	deltaRequest.AddTables(contract, []string{docTable, edgeTable})
 	client.DeltaStream(deltaRequest, &deltaStreamHandler{
-		doccache: cache,
+		doccache:    cache,
+		mirror:      sqlMirror,
+		errorPolicy: errorPolicy,
 	})
 }
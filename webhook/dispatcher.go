@@ -0,0 +1,251 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sebastianmontero/hypha-document-cache-go/doccache"
+	"github.com/sebastianmontero/hypha-document-cache-go/monitoring/metrics"
+	"github.com/sebastianmontero/slog-go/slog"
+)
+
+var log *slog.Log
+
+//Delivery request headers
+const (
+	HeaderDelivery  = "X-Doccache-Delivery"
+	HeaderEvent     = "X-Doccache-Event"
+	HeaderTimestamp = "X-Doccache-Timestamp"
+	//HeaderSignature sha256=<hex hmac of "<timestamp>.<body>" keyed with the webhook secret>
+	HeaderSignature = "X-Doccache-Signature"
+)
+
+//Config delivery settings, zero values are replaced by the defaults
+type Config struct {
+	//MaxAttempts number of attempts after which a delivery is marked as failed
+	MaxAttempts int
+	//InitialBackoff wait before the second attempt, doubled on each failed attempt up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	//Timeout of each delivery request
+	Timeout time.Duration
+	//PollInterval time between checks for due deliveries when no events are published
+	PollInterval time.Duration
+	//BatchSize maximum number of deliveries attempted per check
+	BatchSize int
+}
+
+//DefaultConfig returns the default delivery settings
+func DefaultConfig() *Config {
+	return &Config{
+		MaxAttempts:    10,
+		InitialBackoff: 10 * time.Second,
+		MaxBackoff:     time.Hour,
+		Timeout:        10 * time.Second,
+		PollInterval:   5 * time.Second,
+		BatchSize:      100,
+	}
+}
+
+func (m *Config) String() string {
+	return fmt.Sprintf("Config{MaxAttempts: %v, InitialBackoff: %v, MaxBackoff: %v, Timeout: %v, PollInterval: %v, BatchSize: %v}", m.MaxAttempts, m.InitialBackoff, m.MaxBackoff, m.Timeout, m.PollInterval, m.BatchSize)
+}
+
+//Payload body of the delivery requests
+type Payload struct {
+	DeliveryID string          `json:"delivery_id"`
+	Attempt    int             `json:"attempt"`
+	Event      json.RawMessage `json:"event"`
+}
+
+//Dispatcher delivers the queued webhook deliveries, retrying failed ones with exponential backoff
+type Dispatcher struct {
+	doccache *doccache.Doccache
+	config   *Config
+	client   *http.Client
+}
+
+//New creates a dispatcher, the default config is used if config is nil
+func New(cache *doccache.Doccache, config *Config, logConfig *slog.Config) *Dispatcher {
+	log = slog.New(logConfig, "webhook")
+	defaults := DefaultConfig()
+	if config == nil {
+		config = defaults
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaults.MaxAttempts
+	}
+	if config.InitialBackoff <= 0 {
+		config.InitialBackoff = defaults.InitialBackoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = defaults.MaxBackoff
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaults.PollInterval
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	return &Dispatcher{
+		doccache: cache,
+		config:   config,
+		client:   &http.Client{Timeout: config.Timeout},
+	}
+}
+
+//Run delivers due deliveries until the context is done, it checks for deliveries as soon as an
+//event is published and every poll interval for retries
+func (m *Dispatcher) Run(ctx context.Context) {
+	log.Infof("Starting webhook dispatcher, config: %v", m.config)
+	subscription, _ := m.doccache.Events.Subscribe(nil, "")
+	published := make(chan struct{}, 1)
+	go func() {
+		for {
+			_, err := subscription.Next(ctx)
+			if err == context.Canceled || err == context.DeadlineExceeded {
+				return
+			}
+			if err != nil {
+				subscription, _ = m.doccache.Events.Subscribe(nil, "")
+			}
+			select {
+			case published <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	ticker := time.NewTicker(m.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		_, err := m.DeliverDue()
+		if err != nil {
+			log.Errorf(err, "Failed to deliver webhooks")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-published:
+		}
+	}
+}
+
+//DeliverDue attempts the deliveries that are due, returns the number of attempts made
+func (m *Dispatcher) DeliverDue() (int, error) {
+	attempts := 0
+	for {
+		deliveries, err := m.doccache.DueDeliveries(time.Now(), m.config.BatchSize)
+		if err != nil {
+			return attempts, err
+		}
+		for _, delivery := range deliveries {
+			m.attempt(delivery)
+			err = m.doccache.UpdateDelivery(delivery)
+			if err != nil {
+				return attempts, err
+			}
+			attempts++
+		}
+		if len(deliveries) < m.config.BatchSize {
+			return attempts, nil
+		}
+	}
+}
+
+//attempt sends the delivery and updates its status, attempts count and next attempt time
+func (m *Dispatcher) attempt(delivery *doccache.WebhookDelivery) {
+	now := time.Now().UTC()
+	delivery.Attempts++
+	status, err := m.send(delivery, now)
+	delivery.LastStatus = status
+	if err == nil {
+		log.Debugf("Delivered: %v", delivery)
+		delivery.Status = doccache.DeliveryDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+		metrics.WebhookDeliveries.WithLabelValues(doccache.DeliveryDelivered).Inc()
+		return
+	}
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= m.config.MaxAttempts || delivery.Webhook == nil {
+		log.Errorf(err, "Delivery failed after %v attempts: %v", delivery.Attempts, delivery)
+		delivery.Status = doccache.DeliveryFailed
+		metrics.WebhookDeliveries.WithLabelValues(doccache.DeliveryFailed).Inc()
+		return
+	}
+	nextAttempt := now.Add(Backoff(delivery.Attempts, m.config.InitialBackoff, m.config.MaxBackoff))
+	log.Infof("Delivery attempt %v failed: %v, retrying at: %v, delivery: %v", delivery.Attempts, err, nextAttempt, delivery)
+	delivery.NextAttempt = &nextAttempt
+	metrics.WebhookDeliveries.WithLabelValues("retried").Inc()
+}
+
+//send posts the signed payload, returns the response status code
+func (m *Dispatcher) send(delivery *doccache.WebhookDelivery, now time.Time) (int, error) {
+	if delivery.Webhook == nil {
+		return 0, fmt.Errorf("webhook of delivery: %v no longer exists", delivery.UID)
+	}
+	body, err := json.Marshal(&Payload{
+		DeliveryID: delivery.UID,
+		Attempt:    delivery.Attempts,
+		Event:      json.RawMessage(delivery.Payload),
+	})
+	if err != nil {
+		return 0, err
+	}
+	request, err := http.NewRequest(http.MethodPost, delivery.Webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(HeaderDelivery, delivery.UID)
+	request.Header.Set(HeaderEvent, string(delivery.EventType))
+	request.Header.Set(HeaderTimestamp, timestamp)
+	request.Header.Set(HeaderSignature, Sign(delivery.Webhook.Secret, timestamp, body))
+	response, err := m.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("webhook: %v responded with status: %v", delivery.Webhook.Name, response.StatusCode)
+	}
+	return response.StatusCode, nil
+}
+
+//Sign returns the signature header value of the body, receivers should recompute it with
+//their secret and reject requests with old timestamps to prevent replays
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+//Backoff returns the wait after the failed attempt, doubling from the initial backoff up to the maximum
+func Backoff(attempt int, initial, max time.Duration) time.Duration {
+	backoff := initial
+	for i := 1; i < attempt && backoff < max; i++ {
+		backoff *= 2
+	}
+	if backoff > max {
+		backoff = max
+	}
+	return backoff
+}
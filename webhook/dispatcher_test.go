@@ -0,0 +1,161 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sebastianmontero/dgraph-go-client/dgraph"
+	"github.com/sebastianmontero/hypha-document-cache-go/doccache"
+)
+
+var dg *dgraph.Dgraph
+var cache *doccache.Doccache
+
+func TestMain(m *testing.M) {
+	var err error
+	dg, err = dgraph.New("")
+	if err != nil {
+		panic(fmt.Sprintf("Unable to create dgraph: %v", err))
+	}
+	cache, err = doccache.New(dg, nil)
+	if err != nil {
+		panic(fmt.Sprintf("Failed creating docCache: %v", err))
+	}
+	retCode := m.Run()
+	dg.Close()
+	os.Exit(retCode)
+}
+
+func TestBackoff(t *testing.T) {
+	expected := []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, time.Minute, time.Minute}
+	for i, backoff := range expected {
+		if found := Backoff(i+1, 10*time.Second, time.Minute); found != backoff {
+			t.Fatalf("Expected backoff: %v for attempt: %v, found: %v", backoff, i+1, found)
+		}
+	}
+}
+
+func TestAttempt(t *testing.T) {
+	var lock sync.Mutex
+	fail := true
+	var received *http.Request
+	var body []byte
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		received = r
+		body, _ = ioutil.ReadAll(r.Body)
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer endpoint.Close()
+
+	dispatcher := New(cache, &Config{MaxAttempts: 2}, nil)
+	delivery := &doccache.WebhookDelivery{
+		UID:       "0x1",
+		Webhook:   &doccache.Webhook{Name: "test", URL: endpoint.URL, Secret: "secret"},
+		EventType: doccache.EventEdgeCreated,
+		Payload:   `{"type":"edge_created"}`,
+		Status:    doccache.DeliveryPending,
+	}
+	dispatcher.attempt(delivery)
+	if delivery.Status != doccache.DeliveryPending || delivery.Attempts != 1 || delivery.LastStatus != http.StatusServiceUnavailable || delivery.NextAttempt == nil {
+		t.Fatalf("Expected delivery to be retried, found: %v", delivery)
+	}
+	signature := Sign("secret", received.Header.Get(HeaderTimestamp), body)
+	if received.Header.Get(HeaderSignature) != signature || received.Header.Get(HeaderEvent) != "edge_created" || received.Header.Get(HeaderDelivery) != "0x1" {
+		t.Fatalf("Unexpected delivery headers: %v", received.Header)
+	}
+	payload := &Payload{}
+	err := json.Unmarshal(body, payload)
+	if err != nil || payload.Attempt != 1 || string(payload.Event) != delivery.Payload {
+		t.Fatalf("Unexpected payload: %v, error: %v", string(body), err)
+	}
+	dispatcher.attempt(delivery)
+	if delivery.Status != doccache.DeliveryFailed || delivery.Attempts != 2 {
+		t.Fatalf("Expected delivery to fail after max attempts, found: %v", delivery)
+	}
+
+	lock.Lock()
+	fail = false
+	lock.Unlock()
+	delivery.Status, delivery.Attempts = doccache.DeliveryPending, 0
+	dispatcher.attempt(delivery)
+	if delivery.Status != doccache.DeliveryDelivered || delivery.DeliveredAt == nil || delivery.LastError != "" {
+		t.Fatalf("Expected delivery to succeed, found: %v", delivery)
+	}
+}
+
+func TestDeliverDue(t *testing.T) {
+	received := make(chan *Payload, 10)
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload := &Payload{}
+		json.NewDecoder(r.Body).Decode(payload)
+		received <- payload
+	}))
+	defer endpoint.Close()
+
+	_, err := cache.SaveWebhook("payouts", endpoint.URL, "secret", &doccache.EventFilter{
+		Types:    []doccache.EventType{doccache.EventDocumentCreated},
+		DocTypes: []string{"payout"},
+	})
+	if err != nil {
+		t.Fatalf("SaveWebhook failed: %v", err)
+	}
+	defer cache.RemoveWebhook("payouts")
+	for i, docType := range []string{"payout", "member"} {
+		err = cache.StoreDocument(&doccache.ChainDocument{
+			Hash:        fmt.Sprintf("d%vf0cc8e3e1d0b12d36fc2d4e0c0c2f8c8aa4e24c9c2c9b6eb2fd9dbba0c1c50", i),
+			CreatedDate: "2021-07-10T10:00:00",
+			Creator:     "webhook",
+			ContentGroups: [][]*doccache.ChainContent{
+				{
+					{
+						Label: "content_group_label",
+						Value: []interface{}{"string", "system"},
+					},
+					{
+						Label: "type",
+						Value: []interface{}{"name", docType},
+					},
+				},
+			},
+		}, fmt.Sprintf("webhook%v", i))
+		if err != nil {
+			t.Fatalf("StoreDocument failed: %v", err)
+		}
+	}
+
+	pending, err := cache.GetDeliveries("payouts", doccache.DeliveryPending, 0)
+	if err != nil || len(pending) != 1 {
+		t.Fatalf("Expected 1 pending delivery, found: %v, error: %v", pending, err)
+	}
+	pending[0].LastError = "previous attempt failed"
+	err = cache.UpdateDelivery(pending[0])
+	if err != nil {
+		t.Fatalf("UpdateDelivery failed: %v", err)
+	}
+
+	attempts, err := New(cache, nil, nil).DeliverDue()
+	if err != nil || attempts != 1 {
+		t.Fatalf("Expected 1 delivery attempt, found: %v, error: %v", attempts, err)
+	}
+	payload := <-received
+	event := &doccache.Event{}
+	err = json.Unmarshal(payload.Event, event)
+	if err != nil || event.Document.DocType != "payout" || event.ChainCursor != "webhook0" {
+		t.Fatalf("Expected payout created event, found: %v, error: %v", string(payload.Event), err)
+	}
+	deliveries, err := cache.GetDeliveries("payouts", doccache.DeliveryDelivered, 0)
+	if err != nil || len(deliveries) != 1 || deliveries[0].Attempts != 1 || deliveries[0].LastError != "" {
+		t.Fatalf("Expected 1 delivered delivery without error, found: %v, error: %v", deliveries, err)
+	}
+}